@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/chatapi"
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Serve the agent as an OpenAI-compatible API",
+	Long: `Serve the agent over an OpenAI-compatible chat completions API, so that
+Open WebUI, LobeChat, Chatbox and other OpenAI clients can use it.
+
+Endpoints:
+  GET  /v1/models             One model per agent profile ("default" and agents.profiles)
+  POST /v1/chat/completions   Streaming and non-streaming; tools run on the server
+
+Clients authenticate with "Authorization: Bearer <key>" using one of the
+configured keys. The router serves the same API when api.enabled is set.
+
+Environment variables:
+  - API_LISTEN: Address to listen on (default: 127.0.0.1:8689)
+  - API_KEYS: Comma-separated API keys
+  - AI_API_KEY: API Key for the AI provider
+
+These can also be set in the api and ai sections of bot.yaml.`,
+	Run: runAPI,
+}
+
+func init() {
+	rootCmd.AddCommand(apiCmd)
+
+	apiCmd.Flags().String("listen", "", "Address to listen on (or API_LISTEN env, default: 127.0.0.1:8689)")
+	apiCmd.Flags().StringSlice("key", nil, "Accepted API key, repeatable (or API_KEYS env)")
+	addAIFlags(apiCmd, "provider")
+}
+
+func runAPI(cmd *cobra.Command, args []string) {
+	cfg := loadConfig(cmd, aiFlags("provider"), func(cfg *config.Config) map[string]any {
+		return map[string]any{
+			"listen": &cfg.API.Listen,
+			"key":    &cfg.API.Keys,
+		}
+	})
+
+	aiAgent := newAgent(cfg)
+	server, err := newAPIServer(cfg, aiAgent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := server.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	logger.Info("Press Ctrl+C to stop.")
+
+	// Wait for shutdown signal
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	logger.Info("Shutting down...")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancelShutdown()
+	server.Stop(shutdownCtx)
+}
+
+// newAPIServer builds the chat completions API server from bot.yaml
+func newAPIServer(cfg *config.Config, aiAgent *agent.Agent) (*chatapi.Server, error) {
+	return chatapi.New(chatapi.Config{
+		Listen: cfg.API.Listen,
+		Keys:   cfg.API.Keys,
+	}, aiAgent)
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// flagBindings maps flag names to the configuration fields they override
+// (*string or *int)
+type flagBindings func(cfg *config.Config) map[string]any
+
+// loadConfig merges bot.yaml, environment variables and the flags set on the
+// command line (in that order of precedence), then validates the result.
+// It exits with an error message if the configuration is invalid.
+func loadConfig(cmd *cobra.Command, bindings ...flagBindings) *config.Config {
+	cfg, err := buildConfig(cmd, bindings...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// buildConfig is loadConfig without exiting, for reloads
+func buildConfig(cmd *cobra.Command, bindings ...flagBindings) (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bind := range bindings {
+		applyFlags(cmd, bind(cfg))
+	}
+
+	if err := cfg.Finalize(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyFlags copies the flags the user set explicitly into the configuration
+func applyFlags(cmd *cobra.Command, fields map[string]any) {
+	flags := cmd.Flags()
+	for name, dst := range fields {
+		flag := flags.Lookup(name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		switch dst := dst.(type) {
+		case *string:
+			*dst = flag.Value.String()
+		case *int:
+			*dst, _ = flags.GetInt(name)
+		case *[]string:
+			*dst, _ = flags.GetStringSlice(name)
+		}
+	}
+}
+
+// addAIFlags registers the AI provider flags; providerFlag differs for
+// commands that use --provider for something else
+func addAIFlags(cmd *cobra.Command, providerFlag string) {
+	cmd.Flags().String(providerFlag, "", "AI provider: claude, deepseek, kimi (or AI_PROVIDER env)")
+	cmd.Flags().String("api-key", "", "AI API Key (or AI_API_KEY env)")
+	cmd.Flags().String("base-url", "", "AI API base URL (or AI_BASE_URL env)")
+	cmd.Flags().String("model", "", "Model name (or AI_MODEL env)")
+}
+
+// aiFlags binds the flags registered by addAIFlags
+func aiFlags(providerFlag string) flagBindings {
+	return func(cfg *config.Config) map[string]any {
+		return map[string]any{
+			providerFlag: &cfg.AI.Provider,
+			"api-key":    &cfg.AI.APIKey,
+			"base-url":   &cfg.AI.BaseURL,
+			"model":      &cfg.AI.Model,
+		}
+	}
+}
+
+// newAgent creates the AI agent from the configuration, exiting on error
+func newAgent(cfg *config.Config) *agent.Agent {
+	if cfg.AI.APIKey == "" {
+		fmt.Fprintln(os.Stderr, "Error: AI API key is required (ai.api_key in bot.yaml, AI_API_KEY env or --api-key)")
+		os.Exit(1)
+	}
+
+	aiAgent, err := agent.New(agentConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
+		os.Exit(1)
+	}
+	return aiAgent
+}
+
+// agentConfig converts the ai, memory, agents and prompts sections for the agent
+func agentConfig(cfg *config.Config) agent.Config {
+	ac := agent.Config{
+		Provider:    cfg.AI.Provider,
+		APIKey:      cfg.AI.APIKey,
+		BaseURL:     cfg.AI.BaseURL,
+		Model:       cfg.AI.Model,
+		MaxMessages: cfg.Memory.MaxMessages,
+		MemoryTTL:   cfg.Memory.TTL,
+
+		SystemPromptFile: config.PromptPath(cfg.Prompts.System),
+		PromptDir:        cfg.PromptDir(),
+		Admins:           cfg.Security.Admins,
+	}
+	for _, p := range cfg.Agents.Profiles {
+		ac.Profiles = append(ac.Profiles, agentProfile(cfg, p))
+	}
+	for _, r := range cfg.Agents.Routes {
+		ac.Routes = append(ac.Routes, agent.Route{
+			Platform:  r.Platform,
+			ChannelID: r.Channel,
+			UserID:    r.User,
+			Profile:   r.Profile,
+		})
+	}
+	return ac
+}
+
+// agentProfile fills in the settings a profile inherits from ai and memory
+func agentProfile(cfg *config.Config, p config.AgentProfile) agent.Profile {
+	profile := agent.Profile{
+		Name:             p.Name,
+		Provider:         p.Provider,
+		APIKey:           p.APIKey,
+		BaseURL:          p.BaseURL,
+		Model:            p.Model,
+		MaxMessages:      p.Memory.MaxMessages,
+		MemoryTTL:        p.Memory.TTL,
+		SystemPrompt:     p.SystemPrompt,
+		SystemPromptFile: config.PromptPath(p.SystemPromptFile),
+		Tools:            p.Tools,
+	}
+
+	// Credentials, endpoint and model only carry over to the same provider
+	if p.Provider == "" {
+		profile.Provider = cfg.AI.Provider
+	}
+	if strings.EqualFold(profile.Provider, cfg.AI.Provider) {
+		if profile.APIKey == "" {
+			profile.APIKey = cfg.AI.APIKey
+		}
+		if profile.BaseURL == "" {
+			profile.BaseURL = cfg.AI.BaseURL
+		}
+		if profile.Model == "" {
+			profile.Model = cfg.AI.Model
+		}
+	}
+
+	if profile.MaxMessages == 0 {
+		profile.MaxMessages = cfg.Memory.MaxMessages
+	}
+	if profile.MemoryTTL == 0 {
+		profile.MemoryTTL = cfg.Memory.TTL
+	}
+	return profile
+}
+
+// providerAndModel returns the effective AI provider and model names for display
+func providerAndModel(cfg *config.Config) (string, string) {
+	providerName := cfg.AI.Provider
+	if providerName == "" {
+		providerName = "claude"
+	}
+	modelName := cfg.AI.Model
+	if modelName == "" {
+		switch providerName {
+		case "deepseek":
+			modelName = "deepseek-chat"
+		case "kimi", "moonshot":
+			modelName = "moonshot-v1-8k"
+		default:
+			modelName = "claude-sonnet-4-20250514"
+		}
+	}
+	return providerName, modelName
+}
@@ -8,6 +8,8 @@ import (
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkcontact "github.com/larksuite/oapi-sdk-go/v3/service/contact/v3"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/pltanton/lingti-bot/internal/platforms/feishu"
 	"github.com/spf13/cobra"
 )
 
@@ -38,19 +40,26 @@ func init() {
 	feishuCmd.AddCommand(feishuUserCmd)
 }
 
-func getFeishuClient() (*lark.Client, error) {
-	appID := os.Getenv("FEISHU_APP_ID")
-	appSecret := os.Getenv("FEISHU_APP_SECRET")
+func getFeishuClient() (*lark.Client, string, error) {
+	cfg, err := config.Load()
+	if err == nil {
+		err = cfg.ResolveSecrets()
+	}
+	if err != nil {
+		return nil, "", err
+	}
 
-	if appID == "" || appSecret == "" {
-		return nil, fmt.Errorf("FEISHU_APP_ID and FEISHU_APP_SECRET environment variables are required")
+	feishuCfg := cfg.Platforms.Feishu
+	if !feishuCfg.Enabled() {
+		return nil, "", fmt.Errorf("Feishu credentials are required (platforms.feishu in bot.yaml or FEISHU_APP_ID and FEISHU_APP_SECRET env)")
 	}
 
-	return lark.NewClient(appID, appSecret), nil
+	client := lark.NewClient(feishuCfg.AppID, feishuCfg.AppSecret, lark.WithOpenBaseUrl(feishu.BaseURL(feishuCfg.Domain)))
+	return client, feishuCfg.AppID, nil
 }
 
 func runFeishuInfo(cmd *cobra.Command, args []string) {
-	client, err := getFeishuClient()
+	client, appID, err := getFeishuClient()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -76,7 +85,7 @@ func runFeishuInfo(cmd *cobra.Command, args []string) {
 
 	fmt.Println("Feishu Bot Information:")
 	fmt.Println("  Status: Connected")
-	fmt.Printf("  App ID: %s\n", os.Getenv("FEISHU_APP_ID"))
+	fmt.Printf("  App ID: %s\n", appID)
 
 	if result.Data != nil && result.Data.Items != nil {
 		fmt.Printf("  Chats: %d\n", len(result.Data.Items))
@@ -98,7 +107,7 @@ func runFeishuInfo(cmd *cobra.Command, args []string) {
 }
 
 func runFeishuUser(cmd *cobra.Command, args []string) {
-	client, err := getFeishuClient()
+	client, _, err := getFeishuClient()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
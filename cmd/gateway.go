@@ -7,18 +7,13 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/config"
 	"github.com/pltanton/lingti-bot/internal/gateway"
 	"github.com/pltanton/lingti-bot/internal/logger"
 	"github.com/pltanton/lingti-bot/internal/router"
 	"github.com/spf13/cobra"
 )
 
-var (
-	gatewayAddr      string
-	gatewayAuthToken string
-)
-
 var gatewayCmd = &cobra.Command{
 	Use:   "gateway",
 	Short: "Start the WebSocket gateway",
@@ -36,75 +31,34 @@ This enables:
 Environment variables:
   - GATEWAY_ADDR: Address to listen on (default: :18789)
   - GATEWAY_AUTH_TOKEN: Optional authentication token
-  - AI_API_KEY: API Key for the AI provider`,
+  - AI_API_KEY: API Key for the AI provider
+
+These can also be set in the gateway and ai sections of bot.yaml.`,
 	Run: runGateway,
 }
 
 func init() {
 	rootCmd.AddCommand(gatewayCmd)
 
-	gatewayCmd.Flags().StringVar(&gatewayAddr, "addr", "", "Gateway address (or GATEWAY_ADDR env, default: :18789)")
-	gatewayCmd.Flags().StringVar(&gatewayAuthToken, "auth-token", "", "Authentication token (or GATEWAY_AUTH_TOKEN env)")
-	gatewayCmd.Flags().StringVar(&aiProvider, "provider", "", "AI provider: claude, deepseek, kimi (or AI_PROVIDER env)")
-	gatewayCmd.Flags().StringVar(&aiAPIKey, "api-key", "", "AI API Key (or AI_API_KEY env)")
-	gatewayCmd.Flags().StringVar(&aiBaseURL, "base-url", "", "AI API base URL (or AI_BASE_URL env)")
-	gatewayCmd.Flags().StringVar(&aiModel, "model", "", "Model name (or AI_MODEL env)")
+	gatewayCmd.Flags().String("addr", "", "Gateway address (or GATEWAY_ADDR env, default: :18789)")
+	gatewayCmd.Flags().String("auth-token", "", "Authentication token (or GATEWAY_AUTH_TOKEN env)")
+	addAIFlags(gatewayCmd, "provider")
 }
 
 func runGateway(cmd *cobra.Command, args []string) {
-	// Get config from flags or environment
-	if gatewayAddr == "" {
-		gatewayAddr = os.Getenv("GATEWAY_ADDR")
-		if gatewayAddr == "" {
-			gatewayAddr = ":18789"
-		}
-	}
-	if gatewayAuthToken == "" {
-		gatewayAuthToken = os.Getenv("GATEWAY_AUTH_TOKEN")
-	}
-	if aiProvider == "" {
-		aiProvider = os.Getenv("AI_PROVIDER")
-	}
-	if aiAPIKey == "" {
-		aiAPIKey = os.Getenv("AI_API_KEY")
-		if aiAPIKey == "" {
-			aiAPIKey = os.Getenv("ANTHROPIC_API_KEY")
-		}
-	}
-	if aiModel == "" {
-		aiModel = os.Getenv("AI_MODEL")
-		if aiModel == "" {
-			aiModel = os.Getenv("ANTHROPIC_MODEL")
+	cfg := loadConfig(cmd, aiFlags("provider"), func(cfg *config.Config) map[string]any {
+		return map[string]any{
+			"addr":       &cfg.Gateway.Addr,
+			"auth-token": &cfg.Gateway.AuthToken,
 		}
-	}
-	if aiBaseURL == "" {
-		aiBaseURL = os.Getenv("AI_BASE_URL")
-		if aiBaseURL == "" {
-			aiBaseURL = os.Getenv("ANTHROPIC_BASE_URL")
-		}
-	}
-
-	if aiAPIKey == "" {
-		fmt.Fprintln(os.Stderr, "Error: AI_API_KEY is required")
-		os.Exit(1)
-	}
-
-	// Create the AI agent
-	aiAgent, err := agent.New(agent.Config{
-		Provider: aiProvider,
-		APIKey:   aiAPIKey,
-		BaseURL:  aiBaseURL,
-		Model:    aiModel,
 	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
-		os.Exit(1)
-	}
+
+	aiAgent := newAgent(cfg)
 
 	// Create the gateway
 	gw := gateway.New(gateway.Config{
-		Addr:      gatewayAddr,
-		AuthToken: gatewayAuthToken,
+		Addr:      cfg.Gateway.Addr,
+		AuthToken: cfg.Gateway.AuthToken,
 	})
 
 	// Set up message handler that wraps agent responses for streaming
@@ -156,8 +110,8 @@ func runGateway(cmd *cobra.Command, args []string) {
 		}
 	}()
 
-	logger.Info("Gateway started on %s", gatewayAddr)
-	if gatewayAuthToken != "" {
+	logger.Info("Gateway started on %s", cfg.Gateway.Addr)
+	if cfg.Gateway.AuthToken != "" {
 		logger.Info("Authentication enabled")
 	}
 	logger.Info("Press Ctrl+C to stop.")
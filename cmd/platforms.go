@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/platforms/dingtalk"
+	"github.com/pltanton/lingti-bot/internal/platforms/discord"
+	"github.com/pltanton/lingti-bot/internal/platforms/email"
+	"github.com/pltanton/lingti-bot/internal/platforms/feishu"
+	"github.com/pltanton/lingti-bot/internal/platforms/matrix"
+	"github.com/pltanton/lingti-bot/internal/platforms/mattermost"
+	"github.com/pltanton/lingti-bot/internal/platforms/rocketchat"
+	"github.com/pltanton/lingti-bot/internal/platforms/signal"
+	"github.com/pltanton/lingti-bot/internal/platforms/slack"
+	"github.com/pltanton/lingti-bot/internal/platforms/telegram"
+	"github.com/pltanton/lingti-bot/internal/platforms/wecom"
+	"github.com/pltanton/lingti-bot/internal/router"
+	"github.com/pltanton/lingti-bot/internal/voice"
+)
+
+// platformSpec describes how the router builds one platform from bot.yaml
+type platformSpec struct {
+	name     string // router.Platform Name()
+	title    string // For log messages
+	enabled  bool
+	settings any // Everything the platform is built from; a change means a restart
+	create   func() (router.Platform, error)
+}
+
+// platformSpecs lists every platform the router supports
+func platformSpecs(cfg *config.Config) []platformSpec {
+	p := cfg.Platforms
+	stt := struct{ Provider, APIKey string }{cfg.Voice.STTProvider, cfg.Voice.STTAPIKey}
+
+	return []platformSpec{
+		{
+			name: "slack", title: "Slack", enabled: p.Slack.Enabled(), settings: p.Slack,
+			create: func() (router.Platform, error) {
+				return slack.New(slack.Config{
+					BotToken: p.Slack.BotToken,
+					AppToken: p.Slack.AppToken,
+				})
+			},
+		},
+		{
+			// Feishu also depends on the voice transcriber for audio messages
+			name: "feishu", title: "Feishu", enabled: p.Feishu.Enabled(),
+			settings: struct {
+				config.FeishuConfig
+				STT any
+			}{p.Feishu, stt},
+			create: func() (router.Platform, error) {
+				return feishu.New(feishu.Config{
+					AppID:       p.Feishu.AppID,
+					AppSecret:   p.Feishu.AppSecret,
+					Domain:      p.Feishu.Domain,
+					Transcriber: newTranscriber(cfg.Voice),
+
+					Listen:            p.Feishu.Listen,
+					VerificationToken: p.Feishu.VerificationToken,
+					EncryptKey:        p.Feishu.EncryptKey,
+				})
+			},
+		},
+		{
+			// Telegram also depends on the voice transcriber for voice messages
+			name: "telegram", title: "Telegram", enabled: p.Telegram.Enabled(),
+			settings: struct {
+				config.TelegramConfig
+				STT any
+			}{p.Telegram, stt},
+			create: func() (router.Platform, error) {
+				return telegram.New(telegram.Config{
+					Token:       p.Telegram.Token,
+					Transcriber: newTranscriber(cfg.Voice),
+					ParseMode:   p.Telegram.ParseMode,
+				})
+			},
+		},
+		{
+			name: "discord", title: "Discord", enabled: p.Discord.Enabled(), settings: p.Discord,
+			create: func() (router.Platform, error) {
+				return discord.New(discord.Config{
+					Token: p.Discord.Token,
+				})
+			},
+		},
+		{
+			// WeCom also depends on the voice transcriber for voice messages
+			name: "wecom", title: "WeCom", enabled: p.WeCom.Enabled(),
+			settings: struct {
+				config.WeComConfig
+				STT any
+			}{p.WeCom, stt},
+			create: func() (router.Platform, error) {
+				return wecom.New(wecom.Config{
+					CorpID:         p.WeCom.CorpID,
+					AgentID:        p.WeCom.AgentID,
+					Secret:         p.WeCom.Secret,
+					Token:          p.WeCom.Token,
+					EncodingAESKey: p.WeCom.AESKey,
+					CallbackPort:   p.WeCom.Port,
+					MsgType:        p.WeCom.MsgType,
+					WebhookURL:     p.WeCom.WebhookURL,
+					Transcriber:    newTranscriber(cfg.Voice),
+				})
+			},
+		},
+		{
+			name: "dingtalk", title: "DingTalk", enabled: p.DingTalk.Enabled(), settings: p.DingTalk,
+			create: func() (router.Platform, error) {
+				return dingtalk.New(dingtalk.Config{
+					ClientID:       p.DingTalk.ClientID,
+					ClientSecret:   p.DingTalk.ClientSecret,
+					RobotCode:      p.DingTalk.RobotCode,
+					CardTemplateID: p.DingTalk.CardTemplateID,
+				})
+			},
+		},
+		{
+			name: "matrix", title: "Matrix", enabled: p.Matrix.Enabled(), settings: p.Matrix,
+			create: func() (router.Platform, error) {
+				return matrix.New(matrix.Config{
+					Homeserver:  p.Matrix.Homeserver,
+					AccessToken: p.Matrix.AccessToken,
+					UserID:      p.Matrix.UserID,
+					AutoJoin:    p.Matrix.AutoJoin,
+				})
+			},
+		},
+		{
+			name: "mattermost", title: "Mattermost", enabled: p.Mattermost.Enabled(), settings: p.Mattermost,
+			create: func() (router.Platform, error) {
+				return mattermost.New(mattermost.Config{
+					URL:   p.Mattermost.URL,
+					Token: p.Mattermost.Token,
+				})
+			},
+		},
+		{
+			name: "rocketchat", title: "Rocket.Chat", enabled: p.RocketChat.Enabled(), settings: p.RocketChat,
+			create: func() (router.Platform, error) {
+				return rocketchat.New(rocketchat.Config{
+					URL:    p.RocketChat.URL,
+					UserID: p.RocketChat.UserID,
+					Token:  p.RocketChat.Token,
+				})
+			},
+		},
+		{
+			name: "signal", title: "Signal", enabled: p.Signal.Enabled(), settings: p.Signal,
+			create: func() (router.Platform, error) {
+				return signal.New(signal.Config{
+					Account: p.Signal.Account,
+					Socket:  p.Signal.Socket,
+				})
+			},
+		},
+		{
+			name: "email", title: "Email", enabled: p.Email.Enabled(), settings: p.Email,
+			create: func() (router.Platform, error) {
+				return email.New(email.Config{
+					IMAPAddr:        p.Email.IMAPAddr,
+					SMTPAddr:        p.Email.SMTPAddr,
+					Username:        p.Email.Username,
+					Password:        p.Email.Password,
+					Address:         p.Email.Address,
+					Mailbox:         p.Email.Mailbox,
+					PollInterval:    p.Email.PollInterval,
+					AllowedSenders:  p.Email.AllowedSenders,
+					Insecure:        p.Email.Insecure,
+					TrustUnverified: p.Email.TrustUnverified,
+				})
+			},
+		},
+	}
+}
+
+// registerPlatform builds a configured platform and adds it to the router,
+// which starts it right away if the router is running. A misconfigured
+// platform is skipped rather than taking the others down with it.
+func registerPlatform(r *router.Router, spec platformSpec) bool {
+	if !spec.enabled {
+		logger.Info("%s credentials not provided, skipping %s integration", spec.title, spec.title)
+		return false
+	}
+
+	platform, err := spec.create()
+	if err != nil {
+		logger.Error("Error creating %s platform: %v", spec.title, err)
+		return false
+	}
+	r.Register(platform)
+	return true
+}
+
+// voiceTranscriber is what platforms need from a voice.Transcriber. Returning
+// it as an interface keeps "no transcriber" a nil interface, not a nil pointer.
+type voiceTranscriber interface {
+	Transcribe(ctx context.Context, audio []byte) (string, error)
+}
+
+// newTranscriber creates the voice message transcriber, or nil if no STT
+// provider is configured
+func newTranscriber(cfg config.VoiceConfig) voiceTranscriber {
+	if cfg.STTProvider == "" {
+		return nil
+	}
+
+	transcriber, err := voice.NewTranscriber(voice.TranscriberConfig{
+		Provider: cfg.STTProvider,
+		APIKey:   cfg.STTAPIKey,
+	})
+	if err != nil {
+		logger.Info("Warning: Failed to create voice transcriber: %v", err)
+		return nil
+	}
+	logger.Info("Voice transcription enabled (provider: %s)", cfg.STTProvider)
+	return transcriber
+}
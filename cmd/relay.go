@@ -8,29 +8,12 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/config"
 	"github.com/pltanton/lingti-bot/internal/platforms/relay"
 	"github.com/pltanton/lingti-bot/internal/router"
 	"github.com/spf13/cobra"
 )
 
-var (
-	relayUserID     string
-	relayPlatform   string
-	relayServerURL  string
-	relayWebhookURL string
-	relayAIProvider string
-	relayAPIKey     string
-	relayBaseURL    string
-	relayModel      string
-	// WeCom credentials for cloud relay
-	relayWeComCorpID  string
-	relayWeComAgentID string
-	relayWeComSecret  string
-	relayWeComToken   string
-	relayWeComAESKey  string
-)
-
 var relayCmd = &cobra.Command{
 	Use:   "relay",
 	Short: "Connect to the cloud relay service",
@@ -85,125 +68,70 @@ Environment variables:
   AI_PROVIDER          AI provider: claude or deepseek (default: claude)
   AI_API_KEY           AI API key
   AI_BASE_URL          Custom API base URL
-  AI_MODEL             Model name`,
+  AI_MODEL             Model name
+
+All of these can also be set in the relay, ai and platforms.wecom sections
+of bot.yaml.`,
 	Run: runRelay,
 }
 
 func init() {
 	rootCmd.AddCommand(relayCmd)
 
-	relayCmd.Flags().StringVar(&relayUserID, "user-id", "", "User ID from /whoami (required, or RELAY_USER_ID env)")
-	relayCmd.Flags().StringVar(&relayPlatform, "platform", "", "Platform: feishu, slack, wechat, or wecom (required, or RELAY_PLATFORM env)")
-	relayCmd.Flags().StringVar(&relayServerURL, "server", "", "WebSocket URL (default: wss://bot.lingti.com/ws, or RELAY_SERVER_URL env)")
-	relayCmd.Flags().StringVar(&relayWebhookURL, "webhook", "", "Webhook URL (default: https://bot.lingti.com/webhook, or RELAY_WEBHOOK_URL env)")
-	relayCmd.Flags().StringVar(&relayAIProvider, "provider", "", "AI provider: claude or deepseek (or AI_PROVIDER env)")
-	relayCmd.Flags().StringVar(&relayAPIKey, "api-key", "", "AI API key (or AI_API_KEY env)")
-	relayCmd.Flags().StringVar(&relayBaseURL, "base-url", "", "Custom API base URL (or AI_BASE_URL env)")
-	relayCmd.Flags().StringVar(&relayModel, "model", "", "Model name (or AI_MODEL env)")
+	flags := relayCmd.Flags()
+	flags.String("user-id", "", "User ID from /whoami (required, or RELAY_USER_ID env)")
+	flags.String("platform", "", "Platform: feishu, slack, wechat, or wecom (required, or RELAY_PLATFORM env)")
+	flags.String("server", "", "WebSocket URL (default: wss://bot.lingti.com/ws, or RELAY_SERVER_URL env)")
+	flags.String("webhook", "", "Webhook URL (default: https://bot.lingti.com/webhook, or RELAY_WEBHOOK_URL env)")
+	addAIFlags(relayCmd, "provider")
 
 	// WeCom credentials for cloud relay
-	relayCmd.Flags().StringVar(&relayWeComCorpID, "wecom-corp-id", "", "WeCom Corp ID (or WECOM_CORP_ID env)")
-	relayCmd.Flags().StringVar(&relayWeComAgentID, "wecom-agent-id", "", "WeCom Agent ID (or WECOM_AGENT_ID env)")
-	relayCmd.Flags().StringVar(&relayWeComSecret, "wecom-secret", "", "WeCom Secret (or WECOM_SECRET env)")
-	relayCmd.Flags().StringVar(&relayWeComToken, "wecom-token", "", "WeCom Callback Token (or WECOM_TOKEN env)")
-	relayCmd.Flags().StringVar(&relayWeComAESKey, "wecom-aes-key", "", "WeCom Encoding AES Key (or WECOM_AES_KEY env)")
+	flags.String("wecom-corp-id", "", "WeCom Corp ID (or WECOM_CORP_ID env)")
+	flags.String("wecom-agent-id", "", "WeCom Agent ID (or WECOM_AGENT_ID env)")
+	flags.String("wecom-secret", "", "WeCom Secret (or WECOM_SECRET env)")
+	flags.String("wecom-token", "", "WeCom Callback Token (or WECOM_TOKEN env)")
+	flags.String("wecom-aes-key", "", "WeCom Encoding AES Key (or WECOM_AES_KEY env)")
 }
 
 func runRelay(cmd *cobra.Command, args []string) {
-	// Get values from flags or environment
-	if relayUserID == "" {
-		relayUserID = os.Getenv("RELAY_USER_ID")
-	}
-	if relayPlatform == "" {
-		relayPlatform = os.Getenv("RELAY_PLATFORM")
-	}
-	if relayServerURL == "" {
-		relayServerURL = os.Getenv("RELAY_SERVER_URL")
-	}
-	if relayWebhookURL == "" {
-		relayWebhookURL = os.Getenv("RELAY_WEBHOOK_URL")
-	}
-	if relayAIProvider == "" {
-		relayAIProvider = os.Getenv("AI_PROVIDER")
-	}
-	if relayAPIKey == "" {
-		relayAPIKey = os.Getenv("AI_API_KEY")
-		// Fallback to legacy env var
-		if relayAPIKey == "" {
-			relayAPIKey = os.Getenv("ANTHROPIC_API_KEY")
-		}
-	}
-	if relayBaseURL == "" {
-		relayBaseURL = os.Getenv("AI_BASE_URL")
-		if relayBaseURL == "" {
-			relayBaseURL = os.Getenv("ANTHROPIC_BASE_URL")
-		}
-	}
-	if relayModel == "" {
-		relayModel = os.Getenv("AI_MODEL")
-		if relayModel == "" {
-			relayModel = os.Getenv("ANTHROPIC_MODEL")
-		}
-	}
-
-	// Get WeCom credentials from flags or environment
-	if relayWeComCorpID == "" {
-		relayWeComCorpID = os.Getenv("WECOM_CORP_ID")
-	}
-	if relayWeComAgentID == "" {
-		relayWeComAgentID = os.Getenv("WECOM_AGENT_ID")
-	}
-	if relayWeComSecret == "" {
-		relayWeComSecret = os.Getenv("WECOM_SECRET")
-	}
-	if relayWeComToken == "" {
-		relayWeComToken = os.Getenv("WECOM_TOKEN")
-	}
-	if relayWeComAESKey == "" {
-		relayWeComAESKey = os.Getenv("WECOM_AES_KEY")
-	}
+	cfg := loadConfig(cmd, aiFlags("provider"), relayFlags, wecomFlags)
+	relayCfg := cfg.Relay
+	wecomCfg := cfg.Platforms.WeCom
 
 	// Validate required parameters
-	if relayPlatform == "" {
+	// The platform value itself is checked by config validation
+	if relayCfg.Platform == "" {
 		fmt.Fprintln(os.Stderr, "Error: --platform is required (feishu, slack, wechat, or wecom)")
 		os.Exit(1)
 	}
-	if relayPlatform != "feishu" && relayPlatform != "slack" && relayPlatform != "wechat" && relayPlatform != "wecom" {
-		fmt.Fprintln(os.Stderr, "Error: --platform must be 'feishu', 'slack', 'wechat', or 'wecom'")
-		os.Exit(1)
-	}
-	if relayAPIKey == "" {
-		fmt.Fprintln(os.Stderr, "Error: AI API key is required (--api-key or AI_API_KEY env)")
-		os.Exit(1)
-	}
 
 	// For WeCom, user-id is optional - auto-generate from corp_id
 	// For other platforms, user-id is required
-	if relayUserID == "" {
-		if relayPlatform == "wecom" && relayWeComCorpID != "" {
-			relayUserID = "wecom-" + relayWeComCorpID
-		} else if relayPlatform != "wecom" {
+	if relayCfg.UserID == "" {
+		if relayCfg.Platform == "wecom" && wecomCfg.CorpID != "" {
+			relayCfg.UserID = "wecom-" + wecomCfg.CorpID
+		} else if relayCfg.Platform != "wecom" {
 			fmt.Fprintln(os.Stderr, "Error: --user-id is required (get it from /whoami)")
 			os.Exit(1)
 		}
 	}
 
 	// Validate WeCom credentials when platform is wecom
-	if relayPlatform == "wecom" {
+	if relayCfg.Platform == "wecom" {
 		missing := []string{}
-		if relayWeComCorpID == "" {
+		if wecomCfg.CorpID == "" {
 			missing = append(missing, "--wecom-corp-id")
 		}
-		if relayWeComAgentID == "" {
+		if wecomCfg.AgentID == "" {
 			missing = append(missing, "--wecom-agent-id")
 		}
-		if relayWeComSecret == "" {
+		if wecomCfg.Secret == "" {
 			missing = append(missing, "--wecom-secret")
 		}
-		if relayWeComToken == "" {
+		if wecomCfg.Token == "" {
 			missing = append(missing, "--wecom-token")
 		}
-		if relayWeComAESKey == "" {
+		if wecomCfg.AESKey == "" {
 			missing = append(missing, "--wecom-aes-key")
 		}
 		if len(missing) > 0 {
@@ -213,57 +141,32 @@ func runRelay(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	// Create the AI agent
-	aiAgent, err := agent.New(agent.Config{
-		Provider: relayAIProvider,
-		APIKey:   relayAPIKey,
-		BaseURL:  relayBaseURL,
-		Model:    relayModel,
-	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
-		os.Exit(1)
-	}
-
-	// Resolve provider and model names
-	providerName := relayAIProvider
-	if providerName == "" {
-		providerName = "claude"
-	}
-	modelName := relayModel
-	if modelName == "" {
-		switch providerName {
-		case "deepseek":
-			modelName = "deepseek-chat"
-		case "kimi", "moonshot":
-			modelName = "moonshot-v1-8k"
-		default:
-			modelName = "claude-sonnet-4-20250514"
-		}
-	}
+	aiAgent := newAgent(cfg)
+	providerName, modelName := providerAndModel(cfg)
 
 	// Create the router with the agent as message handler
 	r := router.New(aiAgent.HandleMessage)
 
 	// Create and register relay platform
 	relayPlatformInstance, err := relay.New(relay.Config{
-		UserID:       relayUserID,
-		Platform:     relayPlatform,
-		ServerURL:    relayServerURL,
-		WebhookURL:   relayWebhookURL,
+		UserID:       relayCfg.UserID,
+		Platform:     relayCfg.Platform,
+		ServerURL:    relayCfg.ServerURL,
+		WebhookURL:   relayCfg.WebhookURL,
 		AIProvider:   providerName,
 		AIModel:      modelName,
-		WeComCorpID:  relayWeComCorpID,
-		WeComAgentID: relayWeComAgentID,
-		WeComSecret:  relayWeComSecret,
-		WeComToken:   relayWeComToken,
-		WeComAESKey:  relayWeComAESKey,
+		WeComCorpID:  wecomCfg.CorpID,
+		WeComAgentID: wecomCfg.AgentID,
+		WeComSecret:  wecomCfg.Secret,
+		WeComToken:   wecomCfg.Token,
+		WeComAESKey:  wecomCfg.AESKey,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating relay platform: %v\n", err)
 		os.Exit(1)
 	}
 	r.Register(relayPlatformInstance)
+	aiAgent.SetPlatformStatus(r.Status)
 
 	// Start the router
 	ctx, cancel := context.WithCancel(context.Background())
@@ -274,7 +177,11 @@ func runRelay(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	log.Printf("Relay connected. User: %s, Platform: %s", relayUserID, relayPlatform)
+	if status := r.Status(); len(status) > 0 && status[0].State != router.StateRunning {
+		log.Printf("Relay not connected yet (%s), retrying in the background", status[0].LastError)
+	} else {
+		log.Printf("Relay connected. User: %s, Platform: %s", relayCfg.UserID, relayCfg.Platform)
+	}
 	log.Printf("AI Provider: %s, Model: %s", providerName, modelName)
 	log.Println("Press Ctrl+C to stop.")
 
@@ -284,5 +191,31 @@ func runRelay(cmd *cobra.Command, args []string) {
 	<-sigCh
 
 	log.Println("Shutting down...")
-	r.Stop()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancelShutdown()
+	if err := r.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error during shutdown: %v", err)
+	}
+}
+
+// relayFlags binds the relay connection flags
+func relayFlags(cfg *config.Config) map[string]any {
+	return map[string]any{
+		"user-id":  &cfg.Relay.UserID,
+		"platform": &cfg.Relay.Platform,
+		"server":   &cfg.Relay.ServerURL,
+		"webhook":  &cfg.Relay.WebhookURL,
+	}
+}
+
+// wecomFlags binds the WeCom credential flags shared by relay and verify
+func wecomFlags(cfg *config.Config) map[string]any {
+	w := &cfg.Platforms.WeCom
+	return map[string]any{
+		"wecom-corp-id":  &w.CorpID,
+		"wecom-agent-id": &w.AgentID,
+		"wecom-secret":   &w.Secret,
+		"wecom-token":    &w.Token,
+		"wecom-aes-key":  &w.AESKey,
+	}
 }
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/chatapi"
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/router"
+	"github.com/pltanton/lingti-bot/internal/skills"
+	"github.com/spf13/cobra"
+)
+
+// reloader applies bot.yaml changes to a running router without dropping
+// in-flight work or connections that did not change
+type reloader struct {
+	cmd      *cobra.Command
+	bindings []flagBindings // Flags keep overriding the file across reloads
+	router   *router.Router
+	agent    *agent.Agent
+	limiter  *router.RateLimiter
+	skills   *skills.Registry // nil unless a webhook route triggers skills
+	api      *chatapi.Server  // nil unless the chat completions API runs
+
+	mu        sync.Mutex
+	cfg       *config.Config // Last applied configuration
+	platforms map[string]any // Settings of the running platforms, by name
+}
+
+func newReloader(cmd *cobra.Command, cfg *config.Config, r *router.Router, aiAgent *agent.Agent, bindings ...flagBindings) *reloader {
+	rl := &reloader{
+		cmd:       cmd,
+		bindings:  bindings,
+		router:    r,
+		agent:     aiAgent,
+		cfg:       cfg,
+		platforms: make(map[string]any),
+	}
+
+	r.SetChunkMarkers(cfg.Messages.ChunkMarkers)
+	r.SetSupervisor(supervisorConfig(cfg))
+	rl.applyRateLimit(cfg)
+
+	for _, spec := range platformSpecs(cfg) {
+		if registerPlatform(r, spec) {
+			rl.platforms[spec.name] = spec.settings
+		}
+	}
+	return rl
+}
+
+// current returns the configuration that was applied last
+func (rl *reloader) current() *config.Config {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.cfg
+}
+
+// reload re-reads the configuration and applies what changed. An invalid
+// configuration is rejected and the running one is kept.
+func (rl *reloader) reload(reason string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	logger.Info("[Config] Reloading configuration (%s)", reason)
+	cfg, err := buildConfig(rl.cmd, rl.bindings...)
+	if err != nil {
+		logger.Error("[Config] Rejected new configuration, keeping the current one:\n%v", err)
+		return
+	}
+
+	old := rl.cfg
+	var applied []string
+
+	// The provider is the only part that can still fail, so it goes first
+	if old.AI != cfg.AI || old.Memory != cfg.Memory || old.Prompts != cfg.Prompts ||
+		!reflect.DeepEqual(old.Agents, cfg.Agents) || !reflect.DeepEqual(old.Security.Admins, cfg.Security.Admins) {
+		if err := rl.agent.Reconfigure(agentConfig(cfg)); err != nil {
+			logger.Error("[Config] Rejected new configuration, keeping the current one: ai: %v", err)
+			return
+		}
+		applied = append(applied, "ai")
+	}
+
+	if old.RateLimit != cfg.RateLimit || !reflect.DeepEqual(old.Security, cfg.Security) {
+		rl.applyRateLimit(cfg)
+		applied = append(applied, "security")
+	}
+	if old.Messages != cfg.Messages {
+		rl.router.SetChunkMarkers(cfg.Messages.ChunkMarkers)
+		applied = append(applied, "messages")
+	}
+	if supervisorConfig(old) != supervisorConfig(cfg) {
+		rl.router.SetSupervisor(supervisorConfig(cfg))
+		applied = append(applied, "platform supervision")
+	}
+
+	applied = append(applied, rl.applyPlatforms(cfg)...)
+
+	if rl.api != nil && !reflect.DeepEqual(old.API.Keys, cfg.API.Keys) {
+		if err := rl.api.SetKeys(cfg.API.Keys); err != nil {
+			logger.Error("[Config] Keeping the current API keys: %v", err)
+		} else {
+			applied = append(applied, "api keys")
+		}
+	}
+
+	if rl.skills != nil {
+		if err := rl.skills.Reload(); err != nil {
+			logger.Error("[Config] Failed to reload skills: %v", err)
+		} else {
+			applied = append(applied, "skills")
+		}
+	}
+
+	rl.cfg = cfg
+
+	if len(applied) == 0 {
+		logger.Info("[Config] Reloaded, nothing changed")
+	} else {
+		logger.Info("[Config] Reloaded: %s", strings.Join(applied, ", "))
+	}
+	if pending := restartRequired(old, cfg); len(pending) > 0 {
+		logger.Info("[Config] Changes to %s take effect after a restart", strings.Join(pending, ", "))
+	}
+}
+
+// applyPlatforms starts, restarts or stops the platforms whose settings
+// changed. A platform that fails to build keeps running with its old settings.
+func (rl *reloader) applyPlatforms(cfg *config.Config) []string {
+	var changes []string
+	for _, spec := range platformSpecs(cfg) {
+		running, ok := rl.platforms[spec.name]
+		if ok && reflect.DeepEqual(running, spec.settings) {
+			continue
+		}
+
+		switch {
+		case !spec.enabled && !ok:
+			// Still not configured
+		case !spec.enabled:
+			if err := rl.router.Unregister(spec.name); err != nil {
+				logger.Error("[Config] Error stopping %s: %v", spec.title, err)
+			}
+			delete(rl.platforms, spec.name)
+			changes = append(changes, spec.name+" stopped")
+		case registerPlatform(rl.router, spec):
+			rl.platforms[spec.name] = spec.settings
+			if ok {
+				changes = append(changes, spec.name+" restarted")
+			} else {
+				changes = append(changes, spec.name+" started")
+			}
+		}
+	}
+	return changes
+}
+
+// applyRateLimit updates the limiter in place so buckets and blocks survive
+func (rl *reloader) applyRateLimit(cfg *config.Config) {
+	switch {
+	case !cfg.RateLimit.Enabled:
+		rl.limiter = nil
+	case rl.limiter != nil:
+		rl.limiter.SetConfig(rateLimitConfig(cfg))
+		return
+	default:
+		rl.limiter = router.NewRateLimiter(rateLimitConfig(cfg))
+	}
+	rl.router.SetRateLimiter(rl.limiter)
+}
+
+// restartRequired lists changed sections that are only read at startup
+func restartRequired(old, cfg *config.Config) []string {
+	var sections []string
+	if old.Dedup != cfg.Dedup {
+		sections = append(sections, "dedup")
+	}
+	if old.Control != cfg.Control {
+		sections = append(sections, "control")
+	}
+	if !reflect.DeepEqual(old.Webhooks, cfg.Webhooks) {
+		sections = append(sections, "webhooks")
+	}
+	if old.API.Enabled != cfg.API.Enabled || old.API.Listen != cfg.API.Listen {
+		sections = append(sections, "api")
+	}
+	if old.Reload != cfg.Reload {
+		sections = append(sections, "reload")
+	}
+	return sections
+}
+
+// supervisorConfig converts the platform supervision settings for the router
+func supervisorConfig(cfg *config.Config) router.SupervisorConfig {
+	return router.SupervisorConfig{
+		RetryMin:       cfg.Platforms.RetryMin,
+		RetryMax:       cfg.Platforms.RetryMax,
+		MaxAttempts:    cfg.Platforms.MaxAttempts,
+		HealthInterval: cfg.Platforms.HealthInterval,
+	}
+}
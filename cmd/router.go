@@ -8,46 +8,22 @@ import (
 	"syscall"
 
 	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/chatapi"
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/pltanton/lingti-bot/internal/control"
 	"github.com/pltanton/lingti-bot/internal/logger"
-	"github.com/pltanton/lingti-bot/internal/platforms/dingtalk"
-	"github.com/pltanton/lingti-bot/internal/platforms/discord"
-	"github.com/pltanton/lingti-bot/internal/platforms/feishu"
-	"github.com/pltanton/lingti-bot/internal/platforms/slack"
-	"github.com/pltanton/lingti-bot/internal/platforms/telegram"
-	"github.com/pltanton/lingti-bot/internal/platforms/wecom"
 	"github.com/pltanton/lingti-bot/internal/router"
-	"github.com/pltanton/lingti-bot/internal/voice"
+	"github.com/pltanton/lingti-bot/internal/skills"
+	"github.com/pltanton/lingti-bot/internal/webhook"
 	"github.com/spf13/cobra"
 )
 
-var (
-	slackBotToken      string
-	slackAppToken      string
-	feishuAppID        string
-	feishuAppSecret    string
-	telegramToken      string
-	discordToken       string
-	wecomCorpID        string
-	wecomAgentID       string
-	wecomSecret        string
-	wecomToken         string
-	wecomAESKey        string
-	wecomPort          int
-	dingtalkClientID   string
-	dingtalkClientSecret string
-	aiProvider         string
-	aiAPIKey           string
-	aiBaseURL          string
-	aiModel            string
-	voiceSTTProvider   string
-	voiceSTTAPIKey     string
-)
-
 var routerCmd = &cobra.Command{
 	Use:   "router",
 	Short: "Start the message router",
 	Long: `Start the message router to receive messages from various platforms
-(Slack, Telegram, Discord, Feishu, DingTalk) and respond using AI.
+(Slack, Telegram, Discord, Feishu, DingTalk, Matrix, Mattermost,
+Rocket.Chat, Signal, email) and respond using AI.
 
 Supported platforms:
   - Slack: SLACK_BOT_TOKEN + SLACK_APP_TOKEN
@@ -55,7 +31,13 @@ Supported platforms:
   - Discord: DISCORD_BOT_TOKEN
   - Feishu: FEISHU_APP_ID + FEISHU_APP_SECRET
   - DingTalk: DINGTALK_CLIENT_ID + DINGTALK_CLIENT_SECRET
-  - WeCom: WECOM_CORP_ID + WECOM_AGENT_ID + WECOM_SECRET + WECOM_TOKEN + WECOM_AES_KEY
+  - WeCom: WECOM_CORP_ID + WECOM_AGENT_ID + WECOM_SECRET + WECOM_TOKEN + WECOM_AES_KEY,
+    or WECOM_WEBHOOK_URL alone to only send through a group robot
+  - Matrix: MATRIX_HOMESERVER + MATRIX_ACCESS_TOKEN
+  - Mattermost: MATTERMOST_URL + MATTERMOST_TOKEN
+  - Rocket.Chat: ROCKETCHAT_URL + ROCKETCHAT_USER_ID + ROCKETCHAT_TOKEN
+  - Signal: SIGNAL_ACCOUNT (+ SIGNAL_SOCKET), via a running signal-cli daemon
+  - Email: EMAIL_IMAP_ADDR + EMAIL_SMTP_ADDR + EMAIL_USERNAME + EMAIL_PASSWORD + EMAIL_ALLOWED_SENDERS
 
 Voice message transcription (optional):
   - VOICE_STT_PROVIDER: system, openai (default: system)
@@ -65,274 +47,287 @@ Required environment variables or flags:
   - AI_PROVIDER: AI provider (claude, deepseek, kimi) default: claude
   - AI_API_KEY: API Key for the AI provider
   - AI_BASE_URL: Custom API base URL (optional)
-  - AI_MODEL: Model name (optional)`,
+  - AI_MODEL: Model name (optional)
+
+All settings can also be given in bot.yaml; flags override
+environment variables, which override the file. Changes to bot.yaml are
+applied while running (or on SIGHUP) without restarting the router.`,
 	Run: runRouter,
 }
 
 func init() {
 	rootCmd.AddCommand(routerCmd)
 
-	routerCmd.Flags().StringVar(&slackBotToken, "slack-bot-token", "", "Slack Bot Token (or SLACK_BOT_TOKEN env)")
-	routerCmd.Flags().StringVar(&slackAppToken, "slack-app-token", "", "Slack App Token (or SLACK_APP_TOKEN env)")
-	routerCmd.Flags().StringVar(&feishuAppID, "feishu-app-id", "", "Feishu App ID (or FEISHU_APP_ID env)")
-	routerCmd.Flags().StringVar(&feishuAppSecret, "feishu-app-secret", "", "Feishu App Secret (or FEISHU_APP_SECRET env)")
-	routerCmd.Flags().StringVar(&telegramToken, "telegram-token", "", "Telegram Bot Token (or TELEGRAM_BOT_TOKEN env)")
-	routerCmd.Flags().StringVar(&discordToken, "discord-token", "", "Discord Bot Token (or DISCORD_BOT_TOKEN env)")
-	routerCmd.Flags().StringVar(&wecomCorpID, "wecom-corp-id", "", "WeCom Corp ID (or WECOM_CORP_ID env)")
-	routerCmd.Flags().StringVar(&wecomAgentID, "wecom-agent-id", "", "WeCom Agent ID (or WECOM_AGENT_ID env)")
-	routerCmd.Flags().StringVar(&wecomSecret, "wecom-secret", "", "WeCom Secret (or WECOM_SECRET env)")
-	routerCmd.Flags().StringVar(&wecomToken, "wecom-token", "", "WeCom Callback Token (or WECOM_TOKEN env)")
-	routerCmd.Flags().StringVar(&wecomAESKey, "wecom-aes-key", "", "WeCom EncodingAESKey (or WECOM_AES_KEY env)")
-	routerCmd.Flags().IntVar(&wecomPort, "wecom-port", 0, "WeCom Callback Port (or WECOM_PORT env, default: 8080)")
-	routerCmd.Flags().StringVar(&dingtalkClientID, "dingtalk-client-id", "", "DingTalk AppKey (or DINGTALK_CLIENT_ID env)")
-	routerCmd.Flags().StringVar(&dingtalkClientSecret, "dingtalk-client-secret", "", "DingTalk AppSecret (or DINGTALK_CLIENT_SECRET env)")
-	routerCmd.Flags().StringVar(&aiProvider, "provider", "", "AI provider: claude or deepseek (or AI_PROVIDER env)")
-	routerCmd.Flags().StringVar(&aiAPIKey, "api-key", "", "AI API Key (or AI_API_KEY env)")
-	routerCmd.Flags().StringVar(&aiBaseURL, "base-url", "", "Custom API base URL (or AI_BASE_URL env)")
-	routerCmd.Flags().StringVar(&aiModel, "model", "", "Model name (or AI_MODEL env)")
-	routerCmd.Flags().StringVar(&voiceSTTProvider, "voice-stt-provider", "", "Voice STT provider: system, openai (or VOICE_STT_PROVIDER env)")
-	routerCmd.Flags().StringVar(&voiceSTTAPIKey, "voice-stt-api-key", "", "Voice STT API key (or VOICE_STT_API_KEY env)")
+	flags := routerCmd.Flags()
+	flags.String("slack-bot-token", "", "Slack Bot Token (or SLACK_BOT_TOKEN env)")
+	flags.String("slack-app-token", "", "Slack App Token (or SLACK_APP_TOKEN env)")
+	flags.String("feishu-app-id", "", "Feishu App ID (or FEISHU_APP_ID env)")
+	flags.String("feishu-app-secret", "", "Feishu App Secret (or FEISHU_APP_SECRET env)")
+	flags.String("feishu-domain", "", "Feishu domain: feishu, lark or an API base URL (or FEISHU_DOMAIN env, default: feishu)")
+	flags.String("feishu-listen", "", "Receive Feishu events over HTTP on this address instead of the long connection (or FEISHU_LISTEN env)")
+	flags.String("feishu-verification-token", "", "Feishu event subscription verification token (or FEISHU_VERIFICATION_TOKEN env)")
+	flags.String("feishu-encrypt-key", "", "Feishu event encrypt key (or FEISHU_ENCRYPT_KEY env)")
+	flags.String("telegram-token", "", "Telegram Bot Token (or TELEGRAM_BOT_TOKEN env)")
+	flags.String("telegram-parse-mode", "", "Telegram formatting: HTML, MarkdownV2 or plain (or TELEGRAM_PARSE_MODE env, default: HTML)")
+	flags.String("discord-token", "", "Discord Bot Token (or DISCORD_BOT_TOKEN env)")
+	flags.String("wecom-corp-id", "", "WeCom Corp ID (or WECOM_CORP_ID env)")
+	flags.String("wecom-agent-id", "", "WeCom Agent ID (or WECOM_AGENT_ID env)")
+	flags.String("wecom-secret", "", "WeCom Secret (or WECOM_SECRET env)")
+	flags.String("wecom-token", "", "WeCom Callback Token (or WECOM_TOKEN env)")
+	flags.String("wecom-aes-key", "", "WeCom EncodingAESKey (or WECOM_AES_KEY env)")
+	flags.Int("wecom-port", 0, "WeCom Callback Port (or WECOM_PORT env, default: 8080)")
+	flags.String("wecom-msg-type", "", "WeCom reply format: markdown, text (or WECOM_MSG_TYPE env, default: markdown)")
+	flags.String("wecom-webhook-url", "", "WeCom group robot webhook URL (or WECOM_WEBHOOK_URL env)")
+	flags.String("dingtalk-client-id", "", "DingTalk AppKey (or DINGTALK_CLIENT_ID env)")
+	flags.String("dingtalk-client-secret", "", "DingTalk AppSecret (or DINGTALK_CLIENT_SECRET env)")
+	flags.String("dingtalk-robot-code", "", "DingTalk robot code for proactive messages (or DINGTALK_ROBOT_CODE env, default: AppKey)")
+	flags.String("dingtalk-card-template-id", "", "DingTalk AI card template ID for streamed answers (or DINGTALK_CARD_TEMPLATE_ID env)")
+	flags.String("matrix-homeserver", "", "Matrix homeserver URL (or MATRIX_HOMESERVER env)")
+	flags.String("matrix-access-token", "", "Matrix bot access token (or MATRIX_ACCESS_TOKEN env)")
+	flags.String("mattermost-url", "", "Mattermost server URL (or MATTERMOST_URL env)")
+	flags.String("mattermost-token", "", "Mattermost bot token (or MATTERMOST_TOKEN env)")
+	flags.String("rocketchat-url", "", "Rocket.Chat server URL (or ROCKETCHAT_URL env)")
+	flags.String("rocketchat-user-id", "", "Rocket.Chat bot user ID (or ROCKETCHAT_USER_ID env)")
+	flags.String("rocketchat-token", "", "Rocket.Chat personal access token (or ROCKETCHAT_TOKEN env)")
+	flags.String("signal-account", "", "Signal phone number registered with signal-cli (or SIGNAL_ACCOUNT env)")
+	flags.String("signal-socket", "", "signal-cli daemon socket path or host:port (or SIGNAL_SOCKET env)")
+	flags.String("email-imap-addr", "", "IMAP server host:port (or EMAIL_IMAP_ADDR env)")
+	flags.String("email-smtp-addr", "", "SMTP server host:port (or EMAIL_SMTP_ADDR env)")
+	flags.String("email-username", "", "Mailbox login (or EMAIL_USERNAME env)")
+	flags.String("email-password", "", "Mailbox password or app password (or EMAIL_PASSWORD env)")
+	flags.StringSlice("email-allowed-senders", nil, "Addresses or @domains the bot answers (or EMAIL_ALLOWED_SENDERS env)")
+	addAIFlags(routerCmd, "provider")
+	flags.String("voice-stt-provider", "", "Voice STT provider: system, openai (or VOICE_STT_PROVIDER env)")
+	flags.String("voice-stt-api-key", "", "Voice STT API key (or VOICE_STT_API_KEY env)")
 }
 
 func runRouter(cmd *cobra.Command, args []string) {
-	// Get tokens from flags or environment
-	if slackBotToken == "" {
-		slackBotToken = os.Getenv("SLACK_BOT_TOKEN")
-	}
-	if slackAppToken == "" {
-		slackAppToken = os.Getenv("SLACK_APP_TOKEN")
-	}
-	if feishuAppID == "" {
-		feishuAppID = os.Getenv("FEISHU_APP_ID")
-	}
-	if feishuAppSecret == "" {
-		feishuAppSecret = os.Getenv("FEISHU_APP_SECRET")
-	}
-	if telegramToken == "" {
-		telegramToken = os.Getenv("TELEGRAM_BOT_TOKEN")
-	}
-	if discordToken == "" {
-		discordToken = os.Getenv("DISCORD_BOT_TOKEN")
-	}
-	if wecomCorpID == "" {
-		wecomCorpID = os.Getenv("WECOM_CORP_ID")
-	}
-	if wecomAgentID == "" {
-		wecomAgentID = os.Getenv("WECOM_AGENT_ID")
-	}
-	if wecomSecret == "" {
-		wecomSecret = os.Getenv("WECOM_SECRET")
-	}
-	if wecomToken == "" {
-		wecomToken = os.Getenv("WECOM_TOKEN")
-	}
-	if wecomAESKey == "" {
-		wecomAESKey = os.Getenv("WECOM_AES_KEY")
-	}
-	if wecomPort == 0 {
-		if port := os.Getenv("WECOM_PORT"); port != "" {
-			fmt.Sscanf(port, "%d", &wecomPort)
-		}
-	}
-	if dingtalkClientID == "" {
-		dingtalkClientID = os.Getenv("DINGTALK_CLIENT_ID")
-	}
-	if dingtalkClientSecret == "" {
-		dingtalkClientSecret = os.Getenv("DINGTALK_CLIENT_SECRET")
-	}
-	if aiProvider == "" {
-		aiProvider = os.Getenv("AI_PROVIDER")
-	}
-	if aiAPIKey == "" {
-		aiAPIKey = os.Getenv("AI_API_KEY")
-		// Fallback to legacy env var
-		if aiAPIKey == "" {
-			aiAPIKey = os.Getenv("ANTHROPIC_API_KEY")
-		}
-	}
-	if aiBaseURL == "" {
-		aiBaseURL = os.Getenv("AI_BASE_URL")
-		if aiBaseURL == "" {
-			aiBaseURL = os.Getenv("ANTHROPIC_BASE_URL")
+	bindings := []flagBindings{aiFlags("provider"), platformFlags, func(cfg *config.Config) map[string]any {
+		return map[string]any{
+			"voice-stt-provider": &cfg.Voice.STTProvider,
+			"voice-stt-api-key":  &cfg.Voice.STTAPIKey,
 		}
-	}
-	if aiModel == "" {
-		aiModel = os.Getenv("AI_MODEL")
-		if aiModel == "" {
-			aiModel = os.Getenv("ANTHROPIC_MODEL")
-		}
-	}
-	if voiceSTTProvider == "" {
-		voiceSTTProvider = os.Getenv("VOICE_STT_PROVIDER")
-	}
-	if voiceSTTAPIKey == "" {
-		voiceSTTAPIKey = os.Getenv("VOICE_STT_API_KEY")
-		if voiceSTTAPIKey == "" && voiceSTTProvider == "openai" {
-			voiceSTTAPIKey = os.Getenv("OPENAI_API_KEY")
-		}
-	}
+	}}
+	cfg := loadConfig(cmd, bindings...)
 
-	// Validate required tokens
-	if aiAPIKey == "" {
-		fmt.Fprintln(os.Stderr, "Error: AI_API_KEY is required")
-		os.Exit(1)
-	}
-
-	// Create the AI agent
-	aiAgent, err := agent.New(agent.Config{
-		Provider: aiProvider,
-		APIKey:   aiAPIKey,
-		BaseURL:  aiBaseURL,
-		Model:    aiModel,
-	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
-		os.Exit(1)
-	}
+	aiAgent := newAgent(cfg)
 
 	// Create the router with the agent as message handler
 	r := router.New(aiAgent.HandleMessage)
 
-	// Register Slack if tokens are provided
-	if slackBotToken != "" && slackAppToken != "" {
-		slackPlatform, err := slack.New(slack.Config{
-			BotToken: slackBotToken,
-			AppToken: slackAppToken,
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating Slack platform: %v\n", err)
-			os.Exit(1)
+	if cfg.Dedup.Enabled {
+		dedupPath := ""
+		if cfg.Dedup.Persist {
+			dedupPath = config.DedupPath()
 		}
-		r.Register(slackPlatform)
-	} else {
-		logger.Info("Slack tokens not provided, skipping Slack integration")
+		r.SetDeduplicator(router.NewDeduplicator(cfg.Dedup.TTL, dedupPath))
 	}
+	aiAgent.SetPlatformStatus(r.Status)
 
-	// Register Feishu if tokens are provided
-	if feishuAppID != "" && feishuAppSecret != "" {
-		feishuPlatform, err := feishu.New(feishu.Config{
-			AppID:     feishuAppID,
-			AppSecret: feishuAppSecret,
-		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating Feishu platform: %v\n", err)
-			os.Exit(1)
-		}
-		r.Register(feishuPlatform)
-	} else {
-		logger.Info("Feishu tokens not provided, skipping Feishu integration")
+	// Applies settings and registers platforms, now and on every reload
+	reloader := newReloader(cmd, cfg, r, aiAgent, bindings...)
+
+	// Start the router
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := r.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting router: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Register Telegram if token is provided
-	if telegramToken != "" {
-		// Create voice transcriber if STT provider is configured
-		var transcriber *voice.Transcriber
-		if voiceSTTProvider != "" {
-			var err error
-			transcriber, err = voice.NewTranscriber(voice.TranscriberConfig{
-				Provider: voiceSTTProvider,
-				APIKey:   voiceSTTAPIKey,
-			})
-			if err != nil {
-				logger.Info("Warning: Failed to create voice transcriber: %v", err)
-			} else {
-				logger.Info("Voice transcription enabled (provider: %s)", voiceSTTProvider)
-			}
+	// Local API for "lingti-bot send"
+	var controlServer *control.Server
+	if cfg.Control.Enabled {
+		var err error
+		controlServer, err = control.NewServer(control.Config{
+			Socket: cfg.ControlSocketPath(),
+			Listen: cfg.Control.Listen,
+			Token:  cfg.Control.Token,
+		}, r)
+		if err == nil {
+			err = controlServer.Start()
 		}
-
-		telegramPlatform, err := telegram.New(telegram.Config{
-			Token:       telegramToken,
-			Transcriber: transcriber,
-		})
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating Telegram platform: %v\n", err)
-			os.Exit(1)
+			logger.Error("Control API disabled: %v", err)
+			controlServer = nil
 		}
-		r.Register(telegramPlatform)
-	} else {
-		logger.Info("Telegram token not provided, skipping Telegram integration")
 	}
 
-	// Register Discord if token is provided
-	if discordToken != "" {
-		discordPlatform, err := discord.New(discord.Config{
-			Token: discordToken,
-		})
+	// Inbound webhooks that run the agent or skills
+	var webhookServer *webhook.Server
+	if cfg.Webhooks.Listen != "" {
+		var err error
+		webhookServer, reloader.skills, err = newWebhookServer(cfg, aiAgent, r)
+		if err == nil {
+			err = webhookServer.Start(ctx)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating Discord platform: %v\n", err)
-			os.Exit(1)
+			logger.Error("Webhooks disabled: %v", err)
+			webhookServer = nil
 		}
-		r.Register(discordPlatform)
-	} else {
-		logger.Info("Discord token not provided, skipping Discord integration")
 	}
 
-	// Register WeCom if tokens are provided
-	if wecomCorpID != "" && wecomAgentID != "" && wecomSecret != "" && wecomToken != "" && wecomAESKey != "" {
-		wecomPlatform, err := wecom.New(wecom.Config{
-			CorpID:         wecomCorpID,
-			AgentID:        wecomAgentID,
-			Secret:         wecomSecret,
-			Token:          wecomToken,
-			EncodingAESKey: wecomAESKey,
-			CallbackPort:   wecomPort,
-		})
+	// OpenAI-compatible chat completions API
+	var apiServer *chatapi.Server
+	if cfg.API.Enabled {
+		var err error
+		apiServer, err = newAPIServer(cfg, aiAgent)
+		if err == nil {
+			err = apiServer.Start(ctx)
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating WeCom platform: %v\n", err)
-			os.Exit(1)
+			logger.Error("Chat completions API disabled: %v", err)
+			apiServer = nil
 		}
-		r.Register(wecomPlatform)
-	} else {
-		logger.Info("WeCom tokens not provided, skipping WeCom integration")
+		reloader.api = apiServer
 	}
 
-	// Register DingTalk if tokens are provided
-	if dingtalkClientID != "" && dingtalkClientSecret != "" {
-		dingtalkPlatform, err := dingtalk.New(dingtalk.Config{
-			ClientID:     dingtalkClientID,
-			ClientSecret: dingtalkClientSecret,
+	providerName, modelName := providerAndModel(cfg)
+	logger.Info("Router started. AI Provider: %s, Model: %s", providerName, modelName)
+	logger.Info("Press Ctrl+C to stop.")
+
+	// Apply bot.yaml changes without a restart
+	if cfg.Reload.Watch {
+		go config.Watch(ctx, cfg.Reload.Interval, func() {
+			reloader.reload(config.ConfigPath() + " changed")
 		})
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating DingTalk platform: %v\n", err)
-			os.Exit(1)
-		}
-		r.Register(dingtalkPlatform)
-	} else {
-		logger.Info("DingTalk tokens not provided, skipping DingTalk integration")
 	}
 
-	// Start the router
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Wait for shutdown signal; SIGHUP reloads the configuration
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := <-sigCh; sig == syscall.SIGHUP; sig = <-sigCh {
+		reloader.reload("SIGHUP")
+	}
 
-	if err := r.Start(ctx); err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting router: %v\n", err)
-		os.Exit(1)
+	logger.Info("Shutting down...")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), reloader.current().Shutdown.Timeout)
+	defer cancelShutdown()
+	if controlServer != nil {
+		controlServer.Stop(shutdownCtx)
+	}
+	if webhookServer != nil {
+		webhookServer.Stop(shutdownCtx)
+	}
+	if apiServer != nil {
+		apiServer.Stop(shutdownCtx)
+	}
+	if err := r.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Error during shutdown: %v", err)
 	}
+}
 
-	providerName := aiProvider
-	if providerName == "" {
-		providerName = "claude"
+// platformFlags binds the router's platform credential flags
+func platformFlags(cfg *config.Config) map[string]any {
+	p := &cfg.Platforms
+	return map[string]any{
+		"slack-bot-token":           &p.Slack.BotToken,
+		"slack-app-token":           &p.Slack.AppToken,
+		"feishu-app-id":             &p.Feishu.AppID,
+		"feishu-app-secret":         &p.Feishu.AppSecret,
+		"feishu-domain":             &p.Feishu.Domain,
+		"feishu-listen":             &p.Feishu.Listen,
+		"feishu-verification-token": &p.Feishu.VerificationToken,
+		"feishu-encrypt-key":        &p.Feishu.EncryptKey,
+		"telegram-token":            &p.Telegram.Token,
+		"telegram-parse-mode":       &p.Telegram.ParseMode,
+		"discord-token":             &p.Discord.Token,
+		"wecom-corp-id":             &p.WeCom.CorpID,
+		"wecom-agent-id":            &p.WeCom.AgentID,
+		"wecom-secret":              &p.WeCom.Secret,
+		"wecom-token":               &p.WeCom.Token,
+		"wecom-aes-key":             &p.WeCom.AESKey,
+		"wecom-port":                &p.WeCom.Port,
+		"wecom-msg-type":            &p.WeCom.MsgType,
+		"wecom-webhook-url":         &p.WeCom.WebhookURL,
+		"dingtalk-client-id":        &p.DingTalk.ClientID,
+		"dingtalk-client-secret":    &p.DingTalk.ClientSecret,
+		"dingtalk-robot-code":       &p.DingTalk.RobotCode,
+		"dingtalk-card-template-id": &p.DingTalk.CardTemplateID,
+		"matrix-homeserver":         &p.Matrix.Homeserver,
+		"matrix-access-token":       &p.Matrix.AccessToken,
+		"mattermost-url":            &p.Mattermost.URL,
+		"mattermost-token":          &p.Mattermost.Token,
+		"rocketchat-url":            &p.RocketChat.URL,
+		"rocketchat-user-id":        &p.RocketChat.UserID,
+		"rocketchat-token":          &p.RocketChat.Token,
+		"signal-account":            &p.Signal.Account,
+		"signal-socket":             &p.Signal.Socket,
+		"email-imap-addr":           &p.Email.IMAPAddr,
+		"email-smtp-addr":           &p.Email.SMTPAddr,
+		"email-username":            &p.Email.Username,
+		"email-password":            &p.Email.Password,
+		"email-allowed-senders":     &p.Email.AllowedSenders,
 	}
-	modelName := aiModel
-	if modelName == "" {
-		switch providerName {
-		case "deepseek":
-			modelName = "deepseek-chat"
-		case "kimi", "moonshot":
-			modelName = "moonshot-v1-8k"
-		default:
-			modelName = "claude-sonnet-4-20250514"
+}
+
+// rateLimitConfig converts the bot.yaml rate limit section for the router
+func rateLimitConfig(cfg *config.Config) router.RateLimitConfig {
+	rl := cfg.RateLimit
+	return router.RateLimitConfig{
+		User:          router.RateLimit{Rate: rl.User.Rate, Burst: rl.User.Burst},
+		Channel:       router.RateLimit{Rate: rl.Channel.Rate, Burst: rl.Channel.Burst},
+		Platform:      router.RateLimit{Rate: rl.Platform.Rate, Burst: rl.Platform.Burst},
+		Window:        rl.Window,
+		BlockAfter:    rl.BlockAfter,
+		BlockDuration: rl.BlockDuration,
+		Admins:        cfg.Security.Admins,
+	}
+}
+
+// newWebhookServer builds the webhook server from bot.yaml. Skills are only
+// loaded when a route triggers them; the registry is returned for reloads.
+func newWebhookServer(cfg *config.Config, aiAgent *agent.Agent, r *router.Router) (*webhook.Server, *skills.Registry, error) {
+	var routes []webhook.Route
+	var registry *skills.Registry
+	for _, route := range cfg.Webhooks.Routes {
+		routes = append(routes, webhook.Route{
+			Name:     route.Name,
+			Path:     route.Path,
+			Verify:   route.Verify,
+			Secret:   route.Secret,
+			Events:   route.Events,
+			Prompt:   route.Prompt,
+			Profile:  route.Profile,
+			Trigger:  route.Trigger,
+			Platform: route.Platform,
+			Channel:  route.Channel,
+		})
+		if route.Trigger != "" && registry == nil {
+			registry = newSkillRegistry(aiAgent)
 		}
 	}
-	logger.Info("Router started. AI Provider: %s, Model: %s", providerName, modelName)
-	logger.Info("Press Ctrl+C to stop.")
 
-	// Wait for shutdown signal
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	server, err := webhook.New(webhook.Config{
+		Listen: cfg.Webhooks.Listen,
+		Routes: routes,
+	}, aiAgent, r, registry)
+	return server, registry, err
+}
 
-	logger.Info("Shutting down...")
-	r.Stop()
+// newSkillRegistry loads skills with executors backed by the agent. Skills
+// run on webhook payloads, so their prompts are answered without tools.
+func newSkillRegistry(aiAgent *agent.Agent) *skills.Registry {
+	registry := skills.NewRegistry("")
+	registry.RegisterExecutor(skills.ActionShell, skills.NewShellExecutor())
+	registry.RegisterExecutor(skills.ActionHTTP, skills.NewHTTPExecutor())
+	registry.RegisterExecutor(skills.ActionPrompt, skills.NewPromptExecutor(func(ctx context.Context, prompt string) (string, error) {
+		resp, err := aiAgent.HandleTranscript(ctx, agent.Transcript{
+			Message: router.Message{
+				Platform: "skill",
+				UserID:   "skill",
+				Username: "skill",
+				Text:     prompt,
+			},
+			NoTools: true,
+		})
+		return resp.Text, err
+	}))
+	registry.RegisterExecutor(skills.ActionWorkflow, skills.NewWorkflowExecutor(registry))
+
+	if err := registry.LoadFromDirectory(""); err != nil {
+		logger.Error("Error loading skills: %v", err)
+	}
+	return registry
 }
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/pltanton/lingti-bot/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the encrypted secret store",
+	Long: `Store API keys and platform credentials in an encrypted file instead of
+bot.yaml, environment variables or flags.
+
+Refer to a stored secret from bot.yaml (or an environment variable) as
+secret://<name>:
+
+  ai:
+    api_key: secret://deepseek
+  platforms:
+    feishu:
+      app_id: cli_xxx
+      app_secret: secret://feishu-secret
+
+The store is encrypted with a key derived from LINGTI_SECRETS_PASSPHRASE
+if set when it is created, otherwise from this machine's ID and your user
+account. Use "secrets rekey" to switch between the two later.`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <name> [value]",
+	Short: "Add or replace a secret",
+	Long: `Add or replace a secret. The value is read from stdin when it is not
+given, which keeps it out of your shell history:
+
+  lingti-bot secrets set deepseek
+  echo -n "$TOKEN" | lingti-bot secrets set telegram`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else {
+			if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				fmt.Fprintf(os.Stderr, "Value for %s: ", args[0])
+			}
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				fmt.Fprintf(os.Stderr, "Error reading value: %v\n", err)
+				os.Exit(1)
+			}
+			value = strings.TrimRight(line, "\r\n")
+		}
+		if value == "" {
+			fmt.Fprintln(os.Stderr, "Error: secret value is empty")
+			os.Exit(1)
+		}
+
+		store := openSecrets()
+		if err := store.Set(args[0], value); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveSecrets(store)
+		fmt.Printf("Saved %s (use it as %s%s)\n", args[0], secrets.RefPrefix, args[0])
+	},
+}
+
+var secretsGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "Print a secret's value",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		value, err := openSecrets().Get(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(value)
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secret names",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names := openSecrets().Names()
+		if len(names) == 0 {
+			fmt.Println("No secrets stored.")
+			return
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	},
+}
+
+var secretsRmCmd = &cobra.Command{
+	Use:     "rm <name>",
+	Aliases: []string{"remove", "delete"},
+	Short:   "Remove a secret",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openSecrets()
+		if err := store.Delete(args[0]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		saveSecrets(store)
+		fmt.Printf("Removed %s\n", args[0])
+	},
+}
+
+var secretsRekeyMachine bool
+
+var secretsRekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Re-encrypt the store with a new passphrase or the machine key",
+	Long: `Re-encrypt the secret store with a new passphrase, read from stdin, or
+with the machine-derived key when --machine is given. The store is opened
+with LINGTI_SECRETS_PASSPHRASE as usual; afterwards that variable must hold
+the new passphrase (or be unset for the machine key):
+
+  lingti-bot secrets rekey
+  lingti-bot secrets rekey --machine`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		store := openSecrets()
+
+		var passphrase string
+		if !secretsRekeyMachine {
+			if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				fmt.Fprint(os.Stderr, "New passphrase: ")
+			}
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				fmt.Fprintf(os.Stderr, "Error reading passphrase: %v\n", err)
+				os.Exit(1)
+			}
+			passphrase = strings.TrimRight(line, "\r\n")
+			if passphrase == "" {
+				fmt.Fprintln(os.Stderr, "Error: passphrase is empty (use --machine for the machine key)")
+				os.Exit(1)
+			}
+		}
+
+		store.Rekey(passphrase)
+		saveSecrets(store)
+		if store.UsesPassphrase() {
+			fmt.Printf("Re-encrypted with the new passphrase; set %s to it from now on\n", secrets.PassphraseEnv)
+		} else {
+			fmt.Printf("Re-encrypted with the machine key; %s is no longer needed\n", secrets.PassphraseEnv)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsGetCmd)
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsRmCmd)
+	secretsCmd.AddCommand(secretsRekeyCmd)
+
+	secretsRekeyCmd.Flags().BoolVar(&secretsRekeyMachine, "machine", false, "use the machine-derived key instead of a passphrase")
+}
+
+func openSecrets() *secrets.Store {
+	store, err := secrets.OpenDefault(config.SecretsPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening secret store: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+func saveSecrets(store *secrets.Store) {
+	if err := store.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving secret store: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/config"
+	"github.com/pltanton/lingti-bot/internal/control"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sendPlatform string
+	sendChannel  string
+	sendThread   string
+	sendSocket   string
+	sendTimeout  time.Duration
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send [text]",
+	Short: "Send a message through the running router",
+	Long: `Send a message to a channel through a running "lingti-bot router".
+
+The text is taken from the arguments, or read from stdin when no text
+is given or the text is "-".
+
+Examples:
+  lingti-bot send --platform telegram --channel 123456 "Build finished"
+  make test 2>&1 | tail -20 | lingti-bot send --platform slack --channel C0123 -`,
+	Run: runSend,
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+
+	sendCmd.Flags().StringVar(&sendPlatform, "platform", "", "Platform to send to: slack, telegram, discord, feishu, ... (required)")
+	sendCmd.Flags().StringVar(&sendChannel, "channel", "", "Channel, chat or user ID (required)")
+	sendCmd.Flags().StringVar(&sendThread, "thread", "", "Thread or message ID to reply to")
+	sendCmd.Flags().StringVar(&sendSocket, "socket", "", "Control socket path (default: from bot.yaml)")
+	sendCmd.Flags().DurationVar(&sendTimeout, "timeout", 30*time.Second, "How long to wait for the platform")
+	sendCmd.MarkFlagRequired("platform")
+	sendCmd.MarkFlagRequired("channel")
+}
+
+func runSend(cmd *cobra.Command, args []string) {
+	text := strings.Join(args, " ")
+	if text == "" || text == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			os.Exit(1)
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		fmt.Fprintln(os.Stderr, "Error: message text is empty")
+		os.Exit(1)
+	}
+
+	if sendSocket == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		sendSocket = cfg.ControlSocketPath()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	id, err := control.NewClient(sendSocket).Send(ctx, control.SendRequest{
+		Platform: sendPlatform,
+		Channel:  sendChannel,
+		Text:     text,
+		ThreadID: sendThread,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending message: %v\n", err)
+		os.Exit(1)
+	}
+
+	if id != "" {
+		fmt.Printf("Sent (message ID: %s)\n", id)
+	} else {
+		fmt.Println("Sent")
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"os/signal"
 	"syscall"
 
-	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/config"
 	"github.com/pltanton/lingti-bot/internal/logger"
 	"github.com/pltanton/lingti-bot/internal/router"
 	"github.com/pltanton/lingti-bot/internal/voice"
@@ -15,12 +15,8 @@ import (
 )
 
 var (
-	voiceProvider   string
-	voiceAPIKey     string
-	wakeWord        string
-	continuousMode  bool
-	defaultVoice    string
-	briefVoice      bool
+	continuousMode bool
+	briefVoice     bool
 )
 
 var talkCmd = &cobra.Command{
@@ -38,83 +34,35 @@ Environment variables:
   - VOICE_PROVIDER: Speech provider (system, openai, elevenlabs)
   - VOICE_API_KEY: API key for cloud providers
   - WAKE_WORD: Wake word for activation (e.g., "hey lingti")
-  - AI_API_KEY: API Key for the AI provider`,
+  - AI_API_KEY: API Key for the AI provider
+
+These can also be set in the voice and ai sections of bot.yaml.`,
 	Run: runTalk,
 }
 
 func init() {
 	rootCmd.AddCommand(talkCmd)
 
-	talkCmd.Flags().StringVar(&voiceProvider, "voice-provider", "", "Voice provider: system, openai, elevenlabs (or VOICE_PROVIDER env)")
-	talkCmd.Flags().StringVar(&voiceAPIKey, "voice-api-key", "", "Voice API key (or VOICE_API_KEY env)")
-	talkCmd.Flags().StringVar(&wakeWord, "wake-word", "", "Wake word for activation (or WAKE_WORD env)")
+	talkCmd.Flags().String("voice-provider", "", "Voice provider: system, openai, elevenlabs (or VOICE_PROVIDER env)")
+	talkCmd.Flags().String("voice-api-key", "", "Voice API key (or VOICE_API_KEY env)")
+	talkCmd.Flags().String("wake-word", "", "Wake word for activation (or WAKE_WORD env)")
 	talkCmd.Flags().BoolVar(&continuousMode, "continuous", false, "Keep listening after each response")
 	talkCmd.Flags().BoolVar(&briefVoice, "brief", true, "Brief voice mode: print full text, speak only notification")
-	talkCmd.Flags().StringVar(&defaultVoice, "voice", "", "Default voice name")
-	talkCmd.Flags().StringVar(&aiProvider, "provider", "", "AI provider: claude, deepseek, kimi (or AI_PROVIDER env)")
-	talkCmd.Flags().StringVar(&aiAPIKey, "api-key", "", "AI API Key (or AI_API_KEY env)")
-	talkCmd.Flags().StringVar(&aiBaseURL, "base-url", "", "AI API base URL (or AI_BASE_URL env)")
-	talkCmd.Flags().StringVar(&aiModel, "model", "", "Model name (or AI_MODEL env)")
+	talkCmd.Flags().String("voice", "", "Default voice name")
+	addAIFlags(talkCmd, "provider")
 }
 
 func runTalk(cmd *cobra.Command, args []string) {
-	// Get config from flags or environment
-	if voiceProvider == "" {
-		voiceProvider = os.Getenv("VOICE_PROVIDER")
-		if voiceProvider == "" {
-			voiceProvider = "system"
-		}
-	}
-	if voiceAPIKey == "" {
-		voiceAPIKey = os.Getenv("VOICE_API_KEY")
-		if voiceAPIKey == "" && voiceProvider == "openai" {
-			voiceAPIKey = os.Getenv("OPENAI_API_KEY")
+	cfg := loadConfig(cmd, aiFlags("provider"), func(cfg *config.Config) map[string]any {
+		return map[string]any{
+			"voice-provider": &cfg.Voice.Provider,
+			"voice-api-key":  &cfg.Voice.APIKey,
+			"wake-word":      &cfg.Voice.WakeWord,
+			"voice":          &cfg.Voice.VoiceName,
 		}
-		if voiceAPIKey == "" && voiceProvider == "elevenlabs" {
-			voiceAPIKey = os.Getenv("ELEVENLABS_API_KEY")
-		}
-	}
-	if wakeWord == "" {
-		wakeWord = os.Getenv("WAKE_WORD")
-	}
-	if aiProvider == "" {
-		aiProvider = os.Getenv("AI_PROVIDER")
-	}
-	if aiAPIKey == "" {
-		aiAPIKey = os.Getenv("AI_API_KEY")
-		if aiAPIKey == "" {
-			aiAPIKey = os.Getenv("ANTHROPIC_API_KEY")
-		}
-	}
-	if aiModel == "" {
-		aiModel = os.Getenv("AI_MODEL")
-		if aiModel == "" {
-			aiModel = os.Getenv("ANTHROPIC_MODEL")
-		}
-	}
-	if aiBaseURL == "" {
-		aiBaseURL = os.Getenv("AI_BASE_URL")
-		if aiBaseURL == "" {
-			aiBaseURL = os.Getenv("ANTHROPIC_BASE_URL")
-		}
-	}
-
-	if aiAPIKey == "" {
-		fmt.Fprintln(os.Stderr, "Error: AI_API_KEY is required")
-		os.Exit(1)
-	}
-
-	// Create the AI agent
-	aiAgent, err := agent.New(agent.Config{
-		Provider: aiProvider,
-		APIKey:   aiAPIKey,
-		BaseURL:  aiBaseURL,
-		Model:    aiModel,
 	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
-		os.Exit(1)
-	}
+
+	aiAgent := newAgent(cfg)
 
 	// Create message handler for voice mode
 	messageHandler := func(text string) (string, error) {
@@ -138,11 +86,11 @@ func runTalk(cmd *cobra.Command, args []string) {
 
 	// Create talk mode
 	talkMode, err := voice.NewTalkMode(voice.Config{
-		Provider:       voiceProvider,
-		APIKey:         voiceAPIKey,
-		WakeWord:       wakeWord,
+		Provider:       cfg.Voice.Provider,
+		APIKey:         cfg.Voice.APIKey,
+		WakeWord:       cfg.Voice.WakeWord,
 		ContinuousMode: continuousMode,
-		DefaultVoice:   defaultVoice,
+		DefaultVoice:   cfg.Voice.VoiceName,
 		BriefVoice:     briefVoice,
 	}, messageHandler)
 	if err != nil {
@@ -159,9 +107,9 @@ func runTalk(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	logger.Info("Talk mode started (provider: %s)", voiceProvider)
-	if wakeWord != "" {
-		logger.Info("Wake word: %s", wakeWord)
+	logger.Info("Talk mode started (provider: %s)", cfg.Voice.Provider)
+	if cfg.Voice.WakeWord != "" {
+		logger.Info("Wake word: %s", cfg.Voice.WakeWord)
 	}
 	if continuousMode {
 		logger.Info("Continuous mode enabled")
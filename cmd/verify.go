@@ -13,17 +13,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var (
-	verifyPlatform   string
-	verifyServerURL  string
-	verifyTimeout    int
-	// WeCom credentials
-	verifyWeComCorpID  string
-	verifyWeComAgentID string
-	verifyWeComSecret  string
-	verifyWeComToken   string
-	verifyWeComAESKey  string
-)
+var verifyTimeout int
 
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
@@ -57,16 +47,16 @@ respond to user messages - only the 'relay' command can do that.`,
 func init() {
 	rootCmd.AddCommand(verifyCmd)
 
-	verifyCmd.Flags().StringVar(&verifyPlatform, "platform", "", "Platform: wecom (required)")
-	verifyCmd.Flags().StringVar(&verifyServerURL, "server", "", "WebSocket URL (default: wss://bot.lingti.com/ws)")
+	verifyCmd.Flags().String("platform", "", "Platform: wecom (required)")
+	verifyCmd.Flags().String("server", "", "WebSocket URL (default: wss://bot.lingti.com/ws)")
 	verifyCmd.Flags().IntVar(&verifyTimeout, "timeout", 300, "Timeout in seconds (default: 300)")
 
 	// WeCom credentials
-	verifyCmd.Flags().StringVar(&verifyWeComCorpID, "wecom-corp-id", "", "WeCom Corp ID (or WECOM_CORP_ID env)")
-	verifyCmd.Flags().StringVar(&verifyWeComAgentID, "wecom-agent-id", "", "WeCom Agent ID (or WECOM_AGENT_ID env)")
-	verifyCmd.Flags().StringVar(&verifyWeComSecret, "wecom-secret", "", "WeCom Secret (or WECOM_SECRET env)")
-	verifyCmd.Flags().StringVar(&verifyWeComToken, "wecom-token", "", "WeCom Callback Token (or WECOM_TOKEN env)")
-	verifyCmd.Flags().StringVar(&verifyWeComAESKey, "wecom-aes-key", "", "WeCom Encoding AES Key (or WECOM_AES_KEY env)")
+	verifyCmd.Flags().String("wecom-corp-id", "", "WeCom Corp ID (or WECOM_CORP_ID env)")
+	verifyCmd.Flags().String("wecom-agent-id", "", "WeCom Agent ID (or WECOM_AGENT_ID env)")
+	verifyCmd.Flags().String("wecom-secret", "", "WeCom Secret (or WECOM_SECRET env)")
+	verifyCmd.Flags().String("wecom-token", "", "WeCom Callback Token (or WECOM_TOKEN env)")
+	verifyCmd.Flags().String("wecom-aes-key", "", "WeCom Encoding AES Key (or WECOM_AES_KEY env)")
 }
 
 func runVerify(cmd *cobra.Command, args []string) {
@@ -76,30 +66,9 @@ func runVerify(cmd *cobra.Command, args []string) {
 	log.Println("Use 'relay' instead - it handles both verification AND messages.")
 	log.Println("")
 
-	// Get values from flags or environment
-	if verifyPlatform == "" {
-		verifyPlatform = os.Getenv("RELAY_PLATFORM")
-	}
-	if verifyServerURL == "" {
-		verifyServerURL = os.Getenv("RELAY_SERVER_URL")
-	}
-
-	// Get WeCom credentials from flags or environment
-	if verifyWeComCorpID == "" {
-		verifyWeComCorpID = os.Getenv("WECOM_CORP_ID")
-	}
-	if verifyWeComAgentID == "" {
-		verifyWeComAgentID = os.Getenv("WECOM_AGENT_ID")
-	}
-	if verifyWeComSecret == "" {
-		verifyWeComSecret = os.Getenv("WECOM_SECRET")
-	}
-	if verifyWeComToken == "" {
-		verifyWeComToken = os.Getenv("WECOM_TOKEN")
-	}
-	if verifyWeComAESKey == "" {
-		verifyWeComAESKey = os.Getenv("WECOM_AES_KEY")
-	}
+	cfg := loadConfig(cmd, relayFlags, wecomFlags)
+	verifyPlatform := cfg.Relay.Platform
+	wecomCfg := cfg.Platforms.WeCom
 
 	// Validate platform
 	if verifyPlatform == "" {
@@ -115,19 +84,19 @@ func runVerify(cmd *cobra.Command, args []string) {
 	switch verifyPlatform {
 	case "wecom":
 		missing := []string{}
-		if verifyWeComCorpID == "" {
+		if wecomCfg.CorpID == "" {
 			missing = append(missing, "--wecom-corp-id")
 		}
-		if verifyWeComAgentID == "" {
+		if wecomCfg.AgentID == "" {
 			missing = append(missing, "--wecom-agent-id")
 		}
-		if verifyWeComSecret == "" {
+		if wecomCfg.Secret == "" {
 			missing = append(missing, "--wecom-secret")
 		}
-		if verifyWeComToken == "" {
+		if wecomCfg.Token == "" {
 			missing = append(missing, "--wecom-token")
 		}
-		if verifyWeComAESKey == "" {
+		if wecomCfg.AESKey == "" {
 			missing = append(missing, "--wecom-aes-key")
 		}
 		if len(missing) > 0 {
@@ -143,14 +112,14 @@ func runVerify(cmd *cobra.Command, args []string) {
 	verifyPlatformInstance, err := relay.New(relay.Config{
 		UserID:       verifyUserID,
 		Platform:     verifyPlatform,
-		ServerURL:    verifyServerURL,
+		ServerURL:    cfg.Relay.ServerURL,
 		AIProvider:   "verify", // Special marker for verification mode
 		AIModel:      "verify",
-		WeComCorpID:  verifyWeComCorpID,
-		WeComAgentID: verifyWeComAgentID,
-		WeComSecret:  verifyWeComSecret,
-		WeComToken:   verifyWeComToken,
-		WeComAESKey:  verifyWeComAESKey,
+		WeComCorpID:  wecomCfg.CorpID,
+		WeComAgentID: wecomCfg.AgentID,
+		WeComSecret:  wecomCfg.Secret,
+		WeComToken:   wecomCfg.Token,
+		WeComAESKey:  wecomCfg.AESKey,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating verify connection: %v\n", err)
@@ -171,7 +140,7 @@ func runVerify(cmd *cobra.Command, args []string) {
 	log.Println("=== Callback URL Verification Mode ===")
 	log.Println("")
 	log.Printf("  Platform: %s", verifyPlatform)
-	log.Printf("  Corp ID:  %s", verifyWeComCorpID)
+	log.Printf("  Corp ID:  %s", wecomCfg.CorpID)
 	log.Println("")
 	log.Println("Your credentials have been sent to the cloud relay server.")
 	log.Println("")
@@ -207,7 +176,7 @@ func runVerify(cmd *cobra.Command, args []string) {
 	log.Println("Use 'relay' instead - it handles both verification AND messages:")
 	log.Println("")
 	log.Printf("  lingti-bot relay --platform %s \\\n", verifyPlatform)
-	log.Printf("    --wecom-corp-id %s \\\n", verifyWeComCorpID)
+	log.Printf("    --wecom-corp-id %s \\\n", wecomCfg.CorpID)
 	log.Println("    --wecom-agent-id ... --wecom-secret ... \\")
 	log.Println("    --wecom-token ... --wecom-aes-key ... \\")
 	log.Println("    --provider deepseek --api-key YOUR_API_KEY")
@@ -10,7 +10,7 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/config"
 	"github.com/pltanton/lingti-bot/internal/logger"
 	"github.com/pltanton/lingti-bot/internal/router"
 	"github.com/pltanton/lingti-bot/internal/voice"
@@ -18,10 +18,8 @@ import (
 )
 
 var (
-	recordDuration  int
-	speakResponse   bool
-	voiceName       string
-	voiceLanguage   string
+	recordDuration int
+	speakResponse  bool
 )
 
 var voiceCmd = &cobra.Command{
@@ -45,7 +43,9 @@ Example:
 Environment variables:
   - VOICE_PROVIDER: STT/TTS provider (system, openai)
   - VOICE_API_KEY: API key for cloud providers
-  - AI_API_KEY: API Key for the AI provider`,
+  - AI_API_KEY: API Key for the AI provider
+
+These can also be set in the voice and ai sections of bot.yaml.`,
 	Run: runVoice,
 }
 
@@ -54,68 +54,25 @@ func init() {
 
 	voiceCmd.Flags().IntVarP(&recordDuration, "duration", "d", 5, "Recording duration in seconds")
 	voiceCmd.Flags().BoolVarP(&speakResponse, "speak", "s", false, "Speak AI responses aloud")
-	voiceCmd.Flags().StringVar(&voiceName, "voice-name", "", "Voice name for TTS")
-	voiceCmd.Flags().StringVarP(&voiceLanguage, "language", "l", "zh", "Language for speech recognition (default: zh)")
-	voiceCmd.Flags().StringVar(&voiceProvider, "provider", "", "Voice provider: system, openai (or VOICE_PROVIDER env)")
-	voiceCmd.Flags().StringVar(&voiceAPIKey, "voice-api-key", "", "Voice API key (or VOICE_API_KEY env)")
-	voiceCmd.Flags().StringVar(&aiProvider, "ai-provider", "", "AI provider: claude, deepseek, kimi (or AI_PROVIDER env)")
-	voiceCmd.Flags().StringVar(&aiAPIKey, "api-key", "", "AI API Key (or AI_API_KEY env)")
-	voiceCmd.Flags().StringVar(&aiBaseURL, "base-url", "", "AI API base URL (or AI_BASE_URL env)")
-	voiceCmd.Flags().StringVar(&aiModel, "model", "", "Model name (or AI_MODEL env)")
+	voiceCmd.Flags().String("voice-name", "", "Voice name for TTS")
+	voiceCmd.Flags().StringP("language", "l", "", "Language for speech recognition (default: zh)")
+	voiceCmd.Flags().String("provider", "", "Voice provider: system, openai (or VOICE_PROVIDER env)")
+	voiceCmd.Flags().String("voice-api-key", "", "Voice API key (or VOICE_API_KEY env)")
+	addAIFlags(voiceCmd, "ai-provider")
 }
 
 func runVoice(cmd *cobra.Command, args []string) {
-	// Get config from flags or environment
-	if voiceProvider == "" {
-		voiceProvider = os.Getenv("VOICE_PROVIDER")
-		if voiceProvider == "" {
-			voiceProvider = "system"
-		}
-	}
-	if voiceAPIKey == "" {
-		voiceAPIKey = os.Getenv("VOICE_API_KEY")
-		if voiceAPIKey == "" && voiceProvider == "openai" {
-			voiceAPIKey = os.Getenv("OPENAI_API_KEY")
-		}
-	}
-	if aiProvider == "" {
-		aiProvider = os.Getenv("AI_PROVIDER")
-	}
-	if aiAPIKey == "" {
-		aiAPIKey = os.Getenv("AI_API_KEY")
-		if aiAPIKey == "" {
-			aiAPIKey = os.Getenv("ANTHROPIC_API_KEY")
-		}
-	}
-	if aiModel == "" {
-		aiModel = os.Getenv("AI_MODEL")
-		if aiModel == "" {
-			aiModel = os.Getenv("ANTHROPIC_MODEL")
-		}
-	}
-	if aiBaseURL == "" {
-		aiBaseURL = os.Getenv("AI_BASE_URL")
-		if aiBaseURL == "" {
-			aiBaseURL = os.Getenv("ANTHROPIC_BASE_URL")
+	cfg := loadConfig(cmd, aiFlags("ai-provider"), func(cfg *config.Config) map[string]any {
+		return map[string]any{
+			"provider":      &cfg.Voice.Provider,
+			"voice-api-key": &cfg.Voice.APIKey,
+			"language":      &cfg.Voice.Language,
+			"voice-name":    &cfg.Voice.VoiceName,
 		}
-	}
-
-	if aiAPIKey == "" {
-		fmt.Fprintln(os.Stderr, "Error: AI_API_KEY is required")
-		os.Exit(1)
-	}
-
-	// Create the AI agent
-	aiAgent, err := agent.New(agent.Config{
-		Provider: aiProvider,
-		APIKey:   aiAPIKey,
-		BaseURL:  aiBaseURL,
-		Model:    aiModel,
 	})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating agent: %v\n", err)
-		os.Exit(1)
-	}
+
+	aiAgent := newAgent(cfg)
+	voiceProvider := cfg.Voice.Provider
 
 	// Check and download whisper model if using system provider
 	if voiceProvider == "" || voiceProvider == "system" {
@@ -133,8 +90,8 @@ func runVoice(cmd *cobra.Command, args []string) {
 	// Create voice recorder/transcriber
 	recorder, err := voice.NewRecorder(voice.RecorderConfig{
 		Provider: voiceProvider,
-		APIKey:   voiceAPIKey,
-		Language: voiceLanguage,
+		APIKey:   cfg.Voice.APIKey,
+		Language: cfg.Voice.Language,
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating voice recorder: %v\n", err)
@@ -147,8 +104,8 @@ func runVoice(cmd *cobra.Command, args []string) {
 	if speakResponse {
 		speaker, err = voice.NewSpeaker(voice.SpeakerConfig{
 			Provider: voiceProvider,
-			APIKey:   voiceAPIKey,
-			Voice:    voiceName,
+			APIKey:   cfg.Voice.APIKey,
+			Voice:    cfg.Voice.VoiceName,
 		})
 		if err != nil {
 			logger.Verbose("Warning: Failed to create speaker: %v (responses will be text only)", err)
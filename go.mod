@@ -1,6 +1,6 @@
 module github.com/pltanton/lingti-bot
 
-go 1.23
+go 1.23.0
 
 require (
 	github.com/bwmarrin/discordgo v0.29.0
@@ -14,6 +14,8 @@ require (
 	github.com/shirou/gopsutil/v4 v4.24.11
 	github.com/slack-go/slack v0.15.0
 	github.com/spf13/cobra v1.8.1
+	github.com/yuin/goldmark v1.7.8
+	golang.org/x/crypto v0.41.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -31,6 +33,5 @@ require (
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
-	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
-	golang.org/x/sys v0.26.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
 )
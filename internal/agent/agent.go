@@ -4,9 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pltanton/lingti-bot/internal/logger"
@@ -15,9 +14,13 @@ import (
 
 // Agent processes messages using AI providers and tools
 type Agent struct {
-	provider Provider
-	memory   *ConversationMemory
-	sessions *SessionStore
+	mu             sync.RWMutex
+	profiles       map[string]*profile // By name, including DefaultProfile
+	routes         []Route
+	promptDir      string   // Channel and user prompt files
+	admins         []string // "platform:user_id" or "user_id"; may use /prompt and see platform errors
+	sessions       *SessionStore
+	platformStatus func() []router.PlatformStatus // Optional; shown by /status
 }
 
 // Config holds agent configuration
@@ -26,6 +29,17 @@ type Config struct {
 	APIKey   string
 	BaseURL  string // Custom API base URL (optional)
 	Model    string // Model name (optional, uses provider default)
+
+	MaxMessages int           // Conversation history kept per chat (default: 50)
+	MemoryTTL   time.Duration // Idle time before history is dropped (default: 60 minutes)
+
+	SystemPrompt     string   // System prompt template replacing the built-in one (optional)
+	SystemPromptFile string   // Template file, used when SystemPrompt is empty
+	PromptDir        string   // Directory of per-channel and per-user prompt additions (optional)
+	Admins           []string // Users who may see the effective prompt with /prompt
+
+	Profiles []Profile // Additional named profiles (optional)
+	Routes   []Route   // Which messages the profiles handle
 }
 
 // New creates a new Agent with the specified provider
@@ -34,16 +48,64 @@ func New(cfg Config) (*Agent, error) {
 		return nil, fmt.Errorf("API key is required")
 	}
 
-	provider, err := createProvider(cfg)
-	if err != nil {
+	a := &Agent{sessions: NewSessionStore()}
+	if err := a.configure(cfg); err != nil {
 		return nil, err
 	}
+	return a, nil
+}
 
-	return &Agent{
-		provider: provider,
-		memory:   NewMemory(50, 60*time.Minute), // Keep 50 messages, 60 min TTL
-		sessions: NewSessionStore(),
-	}, nil
+func (c Config) withDefaults() Config {
+	if c.MaxMessages <= 0 {
+		c.MaxMessages = 50
+	}
+	if c.MemoryTTL <= 0 {
+		c.MemoryTTL = 60 * time.Minute
+	}
+	return c
+}
+
+// profile returns the default profile described by the top-level settings
+func (c Config) profile() Profile {
+	return Profile{
+		Name:             DefaultProfile,
+		Provider:         c.Provider,
+		APIKey:           c.APIKey,
+		BaseURL:          c.BaseURL,
+		Model:            c.Model,
+		MaxMessages:      c.MaxMessages,
+		MemoryTTL:        c.MemoryTTL,
+		SystemPrompt:     c.SystemPrompt,
+		SystemPromptFile: c.SystemPromptFile,
+	}
+}
+
+// Reconfigure switches to new providers, profiles, routes and memory limits.
+// Requests already talking to an old provider finish with it; conversation
+// history is kept for profiles that still exist.
+func (a *Agent) Reconfigure(cfg Config) error {
+	if cfg.APIKey == "" {
+		return fmt.Errorf("API key is required")
+	}
+	return a.configure(cfg)
+}
+
+// SetPlatformStatus sets the source of platform health shown by /status
+func (a *Agent) SetPlatformStatus(fn func() []router.PlatformStatus) {
+	a.platformStatus = fn
+}
+
+// isAdmin reports whether the sender is listed in Config.Admins
+func (a *Agent) isAdmin(msg router.Message) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, admin := range a.admins {
+		if admin == msg.UserID || admin == msg.Platform+":"+msg.UserID {
+			return true
+		}
+	}
+	return false
 }
 
 // createProvider creates the appropriate AI provider based on config
@@ -77,6 +139,7 @@ func (a *Agent) handleBuiltinCommand(msg router.Message) (router.Response, bool)
 	text := strings.TrimSpace(msg.Text)
 	textLower := strings.ToLower(text)
 	convKey := ConversationKey(msg.Platform, msg.ChannelID, msg.UserID)
+	p := a.profile(msg)
 
 	// Exact match commands
 	switch textLower {
@@ -108,39 +171,58 @@ func (a *Agent) handleBuiltinCommand(msg router.Message) (router.Response, bool)
   /whoami         查看用户信息
   /model          查看当前模型
   /tools          列出可用工具
+  /prompt         查看系统提示词（管理员）
   /help           显示帮助
 
 直接用自然语言和我对话即可！`,
 		}, true
 
 	case "/new", "/reset", "/clear", "新对话", "清除历史":
-		a.memory.Clear(convKey)
+		p.memory.Clear(convKey)
 		a.sessions.Clear(convKey)
 		return router.Response{
 			Text: "已开始新对话，历史记录和会话设置已重置。",
 		}, true
 
 	case "/status", "状态":
-		history := a.memory.GetHistory(convKey)
+		history := p.memory.GetHistory(convKey)
 		settings := a.sessions.Get(convKey)
-		return router.Response{
-			Text: fmt.Sprintf(`会话状态:
+		text := fmt.Sprintf(`会话状态:
 - 平台: %s
 - 用户: %s
 - 历史消息: %d 条
 - 思考模式: %s
 - 详细模式: %v
+- 配置档: %s
 - AI 模型: %s`,
-				msg.Platform, msg.Username, len(history),
-				settings.ThinkingLevel, settings.Verbose, a.provider.Name()),
-		}, true
+			msg.Platform, msg.Username, len(history),
+			settings.ThinkingLevel, settings.Verbose, p.name, p.provider.Name())
+		if a.platformStatus != nil {
+			text += "\n\n平台状态:\n" + router.FormatStatus(a.platformStatus(), a.isAdmin(msg))
+		}
+		return router.Response{Text: text}, true
+
+	case "/prompt", "提示词":
+		if !a.isAdmin(msg) {
+			return router.Response{Text: "仅管理员可以查看系统提示词。"}, true
+		}
+		tools := p.filterTools(a.buildToolsList())
+		prompt := a.systemPrompt(msg, p, tools, a.sessions.Get(convKey).ThinkingLevel)
+		return router.Response{Text: fmt.Sprintf("当前系统提示词 (配置档: %s):\n\n%s", p.name, prompt)}, true
 
 	case "/model", "模型":
 		return router.Response{
-			Text: fmt.Sprintf("当前模型: %s", a.provider.Name()),
+			Text: fmt.Sprintf("当前模型: %s", p.provider.Name()),
 		}, true
 
 	case "/tools", "工具", "工具列表":
+		if len(p.tools) > 0 {
+			var names []string
+			for _, tool := range p.filterTools(a.buildToolsList()) {
+				names = append(names, tool.Name)
+			}
+			return router.Response{Text: "可用工具:\n\n  " + strings.Join(names, "\n  ")}, true
+		}
 		return router.Response{
 			Text: `可用工具:
 
@@ -210,7 +292,9 @@ func (a *Agent) handleBuiltinCommand(msg router.Message) (router.Response, bool)
 
 // HandleMessage processes a message and returns a response
 func (a *Agent) HandleMessage(ctx context.Context, msg router.Message) (router.Response, error) {
-	logger.Info("[Agent] Processing message from %s: %s (provider: %s)", msg.Username, msg.Text, a.provider.Name())
+	p := a.profile(msg)
+	provider := p.provider
+	logger.Info("[Agent] Processing message from %s: %s (profile: %s, provider: %s)", msg.Username, msg.Text, p.name, provider.Name())
 
 	// Handle built-in commands
 	if resp, handled := a.handleBuiltinCommand(msg); handled {
@@ -221,10 +305,10 @@ func (a *Agent) HandleMessage(ctx context.Context, msg router.Message) (router.R
 	convKey := ConversationKey(msg.Platform, msg.ChannelID, msg.UserID)
 
 	// Build the tools list
-	tools := a.buildToolsList()
+	tools := p.filterTools(a.buildToolsList())
 
 	// Get conversation history
-	history := a.memory.GetHistory(convKey)
+	history := p.memory.GetHistory(convKey)
 	logger.Debug("[Agent] Conversation key: %s, history messages: %d", convKey, len(history))
 
 	// Create messages with history
@@ -235,107 +319,47 @@ func (a *Agent) HandleMessage(ctx context.Context, msg router.Message) (router.R
 		Content: msg.Text,
 	})
 
-	// Get system info for context
-	homeDir, _ := os.UserHomeDir()
-	if homeDir == "" {
-		homeDir = "~"
-	}
-
 	// Get session settings
 	settings := a.sessions.Get(convKey)
-	thinkingPrompt := ThinkingPrompt(settings.ThinkingLevel)
-
-	// System prompt with actual paths
-	systemPrompt := fmt.Sprintf(`You are 灵缇 (Lingti), a helpful AI assistant running on the user's computer.
-
-## System Environment
-- Operating System: %s
-- Architecture: %s
-- Home Directory: %s
-- Desktop: %s/Desktop
-- Documents: %s/Documents
-- Downloads: %s/Downloads
-- User: %s
-
-## Available Tools
-
-### File Operations
-- file_list: List directory contents (use ~/Desktop for desktop)
-- file_read: Read file contents
-- file_trash: Move files to trash (for delete operations)
-- file_list_old: Find old files not modified for N days
-
-### Calendar (macOS)
-- calendar_today: Get today's events
-- calendar_list_events: List upcoming events
-- calendar_create_event: Create new event
-- calendar_search: Search events
-- calendar_delete: Delete event
-
-### Reminders (macOS)
-- reminders_list: List pending reminders
-- reminders_add: Add new reminder
-- reminders_complete: Mark as complete
-- reminders_delete: Delete reminder
-
-### Notes (macOS)
-- notes_list: List notes
-- notes_read: Read note content
-- notes_create: Create new note
-- notes_search: Search notes
-
-### Weather
-- weather_current: Current weather
-- weather_forecast: Weather forecast
-
-### Web
-- web_search: Search the web (DuckDuckGo)
-- web_fetch: Fetch URL content
-- open_url: Open URL in browser
-
-### Clipboard
-- clipboard_read: Read clipboard
-- clipboard_write: Write to clipboard
-
-### System
-- system_info: System information
-- shell_execute: Execute shell command
-- process_list: List processes
-- notification_send: Send notification
-- screenshot: Capture screen
-
-### Music (macOS)
-- music_play/pause/next/previous: Playback control
-- music_now_playing: Current track info
-- music_volume: Set volume
-- music_search: Search and play
-
-## Important Rules
-1. **ALWAYS use tools** - Never tell users to do things manually
-2. **Be action-oriented** - Execute tasks, don't just describe them
-3. **Use correct paths** - 桌面=~/Desktop, 下载=~/Downloads, 文档=~/Documents
-4. **Full permission** - You have full permission to execute all tools
-5. **Be concise** - Short, helpful responses
-6. **NEVER claim success without tool execution** - If user asks to create/add/delete something, you MUST call the corresponding tool. Never say "已创建/已添加/已删除" unless you actually called the tool and it succeeded.
-7. **Date format for calendar** - When creating calendar events, use YYYY-MM-DD HH:MM format. Convert relative dates (明天/下周一) to absolute dates based on today's date.
-
-Current date: %s%s`, time.Now().Format("2006-01-02"), runtime.GOOS, runtime.GOARCH, homeDir, homeDir, homeDir, homeDir, msg.Username, thinkingPrompt)
+	systemPrompt := a.systemPrompt(msg, p, tools, settings.ThinkingLevel)
+
+	resp, err := a.complete(ctx, p, messages, systemPrompt, tools, settings.Verbose)
+	if err != nil {
+		return router.Response{}, err
+	}
+
+	// Save conversation to memory
+	p.memory.AddExchange(convKey,
+		Message{Role: "user", Content: msg.Text},
+		Message{Role: "assistant", Content: resp.Content},
+	)
+
+	// Log response at verbose level
+	logger.Verbose("[Agent] Response: %s", resp.Content)
+
+	return router.Response{Text: resp.Content}, nil
+}
+
+// complete calls the profile's provider and runs the tools it asks for until
+// it produces a final answer
+func (a *Agent) complete(ctx context.Context, p *profile, messages []Message, systemPrompt string, tools []Tool, verbose bool) (ChatResponse, error) {
+	provider := p.provider
 
 	// Call AI provider
-	resp, err := a.provider.Chat(ctx, ChatRequest{
+	resp, err := provider.Chat(ctx, ChatRequest{
 		Messages:     messages,
 		SystemPrompt: systemPrompt,
 		Tools:        tools,
 		MaxTokens:    4096,
 	})
 	if err != nil {
-		return router.Response{}, fmt.Errorf("AI error: %w", err)
+		return ChatResponse{}, fmt.Errorf("AI error: %w", err)
 	}
 
 	// Handle tool use if needed
 	for resp.FinishReason == "tool_use" {
 		// Process tool calls
-		toolResults := a.processToolCalls(ctx, resp.ToolCalls)
+		toolResults := a.processToolCalls(ctx, p, tools, resp.ToolCalls, verbose)
 
 		// Add assistant response with tool calls
 		messages = append(messages, Message{
@@ -353,27 +377,17 @@ Current date: %s%s`, time.Now().Format("2006-01-02"), runtime.GOOS, runtime.GOAR
 		}
 
 		// Continue the conversation
-		resp, err = a.provider.Chat(ctx, ChatRequest{
+		resp, err = provider.Chat(ctx, ChatRequest{
 			Messages:     messages,
 			SystemPrompt: systemPrompt,
 			Tools:        tools,
 			MaxTokens:    4096,
 		})
 		if err != nil {
-			return router.Response{}, fmt.Errorf("AI error: %w", err)
+			return ChatResponse{}, fmt.Errorf("AI error: %w", err)
 		}
 	}
-
-	// Save conversation to memory
-	a.memory.AddExchange(convKey,
-		Message{Role: "user", Content: msg.Text},
-		Message{Role: "assistant", Content: resp.Content},
-	)
-
-	// Log response at verbose level
-	logger.Verbose("[Agent] Response: %s", resp.Content)
-
-	return router.Response{Text: resp.Content}, nil
+	return resp, nil
 }
 
 // buildToolsList creates the tools list for the AI provider
@@ -817,11 +831,29 @@ func (a *Agent) buildToolsList() []Tool {
 	}
 }
 
-// processToolCalls executes tool calls and returns results
-func (a *Agent) processToolCalls(ctx context.Context, toolCalls []ToolCall) []ToolResult {
+// processToolCalls executes tool calls and returns results. Only the offered
+// tools run, whatever the model asks for.
+// In verbose mode the running tool is shown in the platform's placeholder.
+func (a *Agent) processToolCalls(ctx context.Context, p *profile, tools []Tool, toolCalls []ToolCall, verbose bool) []ToolResult {
 	results := make([]ToolResult, 0, len(toolCalls))
+	offered := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		offered[tool.Name] = true
+	}
 
 	for _, tc := range toolCalls {
+		if verbose {
+			router.ReportProgress(ctx, fmt.Sprintf("🔧 正在执行 %s…", tc.Name))
+		}
+		if !offered[tc.Name] || !p.allows(tc.Name) {
+			logger.Info("[Agent] Tool %s is not allowed for profile %s", tc.Name, p.name)
+			results = append(results, ToolResult{
+				ToolCallID: tc.ID,
+				Content:    fmt.Sprintf("Error: tool %s is not available", tc.Name),
+				IsError:    true,
+			})
+			continue
+		}
 		result := a.executeTool(ctx, tc.Name, tc.Input)
 		results = append(results, ToolResult{
 			ToolCallID: tc.ID,
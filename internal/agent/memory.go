@@ -11,6 +11,7 @@ type ConversationMemory struct {
 	mu            sync.RWMutex
 	maxMessages   int           // Max messages to keep per conversation
 	ttl           time.Duration // Time to live for conversations
+	done          chan struct{}
 }
 
 // Conversation holds messages for a single conversation
@@ -32,6 +33,7 @@ func NewMemory(maxMessages int, ttl time.Duration) *ConversationMemory {
 		conversations: make(map[string]*Conversation),
 		maxMessages:   maxMessages,
 		ttl:           ttl,
+		done:          make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
@@ -128,12 +130,31 @@ func (m *ConversationMemory) ClearAll() {
 	m.conversations = make(map[string]*Conversation)
 }
 
+// SetLimits changes the history size and TTL; longer conversations are
+// trimmed when they are next updated
+func (m *ConversationMemory) SetLimits(maxMessages int, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if maxMessages > 0 {
+		m.maxMessages = maxMessages
+	}
+	if ttl > 0 {
+		m.ttl = ttl
+	}
+}
+
 // cleanup periodically removes expired conversations
 func (m *ConversationMemory) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+
 		m.mu.Lock()
 		now := time.Now()
 		for key, conv := range m.conversations {
@@ -145,6 +166,11 @@ func (m *ConversationMemory) cleanup() {
 	}
 }
 
+// Close stops the cleanup goroutine of a memory that is no longer used
+func (m *ConversationMemory) Close() {
+	close(m.done)
+}
+
 // ConversationKey generates a unique key for a conversation
 func ConversationKey(platform, channelID, userID string) string {
 	// Use channel+user for unique conversations
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// DefaultProfile is the name of the profile built from the agent's own Config
+const DefaultProfile = "default"
+
+// Profile is a named agent with its own provider, system prompt, tools and
+// conversation memory. Settings are used as given; inheriting from the
+// default profile is up to the caller.
+type Profile struct {
+	Name     string
+	Provider string
+	APIKey   string
+	BaseURL  string
+	Model    string
+
+	MaxMessages int
+	MemoryTTL   time.Duration
+
+	SystemPrompt     string   // System prompt template (optional, see PromptData)
+	SystemPromptFile string   // Template file, used when SystemPrompt is empty
+	Tools            []string // Allowed tools as path.Match patterns, e.g. "git_*"; empty allows all
+}
+
+// Route sends matching messages to a profile. Empty fields match anything;
+// the first matching route wins and unmatched messages use the default profile.
+type Route struct {
+	Platform  string
+	ChannelID string
+	UserID    string
+	Profile   string
+}
+
+// matches reports whether the route applies to msg
+func (r Route) matches(msg router.Message) bool {
+	return (r.Platform == "" || r.Platform == msg.Platform) &&
+		(r.ChannelID == "" || r.ChannelID == msg.ChannelID) &&
+		(r.UserID == "" || r.UserID == msg.UserID)
+}
+
+// profile is a Profile ready to serve messages
+type profile struct {
+	name     string
+	provider Provider
+	memory   *ConversationMemory
+	prompt   promptSource
+	tools    []string
+}
+
+// allows reports whether the profile may use a tool
+func (p *profile) allows(tool string) bool {
+	if len(p.tools) == 0 {
+		return true
+	}
+	for _, pattern := range p.tools {
+		if ok, _ := path.Match(pattern, tool); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTools drops the tools the profile may not use
+func (p *profile) filterTools(tools []Tool) []Tool {
+	if len(p.tools) == 0 {
+		return tools
+	}
+	allowed := make([]Tool, 0, len(tools))
+	for _, tool := range tools {
+		if p.allows(tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// profile returns the profile that handles msg
+func (a *Agent) profile(msg router.Message) *profile {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, route := range a.routes {
+		if route.matches(msg) {
+			if p, ok := a.profiles[route.Profile]; ok {
+				return p
+			}
+		}
+	}
+	return a.profiles[DefaultProfile]
+}
+
+// Profiles returns the names of the configured profiles, default first
+func (a *Agent) Profiles() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	names := make([]string, 0, len(a.profiles))
+	for name := range a.profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...)
+}
+
+// configure builds the providers of every profile and then swaps them in.
+// Memory of profiles that keep their name survives; nothing changes on error.
+// Profiles without a prompt of their own use the default profile's.
+func (a *Agent) configure(cfg Config) error {
+	all := append([]Profile{cfg.profile()}, cfg.Profiles...)
+	defaultPrompt := promptSource{text: cfg.SystemPrompt, file: cfg.SystemPromptFile}
+
+	built := make(map[string]*profile, len(all))
+	for _, p := range all {
+		if _, dup := built[p.Name]; dup {
+			return fmt.Errorf("profile %s: defined twice", p.Name)
+		}
+		if p.APIKey == "" {
+			return fmt.Errorf("profile %s: API key is required", p.Name)
+		}
+		provider, err := createProvider(Config{
+			Provider: p.Provider,
+			APIKey:   p.APIKey,
+			BaseURL:  p.BaseURL,
+			Model:    p.Model,
+		})
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+		prompt := promptSource{text: p.SystemPrompt, file: p.SystemPromptFile}
+		if !prompt.isSet() {
+			prompt = defaultPrompt
+		}
+		if _, err := prompt.load(); err != nil {
+			return fmt.Errorf("profile %s: system prompt: %w", p.Name, err)
+		}
+		built[p.Name] = &profile{
+			name:     p.Name,
+			provider: provider,
+			prompt:   prompt,
+			tools:    p.Tools,
+		}
+	}
+	for i, route := range cfg.Routes {
+		if _, ok := built[route.Profile]; !ok {
+			return fmt.Errorf("route %d: unknown profile %q", i+1, route.Profile)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, p := range all {
+		limits := Config{MaxMessages: p.MaxMessages, MemoryTTL: p.MemoryTTL}.withDefaults()
+		if old, ok := a.profiles[p.Name]; ok {
+			old.memory.SetLimits(limits.MaxMessages, limits.MemoryTTL)
+			built[p.Name].memory = old.memory
+		} else {
+			built[p.Name].memory = NewMemory(limits.MaxMessages, limits.MemoryTTL)
+		}
+	}
+	for name, old := range a.profiles {
+		if _, ok := built[name]; !ok {
+			old.memory.Close()
+		}
+	}
+
+	a.profiles = built
+	a.routes = cfg.Routes
+	a.promptDir = cfg.PromptDir
+	a.admins = cfg.Admins
+	return nil
+}
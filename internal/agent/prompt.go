@@ -0,0 +1,156 @@
+package agent
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+//go:embed prompts/system.tmpl
+var builtinPrompt string
+
+// builtinTemplate is used when neither the profile nor the agent has a prompt
+var builtinTemplate = template.Must(template.New("builtin").Parse(builtinPrompt))
+
+// PromptData is what system prompt templates are rendered with
+type PromptData struct {
+	OS       string // runtime.GOOS
+	Arch     string
+	HomeDir  string
+	User     string // Sender's display name
+	UserID   string
+	Platform string
+	Channel  string // Channel ID
+	Profile  string // Agent profile handling the message
+	Date     string // 2006-01-02
+	Time     string // 15:04
+	Weekday  string
+	Timezone string // e.g. "CST +08:00"
+	Tools    []Tool // Tools the profile may use
+}
+
+// promptSource is where a profile's system prompt template comes from.
+// Files are re-read for every message so edits apply without a reload.
+type promptSource struct {
+	text string // Inline template
+	file string // Template file, used when text is empty
+}
+
+func (s promptSource) isSet() bool { return s.text != "" || s.file != "" }
+
+// load parses the template, falling back to the built-in one if unset
+func (s promptSource) load() (*template.Template, error) {
+	switch {
+	case s.text != "":
+		return template.New("system_prompt").Parse(s.text)
+	case s.file != "":
+		data, err := os.ReadFile(s.file)
+		if err != nil {
+			return nil, err
+		}
+		return template.New(filepath.Base(s.file)).Parse(string(data))
+	default:
+		return builtinTemplate, nil
+	}
+}
+
+// newPromptData collects the template data for a message
+func newPromptData(msg router.Message, p *profile, tools []Tool) PromptData {
+	homeDir, _ := os.UserHomeDir()
+	if homeDir == "" {
+		homeDir = "~"
+	}
+	now := time.Now()
+	return PromptData{
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		HomeDir:  homeDir,
+		User:     msg.Username,
+		UserID:   msg.UserID,
+		Platform: msg.Platform,
+		Channel:  msg.ChannelID,
+		Profile:  p.name,
+		Date:     now.Format("2006-01-02"),
+		Time:     now.Format("15:04"),
+		Weekday:  now.Weekday().String(),
+		Timezone: now.Format("MST -07:00"),
+		Tools:    tools,
+	}
+}
+
+// systemPrompt renders the profile's prompt and appends the channel and user
+// prompt files. A broken template falls back to the built-in prompt so the
+// bot keeps answering.
+func (a *Agent) systemPrompt(msg router.Message, p *profile, tools []Tool, thinking ThinkingLevel) string {
+	data := newPromptData(msg, p, tools)
+
+	prompt, err := renderPrompt(p.prompt, data)
+	if err != nil {
+		logger.Error("[Agent] System prompt of profile %s: %v; using the built-in prompt", p.name, err)
+		prompt, _ = renderPrompt(promptSource{}, data)
+	}
+
+	a.mu.RLock()
+	dir := a.promptDir
+	a.mu.RUnlock()
+	for _, file := range extraPromptFiles(dir, msg) {
+		extra, err := renderPrompt(promptSource{file: file}, data)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			logger.Error("[Agent] Prompt file %s: %v", file, err)
+			continue
+		}
+		prompt += "\n\n" + extra
+	}
+
+	prompt += ThinkingPrompt(thinking)
+
+	// Let the model know what the current platform can do beyond plain text
+	if caps := msg.Metadata["capabilities"]; caps != "" {
+		prompt += fmt.Sprintf("\n\n## Chat Platform\n- Platform: %s\n- Capabilities: %s", msg.Platform, caps)
+	}
+	return prompt
+}
+
+func renderPrompt(src promptSource, data PromptData) (string, error) {
+	tmpl, err := src.load()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// extraPromptFiles returns the channel and user prompt files for msg:
+// <dir>/channels/<platform>/<channel>.md and <dir>/users/<platform>/<user>.md
+func extraPromptFiles(dir string, msg router.Message) []string {
+	if dir == "" || !safeFileName(msg.Platform) {
+		return nil
+	}
+	var files []string
+	if safeFileName(msg.ChannelID) {
+		files = append(files, filepath.Join(dir, "channels", msg.Platform, msg.ChannelID+".md"))
+	}
+	if safeFileName(msg.UserID) {
+		files = append(files, filepath.Join(dir, "users", msg.Platform, msg.UserID+".md"))
+	}
+	return files
+}
+
+// safeFileName rejects IDs that could escape the prompt directory
+func safeFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
@@ -0,0 +1,24 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+func TestSystemPromptCapabilities(t *testing.T) {
+	a := &Agent{}
+	p := &profile{name: DefaultProfile}
+
+	msg := router.Message{Platform: "telegram", Metadata: map[string]string{"capabilities": "edit,files,buttons"}}
+	prompt := a.systemPrompt(msg, p, nil, ThinkOff)
+	if !strings.Contains(prompt, "## Chat Platform\n- Platform: telegram\n- Capabilities: edit,files,buttons") {
+		t.Errorf("capabilities missing from the prompt:\n%s", prompt)
+	}
+
+	msg.Metadata = nil
+	if prompt := a.systemPrompt(msg, p, nil, ThinkOff); strings.Contains(prompt, "## Chat Platform") {
+		t.Errorf("platform section without capabilities:\n%s", prompt)
+	}
+}
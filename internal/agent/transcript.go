@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// ErrUnknownProfile is returned for a transcript naming a profile that isn't configured
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Transcript is a conversation supplied by the caller, e.g. a chat
+// completions API client, instead of being kept in the agent's memory
+type Transcript struct {
+	Profile      string         // Profile name; empty uses the default profile
+	Message      router.Message // The latest user message
+	History      []Message      // Earlier user and assistant messages, oldest first
+	Instructions string         // Extra system instructions from the caller
+	NoTools      bool           // Answer without tools, e.g. for untrusted input
+}
+
+// HandleTranscript answers the latest message of a transcript with the
+// named profile. Tools run as for chat messages unless NoTools is set; the
+// agent's memory and built-in commands are not used.
+func (a *Agent) HandleTranscript(ctx context.Context, t Transcript) (router.Response, error) {
+	name := t.Profile
+	if name == "" {
+		name = DefaultProfile
+	}
+	a.mu.RLock()
+	p, ok := a.profiles[name]
+	a.mu.RUnlock()
+	if !ok {
+		return router.Response{}, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+
+	msg := t.Message
+	logger.Info("[Agent] Processing transcript from %s: %s (profile: %s, provider: %s)", msg.Username, msg.Text, p.name, p.provider.Name())
+
+	var tools []Tool
+	if !t.NoTools {
+		tools = p.filterTools(a.buildToolsList())
+	}
+
+	messages := make([]Message, 0, len(t.History)+1)
+	messages = append(messages, t.History...)
+	messages = append(messages, Message{Role: "user", Content: msg.Text})
+
+	systemPrompt := a.systemPrompt(msg, p, tools, ThinkMedium)
+	if t.Instructions != "" {
+		systemPrompt += "\n\n" + t.Instructions
+	}
+
+	resp, err := a.complete(ctx, p, messages, systemPrompt, tools, false)
+	if err != nil {
+		return router.Response{}, err
+	}
+	logger.Verbose("[Agent] Response: %s", resp.Content)
+	return router.Response{Text: resp.Content}, nil
+}
@@ -0,0 +1,136 @@
+package chatapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/agent"
+)
+
+// chatRequest is the part of a chat completions request the server uses.
+// Sampling parameters are accepted and ignored; the profile decides.
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	User     string        `json:"user"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// contentPart is one element of an array content, e.g. {"type":"text","text":"..."}
+type contentPart struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// text returns a message's content as plain text. Array content keeps its
+// text parts; images and other parts are dropped.
+func (m chatMessage) text() (string, error) {
+	raw := bytes.TrimSpace(m.Content)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	}
+
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("content must be a string or an array of parts")
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// transcript splits the messages into system instructions, history and the
+// final user message
+func (r *chatRequest) transcript() (instructions string, history []agent.Message, last string, err error) {
+	if len(r.Messages) == 0 {
+		return "", nil, "", fmt.Errorf("messages must not be empty")
+	}
+
+	if r.Messages[len(r.Messages)-1].Role != "user" {
+		return "", nil, "", fmt.Errorf("the last message must be from the user")
+	}
+
+	var system []string
+	for i, m := range r.Messages {
+		text, err := m.text()
+		if err != nil {
+			return "", nil, "", fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		switch m.Role {
+		case "system", "developer":
+			if text != "" {
+				system = append(system, text)
+			}
+		case "user", "assistant":
+			if i == len(r.Messages)-1 {
+				last = text
+				continue
+			}
+			if text == "" {
+				continue
+			}
+			history = append(history, agent.Message{Role: m.Role, Content: text})
+		case "tool", "function":
+			// Tools run on the server; results of client-side tools have nowhere to go
+			return "", nil, "", fmt.Errorf("messages[%d]: client-side tools are not supported", i)
+		default:
+			return "", nil, "", fmt.Errorf("messages[%d]: unknown role %q", i, m.Role)
+		}
+	}
+	if strings.TrimSpace(last) == "" {
+		return "", nil, "", fmt.Errorf("the last message has no text")
+	}
+	return strings.Join(system, "\n\n"), history, last, nil
+}
+
+// completion is a non-streaming chat completion
+type completion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+}
+
+type choice struct {
+	Index        int            `json:"index"`
+	Message      *choiceMessage `json:"message,omitempty"`
+	Delta        *choiceMessage `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type choiceMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// model is an entry of the model list
+type model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// apiError is the error body OpenAI clients understand
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
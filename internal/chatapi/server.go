@@ -0,0 +1,357 @@
+// Package chatapi serves the agent over an OpenAI-compatible HTTP API
+// (/v1/models and /v1/chat/completions), so that chat front ends such as
+// Open WebUI, LobeChat or Chatbox can use it. Every agent profile is offered
+// as a model and tools run on the server.
+package chatapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	maxBody    = 10 << 20 // Largest accepted request
+	runTimeout = 10 * time.Minute
+	// keepAliveInterval is how often a streaming response gets an SSE comment
+	// while tools run, so proxies don't close the idle connection
+	keepAliveInterval = 10 * time.Second
+	ownedBy           = "lingti-bot"
+)
+
+// Agent answers transcripts; implemented by *agent.Agent
+type Agent interface {
+	HandleTranscript(ctx context.Context, t agent.Transcript) (router.Response, error)
+	Profiles() []string
+}
+
+// Config configures the API server
+type Config struct {
+	Listen string   // Address to listen on, e.g. "127.0.0.1:8689"
+	Keys   []string // Accepted API keys (Authorization: Bearer <key>)
+}
+
+// Server is the OpenAI-compatible API server
+type Server struct {
+	listen  string
+	agent   Agent
+	created int64 // Reported as the creation time of every model
+	server  *http.Server
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu   sync.RWMutex
+	keys [][]byte
+}
+
+// New creates an API server. At least one key is required.
+func New(cfg Config, a Agent) (*Server, error) {
+	if cfg.Listen == "" {
+		return nil, fmt.Errorf("listen address is required")
+	}
+	s := &Server{listen: cfg.Listen, agent: a, created: time.Now().Unix()}
+	if err := s.SetKeys(cfg.Keys); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetKeys replaces the accepted API keys; requests in flight are not affected
+func (s *Server) SetKeys(keys []string) error {
+	var accepted [][]byte
+	for _, key := range keys {
+		if key != "" {
+			accepted = append(accepted, []byte(key))
+		}
+	}
+	if len(accepted) == 0 {
+		return fmt.Errorf("at least one API key is required")
+	}
+
+	s.mu.Lock()
+	s.keys = accepted
+	s.mu.Unlock()
+	return nil
+}
+
+// Start begins serving in the background
+func (s *Server) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.listen, err)
+	}
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.server = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("[API] Server error: %v", err)
+		}
+	}()
+
+	logger.Info("[API] Listening on %s (models: %s)", s.listen, strings.Join(s.agent.Profiles(), ", "))
+	return nil
+}
+
+// Stop stops accepting requests and waits for running ones until ctx expires
+func (s *Server) Stop(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Shutdown(ctx)
+	s.cancel()
+	return err
+}
+
+// Handler returns the API's HTTP handler
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("GET /v1/models/{id}", s.handleModel)
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChat)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// Browser-based clients call the API directly
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		if req.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if !s.authorized(req) {
+			logger.Info("[API] Rejected request from %s: invalid API key", req.RemoteAddr)
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Invalid API key")
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+}
+
+// authorized checks the bearer token against every key in constant time
+func (s *Server) authorized(req *http.Request) bool {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	valid := 0
+	for _, key := range s.keys {
+		valid |= subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), key)
+	}
+	return valid == 1
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, req *http.Request) {
+	models := make([]model, 0)
+	for _, name := range s.agent.Profiles() {
+		models = append(models, s.model(name))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
+
+func (s *Server) handleModel(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	for _, name := range s.agent.Profiles() {
+		if name == id {
+			writeJSON(w, http.StatusOK, s.model(name))
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found",
+		fmt.Sprintf("The model %q does not exist", id))
+}
+
+func (s *Server) model(name string) model {
+	return model{ID: name, Object: "model", Created: s.created, OwnedBy: ownedBy}
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, req *http.Request) {
+	var body chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxBody)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid JSON body: "+err.Error())
+		return
+	}
+	instructions, history, text, err := body.transcript()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	modelName := body.Model
+	if modelName == "" {
+		modelName = agent.DefaultProfile
+	}
+	user := body.User
+	if user == "" {
+		user = "api"
+	}
+	t := agent.Transcript{
+		Profile: modelName,
+		Message: router.Message{
+			ID:        newID(""),
+			Platform:  "api",
+			ChannelID: "api",
+			UserID:    user,
+			Username:  user,
+			Text:      text,
+		},
+		History:      history,
+		Instructions: instructions,
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), runTimeout)
+	defer cancel()
+	if s.ctx != nil {
+		// Stop cancels running requests
+		stop := context.AfterFunc(s.ctx, cancel)
+		defer stop()
+	}
+
+	if body.Stream {
+		s.stream(ctx, w, modelName, t)
+		return
+	}
+
+	resp, err := s.agent.HandleTranscript(ctx, t)
+	if err != nil {
+		s.writeAgentError(w, modelName, err)
+		return
+	}
+	finish := "stop"
+	writeJSON(w, http.StatusOK, completion{
+		ID:      newID("chatcmpl-"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   modelName,
+		Choices: []choice{{
+			Message:      &choiceMessage{Role: "assistant", Content: resp.Text},
+			FinishReason: &finish,
+		}},
+	})
+}
+
+// stream answers with server-sent events. The agent produces the whole answer
+// at once, so the content arrives in a single chunk after the tools have run.
+func (s *Server) stream(ctx context.Context, w http.ResponseWriter, modelName string, t agent.Transcript) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "server_error", "", "Streaming is not supported")
+		return
+	}
+
+	// Check the model before committing to a 200 response
+	if !s.hasModel(modelName) {
+		s.writeAgentError(w, modelName, agent.ErrUnknownProfile)
+		return
+	}
+
+	type result struct {
+		resp router.Response
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := s.agent.HandleTranscript(ctx, t)
+		done <- result{resp, err}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	chunk := completion{
+		ID:      newID("chatcmpl-"),
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   modelName,
+	}
+	send := func(delta choiceMessage, finish *string) {
+		chunk.Choices = []choice{{Delta: &delta, FinishReason: finish}}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(choiceMessage{Role: "assistant"}, nil)
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	var res result
+	for waiting := true; waiting; {
+		select {
+		case res = <-done:
+			waiting = false
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+
+	if res.err != nil {
+		// Headers are sent; report the error in the stream
+		logger.Error("[API] %s: %v", modelName, res.err)
+		data, _ := json.Marshal(map[string]apiError{"error": {Message: res.err.Error(), Type: "server_error"}})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	} else {
+		finish := "stop"
+		send(choiceMessage{Content: res.resp.Text}, nil)
+		send(choiceMessage{}, &finish)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+func (s *Server) hasModel(name string) bool {
+	for _, profile := range s.agent.Profiles() {
+		if profile == name {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAgentError maps an agent error to an API error response
+func (s *Server) writeAgentError(w http.ResponseWriter, modelName string, err error) {
+	if errors.Is(err, agent.ErrUnknownProfile) {
+		writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found",
+			fmt.Sprintf("The model %q does not exist", modelName))
+		return
+	}
+	logger.Error("[API] %s: %v", modelName, err)
+	writeError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, errType, code, message string) {
+	writeJSON(w, status, map[string]apiError{"error": {Message: message, Type: errType, Code: code}})
+}
+
+// newID returns a random identifier with the given prefix
+func newID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,14 +14,109 @@ import (
 type Config struct {
 	Transport string          `yaml:"transport"` // "stdio" or "sse"
 	Port      int             `yaml:"port"`
+	AI        AIConfig        `yaml:"ai"`
+	Memory    MemoryConfig    `yaml:"memory"`
+	Agents    AgentsConfig    `yaml:"agents"`
+	Prompts   PromptsConfig   `yaml:"prompts"`
+	Voice     VoiceConfig     `yaml:"voice"`
+	Gateway   GatewayConfig   `yaml:"gateway"`
+	Relay     RelayConfig     `yaml:"relay"`
 	Security  SecurityConfig  `yaml:"security"`
 	Logging   LoggingConfig   `yaml:"logging"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Messages  MessagesConfig  `yaml:"messages"`
+	Dedup     DedupConfig     `yaml:"dedup"`
+	Platforms PlatformsConfig `yaml:"platforms"`
+	Shutdown  ShutdownConfig  `yaml:"shutdown"`
+	Control   ControlConfig   `yaml:"control"`
+	Webhooks  WebhooksConfig  `yaml:"webhooks"`
+	API       APIConfig       `yaml:"api"`
+	Reload    ReloadConfig    `yaml:"reload"`
+}
+
+// AIConfig selects the AI provider used by the agent
+type AIConfig struct {
+	Provider string `yaml:"provider"` // claude, deepseek or kimi
+	APIKey   string `yaml:"api_key"`
+	BaseURL  string `yaml:"base_url"` // Custom API endpoint (optional)
+	Model    string `yaml:"model"`    // Provider default if empty
+}
+
+// MemoryConfig configures per-conversation history
+type MemoryConfig struct {
+	MaxMessages int           `yaml:"max_messages"` // Messages kept per conversation
+	TTL         time.Duration `yaml:"ttl"`          // Idle time before a conversation is forgotten
+}
+
+// AgentsConfig defines named agent profiles and which conversations they serve.
+// Messages that match no route use the ai and memory sections.
+type AgentsConfig struct {
+	Profiles []AgentProfile `yaml:"profiles"`
+	Routes   []AgentRoute   `yaml:"routes"` // First match wins
+}
+
+// AgentProfile is an agent with its own provider, prompt, tools and memory.
+// Empty provider settings inherit from ai (api_key and base_url only when the
+// provider is the same) and an empty memory section inherits from memory.
+type AgentProfile struct {
+	Name             string       `yaml:"name"`
+	Provider         string       `yaml:"provider"`
+	APIKey           string       `yaml:"api_key"`
+	BaseURL          string       `yaml:"base_url"`
+	Model            string       `yaml:"model"`
+	SystemPrompt     string       `yaml:"system_prompt"`      // Prompt template; defaults to prompts.system
+	SystemPromptFile string       `yaml:"system_prompt_file"` // Or a template file
+	Tools            []string     `yaml:"tools"`              // Allowed tools, e.g. "git_*"; empty allows all
+	Memory           MemoryConfig `yaml:"memory"`
+}
+
+// AgentRoute selects a profile by platform, channel and user; empty fields
+// match anything
+type AgentRoute struct {
+	Platform string `yaml:"platform"`
+	Channel  string `yaml:"channel"`
+	User     string `yaml:"user"`
+	Profile  string `yaml:"profile"` // A profile name or "default"
+}
+
+// PromptsConfig configures the agent's system prompt. Prompts are Go
+// text/template files; relative paths are resolved in the config directory.
+type PromptsConfig struct {
+	System string `yaml:"system"` // Template file replacing the built-in prompt
+	Dir    string `yaml:"dir"`    // Per-channel and per-user additions (default: prompts in the config dir)
+}
+
+// VoiceConfig configures speech for the voice and talk commands and
+// transcription of voice messages received by the router
+type VoiceConfig struct {
+	Provider    string `yaml:"provider"`     // system, openai or elevenlabs
+	APIKey      string `yaml:"api_key"`      // For cloud providers
+	STTProvider string `yaml:"stt_provider"` // Router voice messages: system or openai (empty = off)
+	STTAPIKey   string `yaml:"stt_api_key"`
+	WakeWord    string `yaml:"wake_word"`
+	Language    string `yaml:"language"` // Speech recognition language
+	VoiceName   string `yaml:"voice_name"`
+}
+
+// GatewayConfig configures the WebSocket gateway
+type GatewayConfig struct {
+	Addr      string `yaml:"addr"`
+	AuthToken string `yaml:"auth_token"` // Optional token clients must send
+}
+
+// RelayConfig configures the connection to the cloud relay service
+type RelayConfig struct {
+	UserID     string `yaml:"user_id"`  // From /whoami; generated for WeCom
+	Platform   string `yaml:"platform"` // feishu, slack, wechat or wecom
+	ServerURL  string `yaml:"server_url"`
+	WebhookURL string `yaml:"webhook_url"`
 }
 
 type SecurityConfig struct {
 	AllowedPaths        []string `yaml:"allowed_paths"`
 	BlockedCommands     []string `yaml:"blocked_commands"`
 	RequireConfirmation []string `yaml:"require_confirmation"`
+	Admins              []string `yaml:"admins"` // "platform:user_id" or "user_id"
 }
 
 type LoggingConfig struct {
@@ -26,10 +124,233 @@ type LoggingConfig struct {
 	File  string `yaml:"file"`
 }
 
+// RateLimitConfig configures per-user, per-channel and per-platform message limits
+type RateLimitConfig struct {
+	Enabled       bool          `yaml:"enabled"` // off unless set; the limits below apply once enabled
+	User          RateLimitRule `yaml:"user"`
+	Channel       RateLimitRule `yaml:"channel"`
+	Platform      RateLimitRule `yaml:"platform"`
+	Window        time.Duration `yaml:"window"`         // "slow down" notice and violation window
+	BlockAfter    int           `yaml:"block_after"`    // violations within window before a temporary block
+	BlockDuration time.Duration `yaml:"block_duration"` // length of a temporary block
+}
+
+// RateLimitRule is a token bucket: rate messages per minute with a burst allowance
+type RateLimitRule struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// MessagesConfig configures outgoing message handling
+type MessagesConfig struct {
+	ChunkMarkers bool `yaml:"chunk_markers"` // Append "(1/3)" markers when a response is split
+}
+
+// DedupConfig configures suppression of redelivered platform events
+type DedupConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`     // How long message IDs are remembered
+	Persist bool          `yaml:"persist"` // Keep seen IDs across restarts (dedup.json in the config dir)
+}
+
+// PlatformsConfig holds messaging platform credentials and how their
+// connections are supervised. A platform is enabled when its credentials are set.
+type PlatformsConfig struct {
+	RetryMin       time.Duration `yaml:"retry_min"`       // First restart delay after a failure
+	RetryMax       time.Duration `yaml:"retry_max"`       // Cap for the exponential restart delay
+	MaxAttempts    int           `yaml:"max_attempts"`    // Consecutive failures before giving up (0 = never)
+	HealthInterval time.Duration `yaml:"health_interval"` // How often connections are checked
+
+	Slack      SlackConfig      `yaml:"slack"`
+	Feishu     FeishuConfig     `yaml:"feishu"`
+	Telegram   TelegramConfig   `yaml:"telegram"`
+	Discord    DiscordConfig    `yaml:"discord"`
+	WeCom      WeComConfig      `yaml:"wecom"`
+	DingTalk   DingTalkConfig   `yaml:"dingtalk"`
+	Matrix     MatrixConfig     `yaml:"matrix"`
+	Email      EmailConfig      `yaml:"email"`
+	Mattermost MattermostConfig `yaml:"mattermost"`
+	RocketChat RocketChatConfig `yaml:"rocketchat"`
+	Signal     SignalConfig     `yaml:"signal"`
+}
+
+type SlackConfig struct {
+	BotToken string `yaml:"bot_token"` // xoxb-...
+	AppToken string `yaml:"app_token"` // xapp-...
+}
+
+type FeishuConfig struct {
+	AppID             string `yaml:"app_id"`
+	AppSecret         string `yaml:"app_secret"`
+	Domain            string `yaml:"domain"`             // feishu (default), lark, or an API base URL such as a proxy
+	Listen            string `yaml:"listen"`             // Receive events over HTTP on this address, e.g. ":8090", instead of the long connection
+	VerificationToken string `yaml:"verification_token"` // HTTP mode: event subscription verification token
+	EncryptKey        string `yaml:"encrypt_key"`        // HTTP mode: event encrypt key, if encryption is on
+}
+
+type TelegramConfig struct {
+	Token     string `yaml:"token"`
+	ParseMode string `yaml:"parse_mode"` // HTML (default), MarkdownV2 or plain
+}
+
+type DiscordConfig struct {
+	Token string `yaml:"token"`
+}
+
+type WeComConfig struct {
+	CorpID  string `yaml:"corp_id"`
+	AgentID string `yaml:"agent_id"`
+	Secret  string `yaml:"secret"`
+	Token   string `yaml:"token"`   // Callback token
+	AESKey  string `yaml:"aes_key"` // EncodingAESKey
+	Port    int    `yaml:"port"`    // Callback port (default: 8080)
+
+	MsgType    string `yaml:"msg_type"`    // Reply format: markdown (default) or text
+	WebhookURL string `yaml:"webhook_url"` // Group robot webhook; alone it enables send-only mode
+}
+
+type DingTalkConfig struct {
+	ClientID       string `yaml:"client_id"` // AppKey
+	ClientSecret   string `yaml:"client_secret"`
+	RobotCode      string `yaml:"robot_code"`       // Robot code for proactive messages (default: client_id)
+	CardTemplateID string `yaml:"card_template_id"` // AI card template; answers are streamed into cards
+}
+
+type MatrixConfig struct {
+	Homeserver  string `yaml:"homeserver"` // e.g. https://matrix.example.org
+	AccessToken string `yaml:"access_token"`
+	UserID      string `yaml:"user_id"`   // Optional check that the token is the bot's
+	AutoJoin    bool   `yaml:"auto_join"` // Accept room invites (default: true)
+}
+
+type MattermostConfig struct {
+	URL   string `yaml:"url"`   // e.g. https://mattermost.example.com
+	Token string `yaml:"token"` // Bot account or personal access token
+}
+
+type RocketChatConfig struct {
+	URL    string `yaml:"url"`     // e.g. https://chat.example.com
+	UserID string `yaml:"user_id"` // User ID of the bot account
+	Token  string `yaml:"token"`   // Personal access token
+}
+
+type SignalConfig struct {
+	Account string `yaml:"account"` // Phone number registered with signal-cli, e.g. "+8613800000000"
+	Socket  string `yaml:"socket"`  // signal-cli daemon socket path or host:port (default: $XDG_RUNTIME_DIR/signal-cli/socket)
+}
+
+type EmailConfig struct {
+	IMAPAddr        string        `yaml:"imap_addr"` // host:port, e.g. imap.gmail.com:993
+	SMTPAddr        string        `yaml:"smtp_addr"` // host:port, e.g. smtp.gmail.com:587
+	Username        string        `yaml:"username"`
+	Password        string        `yaml:"password"`         // Password or app password
+	Address         string        `yaml:"address"`          // From address (default: username)
+	Mailbox         string        `yaml:"mailbox"`          // Mailbox to watch (default: INBOX)
+	PollInterval    time.Duration `yaml:"poll_interval"`    // How often to check for mail (default: 30s)
+	AllowedSenders  []string      `yaml:"allowed_senders"`  // Addresses or "@domain" entries the bot answers
+	Insecure        bool          `yaml:"insecure"`         // Skip STARTTLS, e.g. for a local test server
+	TrustUnverified bool          `yaml:"trust_unverified"` // Answer senders without a passing DMARC, DKIM or SPF result
+}
+
+// Enabled reports whether Slack credentials are configured
+func (c SlackConfig) Enabled() bool { return c.BotToken != "" && c.AppToken != "" }
+
+// Enabled reports whether Feishu credentials are configured
+func (c FeishuConfig) Enabled() bool { return c.AppID != "" && c.AppSecret != "" }
+
+// Enabled reports whether a Telegram token is configured
+func (c TelegramConfig) Enabled() bool { return c.Token != "" }
+
+// Enabled reports whether a Discord token is configured
+func (c DiscordConfig) Enabled() bool { return c.Token != "" }
+
+// Enabled reports whether all WeCom credentials or a group robot webhook are configured
+func (c WeComConfig) Enabled() bool {
+	return c.CorpID != "" && c.AgentID != "" && c.Secret != "" && c.Token != "" && c.AESKey != "" ||
+		c.WebhookURL != ""
+}
+
+// Enabled reports whether DingTalk credentials are configured
+func (c DingTalkConfig) Enabled() bool { return c.ClientID != "" && c.ClientSecret != "" }
+
+// Enabled reports whether a Matrix homeserver and token are configured
+func (c MatrixConfig) Enabled() bool { return c.Homeserver != "" && c.AccessToken != "" }
+
+// Enabled reports whether a Mattermost server and token are configured
+func (c MattermostConfig) Enabled() bool { return c.URL != "" && c.Token != "" }
+
+// Enabled reports whether a Rocket.Chat server and access token are configured
+func (c RocketChatConfig) Enabled() bool { return c.URL != "" && c.UserID != "" && c.Token != "" }
+
+// Enabled reports whether a Signal account is configured
+func (c SignalConfig) Enabled() bool { return c.Account != "" }
+
+// Enabled reports whether mail servers and a login are configured
+func (c EmailConfig) Enabled() bool {
+	return c.IMAPAddr != "" && c.SMTPAddr != "" && c.Username != "" && c.Password != ""
+}
+
+// ShutdownConfig configures graceful shutdown of the router
+type ShutdownConfig struct {
+	Timeout time.Duration `yaml:"timeout"` // How long in-flight messages may finish before users are asked to retry
+}
+
+// ControlConfig configures the local API used by "lingti-bot send"
+type ControlConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Socket  string `yaml:"socket"` // Unix socket path (default: control.sock in the config dir)
+	Listen  string `yaml:"listen"` // Optional TCP address, e.g. "127.0.0.1:8687"; requires token
+	Token   string `yaml:"token"`  // Bearer token for TCP requests
+}
+
+// WebhooksConfig configures the inbound webhook server
+type WebhooksConfig struct {
+	Listen string         `yaml:"listen"` // e.g. "127.0.0.1:8688"; empty disables webhooks
+	Routes []WebhookRoute `yaml:"routes"`
+}
+
+// APIConfig configures the OpenAI-compatible chat completions API
+type APIConfig struct {
+	Enabled bool     `yaml:"enabled"` // Serve the API from the router ("lingti-bot api" always does)
+	Listen  string   `yaml:"listen"`  // Default: 127.0.0.1:8689
+	Keys    []string `yaml:"keys"`    // Accepted API keys; at least one is required
+}
+
+// ReloadConfig controls applying bot.yaml changes to a running router
+type ReloadConfig struct {
+	Watch    bool          `yaml:"watch"`    // Reload when bot.yaml changes (SIGHUP always reloads)
+	Interval time.Duration `yaml:"interval"` // How often bot.yaml is checked for changes
+}
+
+// WebhookRoute maps a webhook path to an agent prompt or skill trigger
+type WebhookRoute struct {
+	Name     string   `yaml:"name"`
+	Path     string   `yaml:"path"`
+	Verify   string   `yaml:"verify"`   // github, gitlab, gitee, hmac, token or none
+	Secret   string   `yaml:"secret"`   // Shared secret used by verify
+	Events   []string `yaml:"events"`   // Only handle these events, e.g. "issues.opened"
+	Prompt   string   `yaml:"prompt"`   // Go template rendered with the payload and sent to the agent
+	Profile  string   `yaml:"profile"`  // Agent profile for the prompt; empty answers without tools
+	Trigger  string   `yaml:"trigger"`  // Run skills with this event trigger instead
+	Platform string   `yaml:"platform"` // Where to deliver the output
+	Channel  string   `yaml:"channel"`
+}
+
 func DefaultConfig() *Config {
 	return &Config{
 		Transport: "stdio",
 		Port:      8686,
+		Memory: MemoryConfig{
+			MaxMessages: 50,
+			TTL:         60 * time.Minute,
+		},
+		Voice: VoiceConfig{
+			Provider: "system",
+			Language: "zh",
+		},
+		Gateway: GatewayConfig{
+			Addr: ":18789",
+		},
 		Security: SecurityConfig{
 			AllowedPaths:        []string{},
 			BlockedCommands:     []string{"rm -rf /", "mkfs", "dd if="},
@@ -39,6 +360,42 @@ func DefaultConfig() *Config {
 			Level: "info",
 			File:  "/tmp/lingti-bot.log",
 		},
+		RateLimit: RateLimitConfig{
+			Enabled:       false,
+			User:          RateLimitRule{Rate: 10, Burst: 5},
+			Channel:       RateLimitRule{Rate: 30, Burst: 15},
+			Platform:      RateLimitRule{Rate: 120, Burst: 60},
+			Window:        time.Minute,
+			BlockAfter:    10,
+			BlockDuration: 10 * time.Minute,
+		},
+		Messages: MessagesConfig{
+			ChunkMarkers: true,
+		},
+		Dedup: DedupConfig{
+			Enabled: true,
+			TTL:     10 * time.Minute,
+			Persist: true,
+		},
+		Platforms: PlatformsConfig{
+			RetryMin:       5 * time.Second,
+			RetryMax:       5 * time.Minute,
+			HealthInterval: 30 * time.Second,
+			Matrix:         MatrixConfig{AutoJoin: true},
+		},
+		Shutdown: ShutdownConfig{
+			Timeout: 30 * time.Second,
+		},
+		Control: ControlConfig{
+			Enabled: true,
+		},
+		API: APIConfig{
+			Listen: "127.0.0.1:8689",
+		},
+		Reload: ReloadConfig{
+			Watch:    true,
+			Interval: 2 * time.Second,
+		},
 	}
 }
 
@@ -60,21 +417,62 @@ func ConfigPath() string {
 	return filepath.Join(ConfigDir(), "bot.yaml")
 }
 
+// DedupPath returns the file used to persist seen message IDs
+func DedupPath() string {
+	return filepath.Join(ConfigDir(), "dedup.json")
+}
+
+// ControlSocketPath returns the Unix socket of the control API, honouring bot.yaml
+func (c *Config) ControlSocketPath() string {
+	if c.Control.Socket != "" {
+		return c.Control.Socket
+	}
+	return filepath.Join(ConfigDir(), "control.sock")
+}
+
+// PromptPath resolves a prompt file setting against the config directory
+func PromptPath(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, rest)
+	}
+	return filepath.Join(ConfigDir(), path)
+}
+
+// PromptDir returns the directory of per-channel and per-user prompt files
+func (c *Config) PromptDir() string {
+	if c.Prompts.Dir != "" {
+		return PromptPath(c.Prompts.Dir)
+	}
+	return filepath.Join(ConfigDir(), "prompts")
+}
+
+// Load reads bot.yaml and applies environment variables on top. Command-line
+// flags are applied by the caller, followed by Finalize. A bot.yaml that other
+// users can read is refused if it contains credentials.
 func Load() (*Config, error) {
 	cfg := DefaultConfig()
 
 	data, err := os.ReadFile(ConfigPath())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
-		}
+	if err != nil && !os.IsNotExist(err) {
 		return nil, err
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, err
+	if err == nil {
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", ConfigPath(), err)
+		}
+		if err := cfg.checkPermissions(ConfigPath()); err != nil {
+			return nil, err
+		}
 	}
 
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
 
@@ -89,5 +487,11 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	return os.WriteFile(ConfigPath(), data, 0644)
+	// bot.yaml may hold credentials. WriteFile keeps the mode of an existing
+	// file, so tighten it explicitly.
+	path := ConfigPath()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
 }
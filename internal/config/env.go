@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// applyEnv overrides file settings with environment variables. Where several
+// variables are listed, the first one that is set wins; the later ones are
+// older names kept for compatibility.
+func (c *Config) applyEnv() error {
+	envString(&c.AI.Provider, "AI_PROVIDER")
+	envString(&c.AI.APIKey, "AI_API_KEY", "ANTHROPIC_API_KEY")
+	envString(&c.AI.BaseURL, "AI_BASE_URL", "ANTHROPIC_BASE_URL")
+	envString(&c.AI.Model, "AI_MODEL", "ANTHROPIC_MODEL")
+
+	p := &c.Platforms
+	envString(&p.Slack.BotToken, "SLACK_BOT_TOKEN")
+	envString(&p.Slack.AppToken, "SLACK_APP_TOKEN")
+	envString(&p.Feishu.AppID, "FEISHU_APP_ID")
+	envString(&p.Feishu.AppSecret, "FEISHU_APP_SECRET")
+	envString(&p.Feishu.Domain, "FEISHU_DOMAIN")
+	envString(&p.Feishu.Listen, "FEISHU_LISTEN")
+	envString(&p.Feishu.VerificationToken, "FEISHU_VERIFICATION_TOKEN")
+	envString(&p.Feishu.EncryptKey, "FEISHU_ENCRYPT_KEY")
+	envString(&p.Telegram.Token, "TELEGRAM_BOT_TOKEN")
+	envString(&p.Telegram.ParseMode, "TELEGRAM_PARSE_MODE")
+	envString(&p.Discord.Token, "DISCORD_BOT_TOKEN")
+	envString(&p.WeCom.CorpID, "WECOM_CORP_ID")
+	envString(&p.WeCom.AgentID, "WECOM_AGENT_ID")
+	envString(&p.WeCom.Secret, "WECOM_SECRET")
+	envString(&p.WeCom.Token, "WECOM_TOKEN")
+	envString(&p.WeCom.AESKey, "WECOM_AES_KEY")
+	if err := envInt(&p.WeCom.Port, "WECOM_PORT"); err != nil {
+		return err
+	}
+	envString(&p.WeCom.MsgType, "WECOM_MSG_TYPE")
+	envString(&p.WeCom.WebhookURL, "WECOM_WEBHOOK_URL")
+	envString(&p.DingTalk.ClientID, "DINGTALK_CLIENT_ID")
+	envString(&p.DingTalk.ClientSecret, "DINGTALK_CLIENT_SECRET")
+	envString(&p.DingTalk.RobotCode, "DINGTALK_ROBOT_CODE")
+	envString(&p.DingTalk.CardTemplateID, "DINGTALK_CARD_TEMPLATE_ID")
+	envString(&p.Matrix.Homeserver, "MATRIX_HOMESERVER")
+	envString(&p.Matrix.AccessToken, "MATRIX_ACCESS_TOKEN")
+	envString(&p.Matrix.UserID, "MATRIX_USER_ID")
+	envString(&p.Mattermost.URL, "MATTERMOST_URL")
+	envString(&p.Mattermost.Token, "MATTERMOST_TOKEN")
+	envString(&p.RocketChat.URL, "ROCKETCHAT_URL")
+	envString(&p.RocketChat.UserID, "ROCKETCHAT_USER_ID")
+	envString(&p.RocketChat.Token, "ROCKETCHAT_TOKEN")
+	envString(&p.Signal.Account, "SIGNAL_ACCOUNT")
+	envString(&p.Signal.Socket, "SIGNAL_SOCKET")
+	envString(&p.Email.IMAPAddr, "EMAIL_IMAP_ADDR")
+	envString(&p.Email.SMTPAddr, "EMAIL_SMTP_ADDR")
+	envString(&p.Email.Username, "EMAIL_USERNAME")
+	envString(&p.Email.Password, "EMAIL_PASSWORD")
+	envString(&p.Email.Address, "EMAIL_ADDRESS")
+	envList(&p.Email.AllowedSenders, "EMAIL_ALLOWED_SENDERS")
+
+	envString(&c.Voice.Provider, "VOICE_PROVIDER")
+	envString(&c.Voice.APIKey, "VOICE_API_KEY")
+	envString(&c.Voice.STTProvider, "VOICE_STT_PROVIDER")
+	envString(&c.Voice.STTAPIKey, "VOICE_STT_API_KEY")
+	envString(&c.Voice.WakeWord, "WAKE_WORD")
+
+	envString(&c.Gateway.Addr, "GATEWAY_ADDR")
+	envString(&c.Gateway.AuthToken, "GATEWAY_AUTH_TOKEN")
+
+	envString(&c.API.Listen, "API_LISTEN")
+	envList(&c.API.Keys, "API_KEYS")
+
+	envString(&c.Relay.UserID, "RELAY_USER_ID")
+	envString(&c.Relay.Platform, "RELAY_PLATFORM")
+	envString(&c.Relay.ServerURL, "RELAY_SERVER_URL")
+	envString(&c.Relay.WebhookURL, "RELAY_WEBHOOK_URL")
+	return nil
+}
+
+func envString(dst *string, keys ...string) {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+			return
+		}
+	}
+}
+
+// envList reads a comma-separated list
+func envList(dst *[]string, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*dst = list
+}
+
+func envInt(dst *int, key string) error {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a number", key, v)
+	}
+	*dst = n
+	return nil
+}
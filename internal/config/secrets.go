@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/secrets"
+)
+
+// SecretsPath returns the encrypted secret store
+func SecretsPath() string {
+	return filepath.Join(ConfigDir(), "secrets.enc")
+}
+
+// secretField is a credential setting and its bot.yaml path
+type secretField struct {
+	path  string
+	value *string
+}
+
+// secretFields lists the settings that hold credentials. These may refer to
+// the secret store as "secret://name".
+func (c *Config) secretFields() []secretField {
+	p := &c.Platforms
+	fields := []secretField{
+		{"ai.api_key", &c.AI.APIKey},
+		{"voice.api_key", &c.Voice.APIKey},
+		{"voice.stt_api_key", &c.Voice.STTAPIKey},
+		{"gateway.auth_token", &c.Gateway.AuthToken},
+		{"control.token", &c.Control.Token},
+		{"platforms.slack.bot_token", &p.Slack.BotToken},
+		{"platforms.slack.app_token", &p.Slack.AppToken},
+		{"platforms.feishu.app_secret", &p.Feishu.AppSecret},
+		{"platforms.feishu.verification_token", &p.Feishu.VerificationToken},
+		{"platforms.feishu.encrypt_key", &p.Feishu.EncryptKey},
+		{"platforms.telegram.token", &p.Telegram.Token},
+		{"platforms.discord.token", &p.Discord.Token},
+		{"platforms.wecom.secret", &p.WeCom.Secret},
+		{"platforms.wecom.token", &p.WeCom.Token},
+		{"platforms.wecom.aes_key", &p.WeCom.AESKey},
+		{"platforms.wecom.webhook_url", &p.WeCom.WebhookURL},
+		{"platforms.dingtalk.client_secret", &p.DingTalk.ClientSecret},
+		{"platforms.matrix.access_token", &p.Matrix.AccessToken},
+		{"platforms.mattermost.token", &p.Mattermost.Token},
+		{"platforms.rocketchat.token", &p.RocketChat.Token},
+		{"platforms.email.password", &p.Email.Password},
+	}
+	for i := range c.Agents.Profiles {
+		fields = append(fields, secretField{fmt.Sprintf("agents.profiles[%d].api_key", i), &c.Agents.Profiles[i].APIKey})
+	}
+	for i := range c.API.Keys {
+		fields = append(fields, secretField{fmt.Sprintf("api.keys[%d]", i), &c.API.Keys[i]})
+	}
+	for i := range c.Webhooks.Routes {
+		fields = append(fields, secretField{fmt.Sprintf("webhooks.routes[%d].secret", i), &c.Webhooks.Routes[i].Secret})
+	}
+	return fields
+}
+
+// ResolveSecrets replaces "secret://name" credentials with values from the
+// secret store. The store is only opened if something refers to it.
+func (c *Config) ResolveSecrets() error {
+	var store *secrets.Store
+	var errs []error
+
+	for _, field := range c.secretFields() {
+		name, ok := secrets.Ref(*field.value)
+		if !ok {
+			continue
+		}
+		if store == nil {
+			s, err := secrets.OpenDefault(SecretsPath())
+			if err != nil {
+				return fmt.Errorf("secret store: %w", err)
+			}
+			store = s
+		}
+
+		value, err := store.Get(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field.path, err))
+			continue
+		}
+		*field.value = value
+	}
+	return errors.Join(errs...)
+}
+
+// checkPermissions refuses a bot.yaml that other users can read while it
+// holds credentials in plain text. c must only contain the file's settings.
+func (c *Config) checkPermissions(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0o004 == 0 {
+		return nil
+	}
+
+	var plain []string
+	for _, field := range c.secretFields() {
+		if _, ref := secrets.Ref(*field.value); *field.value != "" && !ref {
+			plain = append(plain, field.path)
+		}
+	}
+	if len(plain) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s is readable by other users but contains %s; run 'chmod 600 %s' or move them to the secret store with 'lingti-bot secrets set'",
+		path, strings.Join(plain, ", "), path)
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// Finalize resolves secret store references, fills in settings that depend
+// on others (such as provider-specific API key variables) and validates the
+// configuration. It is called once file, environment and command-line
+// settings have been merged.
+func (c *Config) Finalize() error {
+	if err := c.ResolveSecrets(); err != nil {
+		return err
+	}
+
+	if c.Voice.APIKey == "" {
+		switch c.Voice.Provider {
+		case "openai":
+			envString(&c.Voice.APIKey, "OPENAI_API_KEY")
+		case "elevenlabs":
+			envString(&c.Voice.APIKey, "ELEVENLABS_API_KEY")
+		}
+	}
+	if c.Voice.STTAPIKey == "" && c.Voice.STTProvider == "openai" {
+		envString(&c.Voice.STTAPIKey, "OPENAI_API_KEY")
+	}
+
+	return c.Validate()
+}
+
+// Validate reports every invalid setting, each prefixed with its bot.yaml path
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(path, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+
+	switch strings.ToLower(c.AI.Provider) {
+	case "", "claude", "anthropic", "deepseek", "kimi", "moonshot":
+	default:
+		fail("ai.provider", "unknown provider %q (supported: claude, deepseek, kimi)", c.AI.Provider)
+	}
+
+	if c.Memory.MaxMessages < 0 {
+		fail("memory.max_messages", "must not be negative")
+	}
+	if c.Memory.TTL < 0 {
+		fail("memory.ttl", "must not be negative")
+	}
+
+	c.validateAgents(fail)
+
+	switch c.Voice.Provider {
+	case "", "system", "openai", "elevenlabs":
+	default:
+		fail("voice.provider", "unknown provider %q (supported: system, openai, elevenlabs)", c.Voice.Provider)
+	}
+	switch c.Voice.STTProvider {
+	case "", "system", "openai":
+	default:
+		fail("voice.stt_provider", "unknown provider %q (supported: system, openai)", c.Voice.STTProvider)
+	}
+
+	p := c.Platforms
+	if (p.Slack.BotToken == "") != (p.Slack.AppToken == "") {
+		fail("platforms.slack", "bot_token and app_token must be set together")
+	}
+	if (p.Feishu.AppID == "") != (p.Feishu.AppSecret == "") {
+		fail("platforms.feishu", "app_id and app_secret must be set together")
+	}
+	switch d := p.Feishu.Domain; {
+	case d == "", d == "feishu", d == "lark":
+	case strings.HasPrefix(d, "https://"), strings.HasPrefix(d, "http://"):
+	default:
+		fail("platforms.feishu.domain", "must be feishu, lark or an http(s) URL, got %q", d)
+	}
+	if p.Feishu.Listen != "" && p.Feishu.VerificationToken == "" {
+		fail("platforms.feishu.verification_token", "required when listen is set")
+	}
+	switch strings.ToLower(p.Telegram.ParseMode) {
+	case "", "html", "markdownv2", "plain":
+	default:
+		fail("platforms.telegram.parse_mode", "must be HTML, MarkdownV2 or plain, got %q", p.Telegram.ParseMode)
+	}
+	if missing := p.WeCom.missing(); len(missing) > 0 && len(missing) < 5 {
+		fail("platforms.wecom", "missing %s", strings.Join(missing, ", "))
+	}
+	if p.WeCom.Port < 0 || p.WeCom.Port > 65535 {
+		fail("platforms.wecom.port", "%d is not a valid port", p.WeCom.Port)
+	}
+	switch p.WeCom.MsgType {
+	case "", "markdown", "text":
+	default:
+		fail("platforms.wecom.msg_type", "must be markdown or text, got %q", p.WeCom.MsgType)
+	}
+	if u := p.WeCom.WebhookURL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		fail("platforms.wecom.webhook_url", "must be an http(s) URL, got %q", u)
+	}
+	if (p.DingTalk.ClientID == "") != (p.DingTalk.ClientSecret == "") {
+		fail("platforms.dingtalk", "client_id and client_secret must be set together")
+	}
+	if (p.Matrix.Homeserver == "") != (p.Matrix.AccessToken == "") {
+		fail("platforms.matrix", "homeserver and access_token must be set together")
+	}
+	if h := p.Matrix.Homeserver; h != "" && !strings.HasPrefix(h, "https://") && !strings.HasPrefix(h, "http://") {
+		fail("platforms.matrix.homeserver", "must be an http(s) URL, got %q", h)
+	}
+	if (p.Mattermost.URL == "") != (p.Mattermost.Token == "") {
+		fail("platforms.mattermost", "url and token must be set together")
+	}
+	if u := p.Mattermost.URL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		fail("platforms.mattermost.url", "must be an http(s) URL, got %q", u)
+	}
+	if rc := p.RocketChat; (rc.URL != "" || rc.UserID != "" || rc.Token != "") && !rc.Enabled() {
+		fail("platforms.rocketchat", "url, user_id and token must be set together")
+	}
+	if u := p.RocketChat.URL; u != "" && !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		fail("platforms.rocketchat.url", "must be an http(s) URL, got %q", u)
+	}
+	if a := p.Signal.Account; a != "" && !strings.HasPrefix(a, "+") {
+		fail("platforms.signal.account", "must be a phone number in international format, e.g. +8613800000000")
+	}
+	p.Email.validate(fail)
+	if p.RetryMin < 0 || p.RetryMax < 0 || p.HealthInterval < 0 {
+		fail("platforms", "retry_min, retry_max and health_interval must not be negative")
+	}
+	if p.MaxAttempts < 0 {
+		fail("platforms.max_attempts", "must not be negative")
+	}
+
+	if rl := c.RateLimit; rl.Enabled && rl.BlockAfter > 0 && len(c.Security.Admins) == 0 {
+		fail("rate_limit.block_after", "blocking needs at least one security.admins entry to lift blocks (set 0 to disable blocking)")
+	}
+
+	switch c.Relay.Platform {
+	case "", "feishu", "slack", "wechat", "wecom":
+	default:
+		fail("relay.platform", "must be feishu, slack, wechat or wecom, got %q", c.Relay.Platform)
+	}
+
+	if c.Shutdown.Timeout < 0 {
+		fail("shutdown.timeout", "must not be negative")
+	}
+	if c.Control.Listen != "" && c.Control.Token == "" {
+		fail("control.token", "required when control.listen is set")
+	}
+	if c.API.Enabled && len(c.API.Keys) == 0 {
+		fail("api.keys", "at least one key is required when the API is enabled")
+	}
+
+	if c.Reload.Interval < 0 {
+		fail("reload.interval", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateAgents checks agent profiles and the routes that refer to them
+func (c *Config) validateAgents(fail func(path, format string, args ...any)) {
+	names := map[string]bool{"default": true}
+	for i, p := range c.Agents.Profiles {
+		prefix := fmt.Sprintf("agents.profiles[%d]", i)
+		switch {
+		case p.Name == "":
+			fail(prefix+".name", "required")
+		case p.Name == "default":
+			fail(prefix+".name", "\"default\" is reserved for the ai section")
+		case names[p.Name]:
+			fail(prefix+".name", "duplicate profile %q", p.Name)
+		}
+		names[p.Name] = true
+
+		switch strings.ToLower(p.Provider) {
+		case "", "claude", "anthropic", "deepseek", "kimi", "moonshot":
+		default:
+			fail(prefix+".provider", "unknown provider %q (supported: claude, deepseek, kimi)", p.Provider)
+		}
+		if p.SystemPrompt != "" && p.SystemPromptFile != "" {
+			fail(prefix, "set system_prompt or system_prompt_file, not both")
+		}
+		for _, tool := range p.Tools {
+			if _, err := path.Match(tool, ""); err != nil {
+				fail(prefix+".tools", "bad pattern %q", tool)
+			}
+		}
+		if p.Memory.MaxMessages < 0 || p.Memory.TTL < 0 {
+			fail(prefix+".memory", "max_messages and ttl must not be negative")
+		}
+	}
+
+	for i, r := range c.Agents.Routes {
+		path := fmt.Sprintf("agents.routes[%d]", i)
+		if r.Profile == "" {
+			fail(path+".profile", "required")
+		} else if !names[r.Profile] {
+			fail(path+".profile", "unknown profile %q", r.Profile)
+		}
+	}
+
+	for i, r := range c.Webhooks.Routes {
+		if r.Profile != "" && !names[r.Profile] {
+			fail(fmt.Sprintf("webhooks.routes[%d].profile", i), "unknown profile %q", r.Profile)
+		}
+	}
+}
+
+// missing returns the names of unset WeCom credentials
+func (c WeComConfig) missing() []string {
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"corp_id", c.CorpID},
+		{"agent_id", c.AgentID},
+		{"secret", c.Secret},
+		{"token", c.Token},
+		{"aes_key", c.AESKey},
+	} {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	return missing
+}
+
+// validate checks the email settings once any of them is set
+func (e EmailConfig) validate(fail func(path, format string, args ...any)) {
+	if e.IMAPAddr == "" && e.SMTPAddr == "" && e.Username == "" && e.Password == "" {
+		return
+	}
+	if !e.Enabled() {
+		fail("platforms.email", "imap_addr, smtp_addr, username and password must be set together")
+	}
+	for _, field := range []struct{ name, addr string }{
+		{"imap_addr", e.IMAPAddr},
+		{"smtp_addr", e.SMTPAddr},
+	} {
+		if _, _, err := net.SplitHostPort(field.addr); field.addr != "" && err != nil {
+			fail("platforms.email."+field.name, "must be host:port, got %q", field.addr)
+		}
+	}
+	if len(e.AllowedSenders) == 0 {
+		fail("platforms.email.allowed_senders", "must list at least one address or @domain")
+	}
+	if e.PollInterval < 0 {
+		fail("platforms.email.poll_interval", "must not be negative")
+	}
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"time"
+)
+
+// Watch polls bot.yaml and calls onChange whenever its contents change,
+// including when it is created or removed. It returns when ctx is done.
+func Watch(ctx context.Context, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	last := fileDigest(ConfigPath())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			digest := fileDigest(ConfigPath())
+			if digest != last {
+				last = digest
+				onChange()
+			}
+		}
+	}
+}
+
+// fileDigest hashes a file's contents; a missing file hashes to zero
+func fileDigest(path string) [sha256.Size]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(data)
+}
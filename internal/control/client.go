@@ -0,0 +1,66 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// Client talks to a running router over its Unix socket
+type Client struct {
+	socket     string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the control socket at path
+func NewClient(path string) *Client {
+	return &Client{
+		socket: path,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Send asks the router to send a message and returns the sent message ID
+func (c *Client) Send(ctx context.Context, req SendRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// The host is ignored; requests go to the socket
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://lingti-bot/v1/send", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ECONNREFUSED) {
+			return "", fmt.Errorf("router is not running (no control socket at %s)", c.socket)
+		}
+		return "", err
+	}
+	defer httpResp.Body.Close()
+
+	var resp SendResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s", resp.Error)
+	}
+	return resp.MessageID, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package control
+
+import "os"
+
+// checkOwner is a no-op where file ownership isn't a uid
+func checkOwner(path string, info os.FileInfo) error {
+	return nil
+}
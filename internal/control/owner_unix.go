@@ -0,0 +1,21 @@
+//go:build unix
+
+package control
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkOwner reports an error if the current user doesn't own the file
+func checkOwner(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if uid := os.Getuid(); int(stat.Uid) != uid {
+		return fmt.Errorf("%s is owned by uid %d, not the current user (%d)", path, stat.Uid, uid)
+	}
+	return nil
+}
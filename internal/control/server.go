@@ -0,0 +1,223 @@
+// Package control provides the local API of a running router, used by
+// "lingti-bot send" and scripts to push messages to platforms.
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// maxRequestBody caps the size of a send request
+const maxRequestBody = 1 << 20
+
+// Sender delivers outbound messages; implemented by *router.Router
+type Sender interface {
+	SendTo(ctx context.Context, platform, channelID string, resp router.Response) (string, error)
+}
+
+// SendRequest is the body of POST /v1/send
+type SendRequest struct {
+	Platform string `json:"platform"`
+	Channel  string `json:"channel"`
+	Text     string `json:"text"`
+	ThreadID string `json:"thread_id,omitempty"`
+}
+
+// SendResponse is returned by POST /v1/send
+type SendResponse struct {
+	MessageID string `json:"message_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Config configures the control server
+type Config struct {
+	Socket string // Unix socket path; access is limited by file permissions
+	Listen string // Optional TCP address
+	Token  string // Bearer token required on the TCP listener
+}
+
+// Server serves the control API on a Unix socket and, optionally, a TCP address
+type Server struct {
+	config  Config
+	sender  Sender
+	servers []*http.Server
+}
+
+// NewServer creates a control server
+func NewServer(cfg Config, sender Sender) (*Server, error) {
+	if cfg.Socket == "" && cfg.Listen == "" {
+		return nil, fmt.Errorf("a socket path or listen address is required")
+	}
+	if cfg.Listen != "" && cfg.Token == "" {
+		return nil, fmt.Errorf("a token is required to listen on %s", cfg.Listen)
+	}
+	return &Server{config: cfg, sender: sender}, nil
+}
+
+// Start opens the listeners and serves requests in the background
+func (s *Server) Start() error {
+	if s.config.Socket != "" {
+		ln, err := listenUnix(s.config.Socket)
+		if err != nil {
+			return err
+		}
+		s.serve(ln, s.routes(false))
+		logger.Info("[Control] Listening on %s", s.config.Socket)
+	}
+
+	if s.config.Listen != "" {
+		ln, err := net.Listen("tcp", s.config.Listen)
+		if err != nil {
+			s.Stop(context.Background())
+			return fmt.Errorf("failed to listen on %s: %w", s.config.Listen, err)
+		}
+		s.serve(ln, s.routes(true))
+		logger.Info("[Control] Listening on %s", s.config.Listen)
+	}
+	return nil
+}
+
+// Stop closes the listeners and waits for active requests
+func (s *Server) Stop(ctx context.Context) error {
+	var errs []error
+	for _, srv := range s.servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.servers = nil
+	if s.config.Socket != "" {
+		os.Remove(s.config.Socket)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) serve(ln net.Listener, handler http.Handler) {
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	s.servers = append(s.servers, srv)
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("[Control] Server error: %v", err)
+		}
+	}()
+}
+
+func (s *Server) routes(requireToken bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/send", s.handleSend)
+	if !requireToken {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, SendResponse{Error: "unauthorized"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// handleSend handles POST /v1/send
+func (s *Server) handleSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, SendResponse{Error: "method not allowed"})
+		return
+	}
+
+	var req SendRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, SendResponse{Error: "invalid request: " + err.Error()})
+		return
+	}
+	if req.Platform == "" || req.Channel == "" || strings.TrimSpace(req.Text) == "" {
+		writeJSON(w, http.StatusBadRequest, SendResponse{Error: "platform, channel and text are required"})
+		return
+	}
+
+	id, err := s.sender.SendTo(r.Context(), req.Platform, req.Channel, router.Response{
+		Text:     req.Text,
+		ThreadID: req.ThreadID,
+	})
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, router.ErrUnknownPlatform) {
+			status = http.StatusNotFound
+		}
+		logger.Error("[Control] Send to %s/%s failed: %v", req.Platform, req.Channel, err)
+		writeJSON(w, status, SendResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SendResponse{MessageID: id})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// listenUnix listens on a Unix socket readable only by the current user,
+// replacing a stale socket left by a previous run. The socket is created in
+// a private directory and moved into place, so it is never reachable with
+// the process umask's permissions.
+func listenUnix(path string) (net.Listener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return nil, fmt.Errorf("%s is writable by other users; the control socket needs a private directory", dir)
+	}
+	if err := checkOwner(dir, info); err != nil {
+		return nil, fmt.Errorf("the control socket needs a private directory: %w", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("another instance is already listening on %s", path)
+		}
+		os.Remove(path)
+	}
+
+	tmpDir, err := os.MkdirTemp(dir, ".control-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tmp := filepath.Join(tmpDir, "sock")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// The socket file is moved, so Stop removes it rather than Close
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	return ln, nil
+}
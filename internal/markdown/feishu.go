@@ -0,0 +1,249 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// FeishuElement is an element of a Feishu rich text (post) paragraph
+type FeishuElement map[string]any
+
+// FeishuPost renders Markdown as the content of a Feishu "post" message.
+// The result marshals to {"zh_cn": {"title": ..., "content": [[...], ...]}}.
+func FeishuPost(src, title string) map[string]any {
+	doc, source := Parse(src)
+	fw := &feishuWriter{source: source}
+	fw.blocks(doc, "")
+
+	// Drop a trailing empty paragraph
+	for len(fw.lines) > 0 && len(fw.lines[len(fw.lines)-1]) == 0 {
+		fw.lines = fw.lines[:len(fw.lines)-1]
+	}
+
+	return map[string]any{
+		"zh_cn": map[string]any{
+			"title":   title,
+			"content": fw.lines,
+		},
+	}
+}
+
+type feishuWriter struct {
+	source []byte
+	lines  [][]FeishuElement
+}
+
+// newLine starts a new paragraph line, optionally prefixed with text
+func (fw *feishuWriter) newLine(prefix string) {
+	line := []FeishuElement{}
+	if prefix != "" {
+		line = append(line, FeishuElement{"tag": "text", "text": prefix})
+	}
+	fw.lines = append(fw.lines, line)
+}
+
+func (fw *feishuWriter) add(el FeishuElement) {
+	if len(fw.lines) == 0 {
+		fw.newLine("")
+	}
+	fw.lines[len(fw.lines)-1] = append(fw.lines[len(fw.lines)-1], el)
+}
+
+func (fw *feishuWriter) blocks(n ast.Node, prefix string) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		fw.block(c, prefix)
+	}
+}
+
+func (fw *feishuWriter) block(n ast.Node, prefix string) {
+	switch node := n.(type) {
+	case *ast.Paragraph, *ast.TextBlock:
+		fw.newLine(prefix)
+		fw.inlines(node, nil, prefix)
+
+	case *ast.Heading:
+		fw.newLine(prefix)
+		fw.inlines(node, []string{"bold"}, prefix)
+
+	case *ast.ThematicBreak:
+		fw.lines = append(fw.lines, []FeishuElement{{"tag": "hr"}})
+
+	case *ast.FencedCodeBlock:
+		fw.codeBlock(string(node.Language(fw.source)), fw.raw(node))
+
+	case *ast.CodeBlock, *ast.HTMLBlock:
+		fw.codeBlock("", fw.raw(node))
+
+	case *ast.Blockquote:
+		fw.blocks(node, prefix+"> ")
+
+	case *ast.List:
+		num := node.Start
+		if num == 0 {
+			num = 1
+		}
+		for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+			marker := "• "
+			if node.IsOrdered() {
+				marker = strconv.Itoa(num) + ". "
+				num++
+			}
+			first := true
+			for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+				if first {
+					fw.block(c, prefix+marker)
+					first = false
+					continue
+				}
+				fw.block(c, prefix+"    ")
+			}
+		}
+
+	case *extast.Table:
+		w := &walker{source: fw.source}
+		fw.codeBlock("", w.table(node))
+
+	default:
+		fw.blocks(node, prefix)
+	}
+}
+
+func (fw *feishuWriter) codeBlock(lang, code string) {
+	fw.lines = append(fw.lines, []FeishuElement{{
+		"tag":      "code_block",
+		"language": strings.ToUpper(orDefault(lang, "plain_text")),
+		"text":     strings.TrimRight(code, "\n"),
+	}})
+}
+
+func (fw *feishuWriter) raw(n ast.Node) string {
+	return (&walker{source: fw.source}).lines(n)
+}
+
+// inlines emits text and link elements; soft and hard breaks start a new line
+func (fw *feishuWriter) inlines(n ast.Node, styles []string, prefix string) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch node := c.(type) {
+		case *ast.Text:
+			fw.text(textValue(node, fw.source), styles)
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				fw.newLine(strings.Repeat(" ", len([]rune(prefix))))
+			}
+
+		case *ast.String:
+			fw.text(string(node.Value), styles)
+
+		case *ast.CodeSpan:
+			fw.text(plainInline(node, fw.source), appendStyle(styles, "bold"))
+
+		case *ast.Emphasis:
+			st := "italic"
+			if node.Level >= 2 {
+				st = "bold"
+			}
+			fw.inlines(node, appendStyle(styles, st), prefix)
+
+		case *extast.Strikethrough:
+			fw.inlines(node, appendStyle(styles, "lineThrough"), prefix)
+
+		case *ast.Link:
+			fw.add(FeishuElement{"tag": "a", "text": plainInline(node, fw.source), "href": string(node.Destination)})
+
+		case *ast.AutoLink:
+			url := string(node.URL(fw.source))
+			fw.add(FeishuElement{"tag": "a", "text": string(node.Label(fw.source)), "href": url})
+
+		case *ast.Image:
+			url := string(node.Destination)
+			fw.add(FeishuElement{"tag": "a", "text": orDefault(plainInline(node, fw.source), url), "href": url})
+
+		default:
+			fw.inlines(node, styles, prefix)
+		}
+	}
+}
+
+func (fw *feishuWriter) text(s string, styles []string) {
+	if s == "" {
+		return
+	}
+	el := FeishuElement{"tag": "text", "text": s}
+	if len(styles) > 0 {
+		el["style"] = styles
+	}
+	fw.add(el)
+}
+
+func appendStyle(styles []string, st string) []string {
+	out := make([]string, 0, len(styles)+1)
+	out = append(out, styles...)
+	return append(out, st)
+}
+
+// FeishuCard renders Markdown as a Feishu message card: an optional header
+// with the title and a "markdown" element per section, split at thematic
+// breaks. Cards are marked update_multi so they can be patched later.
+func FeishuCard(src, title string) map[string]any {
+	doc, source := Parse(src)
+	w := &walker{st: feishuCardStyle, source: source}
+
+	elements := []FeishuElement{}
+	var parts []string
+	flush := func() {
+		if len(parts) > 0 {
+			elements = append(elements, FeishuElement{"tag": "markdown", "content": strings.Join(parts, "\n\n")})
+			parts = nil
+		}
+	}
+	for c := doc.FirstChild(); c != nil; c = c.NextSibling() {
+		if _, ok := c.(*ast.ThematicBreak); ok {
+			flush()
+			elements = append(elements, FeishuElement{"tag": "hr"})
+			continue
+		}
+		if s := strings.TrimSpace(w.block(c)); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	flush()
+
+	card := map[string]any{
+		"config":   map[string]any{"wide_screen_mode": true, "update_multi": true},
+		"elements": elements,
+	}
+	if title != "" {
+		card["header"] = map[string]any{
+			"title":    map[string]any{"tag": "plain_text", "content": title},
+			"template": "blue",
+		}
+	}
+	return card
+}
+
+// feishuCardStyle targets the Markdown subset of card "markdown" elements
+var feishuCardStyle = style{
+	escape: escapeFeishuCard,
+	bold:   wrap("**", "**"),
+	italic: wrap("*", "*"),
+	strike: wrap("~~", "~~"),
+	code:   func(code string) string { return "`" + escapeFeishuCard(code) + "`" },
+	codeBlock: func(lang, code string) string {
+		return "```" + lang + "\n" + code + "\n```"
+	},
+	link:    func(label, url string) string { return "[" + label + "](" + url + ")" },
+	image:   func(alt, url string) string { return "[" + escapeFeishuCard(orDefault(alt, url)) + "](" + url + ")" },
+	heading: func(level int, inner string) string { return "**" + inner + "**" },
+	quote:   prefixLines("> "),
+	rule:    "———",
+	bullet:  "-",
+}
+
+// Card markdown reads <at>, <font> and similar tags, so text escapes them as entities
+var feishuCardEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeFeishuCard(s string) string {
+	return feishuCardEscaper.Replace(s)
+}
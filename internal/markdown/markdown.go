@@ -0,0 +1,366 @@
+// Package markdown renders the CommonMark emitted by the agent into the
+// formatting dialect each messaging platform understands.
+package markdown
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.Strikethrough, extension.Table, extension.Linkify),
+)
+
+var parser = md.Parser()
+
+// Parse parses CommonMark (with GFM strikethrough, tables and autolinks) into an AST
+func Parse(src string) (ast.Node, []byte) {
+	source := []byte(src)
+	return parser.Parse(text.NewReader(source)), source
+}
+
+// style describes how a dialect renders each Markdown construct.
+// Unset functions fall back to emitting the inner content unchanged.
+type style struct {
+	escape     func(s string) string
+	bold       func(inner string) string
+	italic     func(inner string) string
+	strike     func(inner string) string
+	code       func(code string) string
+	codeBlock  func(lang, code string) string
+	link       func(label, url string) string
+	image      func(alt, url string) string
+	heading    func(level int, inner string) string
+	quote      func(inner string) string
+	hardBreak  string
+	rule       string
+	blockSep   string
+	bullet     string
+	listIndent string
+}
+
+// render walks the AST and renders it using a style
+func render(src string, st style) string {
+	doc, source := Parse(src)
+	r := &walker{st: st, source: source}
+	return strings.TrimSpace(r.blocks(doc))
+}
+
+type walker struct {
+	st     style
+	source []byte
+}
+
+// blocks renders the block-level children of n separated by the style's block separator
+func (w *walker) blocks(n ast.Node) string {
+	var parts []string
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		if s := w.block(c); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, w.blockSep())
+}
+
+func (w *walker) blockSep() string {
+	if w.st.blockSep != "" {
+		return w.st.blockSep
+	}
+	return "\n\n"
+}
+
+func (w *walker) block(n ast.Node) string {
+	switch node := n.(type) {
+	case *ast.Paragraph, *ast.TextBlock:
+		return w.inlines(node)
+
+	case *ast.Heading:
+		inner := w.inlines(node)
+		if w.st.heading != nil {
+			return w.st.heading(node.Level, inner)
+		}
+		return inner
+
+	case *ast.ThematicBreak:
+		return w.st.rule
+
+	case *ast.FencedCodeBlock:
+		return w.codeBlock(string(node.Language(w.source)), w.lines(node))
+
+	case *ast.CodeBlock:
+		return w.codeBlock("", w.lines(node))
+
+	case *ast.HTMLBlock:
+		return w.escape(strings.TrimRight(w.lines(node), "\n"))
+
+	case *ast.Blockquote:
+		inner := w.blocks(node)
+		if w.st.quote != nil {
+			return w.st.quote(inner)
+		}
+		return inner
+
+	case *ast.List:
+		return w.list(node)
+
+	case *extast.Table:
+		return w.table(node)
+
+	default:
+		return w.blocks(node)
+	}
+}
+
+func (w *walker) codeBlock(lang, code string) string {
+	code = strings.TrimRight(code, "\n")
+	if w.st.codeBlock != nil {
+		return w.st.codeBlock(lang, code)
+	}
+	return code
+}
+
+// list renders a list; nested lists are indented under their parent item
+func (w *walker) list(node *ast.List) string {
+	var items []string
+	num := node.Start
+	if num == 0 {
+		num = 1
+	}
+
+	for item := node.FirstChild(); item != nil; item = item.NextSibling() {
+		marker := w.bullet()
+		if node.IsOrdered() {
+			marker = strconv.Itoa(num) + "."
+			num++
+		}
+
+		var lines []string
+		for c := item.FirstChild(); c != nil; c = c.NextSibling() {
+			if s := w.block(c); s != "" {
+				lines = append(lines, s)
+			}
+		}
+
+		body := strings.ReplaceAll(strings.Join(lines, "\n"), "\n", "\n"+w.listIndent())
+		items = append(items, w.escape(marker)+" "+body)
+	}
+	return strings.Join(items, "\n")
+}
+
+func (w *walker) bullet() string {
+	if w.st.bullet != "" {
+		return w.st.bullet
+	}
+	return "•"
+}
+
+func (w *walker) listIndent() string {
+	if w.st.listIndent != "" {
+		return w.st.listIndent
+	}
+	return "  "
+}
+
+// table renders a GFM table as aligned plain rows inside a code block
+func (w *walker) table(node *extast.Table) string {
+	var rows [][]string
+	for row := node.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, plainInline(cell, w.source))
+		}
+		rows = append(rows, cells)
+	}
+
+	widths := map[int]int{}
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := displayWidth(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var lines []string
+	for r, row := range rows {
+		var cells []string
+		for i, cell := range row {
+			cells = append(cells, cell+strings.Repeat(" ", widths[i]-displayWidth(cell)))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, " | "), " "))
+		if r == 0 {
+			var seps []string
+			for i := range row {
+				seps = append(seps, strings.Repeat("-", widths[i]))
+			}
+			lines = append(lines, strings.Join(seps, "-|-"))
+		}
+	}
+	return w.codeBlock("", strings.Join(lines, "\n"))
+}
+
+// lines returns the raw source lines of a block node
+func (w *walker) lines(n ast.Node) string {
+	var b strings.Builder
+	segs := n.Lines()
+	for i := 0; i < segs.Len(); i++ {
+		seg := segs.At(i)
+		b.Write(seg.Value(w.source))
+	}
+	return b.String()
+}
+
+// inlines renders the inline children of n
+func (w *walker) inlines(n ast.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		b.WriteString(w.inline(c))
+	}
+	return b.String()
+}
+
+func (w *walker) inline(n ast.Node) string {
+	switch node := n.(type) {
+	case *ast.Text:
+		s := w.escape(textValue(node, w.source))
+		if node.HardLineBreak() {
+			if w.st.hardBreak != "" {
+				return s + w.st.hardBreak
+			}
+			return s + "\n"
+		}
+		if node.SoftLineBreak() {
+			return s + "\n"
+		}
+		return s
+
+	case *ast.String:
+		return w.escape(string(node.Value))
+
+	case *ast.CodeSpan:
+		code := plainInline(node, w.source)
+		if w.st.code != nil {
+			return w.st.code(code)
+		}
+		return code
+
+	case *ast.Emphasis:
+		inner := w.inlines(node)
+		if node.Level >= 2 && w.st.bold != nil {
+			return w.st.bold(inner)
+		}
+		if node.Level == 1 && w.st.italic != nil {
+			return w.st.italic(inner)
+		}
+		return inner
+
+	case *extast.Strikethrough:
+		inner := w.inlines(node)
+		if w.st.strike != nil {
+			return w.st.strike(inner)
+		}
+		return inner
+
+	case *ast.Link:
+		label := w.inlines(node)
+		if w.st.link != nil {
+			return w.st.link(label, string(node.Destination))
+		}
+		return label
+
+	case *ast.AutoLink:
+		url := string(node.URL(w.source))
+		if node.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(url, "mailto:") {
+			url = "mailto:" + url
+		}
+		label := w.escape(string(node.Label(w.source)))
+		if w.st.link != nil {
+			return w.st.link(label, url)
+		}
+		return label
+
+	case *ast.Image:
+		alt := plainInline(node, w.source)
+		if w.st.image != nil {
+			return w.st.image(alt, string(node.Destination))
+		}
+		return w.escape(alt)
+
+	case *ast.RawHTML:
+		var b strings.Builder
+		for i := 0; i < node.Segments.Len(); i++ {
+			seg := node.Segments.At(i)
+			b.Write(seg.Value(w.source))
+		}
+		return w.escape(b.String())
+
+	default:
+		return w.inlines(node)
+	}
+}
+
+func (w *walker) escape(s string) string {
+	if w.st.escape != nil {
+		return w.st.escape(s)
+	}
+	return s
+}
+
+// textValue returns the content of a text node with backslash escapes and
+// entity references resolved; raw text such as code spans is kept as is
+func textValue(node *ast.Text, source []byte) string {
+	value := node.Segment.Value(source)
+	if node.IsRaw() {
+		return string(value)
+	}
+	value = util.UnescapePunctuations(value)
+	value = util.ResolveNumericReferences(value)
+	value = util.ResolveEntityNames(value)
+	return string(value)
+}
+
+// plainInline returns the unformatted text content of an inline subtree
+func plainInline(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := c.(type) {
+		case *ast.Text:
+			b.WriteString(textValue(node, source))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(node.Value)
+		case *ast.AutoLink:
+			b.Write(node.Label(source))
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// displayWidth approximates the terminal width of s (CJK characters count as two)
+func displayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x1100 && (r <= 0x115f || (r >= 0x2e80 && r <= 0xa4cf) || (r >= 0xac00 && r <= 0xd7a3) ||
+			(r >= 0xf900 && r <= 0xfaff) || (r >= 0xfe30 && r <= 0xfe4f) || (r >= 0xff00 && r <= 0xff60) ||
+			(r >= 0xffe0 && r <= 0xffe6)) {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
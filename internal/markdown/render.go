@@ -0,0 +1,239 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// TelegramHTML renders Markdown for Telegram's HTML parse mode
+func TelegramHTML(src string) string {
+	return render(src, style{
+		escape: escapeHTML,
+		bold:   wrap("<b>", "</b>"),
+		italic: wrap("<i>", "</i>"),
+		strike: wrap("<s>", "</s>"),
+		code: func(code string) string {
+			return "<code>" + escapeHTML(code) + "</code>"
+		},
+		codeBlock: func(lang, code string) string {
+			if lang != "" {
+				return `<pre><code class="language-` + escapeHTML(lang) + `">` + escapeHTML(code) + "</code></pre>"
+			}
+			return "<pre>" + escapeHTML(code) + "</pre>"
+		},
+		link: func(label, url string) string {
+			return `<a href="` + escapeHTML(url) + `">` + label + "</a>"
+		},
+		image: func(alt, url string) string {
+			return `<a href="` + escapeHTML(url) + `">` + escapeHTML(orDefault(alt, url)) + "</a>"
+		},
+		heading: func(level int, inner string) string { return "<b>" + inner + "</b>" },
+		quote:   wrap("<blockquote>", "</blockquote>"),
+		rule:    "——————",
+	})
+}
+
+// TelegramMarkdownV2 renders Markdown for Telegram's MarkdownV2 parse mode
+func TelegramMarkdownV2(src string) string {
+	return render(src, style{
+		escape: escapeMarkdownV2,
+		bold:   wrap("*", "*"),
+		italic: wrap("_", "_"),
+		strike: wrap("~", "~"),
+		code: func(code string) string {
+			return "`" + escapeMarkdownV2Code(code) + "`"
+		},
+		codeBlock: func(lang, code string) string {
+			return "```" + lang + "\n" + escapeMarkdownV2Code(code) + "\n```"
+		},
+		link: func(label, url string) string {
+			return "[" + label + "](" + escapeMarkdownV2URL(url) + ")"
+		},
+		image: func(alt, url string) string {
+			return "[" + escapeMarkdownV2(orDefault(alt, url)) + "](" + escapeMarkdownV2URL(url) + ")"
+		},
+		heading: func(level int, inner string) string { return "*" + inner + "*" },
+		quote:   prefixLines(">"),
+		rule:    escapeMarkdownV2("——————"),
+	})
+}
+
+// SlackMrkdwn renders Markdown as Slack mrkdwn
+func SlackMrkdwn(src string) string {
+	return render(src, slackStyle)
+}
+
+// SlackSections renders each top-level Markdown block separately as Slack mrkdwn,
+// for building Block Kit section blocks
+func SlackSections(src string) []string {
+	doc, source := Parse(src)
+	w := &walker{st: slackStyle, source: source}
+
+	var sections []string
+	for c := doc.FirstChild(); c != nil; c = c.NextSibling() {
+		if s := strings.TrimSpace(w.block(c)); s != "" {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+var slackStyle = style{
+	escape: escapeSlack,
+	bold:   wrap("*", "*"),
+	italic: wrap("_", "_"),
+	strike: wrap("~", "~"),
+	code:   func(code string) string { return "`" + escapeSlack(code) + "`" },
+	codeBlock: func(lang, code string) string {
+		return "```\n" + escapeSlack(code) + "\n```"
+	},
+	link: func(label, url string) string {
+		if label == "" || label == url {
+			return "<" + url + ">"
+		}
+		return "<" + url + "|" + label + ">"
+	},
+	image: func(alt, url string) string {
+		return "<" + url + "|" + escapeSlack(orDefault(alt, url)) + ">"
+	},
+	heading: func(level int, inner string) string { return "*" + inner + "*" },
+	quote:   prefixLines("> "),
+	rule:    "———",
+}
+
+// DingTalk renders Markdown for DingTalk's markdown message subset
+func DingTalk(src string) string {
+	return render(src, style{
+		bold:      wrap("**", "**"),
+		italic:    wrap("*", "*"),
+		code:      wrap("`", "`"),
+		codeBlock: func(lang, code string) string { return "```" + lang + "\n" + code + "\n```" },
+		link:      func(label, url string) string { return "[" + label + "](" + url + ")" },
+		image:     func(alt, url string) string { return "![" + alt + "](" + url + ")" },
+		heading: func(level int, inner string) string {
+			return strings.Repeat("#", level) + " " + inner
+		},
+		quote:     prefixLines("> "),
+		hardBreak: "  \n",
+		rule:      "---",
+		bullet:    "-",
+	})
+}
+
+// WeCom renders Markdown for WeCom's markdown message subset, which has no
+// italics, strikethrough, images or code blocks. Code blocks become one
+// inline code span per line.
+func WeCom(src string) string {
+	return render(src, style{
+		bold: wrap("**", "**"),
+		code: wrap("`", "`"),
+		codeBlock: func(lang, code string) string {
+			lines := strings.Split(code, "\n")
+			for i, line := range lines {
+				if strings.TrimSpace(line) != "" {
+					lines[i] = "`" + line + "`"
+				}
+			}
+			return strings.Join(lines, "\n")
+		},
+		link:  func(label, url string) string { return "[" + orDefault(label, url) + "](" + url + ")" },
+		image: func(alt, url string) string { return "[" + orDefault(alt, url) + "](" + url + ")" },
+		heading: func(level int, inner string) string {
+			return strings.Repeat("#", level) + " " + inner
+		},
+		quote:  prefixLines("> "),
+		rule:   "———",
+		bullet: "-",
+	})
+}
+
+// HTML renders Markdown as HTML, e.g. for Matrix's formatted_body. Raw HTML
+// in the source is dropped rather than passed through.
+func HTML(src string) string {
+	var sb strings.Builder
+	if err := md.Convert([]byte(src), &sb); err != nil {
+		return escapeHTML(src)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// PlainText renders Markdown as readable plain text with all markup removed
+func PlainText(src string) string {
+	return render(src, style{
+		link: func(label, url string) string {
+			if label == "" || label == url || strings.TrimPrefix(url, "mailto:") == label {
+				return url
+			}
+			return label + " (" + url + ")"
+		},
+		image: func(alt, url string) string {
+			if alt == "" {
+				return url
+			}
+			return alt + " (" + url + ")"
+		},
+		quote: prefixLines("> "),
+		rule:  "——————",
+	})
+}
+
+func wrap(open, close string) func(string) string {
+	return func(inner string) string {
+		if inner == "" {
+			return ""
+		}
+		return open + inner + close
+	}
+}
+
+func prefixLines(prefix string) func(string) string {
+	return func(inner string) string {
+		lines := strings.Split(inner, "\n")
+		for i, line := range lines {
+			lines[i] = prefix + line
+		}
+		return strings.Join(lines, "\n")
+	}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func escapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeSlack(s string) string {
+	return slackEscaper.Replace(s)
+}
+
+// escapeMarkdownV2 escapes every character Telegram reserves in MarkdownV2 text
+func escapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("_*[]()~`>#+-=|{}.!\\", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var markdownV2CodeEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+func escapeMarkdownV2Code(s string) string {
+	return markdownV2CodeEscaper.Replace(s)
+}
+
+var markdownV2URLEscaper = strings.NewReplacer("\\", "\\\\", ")", "\\)")
+
+func escapeMarkdownV2URL(s string) string {
+	return markdownV2URLEscaper.Replace(s)
+}
@@ -0,0 +1,99 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Private-use runes that mark styled ranges while rendering for Signal
+const (
+	signalBold   = '\uE000'
+	signalItalic = '\uE001'
+	signalStrike = '\uE002'
+	signalMono   = '\uE003'
+	signalEnd    = '\uE00F'
+)
+
+var signalStyles = map[rune]string{
+	signalBold:   "BOLD",
+	signalItalic: "ITALIC",
+	signalStrike: "STRIKETHROUGH",
+	signalMono:   "MONOSPACE",
+}
+
+// Signal renders Markdown as plain text plus text style ranges in signal-cli's
+// "start:length:STYLE" form. Offsets count UTF-16 code units, as Signal does.
+func Signal(src string) (string, []string) {
+	mark := func(open rune) func(string) string {
+		return wrap(string(open), string(signalEnd))
+	}
+	marked := render(src, style{
+		escape: stripSignalMarks,
+		bold:   mark(signalBold),
+		italic: mark(signalItalic),
+		strike: mark(signalStrike),
+		code: func(code string) string {
+			return mark(signalMono)(stripSignalMarks(code))
+		},
+		codeBlock: func(lang, code string) string {
+			return mark(signalMono)(stripSignalMarks(code))
+		},
+		link: func(label, url string) string {
+			url = stripSignalMarks(url)
+			if label == "" || label == url || strings.TrimPrefix(url, "mailto:") == label {
+				return url
+			}
+			return label + " (" + url + ")"
+		},
+		image: func(alt, url string) string {
+			alt, url = stripSignalMarks(alt), stripSignalMarks(url)
+			if alt == "" {
+				return url
+			}
+			return alt + " (" + url + ")"
+		},
+		heading: func(level int, inner string) string { return mark(signalBold)(inner) },
+		quote:   prefixLines("> "),
+		rule:    "——————",
+	})
+
+	var text strings.Builder
+	var styles []string
+	type open struct {
+		style string
+		start int
+	}
+	var stack []open
+	offset := 0
+	for _, r := range marked {
+		if name, ok := signalStyles[r]; ok {
+			stack = append(stack, open{name, offset})
+			continue
+		}
+		if r == signalEnd {
+			if len(stack) == 0 {
+				continue
+			}
+			o := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if offset > o.start {
+				styles = append(styles, fmt.Sprintf("%d:%d:%s", o.start, offset-o.start, o.style))
+			}
+			continue
+		}
+		text.WriteRune(r)
+		offset += utf16.RuneLen(r)
+	}
+	return text.String(), styles
+}
+
+// stripSignalMarks removes marker runes from source text
+func stripSignalMarks(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= signalBold && r <= signalEnd {
+			return -1
+		}
+		return r
+	}, s)
+}
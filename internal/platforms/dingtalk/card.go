@@ -0,0 +1,115 @@
+package dingtalk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// cardContentKey is the card template variable that holds the answer, as in
+// DingTalk's AI card templates
+const cardContentKey = "content"
+
+// Progressive rendering of AI cards: long answers are revealed in steps of
+// about cardStep runes, at most cardMaxSteps updates, cardInterval apart
+const (
+	cardStep     = 300
+	cardMaxSteps = 12
+	cardInterval = 150 * time.Millisecond
+)
+
+// createCard creates an AI card from a template and delivers it to a user's
+// one-on-one chat with the robot or, if userID is empty, to a group
+func (c *apiClient) createCard(ctx context.Context, templateID, trackID, robotCode, userID, conversationID string) error {
+	body := map[string]any{
+		"cardTemplateId": templateID,
+		"outTrackId":     trackID,
+		"callbackType":   "STREAM",
+		"cardData": map[string]any{
+			"cardParamMap": map[string]string{cardContentKey: ""},
+		},
+	}
+	if userID != "" {
+		body["openSpaceId"] = "dtv1.card//IM_ROBOT." + userID
+		body["imRobotOpenSpaceModel"] = map[string]any{"supportForward": true}
+		body["imRobotOpenDeliverModel"] = map[string]any{"spaceType": "IM_ROBOT", "robotCode": robotCode}
+	} else {
+		body["openSpaceId"] = "dtv1.card//IM_GROUP." + conversationID
+		body["imGroupOpenSpaceModel"] = map[string]any{"supportForward": true}
+		body["imGroupOpenDeliverModel"] = map[string]any{"robotCode": robotCode}
+	}
+	return c.do(ctx, http.MethodPost, "/v1.0/card/instances/createAndDeliver", body, nil)
+}
+
+// streamCard replaces the card's content; finalize ends the streaming state
+func (c *apiClient) streamCard(ctx context.Context, trackID, content string, finalize bool) error {
+	body := map[string]any{
+		"outTrackId": trackID,
+		"guid":       newID(),
+		"key":        cardContentKey,
+		"content":    content,
+		"isFull":     true,
+		"isFinalize": finalize,
+		"isError":    false,
+	}
+	return c.do(ctx, http.MethodPut, "/v1.0/card/streaming", body, nil)
+}
+
+// sendCard delivers an AI card and streams the text into it, revealing long
+// answers progressively. The card's track ID is returned as the message ID.
+func (p *Platform) sendCard(ctx context.Context, channelID, text string) (string, error) {
+	userID, conversationID := p.target(channelID)
+	trackID := newID()
+	if err := p.api.createCard(ctx, p.cardTemplateID, trackID, p.robotCode, userID, conversationID); err != nil {
+		return "", fmt.Errorf("failed to create card: %w", err)
+	}
+
+	steps := progressive(text, cardStep, cardMaxSteps)
+	for i, content := range steps {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return trackID, ctx.Err()
+			case <-time.After(cardInterval):
+			}
+		}
+		if err := p.api.streamCard(ctx, trackID, content, i == len(steps)-1); err != nil {
+			return trackID, fmt.Errorf("failed to update card: %w", err)
+		}
+	}
+	return trackID, nil
+}
+
+// progressive splits text into growing prefixes, the last being the whole
+// text. Cuts fall on line breaks where one is near.
+func progressive(text string, step, maxSteps int) []string {
+	runes := []rune(text)
+	if len(runes) <= step {
+		return []string{text}
+	}
+	if (len(runes)+step-1)/step > maxSteps {
+		step = (len(runes) + maxSteps - 1) / maxSteps
+	}
+
+	var prefixes []string
+	for end := step; end < len(runes); end += step {
+		for i := end; i > end-step/2; i-- {
+			if runes[i-1] == '\n' {
+				end = i
+				break
+			}
+		}
+		prefixes = append(prefixes, string(runes[:end]))
+	}
+	return append(prefixes, text)
+}
+
+// newID returns a random identifier for cards and stream updates
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
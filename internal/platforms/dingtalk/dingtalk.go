@@ -4,28 +4,48 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/chatbot"
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/client"
+	"github.com/pltanton/lingti-bot/internal/markdown"
 	"github.com/pltanton/lingti-bot/internal/router"
 )
 
+// userPrefix marks a channel ID that is a user ID, for sending to a user's
+// one-on-one chat with the robot without a conversation to reply to
+const userPrefix = "user:"
+
 // Platform implements router.Platform for DingTalk
 type Platform struct {
 	cli            *client.StreamClient
+	api            *apiClient
+	robotCode      string
+	cardTemplateID string
 	messageHandler func(msg router.Message)
-	webhooks       map[string]string // conversationID -> sessionWebhook
+	conversations  map[string]conversation // By conversation ID
 	mu             sync.RWMutex
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
 
+// conversation is what the bot remembers about a chat it was messaged in
+type conversation struct {
+	webhook string    // Session webhook for replies
+	expires time.Time // When the session webhook stops working; zero if unknown
+	userID  string    // Staff ID of the user in a one-on-one chat
+}
+
 // Config holds DingTalk configuration
 type Config struct {
-	ClientID     string // AppKey from DingTalk Developer Console
-	ClientSecret string // AppSecret from DingTalk Developer Console
+	ClientID       string // AppKey from DingTalk Developer Console
+	ClientSecret   string // AppSecret from DingTalk Developer Console
+	RobotCode      string // Robot code for OpenAPI messages (default: ClientID)
+	CardTemplateID string // Optional AI card template; answers are streamed into cards
 }
 
 // New creates a new DingTalk platform
@@ -34,8 +54,16 @@ func New(cfg Config) (*Platform, error) {
 		return nil, fmt.Errorf("both ClientID (AppKey) and ClientSecret (AppSecret) are required")
 	}
 
+	robotCode := cfg.RobotCode
+	if robotCode == "" {
+		robotCode = cfg.ClientID
+	}
+
 	p := &Platform{
-		webhooks: make(map[string]string),
+		api:            newAPIClient(cfg.ClientID, cfg.ClientSecret),
+		robotCode:      robotCode,
+		cardTemplateID: cfg.CardTemplateID,
+		conversations:  make(map[string]conversation),
 	}
 
 	// Create stream client
@@ -55,6 +83,11 @@ func (p *Platform) Name() string {
 	return "dingtalk"
 }
 
+// MessageLimits returns DingTalk's text message limit (20000 bytes)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 20000, Unit: router.UnitBytes}
+}
+
 // SetMessageHandler sets the callback for incoming messages
 func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 	p.messageHandler = handler
@@ -83,27 +116,153 @@ func (p *Platform) Stop() error {
 	return nil
 }
 
-// Send sends a message to a DingTalk conversation
-func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) error {
-	// DingTalk uses sessionWebhook for replies
-	sessionWebhook := ""
-	if resp.Metadata != nil {
-		sessionWebhook = resp.Metadata["session_webhook"]
+// Healthy reports an error if the app can no longer get an access token,
+// e.g. because its credentials were revoked or the API is unreachable. The
+// stream client reconnects dropped connections by itself.
+func (p *Platform) Healthy() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := p.api.accessToken(ctx)
+	return err
+}
+
+// Send sends a message to a DingTalk conversation: as an AI card when a card
+// template is configured, otherwise as markdown through the session webhook
+// while it is valid and through the robot OpenAPI after that. Only AI cards
+// and OpenAPI messages have an ID.
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
+	text := markdown.DingTalk(resp.Text)
+	if p.cardTemplateID != "" {
+		id, err := p.sendCard(ctx, channelID, text)
+		if err == nil {
+			return id, nil
+		}
+		if id != "" {
+			// The card was delivered; sending again would duplicate it
+			return id, err
+		}
+		log.Printf("[DingTalk] AI card failed, sending markdown: %v", err)
 	}
 
-	if sessionWebhook == "" {
-		// Try to get from stored webhooks (for async replies)
-		p.mu.RLock()
-		sessionWebhook = p.webhooks[channelID]
-		p.mu.RUnlock()
+	title := markdownTitle(resp.Text)
+	if webhook := p.webhook(channelID, resp); webhook != "" {
+		replier := chatbot.NewChatbotReplier()
+		err := replier.SimpleReplyMarkdown(ctx, webhook, []byte(title), []byte(text))
+		if err != nil {
+			log.Printf("[DingTalk] Markdown reply failed, retrying as plain text: %v", err)
+			err = replier.SimpleReplyText(ctx, webhook, []byte(markdown.PlainText(resp.Text)))
+		}
+		if err == nil {
+			return "", nil
+		}
+		log.Printf("[DingTalk] Session webhook reply failed, sending through OpenAPI: %v", err)
 	}
 
-	if sessionWebhook == "" {
-		return fmt.Errorf("no session webhook available for conversation %s", channelID)
+	userID, conversationID := p.target(channelID)
+	return p.api.sendRobotMessage(ctx, p.robotCode, userID, conversationID, "sampleMarkdown",
+		map[string]string{"title": title, "text": text})
+}
+
+// SendInteractive sends an ActionCard with up to five buttons. A click makes
+// the user's client send the button's value as a message, so buttons only
+// reach the bot in one-on-one chats; in groups the bot sees only @mentions.
+func (p *Platform) SendInteractive(ctx context.Context, channelID string, resp router.Response, buttons []router.Button) (string, error) {
+	if len(buttons) == 0 || len(buttons) > maxButtons {
+		return "", fmt.Errorf("ActionCards take 1 to %d buttons, got %d", maxButtons, len(buttons))
 	}
 
-	replier := chatbot.NewChatbotReplier()
-	return replier.SimpleReplyText(ctx, sessionWebhook, []byte(resp.Text))
+	title, text := markdownTitle(resp.Text), markdown.DingTalk(resp.Text)
+	if webhook := p.webhook(channelID, resp); webhook != "" {
+		btns := make([]map[string]string, 0, len(buttons))
+		for _, b := range buttons {
+			btns = append(btns, map[string]string{"title": b.Label, "actionURL": buttonURL(b.Value)})
+		}
+		err := chatbot.NewChatbotReplier().ReplyMessage(ctx, webhook, map[string]any{
+			"msgtype": "actionCard",
+			"actionCard": map[string]any{
+				"title":          title,
+				"text":           text,
+				"btnOrientation": "0",
+				"btns":           btns,
+			},
+		})
+		if err == nil {
+			return "", nil
+		}
+		log.Printf("[DingTalk] Session webhook reply failed, sending through OpenAPI: %v", err)
+	}
+
+	// The OpenAPI templates are sampleActionCard (one button) and
+	// sampleActionCard2 to 5
+	msgKey := "sampleActionCard"
+	param := map[string]string{"title": title, "text": text}
+	if len(buttons) == 1 {
+		param["singleTitle"], param["singleURL"] = buttons[0].Label, buttonURL(buttons[0].Value)
+	} else {
+		msgKey += strconv.Itoa(len(buttons))
+		for i, b := range buttons {
+			n := strconv.Itoa(i + 1)
+			param["actionTitle"+n], param["actionURL"+n] = b.Label, buttonURL(b.Value)
+		}
+	}
+
+	userID, conversationID := p.target(channelID)
+	return p.api.sendRobotMessage(ctx, p.robotCode, userID, conversationID, msgKey, param)
+}
+
+// maxButtons is the most buttons an ActionCard template takes
+const maxButtons = 5
+
+// buttonURL makes a link that sends value as a message from the user
+func buttonURL(value string) string {
+	return "dtmd://dingtalkclient/sendMessage?content=" + url.QueryEscape(value)
+}
+
+// webhook returns the session webhook for a reply: the one of the message
+// being answered, or the conversation's latest while it is valid
+func (p *Platform) webhook(channelID string, resp router.Response) string {
+	if webhook := resp.Metadata["session_webhook"]; webhook != "" {
+		return webhook
+	}
+
+	p.mu.RLock()
+	conv := p.conversations[channelID]
+	p.mu.RUnlock()
+	if conv.webhook == "" || (!conv.expires.IsZero() && time.Now().After(conv.expires)) {
+		return ""
+	}
+	return conv.webhook
+}
+
+// target resolves a channel ID to a user for one-on-one messages or to a
+// group conversation. Conversations the bot hasn't seen are taken as groups.
+func (p *Platform) target(channelID string) (userID, conversationID string) {
+	if id, ok := strings.CutPrefix(channelID, userPrefix); ok {
+		return id, ""
+	}
+
+	p.mu.RLock()
+	conv := p.conversations[channelID]
+	p.mu.RUnlock()
+	if conv.userID != "" {
+		return conv.userID, ""
+	}
+	return "", channelID
+}
+
+// markdownTitle derives the notification title DingTalk shows for a markdown message
+func markdownTitle(text string) string {
+	title := strings.TrimSpace(markdown.PlainText(text))
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	if runes := []rune(title); len(runes) > 20 {
+		title = string(runes[:20]) + "…"
+	}
+	if title == "" {
+		title = "灵缇"
+	}
+	return title
 }
 
 // onChatBotMessageReceived handles incoming chatbot messages
@@ -126,9 +285,16 @@ func (p *Platform) onChatBotMessageReceived(ctx context.Context, data *chatbot.B
 	// Clean @mention from text
 	text = p.cleanMention(text)
 
-	// Store session webhook for later use in Send()
+	// Remember how to reach the conversation for later use in Send()
+	conv := conversation{webhook: data.SessionWebhook}
+	if data.SessionWebhookExpiredTime > 0 {
+		conv.expires = time.UnixMilli(data.SessionWebhookExpiredTime)
+	}
+	if data.ConversationType == "1" {
+		conv.userID = data.SenderStaffId
+	}
 	p.mu.Lock()
-	p.webhooks[data.ConversationId] = data.SessionWebhook
+	p.conversations[data.ConversationId] = conv
 	p.mu.Unlock()
 
 	if p.messageHandler != nil {
@@ -150,7 +316,8 @@ func (p *Platform) onChatBotMessageReceived(ctx context.Context, data *chatbot.B
 			},
 		}
 
-		// Call message handler (this is synchronous)
+		// The router processes messages in the background, so the callback
+		// returns (and the stream ack is sent) without waiting for the agent
 		p.messageHandler(msg)
 	}
 
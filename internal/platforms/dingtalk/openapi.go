@@ -0,0 +1,157 @@
+package dingtalk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// apiBaseURL is the DingTalk OpenAPI (v1.0) endpoint
+const apiBaseURL = "https://api.dingtalk.com"
+
+// tokenMargin is how long before expiry an access token is renewed
+const tokenMargin = 5 * time.Minute
+
+// apiClient is a minimal DingTalk OpenAPI client for robot messages and
+// cards. It fetches and renews the app's access token as needed.
+type apiClient struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	http         *http.Client
+
+	token   string
+	expires time.Time
+	mu      sync.Mutex
+}
+
+// apiError is an error response from the OpenAPI
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("dingtalk: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("dingtalk: %s: %s", e.Code, e.Message)
+}
+
+func newAPIClient(clientID, clientSecret string) *apiClient {
+	return &apiClient{
+		baseURL:      apiBaseURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		http:         &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// accessToken returns a valid app access token, fetching a new one when the
+// cached token is about to expire
+func (c *apiClient) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Until(c.expires) > tokenMargin {
+		return c.token, nil
+	}
+
+	var result struct {
+		AccessToken string `json:"accessToken"`
+		ExpireIn    int    `json:"expireIn"` // Seconds
+	}
+	body := map[string]string{"appKey": c.clientID, "appSecret": c.clientSecret}
+	if err := c.request(ctx, http.MethodPost, "/v1.0/oauth2/accessToken", "", body, &result); err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("failed to get access token: empty token")
+	}
+
+	c.token = result.AccessToken
+	c.expires = time.Now().Add(time.Duration(result.ExpireIn) * time.Second)
+	return c.token, nil
+}
+
+// do calls an OpenAPI method with the app's access token
+func (c *apiClient) do(ctx context.Context, method, path string, body, out any) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	return c.request(ctx, method, path, token, body, out)
+}
+
+// request sends a JSON request and decodes the JSON response into out (if not nil)
+func (c *apiClient) request(ctx context.Context, method, path, token string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("x-acs-dingtalk-access-token", token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &apiError{Status: resp.StatusCode}
+		json.Unmarshal(data, apiErr)
+		return apiErr
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// sendRobotMessage sends a robot message template (msgKey) to users in
+// one-on-one chats or, if userID is empty, to a group conversation. It
+// returns the key that identifies the sent message.
+func (c *apiClient) sendRobotMessage(ctx context.Context, robotCode, userID, conversationID, msgKey string, param map[string]string) (string, error) {
+	msgParam, err := json.Marshal(param)
+	if err != nil {
+		return "", err
+	}
+
+	body := map[string]any{
+		"robotCode": robotCode,
+		"msgKey":    msgKey,
+		"msgParam":  string(msgParam),
+	}
+	path := "/v1.0/robot/groupMessages/send"
+	if userID != "" {
+		path = "/v1.0/robot/oToMessages/batchSend"
+		body["userIds"] = []string{userID}
+	} else {
+		body["openConversationId"] = conversationID
+	}
+
+	var result struct {
+		ProcessQueryKey string `json:"processQueryKey"`
+	}
+	if err := c.do(ctx, http.MethodPost, path, body, &result); err != nil {
+		return "", err
+	}
+	return result.ProcessQueryKey, nil
+}
@@ -0,0 +1,74 @@
+package dingtalk
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/chatbot"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// placeholderDelay is how long a request may run before DingTalk users are told
+// the bot is working. Session webhook messages can't be edited or deleted, so the
+// placeholder is only sent for slow requests.
+const placeholderDelay = 4 * time.Second
+
+// placeholderText is sent when a request is still running after placeholderDelay
+const placeholderText = "⏳ 正在处理，请稍候…"
+
+// StartPresence sends a one-off placeholder if the request takes a while
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	webhook := ""
+	if msg.Metadata != nil {
+		webhook = msg.Metadata["session_webhook"]
+	}
+
+	ind := &indicator{ctx: ctx, webhook: webhook}
+	if webhook != "" {
+		ind.timer = time.AfterFunc(placeholderDelay, func() { ind.send(placeholderText) })
+	}
+	return ind, nil
+}
+
+// indicator sends at most one placeholder message
+type indicator struct {
+	ctx     context.Context
+	webhook string
+	timer   *time.Timer
+	sent    bool
+	stopped bool
+	mu      sync.Mutex
+}
+
+// Update sends the first progress status as the placeholder; later ones are dropped
+// because DingTalk can't edit the message
+func (i *indicator) Update(ctx context.Context, status string) {
+	i.send(status)
+}
+
+// Stop cancels a pending placeholder
+func (i *indicator) Stop(ctx context.Context) {
+	if i.timer != nil {
+		i.timer.Stop()
+	}
+	i.mu.Lock()
+	i.stopped = true
+	i.mu.Unlock()
+}
+
+func (i *indicator) send(text string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.sent || i.stopped || i.webhook == "" {
+		return
+	}
+	i.sent = true
+
+	replier := chatbot.NewChatbotReplier()
+	if err := replier.SimpleReplyText(i.ctx, i.webhook, []byte(text)); err != nil {
+		log.Printf("[DingTalk] Failed to send placeholder: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	maxButtonsPerRow = 5   // Discord's limit per action row
+	maxButtonRows    = 5   // Discord's limit of action rows per message
+	maxCustomID      = 100 // Discord's limit for a button's custom_id
+	maxHistory       = 100 // Discord's limit per ChannelMessages call
+)
+
+// Edit replaces the content of a message the bot sent
+func (p *Platform) Edit(ctx context.Context, channelID, messageID string, resp router.Response) error {
+	_, err := p.session.ChannelMessageEdit(channelID, messageID, resp.Text, discordgo.WithContext(ctx))
+	return err
+}
+
+// Delete removes a message
+func (p *Platform) Delete(ctx context.Context, channelID, messageID string) error {
+	return p.session.ChannelMessageDelete(channelID, messageID, discordgo.WithContext(ctx))
+}
+
+// React adds a reaction; emoji is a Unicode emoji or "name:id" for custom emoji
+func (p *Platform) React(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.session.MessageReactionAdd(channelID, messageID, emoji, discordgo.WithContext(ctx))
+}
+
+// Unreact removes the bot's reaction
+func (p *Platform) Unreact(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.session.MessageReactionRemove(channelID, messageID, emoji, "@me", discordgo.WithContext(ctx))
+}
+
+// SendFile uploads a file to a channel
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	data := file.Data
+	if len(data) == 0 {
+		if file.URL == "" {
+			return "", fmt.Errorf("attachment %q has no data", file.Name)
+		}
+		var err error
+		if data, err = download(ctx, file.URL); err != nil {
+			return "", err
+		}
+	}
+
+	send := &discordgo.MessageSend{
+		Content: resp.Text,
+		Files: []*discordgo.File{{
+			Name:        file.Name,
+			ContentType: file.MimeType,
+			Reader:      bytes.NewReader(data),
+		}},
+	}
+	if resp.ThreadID != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: resp.ThreadID, ChannelID: channelID}
+	}
+
+	sent, err := p.session.ChannelMessageSendComplex(channelID, send, discordgo.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+// SendInteractive sends a message with buttons
+func (p *Platform) SendInteractive(ctx context.Context, channelID string, resp router.Response, buttons []router.Button) (string, error) {
+	if len(buttons) > maxButtonsPerRow*maxButtonRows {
+		return "", fmt.Errorf("too many buttons: %d (max %d)", len(buttons), maxButtonsPerRow*maxButtonRows)
+	}
+
+	var rows []discordgo.MessageComponent
+	var row discordgo.ActionsRow
+	for _, b := range buttons {
+		if len(b.Value) > maxCustomID {
+			return "", fmt.Errorf("button value %q exceeds %d characters", b.Value, maxCustomID)
+		}
+		row.Components = append(row.Components, discordgo.Button{
+			Label:    b.Label,
+			Style:    buttonStyle(b.Style),
+			CustomID: b.Value,
+		})
+		if len(row.Components) == maxButtonsPerRow {
+			rows = append(rows, row)
+			row = discordgo.ActionsRow{}
+		}
+	}
+	if len(row.Components) > 0 {
+		rows = append(rows, row)
+	}
+
+	send := &discordgo.MessageSend{Content: resp.Text, Components: rows}
+	if resp.ThreadID != "" {
+		send.Reference = &discordgo.MessageReference{MessageID: resp.ThreadID, ChannelID: channelID}
+	}
+
+	sent, err := p.session.ChannelMessageSendComplex(channelID, send, discordgo.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
+}
+
+// FetchHistory returns up to limit recent messages, oldest first
+func (p *Platform) FetchHistory(ctx context.Context, channelID string, limit int) ([]router.Message, error) {
+	if limit <= 0 || limit > maxHistory {
+		limit = maxHistory
+	}
+
+	msgs, err := p.session.ChannelMessages(channelID, limit, "", "", "", discordgo.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	// Discord returns newest first
+	history := make([]router.Message, 0, len(msgs))
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		msg := router.Message{
+			ID:        m.ID,
+			Platform:  "discord",
+			ChannelID: m.ChannelID,
+			Text:      m.Content,
+		}
+		if m.Author != nil {
+			msg.UserID = m.Author.ID
+			msg.Username = m.Author.Username
+		}
+		for _, a := range m.Attachments {
+			msg.Attachments = append(msg.Attachments, router.Attachment{
+				Name:     a.Filename,
+				MimeType: a.ContentType,
+				URL:      a.URL,
+			})
+		}
+		history = append(history, msg)
+	}
+	return history, nil
+}
+
+// handleInteraction turns a button click into a message for the router
+func (p *Platform) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionMessageComponent {
+		return
+	}
+
+	// Acknowledge so Discord doesn't show "interaction failed"
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		log.Printf("[Discord] Failed to acknowledge interaction: %v", err)
+	}
+
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if p.messageHandler == nil || user == nil || user.Bot {
+		return
+	}
+
+	metadata := map[string]string{
+		"guild_id": i.GuildID,
+		"button":   "true",
+	}
+	if i.Message != nil {
+		metadata["message_id"] = i.Message.ID
+	}
+
+	p.messageHandler(router.Message{
+		ID:        "interaction:" + i.ID,
+		Platform:  "discord",
+		ChannelID: i.ChannelID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Text:      i.MessageComponentData().CustomID,
+		Metadata:  metadata,
+	})
+}
+
+func buttonStyle(style string) discordgo.ButtonStyle {
+	switch style {
+	case "primary":
+		return discordgo.PrimaryButton
+	case "danger":
+		return discordgo.DangerButton
+	default:
+		return discordgo.SecondaryButton
+	}
+}
+
+// download fetches a file by URL
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
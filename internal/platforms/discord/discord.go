@@ -5,16 +5,24 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/pltanton/lingti-bot/internal/router"
 )
 
+// maxDisconnect is how long the gateway may stay disconnected before the platform
+// reports itself unhealthy; discordgo reconnects on its own within this window
+const maxDisconnect = 2 * time.Minute
+
 // Platform implements router.Platform for Discord
 type Platform struct {
 	session        *discordgo.Session
 	botUserID      string
 	messageHandler func(msg router.Message)
+	disconnectedAt time.Time // Zero while the gateway is connected
+	connMu         sync.Mutex
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -40,9 +48,17 @@ func New(cfg Config) (*Platform, error) {
 		discordgo.IntentsDirectMessages |
 		discordgo.IntentMessageContent
 
-	return &Platform{
+	p := &Platform{
 		session: session,
-	}, nil
+	}
+
+	// Handlers are added once so restarts don't register them twice
+	session.AddHandler(p.handleMessage)
+	session.AddHandler(p.handleInteraction)
+	session.AddHandler(p.handleConnect)
+	session.AddHandler(p.handleDisconnect)
+
+	return p, nil
 }
 
 // Name returns the platform name
@@ -50,6 +66,11 @@ func (p *Platform) Name() string {
 	return "discord"
 }
 
+// MessageLimits returns Discord's message length limit (2000 characters)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 2000, Unit: router.UnitRunes}
+}
+
 // SetMessageHandler sets the callback for incoming messages
 func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 	p.messageHandler = handler
@@ -59,9 +80,6 @@ func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 func (p *Platform) Start(ctx context.Context) error {
 	p.ctx, p.cancel = context.WithCancel(ctx)
 
-	// Add message handler
-	p.session.AddHandler(p.handleMessage)
-
 	// Open connection
 	if err := p.session.Open(); err != nil {
 		return fmt.Errorf("failed to open Discord connection: %w", err)
@@ -86,8 +104,35 @@ func (p *Platform) Stop() error {
 	return p.session.Close()
 }
 
+// Healthy reports an error if the gateway has been disconnected for too long
+func (p *Platform) Healthy() error {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if !p.disconnectedAt.IsZero() && time.Since(p.disconnectedAt) > maxDisconnect {
+		return fmt.Errorf("gateway disconnected since %s", p.disconnectedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// handleConnect clears the disconnect timestamp once the gateway is back
+func (p *Platform) handleConnect(s *discordgo.Session, c *discordgo.Connect) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	p.disconnectedAt = time.Time{}
+}
+
+// handleDisconnect records when the gateway connection dropped
+func (p *Platform) handleDisconnect(s *discordgo.Session, d *discordgo.Disconnect) {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	if p.disconnectedAt.IsZero() {
+		p.disconnectedAt = time.Now()
+	}
+}
+
 // Send sends a message to a Discord channel
-func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) error {
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
 	var reference *discordgo.MessageReference
 	if resp.ThreadID != "" {
 		reference = &discordgo.MessageReference{
@@ -96,11 +141,14 @@ func (p *Platform) Send(ctx context.Context, channelID string, resp router.Respo
 		}
 	}
 
-	_, err := p.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+	sent, err := p.session.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
 		Content:   resp.Text,
 		Reference: reference,
-	})
-	return err
+	}, discordgo.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return sent.ID, nil
 }
 
 // handleMessage processes incoming Discord messages
@@ -0,0 +1,85 @@
+package discord
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// typingRefresh re-triggers typing before Discord's 10 second expiry
+const typingRefresh = 8 * time.Second
+
+// StartPresence shows the typing indicator until the response is sent. Progress
+// updates are shown in a placeholder message that is deleted afterwards.
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	loopCtx, cancel := context.WithCancel(ctx)
+	ind := &indicator{session: p.session, channelID: msg.ChannelID, cancel: cancel}
+	go ind.typing(loopCtx)
+	return ind, nil
+}
+
+// indicator is a typing indicator plus an optional progress placeholder
+type indicator struct {
+	session     *discordgo.Session
+	channelID   string
+	placeholder string // Message ID of the progress placeholder
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+}
+
+func (i *indicator) typing(ctx context.Context) {
+	ticker := time.NewTicker(typingRefresh)
+	defer ticker.Stop()
+
+	for {
+		if err := i.session.ChannelTyping(i.channelID, discordgo.WithContext(ctx)); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[Discord] Failed to trigger typing: %v", err)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update posts or edits the progress placeholder
+func (i *indicator) Update(ctx context.Context, status string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder == "" {
+		sent, err := i.session.ChannelMessageSend(i.channelID, status, discordgo.WithContext(ctx))
+		if err != nil {
+			log.Printf("[Discord] Failed to send placeholder: %v", err)
+			return
+		}
+		i.placeholder = sent.ID
+		return
+	}
+	if _, err := i.session.ChannelMessageEdit(i.channelID, i.placeholder, status, discordgo.WithContext(ctx)); err != nil {
+		log.Printf("[Discord] Failed to update placeholder: %v", err)
+	}
+}
+
+// Stop ends typing and removes the placeholder
+func (i *indicator) Stop(ctx context.Context) {
+	i.cancel()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder != "" {
+		if err := i.session.ChannelMessageDelete(i.channelID, i.placeholder, discordgo.WithContext(ctx)); err != nil {
+			log.Printf("[Discord] Failed to delete placeholder: %v", err)
+		}
+		i.placeholder = ""
+	}
+}
@@ -0,0 +1,472 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	// ioTimeout bounds every IMAP round trip and SMTP session
+	ioTimeout = 60 * time.Second
+	// maxPollFailures is how many failed polls in a row mark the platform unhealthy
+	maxPollFailures = 5
+	// maxAttachmentSize caps files taken from incoming mail
+	maxAttachmentSize = 20 << 20
+	// defaultSubject is used for mail the bot starts itself
+	defaultSubject = "灵缇"
+	// threadTTL is how long an idle thread can still be replied to by ID
+	threadTTL = 7 * 24 * time.Hour
+	// maxThreads caps the threads kept; the least recently used go first
+	maxThreads = 1000
+)
+
+// Platform implements router.Platform for email: it polls an IMAP mailbox
+// and replies over SMTP. Each email thread is one conversation.
+type Platform struct {
+	cfg            Config
+	address        string // The bot's own address
+	allowed        []string
+	messageHandler func(msg router.Message)
+
+	threads   map[string]*thread // Thread root Message-ID → reply state
+	threadsMu sync.Mutex
+
+	minUID  uint32          // Mail delivered before Start is left alone
+	ignored map[uint32]bool // Unread mail from senders that aren't allowed
+
+	runErr error // Set while polling keeps failing
+	runMu  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config holds email configuration
+type Config struct {
+	IMAPAddr        string        // host:port; 993 uses TLS, other ports STARTTLS
+	SMTPAddr        string        // host:port; 465 uses TLS, other ports STARTTLS
+	Username        string        // Login for both servers
+	Password        string        // Password or app password
+	Address         string        // From address; defaults to Username
+	Mailbox         string        // Mailbox to watch; defaults to INBOX
+	PollInterval    time.Duration // Defaults to 30s
+	AllowedSenders  []string      // Addresses or "@domain" entries allowed to talk to the bot
+	Insecure        bool          // Skip STARTTLS, e.g. for a local test server
+	TrustUnverified bool          // Answer allowed senders without a passing DMARC, DKIM or SPF result
+}
+
+// thread is what the bot needs to reply within an email thread
+type thread struct {
+	to         string   // Who to reply to
+	subject    string   // Subject without "Re:"
+	lastID     string   // Message-ID to reply to
+	references []string // Message-IDs of the thread so far
+	used       time.Time
+}
+
+// New creates a new email platform
+func New(cfg Config) (*Platform, error) {
+	if cfg.IMAPAddr == "" || cfg.SMTPAddr == "" {
+		return nil, fmt.Errorf("IMAP and SMTP addresses are required")
+	}
+	if cfg.Username == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("username and password are required")
+	}
+	if len(cfg.AllowedSenders) == 0 {
+		return nil, fmt.Errorf("at least one allowed sender is required")
+	}
+	if cfg.Mailbox == "" {
+		cfg.Mailbox = "INBOX"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 30 * time.Second
+	}
+
+	address := cfg.Address
+	if address == "" {
+		address = cfg.Username
+	}
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", address, err)
+	}
+
+	allowed := make([]string, 0, len(cfg.AllowedSenders))
+	for _, sender := range cfg.AllowedSenders {
+		if sender = strings.ToLower(strings.TrimSpace(sender)); sender != "" {
+			allowed = append(allowed, sender)
+		}
+	}
+
+	return &Platform{
+		cfg:     cfg,
+		address: strings.ToLower(parsed.Address),
+		allowed: allowed,
+		threads: make(map[string]*thread),
+		ignored: make(map[uint32]bool),
+	}, nil
+}
+
+// Name returns the platform name
+func (p *Platform) Name() string {
+	return "email"
+}
+
+// SetMessageHandler sets the callback for incoming messages
+func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
+	p.messageHandler = handler
+}
+
+// Start logs in to the mailbox and begins polling. Unread mail that arrived
+// before the bot started is left for a human.
+func (p *Platform) Start(ctx context.Context) error {
+	conn, err := p.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to IMAP: %w", err)
+	}
+	uidNext, err := conn.selectMailbox(p.cfg.Mailbox)
+	if err != nil {
+		conn.logout()
+		return fmt.Errorf("failed to open %s: %w", p.cfg.Mailbox, err)
+	}
+	p.minUID = uidNext
+
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.setRunErr(nil)
+	go p.pollLoop(p.ctx, conn)
+
+	log.Printf("[Email] Watching %s as %s", p.cfg.Mailbox, p.address)
+	return nil
+}
+
+// Stop ends polling
+func (p *Platform) Stop() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	return nil
+}
+
+// Healthy reports an error while polling keeps failing
+func (p *Platform) Healthy() error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	return p.runErr
+}
+
+func (p *Platform) setRunErr(err error) {
+	p.runMu.Lock()
+	p.runErr = err
+	p.runMu.Unlock()
+}
+
+// Send replies in the thread channelID names. A channelID that is a plain
+// address starts a new thread with that address.
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
+	return p.send(ctx, channelID, resp, nil)
+}
+
+// SendFile sends a reply with the file attached
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+	return p.send(ctx, channelID, resp, &file)
+}
+
+func (p *Platform) send(ctx context.Context, channelID string, resp router.Response, file *router.Attachment) (string, error) {
+	out := outgoing{From: p.cfg.Address, Text: resp.Text, Attachment: file}
+	if out.From == "" {
+		out.From = p.cfg.Username
+	}
+
+	p.threadsMu.Lock()
+	t, ok := p.threads[channelID]
+	if ok {
+		out.To = t.to
+		out.Subject = replySubject(t.subject)
+		out.InReplyTo = t.lastID
+		out.References = t.references
+	}
+	p.threadsMu.Unlock()
+
+	if !ok {
+		if strings.HasPrefix(channelID, "<") {
+			return "", fmt.Errorf("unknown email thread %s", channelID)
+		}
+		out.To = channelID
+		out.Subject = orDefault(resp.Metadata["subject"], defaultSubject)
+	}
+
+	messageID, data, err := out.build(p.address)
+	if err != nil {
+		return "", err
+	}
+	if err := p.sendMail(ctx, out.To, data); err != nil {
+		return "", err
+	}
+
+	p.threadsMu.Lock()
+	if !ok {
+		// The recipient's reply will carry this ID as its thread root
+		channelID = messageID
+		t = &thread{to: out.To, subject: out.Subject}
+		p.threads[channelID] = t
+	}
+	t.lastID = messageID
+	t.references = append(t.references, messageID)
+	t.used = time.Now()
+	p.threadsMu.Unlock()
+
+	return messageID, nil
+}
+
+// dial connects and logs in to the IMAP server
+func (p *Platform) dial() (*imapConn, error) {
+	return dialIMAP(p.cfg.IMAPAddr, p.cfg.Username, p.cfg.Password, p.cfg.Insecure, ioTimeout)
+}
+
+// pollLoop checks for new mail every poll interval, reconnecting as needed
+func (p *Platform) pollLoop(ctx context.Context, conn *imapConn) {
+	defer func() {
+		if conn != nil {
+			conn.logout()
+		}
+	}()
+
+	ticker := time.NewTicker(p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		err := p.poll(ctx, &conn)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			failures++
+			log.Printf("[Email] Poll error: %v", err)
+			if conn != nil {
+				conn.conn.Close()
+				conn = nil
+			}
+			if failures >= maxPollFailures {
+				p.setRunErr(err)
+			}
+		} else if failures > 0 {
+			failures = 0
+			p.setRunErr(nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll handles unread mail, connecting first if there is no connection
+func (p *Platform) poll(ctx context.Context, connp **imapConn) error {
+	if *connp == nil {
+		conn, err := p.dial()
+		if err != nil {
+			return err
+		}
+		if _, err := conn.selectMailbox(p.cfg.Mailbox); err != nil {
+			conn.logout()
+			return err
+		}
+		*connp = conn
+	}
+	conn := *connp
+
+	conn.deadline(ioTimeout)
+	uids, err := conn.searchUnseen(p.minUID)
+	if err != nil {
+		return err
+	}
+	p.pruneIgnored(uids)
+	p.pruneThreads(time.Now())
+
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if p.ignored[uid] {
+			continue
+		}
+		conn.deadline(ioTimeout)
+		raw, err := conn.fetch(uid)
+		if err != nil {
+			return err
+		}
+
+		m, err := parseMessage(raw, maxAttachmentSize)
+		if err != nil {
+			log.Printf("[Email] Skipping unparsable message %d: %v", uid, err)
+			p.ignored[uid] = true
+			continue
+		}
+		sender := strings.ToLower(m.From.Address)
+		if !p.isAllowed(sender) {
+			// Left unread for a human to look at
+			log.Printf("[Email] Ignoring mail from %s (not an allowed sender)", sender)
+			p.ignored[uid] = true
+			continue
+		}
+		if !m.Verified && !p.cfg.TrustUnverified {
+			// From is easy to forge, and the agent can run tools
+			log.Printf("[Email] Ignoring mail from %s (sender not authenticated by DMARC, DKIM or SPF)", sender)
+			p.ignored[uid] = true
+			continue
+		}
+
+		// Marked read first so a crash can't make the bot answer twice
+		if err := conn.markSeen(uid); err != nil {
+			return err
+		}
+		if m.AutoReply || sender == p.address {
+			continue
+		}
+		p.handleMessage(m)
+	}
+	return nil
+}
+
+// pruneIgnored forgets ignored mail that is no longer unread
+func (p *Platform) pruneIgnored(unseen []uint32) {
+	if len(p.ignored) == 0 {
+		return
+	}
+	current := make(map[uint32]bool, len(unseen))
+	for _, uid := range unseen {
+		current[uid] = true
+	}
+	for uid := range p.ignored {
+		if !current[uid] {
+			delete(p.ignored, uid)
+		}
+	}
+}
+
+// pruneThreads drops threads idle for longer than threadTTL and, above
+// maxThreads, the least recently used ones
+func (p *Platform) pruneThreads(now time.Time) {
+	p.threadsMu.Lock()
+	defer p.threadsMu.Unlock()
+
+	for id, t := range p.threads {
+		if now.Sub(t.used) > threadTTL {
+			delete(p.threads, id)
+		}
+	}
+	if len(p.threads) <= maxThreads {
+		return
+	}
+	ids := make([]string, 0, len(p.threads))
+	for id := range p.threads {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return p.threads[ids[i]].used.Before(p.threads[ids[j]].used) })
+	for _, id := range ids[:len(ids)-maxThreads] {
+		delete(p.threads, id)
+	}
+}
+
+// isAllowed checks a sender against the allowlist of addresses and "@domain" entries
+func (p *Platform) isAllowed(sender string) bool {
+	for _, entry := range p.allowed {
+		if entry == sender || strings.HasPrefix(entry, "@") && strings.HasSuffix(sender, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMessage records the thread and passes the mail to the router
+func (p *Platform) handleMessage(m *inbound) {
+	if m.MessageID == "" {
+		m.MessageID = fmt.Sprintf("<%d@%s>", time.Now().UnixNano(), domain(p.address))
+	}
+	root := m.threadRoot()
+
+	references := m.References
+	if len(references) == 0 && m.InReplyTo != "" {
+		references = []string{m.InReplyTo}
+	}
+	references = append(append([]string(nil), references...), m.MessageID)
+
+	p.threadsMu.Lock()
+	p.threads[root] = &thread{
+		to:         m.From.Address,
+		subject:    baseSubject(m.Subject),
+		lastID:     m.MessageID,
+		references: references,
+		used:       time.Now(),
+	}
+	p.threadsMu.Unlock()
+
+	text := m.Text
+	if root == m.MessageID && m.Subject != "" {
+		// The subject often is the request when a thread starts
+		text = strings.TrimSpace("Subject: " + m.Subject + "\n\n" + text)
+	}
+	if text == "" && len(m.Attachments) == 0 {
+		return
+	}
+
+	if p.messageHandler != nil {
+		p.messageHandler(router.Message{
+			ID:          m.MessageID,
+			Platform:    "email",
+			ChannelID:   root,
+			UserID:      strings.ToLower(m.From.Address),
+			Username:    orDefault(m.From.Name, m.From.Address),
+			Text:        text,
+			Attachments: m.Attachments,
+			Metadata:    map[string]string{"subject": m.Subject},
+		})
+	}
+}
+
+// baseSubject strips reply and forward prefixes
+func baseSubject(subject string) string {
+	for {
+		trimmed := strings.TrimSpace(subject)
+		lower := strings.ToLower(trimmed)
+		cut := false
+		for _, prefix := range []string{"re:", "fwd:", "fw:", "回复:", "回复：", "转发:", "转发："} {
+			if strings.HasPrefix(lower, prefix) {
+				trimmed = trimmed[len(prefix):]
+				cut = true
+				break
+			}
+		}
+		if !cut {
+			return trimmed
+		}
+		subject = trimmed
+	}
+}
+
+func replySubject(subject string) string {
+	if subject == "" {
+		return "Re: " + defaultSubject
+	}
+	return "Re: " + subject
+}
+
+func domain(address string) string {
+	if _, d, ok := strings.Cut(address, "@"); ok {
+		return d
+	}
+	return "localhost"
+}
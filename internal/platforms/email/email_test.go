@@ -0,0 +1,311 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// mailbox is an in-process IMAP server holding one mailbox
+type mailbox struct {
+	mu       sync.Mutex
+	messages []*stored
+	uidNext  uint32
+}
+
+type stored struct {
+	uid  uint32
+	raw  string
+	seen bool
+}
+
+// deliver adds an unread message and returns its UID
+func (mb *mailbox) deliver(raw string) uint32 {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.uidNext++
+	mb.messages = append(mb.messages, &stored{uid: mb.uidNext, raw: strings.ReplaceAll(raw, "\n", "\r\n")})
+	return mb.uidNext
+}
+
+func (mb *mailbox) seen(uid uint32) bool {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for _, m := range mb.messages {
+		if m.uid == uid {
+			return m.seen
+		}
+	}
+	return false
+}
+
+func (mb *mailbox) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK fake IMAP ready\r\n")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+
+		mb.mu.Lock()
+		switch verb := strings.Fields(cmd); {
+		case strings.HasPrefix(cmd, "SELECT"):
+			fmt.Fprintf(conn, "* OK [UIDNEXT %d]\r\n", mb.uidNext+1)
+		case strings.HasPrefix(cmd, "UID SEARCH"):
+			var from uint32
+			fmt.Sscanf(verb[3], "%d:*", &from)
+			var uids []string
+			for _, m := range mb.messages {
+				if !m.seen && m.uid >= from {
+					uids = append(uids, fmt.Sprint(m.uid))
+				}
+			}
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case strings.HasPrefix(cmd, "UID FETCH"):
+			for _, m := range mb.messages {
+				if fmt.Sprint(m.uid) == verb[2] {
+					fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", m.uid, m.uid, len(m.raw), m.raw)
+				}
+			}
+		case strings.HasPrefix(cmd, "UID STORE"):
+			for _, m := range mb.messages {
+				if fmt.Sprint(m.uid) == verb[2] {
+					m.seen = true
+				}
+			}
+		case cmd == "LOGOUT":
+			fmt.Fprint(conn, "* BYE\r\n")
+		}
+		mb.mu.Unlock()
+
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+	}
+}
+
+// smtpServer accepts mail without authentication and hands over each message,
+// with LF line endings
+func smtpServer(t *testing.T, conn net.Conn, received chan<- string) {
+	defer conn.Close()
+	c := textproto.NewConn(conn)
+	c.PrintfLine("220 fake SMTP ready")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		switch verb, _, _ := strings.Cut(line, " "); strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			c.PrintfLine("250 localhost")
+		case "DATA":
+			c.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(c.DotReader())
+			if err != nil {
+				t.Errorf("reading DATA: %v", err)
+				return
+			}
+			received <- string(data)
+			c.PrintfLine("250 queued")
+		case "QUIT":
+			c.PrintfLine("221 bye")
+			return
+		default:
+			c.PrintfLine("250 ok")
+		}
+	}
+}
+
+// listen serves every connection to a local port with serve
+func listen(t *testing.T, serve func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// testBot is a platform polling a fake mailbox and sending through a fake SMTP server
+type testBot struct {
+	*Platform
+	mailbox  *mailbox
+	messages chan router.Message
+	outbox   chan string
+}
+
+func newTestBot(t *testing.T, configure func(*Config)) *testBot {
+	t.Helper()
+	b := &testBot{
+		mailbox:  &mailbox{},
+		messages: make(chan router.Message, 10),
+		outbox:   make(chan string, 10),
+	}
+	// Mail that was waiting before the bot started is left for a human
+	b.mailbox.deliver(newMail("old@example.com", "<old@example.com>", "Earlier", "from before", authPass))
+
+	cfg := Config{
+		IMAPAddr:       listen(t, b.mailbox.serve),
+		SMTPAddr:       listen(t, func(conn net.Conn) { smtpServer(t, conn, b.outbox) }),
+		Username:       "bot@example.org",
+		Password:       "secret",
+		PollInterval:   10 * time.Millisecond,
+		AllowedSenders: []string{"alice@example.com", "@example.com"},
+		Insecure:       true,
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetMessageHandler(func(msg router.Message) { b.messages <- msg })
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Stop() })
+	b.Platform = p
+	return b
+}
+
+// receive waits for the next message handed to the router
+func (b *testBot) receive(t *testing.T) router.Message {
+	t.Helper()
+	select {
+	case msg := <-b.messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+		return router.Message{}
+	}
+}
+
+// authPass is what the receiving server adds for an authenticated example.com sender
+const authPass = "Authentication-Results: mx.example.org; dkim=pass header.d=example.com; spf=pass smtp.mailfrom=example.com\n"
+
+func newMail(from, id, subject, body, headers string) string {
+	return headers + "From: " + from + "\nTo: bot@example.org\nSubject: " + subject +
+		"\nMessage-ID: " + id + "\nContent-Type: text/plain; charset=utf-8\n\n" + body + "\n"
+}
+
+func TestReceiveAndReplyInThread(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	uid := b.mailbox.deliver(newMail("Alice <alice@example.com>", "<m1@example.com>", "Weekly report", "Can you summarise it?", authPass))
+
+	msg := b.receive(t)
+	if msg.ChannelID != "<m1@example.com>" || msg.UserID != "alice@example.com" || msg.Username != "Alice" {
+		t.Errorf("channel %q, user %q (%q)", msg.ChannelID, msg.UserID, msg.Username)
+	}
+	if msg.Text != "Subject: Weekly report\n\nCan you summarise it?" {
+		t.Errorf("text = %q", msg.Text)
+	}
+	if !b.mailbox.seen(uid) {
+		t.Error("answered mail was left unread")
+	}
+
+	replyID, err := b.Send(context.Background(), msg.ChannelID, router.Response{Text: "Sure, **here** it is."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := <-b.outbox
+	for _, header := range []string{
+		"To: <alice@example.com>",
+		"Subject: Re: Weekly report",
+		"In-Reply-To: <m1@example.com>",
+		"References: <m1@example.com>",
+		"Message-ID: " + replyID,
+	} {
+		if !strings.Contains(sent, header+"\n") {
+			t.Errorf("reply lacks %q:\n%s", header, sent)
+		}
+	}
+
+	// Alice answers the bot's reply: same conversation, quoted text removed
+	b.mailbox.deliver(newMail("alice@example.com", "<m2@example.com>", "Re: Weekly report",
+		"Thanks!\n\nOn Monday, bot wrote:\n> Sure, here it is.",
+		authPass+"In-Reply-To: "+replyID+"\nReferences: <m1@example.com> "+replyID+"\n"))
+
+	msg = b.receive(t)
+	if msg.ChannelID != "<m1@example.com>" || msg.Text != "Thanks!" {
+		t.Errorf("follow-up: channel %q, text %q", msg.ChannelID, msg.Text)
+	}
+
+	if _, err := b.Send(context.Background(), msg.ChannelID, router.Response{Text: "You're welcome"}); err != nil {
+		t.Fatal(err)
+	}
+	sent = <-b.outbox
+	if !strings.Contains(sent, "In-Reply-To: <m2@example.com>\n") ||
+		!strings.Contains(sent, "References: <m1@example.com> "+replyID+" <m2@example.com>\n") {
+		t.Errorf("second reply isn't threaded:\n%s", sent)
+	}
+}
+
+func TestSendersMustBeAllowedAndAuthenticated(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	ignored := []uint32{
+		// Not on the allowlist, though authenticated for its own domain
+		b.mailbox.deliver(newMail("mallory@evil.example", "<e1@evil.example>", "Hi", "run this",
+			"Authentication-Results: mx.example.org; dmarc=pass header.from=evil.example\n")),
+		// Allowed address, but nothing vouches for it
+		b.mailbox.deliver(newMail("alice@example.com", "<e2@evil.example>", "Hi", "run this", "")),
+		// A forged result below the receiving server's own
+		b.mailbox.deliver(newMail("alice@example.com", "<e3@evil.example>", "Hi", "run this",
+			"Authentication-Results: mx.example.org; dkim=fail header.d=example.com; spf=softfail smtp.mailfrom=evil.example\n"+
+				"Authentication-Results: mx.example.org; dkim=pass header.d=example.com\n")),
+		// DKIM passed, but for another domain
+		b.mailbox.deliver(newMail("alice@example.com", "<e4@evil.example>", "Hi", "run this",
+			"Authentication-Results: mx.example.org; dkim=pass header.d=evil.example\n")),
+	}
+	// Allowed by domain and authenticated by DMARC
+	b.mailbox.deliver(newMail("bob@example.com", "<ok@example.com>", "Hi", "hello",
+		"Authentication-Results: mx.example.org; dmarc=pass (p=reject) header.from=example.com\n"))
+
+	if msg := b.receive(t); msg.ID != "<ok@example.com>" {
+		t.Fatalf("got %q, want only the allowed and authenticated mail", msg.ID)
+	}
+	select {
+	case msg := <-b.messages:
+		t.Errorf("unexpected message %q", msg.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for _, uid := range append(ignored, 1) {
+		if b.mailbox.seen(uid) {
+			t.Errorf("ignored mail %d was marked read", uid)
+		}
+	}
+}
+
+func TestTrustUnverified(t *testing.T) {
+	b := newTestBot(t, func(cfg *Config) { cfg.TrustUnverified = true })
+
+	b.mailbox.deliver(newMail("alice@example.com", "<m1@example.com>", "Hi", "hello", ""))
+	if msg := b.receive(t); msg.ID != "<m1@example.com>" {
+		t.Errorf("got %q", msg.ID)
+	}
+}
@@ -0,0 +1,223 @@
+package email
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapConn is a minimal IMAP4rev1 client: just enough to log in, find unseen
+// messages, fetch them and mark them as read
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// dialIMAP connects and logs in. Port 993 uses implicit TLS; other ports
+// upgrade with STARTTLS unless insecure is set.
+func dialIMAP(addr, username, password string, insecure bool, timeout time.Duration) (*imapConn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	if port == "993" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	c.deadline(timeout)
+	greeting, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting: %s", greeting)
+	}
+
+	if _, ok := conn.(*tls.Conn); !ok && !insecure {
+		if _, err := c.command("STARTTLS"); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("STARTTLS: %w", err)
+		}
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		c.conn, c.r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	if _, err := c.command("LOGIN " + quote(username) + " " + quote(password)); err != nil {
+		c.conn.Close()
+		return nil, fmt.Errorf("login failed: %w", err)
+	}
+	return c, nil
+}
+
+func (c *imapConn) deadline(timeout time.Duration) {
+	_ = c.conn.SetDeadline(time.Now().Add(timeout))
+}
+
+// selectMailbox opens a mailbox for reading and writing flags and returns
+// the UID the next delivered message will get (0 if the server didn't say)
+func (c *imapConn) selectMailbox(name string) (uint32, error) {
+	responses, err := c.command("SELECT " + quote(name))
+	if err != nil {
+		return 0, err
+	}
+	for _, resp := range responses {
+		_, rest, ok := strings.Cut(resp.line, "[UIDNEXT ")
+		if !ok {
+			continue
+		}
+		value, _, _ := strings.Cut(rest, "]")
+		if uid, err := strconv.ParseUint(value, 10, 32); err == nil {
+			return uint32(uid), nil
+		}
+	}
+	return 0, nil
+}
+
+// searchUnseen returns the UIDs of unread messages from minUID on
+func (c *imapConn) searchUnseen(minUID uint32) ([]uint32, error) {
+	responses, err := c.command(fmt.Sprintf("UID SEARCH UID %d:* UNSEEN", max(minUID, 1)))
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, resp := range responses {
+		fields, ok := strings.CutPrefix(resp.line, "* SEARCH")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(fields) {
+			// "n:*" always matches the highest UID, even below n
+			if uid, err := strconv.ParseUint(field, 10, 32); err == nil && uint32(uid) >= minUID {
+				uids = append(uids, uint32(uid))
+			}
+		}
+	}
+	return uids, nil
+}
+
+// fetch returns the raw RFC 5322 message without setting \Seen
+func (c *imapConn) fetch(uid uint32) ([]byte, error) {
+	responses, err := c.command(fmt.Sprintf("UID FETCH %d (BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range responses {
+		if strings.Contains(resp.line, "FETCH") && len(resp.literals) > 0 {
+			return resp.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("message %d not found", uid)
+}
+
+// markSeen sets \Seen on a message
+func (c *imapConn) markSeen(uid uint32) error {
+	_, err := c.command(fmt.Sprintf("UID STORE %d +FLAGS.SILENT (\\Seen)", uid))
+	return err
+}
+
+// logout ends the session and closes the connection
+func (c *imapConn) logout() {
+	c.deadline(5 * time.Second)
+	_, _ = c.command("LOGOUT")
+	c.conn.Close()
+}
+
+// imapResponse is an untagged response line with its literals
+type imapResponse struct {
+	line     string
+	literals [][]byte
+}
+
+// command sends a tagged command and collects untagged responses until the
+// tagged completion, which must be OK
+func (c *imapConn) command(cmd string) ([]imapResponse, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(resp.line, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("%s", status)
+			}
+			return responses, nil
+		}
+		responses = append(responses, resp)
+	}
+}
+
+// readResponse reads one response line, including any {n} literals it carries
+func (c *imapConn) readResponse() (imapResponse, error) {
+	var resp imapResponse
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return resp, err
+		}
+		size, ok := literalSize(line)
+		if !ok {
+			resp.line += line
+			return resp, nil
+		}
+		resp.line += line
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return resp, err
+		}
+		resp.literals = append(resp.literals, literal)
+	}
+}
+
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// literalSize parses a trailing "{123}" literal announcement
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[open+1:len(line)-1], "+"))
+	return n, err == nil && n >= 0
+}
+
+// quote formats an IMAP quoted string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,356 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// inbound is a received email reduced to what the bot needs
+type inbound struct {
+	MessageID   string
+	InReplyTo   string
+	References  []string
+	From        *mail.Address
+	Subject     string
+	Text        string // New text only: quoted replies and signatures removed
+	Attachments []router.Attachment
+	AutoReply   bool // Auto-responders, bounces and mailing lists
+	Verified    bool // The receiving server authenticated the From domain
+}
+
+// threadRoot returns the Message-ID that identifies the conversation
+func (m *inbound) threadRoot() string {
+	if len(m.References) > 0 {
+		return m.References[0]
+	}
+	if m.InReplyTo != "" {
+		return m.InReplyTo
+	}
+	return m.MessageID
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// parseMessage parses a raw RFC 5322 message
+func parseMessage(raw []byte, maxAttachment int) (*inbound, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	h := msg.Header
+
+	m := &inbound{
+		MessageID:  firstID(h.Get("Message-ID")),
+		InReplyTo:  firstID(h.Get("In-Reply-To")),
+		References: messageIDs(h.Get("References")),
+		Subject:    decodeHeader(h.Get("Subject")),
+	}
+	if m.From, err = (&mail.AddressParser{WordDecoder: wordDecoder}).Parse(h.Get("From")); err != nil {
+		return nil, fmt.Errorf("bad From header: %w", err)
+	}
+
+	m.Verified = authenticated(h["Authentication-Results"], m.From.Address)
+
+	auto := strings.ToLower(h.Get("Auto-Submitted"))
+	precedence := strings.ToLower(h.Get("Precedence"))
+	m.AutoReply = (auto != "" && auto != "no") || precedence == "bulk" || precedence == "list" ||
+		precedence == "junk" || h.Get("List-Id") != "" || h.Get("X-Autoreply") != ""
+
+	var body bodyParts
+	if err := body.walk(h, msg.Body, maxAttachment, 0); err != nil {
+		return nil, err
+	}
+	text := body.plain
+	if text == "" {
+		text = htmlToText(body.html)
+	}
+	m.Text = stripQuoted(text)
+	for _, fwd := range body.forwarded {
+		m.Text += "\n\n" + fwd
+	}
+	m.Text = strings.TrimSpace(m.Text)
+	m.Attachments = body.attachments
+	return m, nil
+}
+
+// authenticated reports whether the receiving server vouched for the From
+// domain: DMARC passed for it, or DKIM or SPF passed for it or a parent
+// domain. Only the topmost Authentication-Results header is trusted; the
+// sender can add any number of their own further down.
+func authenticated(results []string, from string) bool {
+	if len(results) == 0 {
+		return false
+	}
+	fromDomain := strings.ToLower(domain(from))
+
+	// Skip the authserv-id before the first result
+	_, methods, _ := strings.Cut(results[0], ";")
+	for _, method := range strings.Split(methods, ";") {
+		fields := strings.Fields(strings.ToLower(method))
+		if len(fields) == 0 {
+			continue
+		}
+		var properties []string
+		switch fields[0] {
+		case "dmarc=pass":
+			properties = []string{"header.from="}
+		case "dkim=pass":
+			properties = []string{"header.d=", "header.i="}
+		case "spf=pass":
+			properties = []string{"smtp.mailfrom="}
+		default:
+			continue
+		}
+		for _, field := range fields[1:] {
+			for _, property := range properties {
+				value, ok := strings.CutPrefix(field, property)
+				if !ok {
+					continue
+				}
+				value = strings.Trim(value, `"`)
+				if at := strings.LastIndexByte(value, '@'); at >= 0 {
+					value = value[at+1:]
+				}
+				if value != "" && (value == fromDomain || strings.HasSuffix(fromDomain, "."+value)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// bodyParts collects the readable text and files of a MIME tree
+type bodyParts struct {
+	plain       string
+	html        string
+	forwarded   []string // Attached messages (forward as attachment), rendered as text
+	attachments []router.Attachment
+}
+
+// header is the subset of a MIME header the walker needs
+type header interface {
+	Get(key string) string
+}
+
+// walk descends into a MIME part
+func (b *bodyParts) walk(h header, r io.Reader, maxAttachment, depth int) error {
+	if depth > 10 {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := b.walk(part.Header, part, maxAttachment, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(h.Get("Content-Transfer-Encoding"), r))
+	if err != nil {
+		return err
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	filename := decodeHeader(dispParams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+	isAttachment := disposition == "attachment" || filename != ""
+
+	switch {
+	case mediaType == "message/rfc822":
+		fwd, err := parseMessage(data, maxAttachment)
+		if err != nil {
+			return nil
+		}
+		b.forwarded = append(b.forwarded, fmt.Sprintf("---------- Forwarded message ---------\nFrom: %s\nSubject: %s\n\n%s",
+			fwd.From, fwd.Subject, fwd.Text))
+		b.attachments = append(b.attachments, fwd.Attachments...)
+	case isAttachment:
+		if len(data) > maxAttachment {
+			b.plain += fmt.Sprintf("\n[附件 %s 过大，已忽略]", filename)
+			return nil
+		}
+		b.attachments = append(b.attachments, router.Attachment{
+			Name:     orDefault(filename, "attachment"),
+			MimeType: mediaType,
+			Data:     data,
+		})
+	case mediaType == "text/plain" && b.plain == "":
+		b.plain = decodeCharset(params["charset"], data)
+	case mediaType == "text/html" && b.html == "":
+		b.html = decodeCharset(params["charset"], data)
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// newlineStripper drops CR and LF, which base64.NewDecoder does not accept
+// everywhere mail clients put them
+type newlineStripper struct{ r io.Reader }
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	for {
+		count, err := n.r.Read(p)
+		kept := 0
+		for _, c := range p[:count] {
+			if c != '\r' && c != '\n' && c != ' ' {
+				p[kept] = c
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// decodeCharset converts a text part to UTF-8. Only UTF-8, ASCII and Latin-1
+// are converted; other charsets are passed through unchanged.
+func decodeCharset(charset string, data []byte) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		return latin1(data)
+	default:
+		return string(data)
+	}
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeCharset(charset, data)), nil
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, c := range data {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// decodeHeader decodes RFC 2047 encoded words
+func decodeHeader(s string) string {
+	if decoded, err := wordDecoder.DecodeHeader(s); err == nil {
+		return decoded
+	}
+	return s
+}
+
+var messageIDPattern = regexp.MustCompile(`<[^<>\s]+>`)
+
+// messageIDs extracts the <id> tokens of a Message-ID list header
+func messageIDs(s string) []string {
+	return messageIDPattern.FindAllString(s, -1)
+}
+
+func firstID(s string) string {
+	if ids := messageIDs(s); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.TrimSpace(s)
+}
+
+var (
+	// Where quoted history starts: "On Mon, 1 Jan 2024, Bob <b@x> wrote:",
+	// "在 2024年1月1日，Bob 写道：", Outlook separators and headers
+	replyHeader = regexp.MustCompile(`(?i)^(on\s.+wrote:|在.+写道[:：]|-{2,}\s*original message\s*-{2,}|-{2,}\s*原始邮件\s*-{2,}|_{10,}|(from|发件人)[:：]\s.+)$`)
+	// Forwarded content is what the user wants acted on, so it is kept
+	forwardHeader = regexp.MustCompile(`(?i)^(-{2,}\s*forwarded message\s*-{2,}|begin forwarded message:|-{2,}\s*转发的邮件\s*-{2,}|-{2,}\s*转发邮件信息\s*-{2,})$`)
+	// Mobile client footers that are not worth sending to the agent
+	sentFrom = regexp.MustCompile(`(?i)^(sent from my .+|发自我的.+|get outlook for .+)$`)
+)
+
+// stripQuoted removes quoted replies ("> ..." and everything after an
+// "On ... wrote:" line) and signatures, but keeps forwarded messages
+func stripQuoted(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	var kept []string
+	inSignature := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if forwardHeader.MatchString(trimmed) {
+			kept = append(kept, lines[i:]...)
+			break
+		}
+		// Long "On ... wrote:" lines are often wrapped onto two
+		if replyHeader.MatchString(trimmed) ||
+			i+1 < len(lines) && strings.HasPrefix(trimmed, "On ") && replyHeader.MatchString(trimmed+" "+strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		if line == "-- " || line == "--" {
+			inSignature = true
+		}
+		if inSignature || strings.HasPrefix(trimmed, ">") || sentFrom.MatchString(trimmed) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+var (
+	htmlDrop  = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>`)
+	htmlQuote = regexp.MustCompile(`(?is)<blockquote[^>]*>.*?</blockquote>`)
+	htmlTag   = regexp.MustCompile(`<[^>]+>`)
+	blankRuns = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText reduces an HTML body to plain text, dropping quoted blocks
+func htmlToText(s string) string {
+	s = htmlDrop.ReplaceAllString(s, "")
+	s = htmlQuote.ReplaceAllString(s, "")
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, " ", " ")
+	return strings.TrimSpace(blankRuns.ReplaceAllString(s, "\n\n"))
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,219 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// outgoing is a mail the bot sends
+type outgoing struct {
+	From       string
+	To         string
+	Subject    string
+	InReplyTo  string
+	References []string
+	Text       string // Markdown; sent as plain text with an HTML alternative
+	Attachment *router.Attachment
+}
+
+// build renders the message and returns its new Message-ID and bytes
+func (o *outgoing) build(address string) (string, []byte, error) {
+	from, err := mail.ParseAddress(o.From)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddress(o.To)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid recipient %q: %w", o.To, err)
+	}
+	messageID, err := newMessageID(address)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", o.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	if o.InReplyTo != "" {
+		header("In-Reply-To", o.InReplyTo)
+	}
+	if len(o.References) > 0 {
+		header("References", strings.Join(o.References, " "))
+	}
+	// Keeps vacation responders from answering the bot
+	if o.InReplyTo != "" {
+		header("Auto-Submitted", "auto-replied")
+	} else {
+		header("Auto-Submitted", "auto-generated")
+	}
+	header("MIME-Version", "1.0")
+
+	contentType, body, err := alternative(o.Text)
+	if err != nil {
+		return "", nil, err
+	}
+	if o.Attachment == nil {
+		header("Content-Type", contentType)
+		buf.WriteString("\r\n")
+		buf.Write(body)
+		return messageID, buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+	part, err := mixed.CreatePart(textproto.MIMEHeader{"Content-Type": {contentType}})
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := part.Write(body); err != nil {
+		return "", nil, err
+	}
+
+	file := o.Attachment
+	part, err = mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {orDefault(file.MimeType, "application/octet-stream")},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": file.Name})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(file.Data)
+	for len(encoded) > 76 {
+		fmt.Fprintf(part, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(part, "%s\r\n", encoded)
+	if err := mixed.Close(); err != nil {
+		return "", nil, err
+	}
+	return messageID, buf.Bytes(), nil
+}
+
+// alternative renders the text and its HTML rendering as a
+// multipart/alternative body and returns the body's Content-Type
+func alternative(text string) (string, []byte, error) {
+	var buf bytes.Buffer
+	alt := multipart.NewWriter(&buf)
+	for _, p := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", markdown.HTML(text)},
+	} {
+		part, err := alt.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.body)); err != nil {
+			return "", nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := alt.Close(); err != nil {
+		return "", nil, err
+	}
+	return "multipart/alternative; boundary=" + alt.Boundary(), buf.Bytes(), nil
+}
+
+// newMessageID returns a unique Message-ID in the bot's domain
+func newMessageID(address string) (string, error) {
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().Unix(), hex.EncodeToString(random), domain(address)), nil
+}
+
+// sendMail delivers a message over SMTP. Port 465 uses implicit TLS; other
+// ports upgrade with STARTTLS unless Insecure is set.
+func (p *Platform) sendMail(ctx context.Context, to string, data []byte) error {
+	recipient, err := mail.ParseAddress(to)
+	if err != nil {
+		return err
+	}
+	host, port, err := net.SplitHostPort(p.cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: ioTimeout}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", p.cfg.SMTPAddr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", p.cfg.SMTPAddr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP: %w", err)
+	}
+	deadline := time.Now().Add(ioTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if _, isTLS := conn.(*tls.Conn); !isTLS && !p.cfg.Insecure {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok {
+		if err := c.Auth(smtp.PlainAuth("", p.cfg.Username, p.cfg.Password, host)); err != nil {
+			return fmt.Errorf("SMTP auth failed: %w", err)
+		}
+	}
+
+	if err := c.Mail(p.address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(recipient.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,243 @@
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// maxHistory is Feishu's page size limit for listing messages
+const maxHistory = 50
+
+// Edit replaces the content of a message the bot sent. Cards are patched in
+// place, which also suits streaming updates; messages that fell back to rich
+// text are updated instead.
+func (p *Platform) Edit(ctx context.Context, chatID, messageID string, resp router.Response) error {
+	cardErr := p.patchCard(ctx, messageID, resp)
+	if cardErr == nil {
+		return nil
+	}
+
+	post, err := json.Marshal(markdown.FeishuPost(resp.Text, ""))
+	if err != nil {
+		return fmt.Errorf("failed to marshal message content: %w", err)
+	}
+
+	req := larkim.NewUpdateMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewUpdateMessageReqBodyBuilder().
+			MsgType(larkim.MsgTypePost).
+			Content(string(post)).
+			Build()).
+		Build()
+
+	result, err := p.client.Im.Message.Update(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
+	if !result.Success() {
+		return fmt.Errorf("failed to edit message: code=%d, msg=%s (%v)", result.Code, result.Msg, cardErr)
+	}
+	return nil
+}
+
+// Delete recalls a message
+func (p *Platform) Delete(ctx context.Context, chatID, messageID string) error {
+	req := larkim.NewDeleteMessageReqBuilder().
+		MessageId(messageID).
+		Build()
+
+	result, err := p.client.Im.Message.Delete(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+	if !result.Success() {
+		return fmt.Errorf("failed to delete message: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// React adds a reaction; emoji is a Feishu emoji type such as "THUMBSUP"
+func (p *Platform) React(ctx context.Context, chatID, messageID, emoji string) error {
+	req := larkim.NewCreateMessageReactionReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewCreateMessageReactionReqBodyBuilder().
+			ReactionType(larkim.NewEmojiBuilder().EmojiType(emoji).Build()).
+			Build()).
+		Build()
+
+	result, err := p.client.Im.MessageReaction.Create(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to add reaction: %w", err)
+	}
+	if !result.Success() {
+		return fmt.Errorf("failed to add reaction: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// Unreact removes the bot's reactions of the given type
+func (p *Platform) Unreact(ctx context.Context, chatID, messageID, emoji string) error {
+	req := larkim.NewListMessageReactionReqBuilder().
+		MessageId(messageID).
+		ReactionType(emoji).
+		Build()
+
+	result, err := p.client.Im.MessageReaction.List(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to list reactions: %w", err)
+	}
+	if !result.Success() {
+		return fmt.Errorf("failed to list reactions: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	if result.Data == nil {
+		return nil
+	}
+
+	for _, reaction := range result.Data.Items {
+		if reaction.ReactionId == nil || reaction.Operator == nil ||
+			reaction.Operator.OperatorType == nil || *reaction.Operator.OperatorType != "app" {
+			continue
+		}
+
+		del := larkim.NewDeleteMessageReactionReqBuilder().
+			MessageId(messageID).
+			ReactionId(*reaction.ReactionId).
+			Build()
+
+		resp, err := p.client.Im.MessageReaction.Delete(ctx, del)
+		if err != nil {
+			return fmt.Errorf("failed to remove reaction: %w", err)
+		}
+		if !resp.Success() {
+			return fmt.Errorf("failed to remove reaction: code=%d, msg=%s", resp.Code, resp.Msg)
+		}
+	}
+	return nil
+}
+
+// SendFile uploads a file and sends it to a chat, images as image messages and
+// everything else as file messages. Feishu file messages have no caption, so
+// resp.Text is sent as a separate message first.
+func (p *Platform) SendFile(ctx context.Context, chatID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+
+	if resp.Text != "" {
+		if _, err := p.Send(ctx, chatID, resp); err != nil {
+			return "", err
+		}
+	}
+
+	var msgType string
+	var content map[string]string
+
+	if strings.HasPrefix(file.MimeType, "image/") {
+		req := larkim.NewCreateImageReqBuilder().
+			Body(larkim.NewCreateImageReqBodyBuilder().
+				ImageType(larkim.ImageTypeMessage).
+				Image(bytes.NewReader(file.Data)).
+				Build()).
+			Build()
+
+		result, err := p.client.Im.Image.Create(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("failed to upload image: %w", err)
+		}
+		if !result.Success() || result.Data == nil || result.Data.ImageKey == nil {
+			return "", fmt.Errorf("failed to upload image: code=%d, msg=%s", result.Code, result.Msg)
+		}
+		msgType, content = larkim.MsgTypeImage, map[string]string{"image_key": *result.Data.ImageKey}
+	} else {
+		req := larkim.NewCreateFileReqBuilder().
+			Body(larkim.NewCreateFileReqBodyBuilder().
+				FileType(larkim.FileTypeStream).
+				FileName(file.Name).
+				File(bytes.NewReader(file.Data)).
+				Build()).
+			Build()
+
+		result, err := p.client.Im.File.Create(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("failed to upload file: %w", err)
+		}
+		if !result.Success() || result.Data == nil || result.Data.FileKey == nil {
+			return "", fmt.Errorf("failed to upload file: code=%d, msg=%s", result.Code, result.Msg)
+		}
+		msgType, content = larkim.MsgTypeFile, map[string]string{"file_key": *result.Data.FileKey}
+	}
+
+	body, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message content: %w", err)
+	}
+	return p.createMessage(ctx, chatID, msgType, string(body))
+}
+
+// FetchHistory returns up to limit recent chat messages, oldest first
+func (p *Platform) FetchHistory(ctx context.Context, chatID string, limit int) ([]router.Message, error) {
+	if limit <= 0 || limit > maxHistory {
+		limit = maxHistory
+	}
+
+	req := larkim.NewListMessageReqBuilder().
+		ContainerIdType("chat").
+		ContainerId(chatID).
+		SortType(larkim.SortTypeListMessageByCreateTimeDesc).
+		PageSize(limit).
+		Build()
+
+	result, err := p.client.Im.Message.List(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	if !result.Success() {
+		return nil, fmt.Errorf("failed to list messages: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	if result.Data == nil {
+		return nil, nil
+	}
+
+	// Requested newest first so the limit keeps the latest messages
+	items := result.Data.Items
+	history := make([]router.Message, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		m := items[i]
+		if m.Deleted != nil && *m.Deleted {
+			continue
+		}
+
+		msg := router.Message{
+			Platform:  "feishu",
+			ChannelID: chatID,
+		}
+		if m.MessageId != nil {
+			msg.ID = *m.MessageId
+		}
+		if m.Sender != nil && m.Sender.Id != nil {
+			msg.UserID = *m.Sender.Id
+		}
+		if m.MsgType != nil && m.Body != nil && m.Body.Content != nil {
+			msg.Text = messageText(*m.MsgType, *m.Body.Content)
+		}
+		history = append(history, msg)
+	}
+	return history, nil
+}
+
+// messageText extracts readable text from a message's content JSON
+func messageText(msgType, content string) string {
+	c, err := parseContent(msgType, content)
+	if err != nil {
+		return ""
+	}
+	return c.text(msgType)
+}
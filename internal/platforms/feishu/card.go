@@ -0,0 +1,129 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// buildCard renders a response as message card JSON. resp.Metadata["title"]
+// becomes the card header; buttons are added as a final action row.
+func buildCard(resp router.Response, buttons []router.Button) (string, error) {
+	card := markdown.FeishuCard(resp.Text, resp.Metadata["title"])
+
+	if len(buttons) > 0 {
+		actions := make([]map[string]any, 0, len(buttons))
+		for _, b := range buttons {
+			actions = append(actions, map[string]any{
+				"tag":   "button",
+				"text":  map[string]any{"tag": "plain_text", "content": b.Label},
+				"type":  buttonType(b.Style),
+				"value": map[string]string{"value": b.Value},
+			})
+		}
+		card["elements"] = append(card["elements"].([]markdown.FeishuElement),
+			markdown.FeishuElement{"tag": "action", "actions": actions})
+	}
+
+	content, err := json.Marshal(card)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal card: %w", err)
+	}
+	return string(content), nil
+}
+
+// buttonType maps a router button style to a Feishu button type
+func buttonType(style string) string {
+	switch style {
+	case "primary", "danger":
+		return style
+	default:
+		return "default"
+	}
+}
+
+// SendInteractive sends a message card with a row of buttons
+func (p *Platform) SendInteractive(ctx context.Context, chatID string, resp router.Response, buttons []router.Button) (string, error) {
+	card, err := buildCard(resp, buttons)
+	if err != nil {
+		return "", err
+	}
+	return p.createMessage(ctx, chatID, larkim.MsgTypeInteractive, card)
+}
+
+// patchCard replaces the content of a card the bot sent. Feishu allows this
+// for 14 days after sending.
+func (p *Platform) patchCard(ctx context.Context, messageID string, resp router.Response) error {
+	card, err := buildCard(resp, nil)
+	if err != nil {
+		return err
+	}
+
+	req := larkim.NewPatchMessageReqBuilder().
+		MessageId(messageID).
+		Body(larkim.NewPatchMessageReqBodyBuilder().
+			Content(card).
+			Build()).
+		Build()
+
+	result, err := p.client.Im.Message.Patch(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to patch card: %w", err)
+	}
+	if !result.Success() {
+		return fmt.Errorf("failed to patch card: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// handleCardAction turns a card button click (card.action.trigger) into a
+// message for the router. Feishu expects an answer within three seconds, so
+// the click is acknowledged before the router sees it.
+func (p *Platform) handleCardAction(ctx context.Context, event *callback.CardActionTriggerEvent) (*callback.CardActionTriggerResponse, error) {
+	if event == nil || event.Event == nil || event.Event.Action == nil ||
+		event.Event.Operator == nil || event.Event.Context == nil {
+		return nil, nil
+	}
+	if !p.authentic(event.EventV2Base) {
+		return nil, errInvalidToken
+	}
+	action := event.Event.Action
+
+	value, _ := action.Value["value"].(string)
+	if value == "" {
+		value = action.Option
+	}
+	if value == "" || p.messageHandler == nil || event.Event.Operator.OpenID == p.botOpenID {
+		return nil, nil
+	}
+
+	id := "card:" + event.Event.Token
+	if event.EventV2Base != nil && event.EventV2Base.Header != nil {
+		id = "card:" + event.EventV2Base.Header.EventID
+	}
+
+	msg := router.Message{
+		ID:        id,
+		Platform:  "feishu",
+		ChannelID: event.Event.Context.OpenChatID,
+		UserID:    event.Event.Operator.OpenID,
+		Text:      value,
+		Metadata: map[string]string{
+			"button":     "true",
+			"action":     action.Tag,
+			"message_id": event.Event.Context.OpenMessageID,
+		},
+	}
+
+	go func() {
+		msg.Username = p.getUsername(p.ctx, msg.UserID)
+		p.messageHandler(msg)
+	}()
+	return nil, nil
+}
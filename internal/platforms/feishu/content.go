@@ -0,0 +1,339 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+const (
+	// maxAttachmentSize caps files downloaded from incoming messages
+	maxAttachmentSize = 20 << 20
+
+	// msgTypeMergeForward is a bundle of forwarded messages; the SDK has no
+	// constant for it
+	msgTypeMergeForward = "merge_forward"
+)
+
+// messageContent is the content JSON of the message types the bot reads
+type messageContent struct {
+	Text     string          `json:"text"`
+	Title    string          `json:"title"`
+	Content  [][]postElement `json:"content"`
+	ImageKey string          `json:"image_key"`
+	FileKey  string          `json:"file_key"`
+	FileName string          `json:"file_name"`
+}
+
+// postElement is an element of a rich text (post) paragraph
+type postElement struct {
+	Tag       string `json:"tag"`
+	Text      string `json:"text"`
+	Href      string `json:"href"`
+	UserID    string `json:"user_id"`
+	ImageKey  string `json:"image_key"`
+	FileKey   string `json:"file_key"`
+	EmojiType string `json:"emoji_type"`
+	Language  string `json:"language"`
+}
+
+// parseContent decodes a message's content JSON. Rich text read back through
+// the API is keyed by locale ({"zh_cn": {...}}); events carry it unwrapped.
+// Other message types are shown by type only and aren't decoded.
+func parseContent(msgType, raw string) (messageContent, error) {
+	var c messageContent
+	switch msgType {
+	case larkim.MsgTypeText, larkim.MsgTypePost, larkim.MsgTypeImage,
+		larkim.MsgTypeFile, larkim.MsgTypeAudio, larkim.MsgTypeMedia:
+	default:
+		return c, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		return c, fmt.Errorf("failed to unmarshal content: %w", err)
+	}
+	if msgType != larkim.MsgTypePost || c.Content != nil {
+		return c, nil
+	}
+
+	var locales map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &locales); err != nil {
+		return c, nil
+	}
+	for _, body := range locales {
+		var post messageContent
+		if json.Unmarshal(body, &post) == nil && post.Content != nil {
+			return post, nil
+		}
+	}
+	return c, nil
+}
+
+// text renders the content as readable text; images and files the bot
+// doesn't download are shown as placeholders
+func (c messageContent) text(msgType string) string {
+	switch msgType {
+	case larkim.MsgTypeText:
+		return c.Text
+	case larkim.MsgTypePost:
+		return c.postText()
+	case larkim.MsgTypeImage:
+		return "[image]"
+	case larkim.MsgTypeFile:
+		return "[file: " + c.FileName + "]"
+	case larkim.MsgTypeAudio:
+		return "[audio]"
+	case larkim.MsgTypeMedia:
+		return "[video: " + c.FileName + "]"
+	case msgTypeMergeForward:
+		return "[merged messages]"
+	default:
+		return "[" + msgType + "]"
+	}
+}
+
+// postText renders rich text with links as "label (url)", one line per paragraph
+func (c messageContent) postText() string {
+	var lines []string
+	if c.Title != "" {
+		lines = append(lines, c.Title)
+	}
+	for _, paragraph := range c.Content {
+		var line strings.Builder
+		for _, el := range paragraph {
+			switch el.Tag {
+			case "text":
+				line.WriteString(el.Text)
+			case "a":
+				if el.Text == "" || el.Text == el.Href {
+					line.WriteString(el.Href)
+				} else {
+					line.WriteString(el.Text + " (" + el.Href + ")")
+				}
+			case "at":
+				// The @_user_N key is removed with the other mentions
+				line.WriteString(el.UserID)
+			case "img":
+				line.WriteString("[image]")
+			case "media":
+				line.WriteString("[video]")
+			case "emotion":
+				line.WriteString(":" + el.EmojiType + ":")
+			case "code_block":
+				line.WriteString("```" + strings.ToLower(el.Language) + "\n" + strings.TrimRight(el.Text, "\n") + "\n```")
+			case "hr":
+				line.WriteString("———")
+			default:
+				line.WriteString(el.Text)
+			}
+		}
+		lines = append(lines, line.String())
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// readMessage extracts the text and attachments of a message. Images, files
+// and videos are downloaded; audio is transcribed when a transcriber is set,
+// otherwise attached. Merged forwards are expanded into their messages.
+func (p *Platform) readMessage(ctx context.Context, messageID, msgType, raw string) (string, []router.Attachment, map[string]string, error) {
+	c, err := parseContent(msgType, raw)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	metadata := map[string]string{}
+
+	var attachments []router.Attachment
+	attach := func(key, resourceType, name string) {
+		file, err := p.download(ctx, messageID, key, resourceType, name)
+		if err != nil {
+			log.Printf("[Feishu] Failed to download %s %s: %v", resourceType, key, err)
+			return
+		}
+		attachments = append(attachments, file)
+	}
+
+	switch msgType {
+	case larkim.MsgTypeText:
+		return c.Text, nil, metadata, nil
+
+	case larkim.MsgTypePost:
+		for _, paragraph := range c.Content {
+			for _, el := range paragraph {
+				switch {
+				case el.Tag == "img" && el.ImageKey != "":
+					attach(el.ImageKey, "image", "")
+				case el.Tag == "media" && el.FileKey != "":
+					attach(el.FileKey, "file", "")
+				}
+			}
+		}
+		return c.postText(), attachments, metadata, nil
+
+	case larkim.MsgTypeImage:
+		attach(c.ImageKey, "image", "")
+		return "", attachments, metadata, nil
+
+	case larkim.MsgTypeFile, larkim.MsgTypeMedia:
+		attach(c.FileKey, "file", c.FileName)
+		return "", attachments, metadata, nil
+
+	case larkim.MsgTypeAudio:
+		file, err := p.download(ctx, messageID, c.FileKey, "file", "")
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to download audio: %w", err)
+		}
+		file.MimeType = "audio/ogg" // Opus in Ogg
+		if p.transcriber == nil {
+			log.Printf("[Feishu] Audio message received but no transcriber configured")
+			return "", []router.Attachment{file}, metadata, nil
+		}
+		text, err := p.transcriber.Transcribe(ctx, file.Data)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to transcribe audio: %w", err)
+		}
+		metadata["message_type"] = "voice"
+		return text, nil, metadata, nil
+
+	case msgTypeMergeForward:
+		text, err := p.forwardedText(ctx, messageID)
+		return text, nil, metadata, err
+
+	default:
+		return "", nil, nil, fmt.Errorf("unsupported message type %q", msgType)
+	}
+}
+
+// download fetches an image or file from a message through the message
+// resource API
+func (p *Platform) download(ctx context.Context, messageID, key, resourceType, name string) (router.Attachment, error) {
+	file := router.Attachment{Name: name}
+	if key == "" {
+		return file, fmt.Errorf("no resource key")
+	}
+
+	req := larkim.NewGetMessageResourceReqBuilder().
+		MessageId(messageID).
+		FileKey(key).
+		Type(resourceType).
+		Build()
+
+	result, err := p.client.Im.MessageResource.Get(ctx, req)
+	if err != nil {
+		return file, err
+	}
+	if !result.Success() {
+		return file, fmt.Errorf("code=%d, msg=%s", result.Code, result.Msg)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(result.File, maxAttachmentSize+1))
+	if err != nil {
+		return file, err
+	}
+	if len(data) > maxAttachmentSize {
+		return file, fmt.Errorf("file is larger than %d bytes", maxAttachmentSize)
+	}
+
+	file.Data = data
+	file.MimeType = http.DetectContentType(data)
+	if file.Name == "" {
+		file.Name = result.FileName
+	}
+	if file.Name == "" {
+		file.Name = key
+	}
+	return file, nil
+}
+
+// getMessage fetches a message and, for merged forwards, the messages inside it
+func (p *Platform) getMessage(ctx context.Context, messageID string) ([]*larkim.Message, error) {
+	req := larkim.NewGetMessageReqBuilder().
+		MessageId(messageID).
+		Build()
+
+	result, err := p.client.Im.Message.Get(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if !result.Success() {
+		return nil, fmt.Errorf("failed to get message: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	if result.Data == nil {
+		return nil, nil
+	}
+	return result.Data.Items, nil
+}
+
+// forwardedText renders the messages of a merged forward as "name: text"
+// lines. Nested forwards are shown as placeholders.
+func (p *Platform) forwardedText(ctx context.Context, messageID string) (string, error) {
+	items, err := p.getMessage(ctx, messageID)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, m := range items {
+		if m.UpperMessageId == nil || *m.UpperMessageId != messageID ||
+			m.MsgType == nil || m.Body == nil || m.Body.Content == nil {
+			continue
+		}
+		c, err := parseContent(*m.MsgType, *m.Body.Content)
+		if err != nil {
+			continue
+		}
+		lines = append(lines, p.senderName(ctx, m.Sender)+": "+c.text(*m.MsgType))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// parentText returns the text of the message being replied to, or "" if it
+// can't be read or was sent by the bot, which remembers its own messages
+func (p *Platform) parentText(ctx context.Context, parentID string) string {
+	items, err := p.getMessage(ctx, parentID)
+	if err != nil {
+		log.Printf("[Feishu] Failed to fetch quoted message: %v", err)
+		return ""
+	}
+
+	for _, m := range items {
+		if m.MessageId == nil || *m.MessageId != parentID || m.MsgType == nil || m.Body == nil || m.Body.Content == nil {
+			continue
+		}
+		if m.Sender != nil && m.Sender.SenderType != nil && *m.Sender.SenderType == "app" {
+			return ""
+		}
+		c, err := parseContent(*m.MsgType, *m.Body.Content)
+		if err != nil {
+			return ""
+		}
+		return p.cleanMention(c.text(*m.MsgType))
+	}
+	return ""
+}
+
+// senderName resolves the sender of a message read back through the API
+func (p *Platform) senderName(ctx context.Context, sender *larkim.Sender) string {
+	if sender == nil || sender.Id == nil {
+		return "unknown"
+	}
+	if sender.IdType != nil && *sender.IdType == "open_id" {
+		return p.getUsername(ctx, *sender.Id)
+	}
+	return *sender.Id
+}
+
+// quoteLines prefixes every line with "> "
+func quoteLines(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -2,35 +2,75 @@ package feishu
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/pltanton/lingti-bot/internal/markdown"
 	"github.com/pltanton/lingti-bot/internal/router"
 
 	lark "github.com/larksuite/oapi-sdk-go/v3"
 	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	"github.com/larksuite/oapi-sdk-go/v3/core/httpserverext"
+	larkevent "github.com/larksuite/oapi-sdk-go/v3/event"
 	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
 	larkcontact "github.com/larksuite/oapi-sdk-go/v3/service/contact/v3"
 	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
-	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
 )
 
+// VoiceTranscriber transcribes audio messages to text
+type VoiceTranscriber interface {
+	Transcribe(ctx context.Context, audio []byte) (string, error)
+}
+
 // Platform implements router.Platform for Feishu/Lark
 type Platform struct {
 	client         *lark.Client
-	wsClient       *larkws.Client
+	ws             *wsConn      // nil in HTTP mode
+	listen         string       // HTTP event subscription address
+	token          string       // HTTP event subscription verification token
+	handler        http.Handler // HTTP event subscription endpoint
+	server         *http.Server
 	botOpenID      string
 	messageHandler func(msg router.Message)
+	transcriber    VoiceTranscriber
+	recvErr        error // Set when the HTTP server exits unexpectedly
+	recvMu         sync.Mutex
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
 
 // Config holds Feishu configuration
 type Config struct {
-	AppID     string // from Feishu Developer Console
-	AppSecret string // from Feishu Developer Console
+	AppID       string           // from Feishu Developer Console
+	AppSecret   string           // from Feishu Developer Console
+	Domain      string           // "feishu" (default), "lark" or an API base URL, e.g. a proxy
+	Transcriber VoiceTranscriber // Optional transcriber for audio messages
+
+	// Listen switches from the WebSocket long connection to HTTP event
+	// subscription: Feishu posts events to /feishu/events on this address
+	Listen            string
+	VerificationToken string // HTTP mode: verification token of the event subscription
+	EncryptKey        string // HTTP mode: encrypt key, if event encryption is on
+}
+
+// BaseURL returns the Open API base URL for a domain setting
+func BaseURL(domain string) string {
+	switch domain {
+	case "", "feishu":
+		return lark.FeishuBaseUrl
+	case "lark":
+		return lark.LarkBaseUrl
+	default:
+		return strings.TrimSuffix(domain, "/")
+	}
 }
 
 // New creates a new Feishu platform
@@ -39,24 +79,29 @@ func New(cfg Config) (*Platform, error) {
 		return nil, fmt.Errorf("both AppID and AppSecret are required")
 	}
 
-	client := lark.NewClient(cfg.AppID, cfg.AppSecret)
-
-	// Get bot info to retrieve bot's open_id
-	botOpenID, err := getBotOpenID(client)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get bot info: %w", err)
+	if cfg.Listen != "" && cfg.VerificationToken == "" {
+		return nil, fmt.Errorf("VerificationToken is required for HTTP event subscription")
 	}
 
+	baseURL := BaseURL(cfg.Domain)
 	p := &Platform{
-		client:    client,
-		botOpenID: botOpenID,
+		client:      lark.NewClient(cfg.AppID, cfg.AppSecret, lark.WithOpenBaseUrl(baseURL)),
+		transcriber: cfg.Transcriber,
+		listen:      cfg.Listen,
+		token:       cfg.VerificationToken,
+	}
+
+	if cfg.Listen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/feishu/events", httpserverext.NewEventHandlerFunc(
+			p.buildEventHandler(cfg.VerificationToken, cfg.EncryptKey),
+			larkevent.WithLogLevel(larkcore.LogLevelInfo)))
+		p.handler = mux
+		return p, nil
 	}
 
-	// Create WebSocket client with event handler
-	p.wsClient = larkws.NewClient(cfg.AppID, cfg.AppSecret,
-		larkws.WithEventHandler(p.buildEventHandler()),
-		larkws.WithLogLevel(larkcore.LogLevelInfo),
-	)
+	// The WebSocket connection is shared with earlier instances for this app
+	p.ws = sharedConn(baseURL, cfg.AppID, cfg.AppSecret)
 
 	return p, nil
 }
@@ -66,6 +111,11 @@ func (p *Platform) Name() string {
 	return "feishu"
 }
 
+// MessageLimits returns a safe Feishu text message size (request body is capped at 150KB)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 30000, Unit: router.UnitBytes}
+}
+
 // SetMessageHandler sets the callback for incoming messages
 func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 	p.messageHandler = handler
@@ -73,13 +123,28 @@ func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 
 // Start begins listening for Feishu events
 func (p *Platform) Start(ctx context.Context) error {
-	p.ctx, p.cancel = context.WithCancel(ctx)
+	// Get bot info to retrieve bot's open_id
+	botOpenID, err := getBotOpenID(ctx, p.client)
+	if err != nil {
+		return fmt.Errorf("failed to get bot info: %w", err)
+	}
+	p.botOpenID = botOpenID
 
-	go func() {
-		if err := p.wsClient.Start(p.ctx); err != nil {
-			log.Printf("[Feishu] WebSocket error: %v", err)
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.recvMu.Lock()
+	p.recvErr = nil
+	p.recvMu.Unlock()
+
+	if p.listen != "" {
+		if err := p.serve(); err != nil {
+			p.cancel()
+			return err
 		}
-	}()
+		log.Printf("[Feishu] Receiving events on %s/feishu/events", p.listen)
+		return nil
+	}
+
+	p.ws.attach(p)
 
 	log.Printf("[Feishu] Connected as bot: %s", p.botOpenID)
 	return nil
@@ -90,41 +155,120 @@ func (p *Platform) Stop() error {
 	if p.cancel != nil {
 		p.cancel()
 	}
+	if p.ws != nil {
+		p.ws.detach(p)
+	}
+	p.recvMu.Lock()
+	server := p.server
+	p.server = nil
+	p.recvMu.Unlock()
+
+	if server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}
+	return nil
+}
+
+// serve starts the HTTP event subscription server. The port is bound up
+// front so a conflict fails Start.
+func (p *Platform) serve() error {
+	ln, err := net.Listen("tcp", p.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", p.listen, err)
+	}
+
+	server := &http.Server{Handler: p.handler, ReadHeaderTimeout: 10 * time.Second}
+	p.recvMu.Lock()
+	p.server = server
+	p.recvMu.Unlock()
+
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Printf("[Feishu] Event server error: %v", err)
+			p.recvMu.Lock()
+			p.recvErr = err
+			p.recvMu.Unlock()
+		}
+	}()
 	return nil
 }
 
-// Send sends a message to a Feishu chat
-func (p *Platform) Send(ctx context.Context, chatID string, resp router.Response) error {
-	content, err := json.Marshal(map[string]string{"text": resp.Text})
+// Healthy reports an error if the WebSocket client or HTTP server has exited
+func (p *Platform) Healthy() error {
+	if p.ws != nil {
+		return p.ws.healthy()
+	}
+	p.recvMu.Lock()
+	defer p.recvMu.Unlock()
+	return p.recvErr
+}
+
+// Send sends a message to a Feishu chat as a message card, falling back to
+// rich text and then plain text. resp.Metadata["title"] sets the card header.
+func (p *Platform) Send(ctx context.Context, chatID string, resp router.Response) (string, error) {
+	card, err := buildCard(resp, nil)
+	if err != nil {
+		return "", err
+	}
+	id, err := p.createMessage(ctx, chatID, larkim.MsgTypeInteractive, card)
+	if err == nil {
+		return id, nil
+	}
+	log.Printf("[Feishu] Card rejected, retrying as rich text: %v", err)
+
+	post, err := json.Marshal(markdown.FeishuPost(resp.Text, ""))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal message content: %w", err)
+	}
+
+	id, err = p.createMessage(ctx, chatID, larkim.MsgTypePost, string(post))
+	if err == nil {
+		return id, nil
+	}
+	log.Printf("[Feishu] Rich text rejected, retrying as plain text: %v", err)
+
+	text, err := json.Marshal(map[string]string{"text": markdown.PlainText(resp.Text)})
 	if err != nil {
-		return fmt.Errorf("failed to marshal message content: %w", err)
+		return "", fmt.Errorf("failed to marshal message content: %w", err)
 	}
+	return p.createMessage(ctx, chatID, larkim.MsgTypeText, string(text))
+}
 
+// createMessage sends a message of the given type to a chat and returns its ID
+func (p *Platform) createMessage(ctx context.Context, chatID, msgType, content string) (string, error) {
 	req := larkim.NewCreateMessageReqBuilder().
 		ReceiveIdType(larkim.ReceiveIdTypeChatId).
 		Body(larkim.NewCreateMessageReqBodyBuilder().
 			ReceiveId(chatID).
-			MsgType(larkim.MsgTypeText).
-			Content(string(content)).
+			MsgType(msgType).
+			Content(content).
 			Build()).
 		Build()
 
 	result, err := p.client.Im.Message.Create(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return "", fmt.Errorf("failed to send message: %w", err)
 	}
 
 	if !result.Success() {
-		return fmt.Errorf("failed to send message: code=%d, msg=%s", result.Code, result.Msg)
+		return "", fmt.Errorf("failed to send message: code=%d, msg=%s", result.Code, result.Msg)
 	}
 
-	return nil
+	if result.Data == nil || result.Data.MessageId == nil {
+		return "", nil
+	}
+	return *result.Data.MessageId, nil
 }
 
-// buildEventHandler creates the event handler for WebSocket events
-func (p *Platform) buildEventHandler() *dispatcher.EventDispatcher {
-	handler := dispatcher.NewEventDispatcher("", "")
+// buildEventHandler creates the handler for message events and card
+// callbacks. Over HTTP it also answers the URL challenge, checks the
+// verification token and signature, and decrypts events.
+func (p *Platform) buildEventHandler(verificationToken, encryptKey string) *dispatcher.EventDispatcher {
+	handler := dispatcher.NewEventDispatcher(verificationToken, encryptKey)
 	handler.OnP2MessageReceiveV1(p.handleMessageEvent)
+	handler.OnP2CardActionTrigger(p.handleCardAction)
 	return handler
 }
 
@@ -133,8 +277,10 @@ func (p *Platform) handleMessageEvent(ctx context.Context, event *larkim.P2Messa
 	if event == nil || event.Event == nil || event.Event.Message == nil {
 		return nil
 	}
+	if !p.authentic(event.EventV2Base) {
+		return errInvalidToken
+	}
 
-	msg := event.Event.Message
 	sender := event.Event.Sender
 
 	// Ignore bot's own messages
@@ -147,53 +293,93 @@ func (p *Platform) handleMessageEvent(ctx context.Context, event *larkim.P2Messa
 		return nil
 	}
 
-	// Extract text content from message
-	text, err := p.extractText(msg)
+	if p.messageHandler != nil {
+		// Ack the event right away; downloads and the username lookup run in
+		// the background so Feishu doesn't redeliver while we wait on the API
+		go p.dispatchMessage(event)
+	}
+
+	return nil
+}
+
+// dispatchMessage reads the message content, resolves the sender and hands
+// the message to the router
+func (p *Platform) dispatchMessage(event *larkim.P2MessageReceiveV1) {
+	msg := event.Event.Message
+	sender := event.Event.Sender
+
+	msgID := ""
+	if msg.MessageId != nil {
+		msgID = *msg.MessageId
+	}
+
+	msgType, content := larkim.MsgTypeText, ""
+	if msg.MessageType != nil {
+		msgType = *msg.MessageType
+	}
+	if msg.Content != nil {
+		content = *msg.Content
+	}
+
+	text, attachments, metadata, err := p.readMessage(p.ctx, msgID, msgType, content)
 	if err != nil {
-		log.Printf("[Feishu] Failed to extract text: %v", err)
-		return nil
+		log.Printf("[Feishu] Failed to read %s message: %v", msgType, err)
+		return
 	}
 
 	// Clean @mention from text
 	text = p.cleanMention(text)
+	if text == "" && len(attachments) == 0 {
+		return
+	}
 
-	if p.messageHandler != nil {
-		userID := ""
-		username := ""
-		if sender != nil && sender.SenderId != nil {
-			userID = *sender.SenderId.OpenId
-			username = p.getUsername(ctx, userID)
-		}
-
-		chatID := ""
-		chatType := ""
-		if msg.ChatId != nil {
-			chatID = *msg.ChatId
-		}
-		if event.Event.Message.ChatType != nil {
-			chatType = *event.Event.Message.ChatType
+	// The agent remembers its own messages, but not what others wrote
+	if msg.ParentId != nil && *msg.ParentId != "" {
+		if quoted := p.parentText(p.ctx, *msg.ParentId); quoted != "" {
+			text = strings.TrimSpace(quoteLines(quoted) + "\n\n" + text)
 		}
+	}
 
-		msgID := ""
-		if msg.MessageId != nil {
-			msgID = *msg.MessageId
-		}
+	userID := ""
+	username := ""
+	if sender != nil && sender.SenderId != nil {
+		userID = *sender.SenderId.OpenId
+		username = p.getUsername(p.ctx, userID)
+	}
 
-		p.messageHandler(router.Message{
-			ID:        msgID,
-			Platform:  "feishu",
-			ChannelID: chatID,
-			UserID:    userID,
-			Username:  username,
-			Text:      text,
-			ThreadID:  "", // Feishu doesn't have traditional threading like Slack
-			Metadata: map[string]string{
-				"chat_type": chatType,
-			},
-		})
+	chatID := ""
+	if msg.ChatId != nil {
+		chatID = *msg.ChatId
+	}
+	if msg.ChatType != nil {
+		metadata["chat_type"] = *msg.ChatType
 	}
 
-	return nil
+	p.messageHandler(router.Message{
+		ID:          msgID,
+		Platform:    "feishu",
+		ChannelID:   chatID,
+		UserID:      userID,
+		Username:    username,
+		Text:        text,
+		ThreadID:    "", // Feishu doesn't have traditional threading like Slack
+		Metadata:    metadata,
+		Attachments: attachments,
+	})
+}
+
+// errInvalidToken rejects HTTP events that don't carry the verification token
+var errInvalidToken = errors.New("invalid verification token")
+
+// authentic reports whether an event carries the verification token. The
+// SDK checks only the URL challenge and, with an encrypt key, the signature;
+// events over the long connection need no check.
+func (p *Platform) authentic(base *larkevent.EventV2Base) bool {
+	if p.token == "" {
+		return true
+	}
+	return base != nil && base.Header != nil &&
+		subtle.ConstantTimeCompare([]byte(base.Header.Token), []byte(p.token)) == 1
 }
 
 // shouldRespond checks if the bot should respond to this message
@@ -221,23 +407,6 @@ func (p *Platform) shouldRespond(event *larkim.P2MessageReceiveV1) bool {
 	return false
 }
 
-// extractText extracts text content from message
-func (p *Platform) extractText(msg *larkim.EventMessage) (string, error) {
-	if msg.Content == nil {
-		return "", nil
-	}
-
-	var content struct {
-		Text string `json:"text"`
-	}
-
-	if err := json.Unmarshal([]byte(*msg.Content), &content); err != nil {
-		return "", fmt.Errorf("failed to unmarshal content: %w", err)
-	}
-
-	return content.Text, nil
-}
-
 // cleanMention removes @mention from the message
 func (p *Platform) cleanMention(text string) string {
 	// Feishu @mentions appear as @_user_N in the text
@@ -276,11 +445,9 @@ func (p *Platform) getUsername(ctx context.Context, openID string) string {
 }
 
 // getBotOpenID retrieves the bot's open_id
-func getBotOpenID(client *lark.Client) (string, error) {
+func getBotOpenID(ctx context.Context, client *lark.Client) (string, error) {
 	// Use bot info API to get bot's open_id
 	// This is done by calling the auth endpoint which returns bot info
-	ctx := context.Background()
-
 	// Get tenant access token to verify credentials and get bot info
 	// The bot's open_id can be retrieved from the /bot/v3/info endpoint
 	req := larkim.NewListChatReqBuilder().
@@ -0,0 +1,68 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+)
+
+// workingEmoji is the reaction added to the user's message while the bot is working
+const workingEmoji = "Typing"
+
+// StartPresence reacts to the user's message until the response is sent
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	if msg.ID == "" {
+		return nil, fmt.Errorf("message has no ID")
+	}
+
+	req := larkim.NewCreateMessageReactionReqBuilder().
+		MessageId(msg.ID).
+		Body(larkim.NewCreateMessageReactionReqBodyBuilder().
+			ReactionType(larkim.NewEmojiBuilder().EmojiType(workingEmoji).Build()).
+			Build()).
+		Build()
+
+	result, err := p.client.Im.MessageReaction.Create(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add reaction: %w", err)
+	}
+	if !result.Success() {
+		return nil, fmt.Errorf("failed to add reaction: code=%d, msg=%s", result.Code, result.Msg)
+	}
+	if result.Data == nil || result.Data.ReactionId == nil {
+		return nil, fmt.Errorf("failed to add reaction: no reaction ID returned")
+	}
+
+	return &indicator{platform: p, messageID: msg.ID, reactionID: *result.Data.ReactionId}, nil
+}
+
+// indicator is a reaction on the user's message
+type indicator struct {
+	platform   *Platform
+	messageID  string
+	reactionID string
+}
+
+// Update is a no-op; reactions can't show progress
+func (i *indicator) Update(ctx context.Context, status string) {}
+
+// Stop removes the reaction
+func (i *indicator) Stop(ctx context.Context) {
+	req := larkim.NewDeleteMessageReactionReqBuilder().
+		MessageId(i.messageID).
+		ReactionId(i.reactionID).
+		Build()
+
+	result, err := i.platform.client.Im.MessageReaction.Delete(ctx, req)
+	if err != nil {
+		log.Printf("[Feishu] Failed to remove reaction: %v", err)
+		return
+	}
+	if !result.Success() {
+		log.Printf("[Feishu] Failed to remove reaction: code=%d, msg=%s", result.Code, result.Msg)
+	}
+}
@@ -0,0 +1,117 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	larkcore "github.com/larksuite/oapi-sdk-go/v3/core"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher"
+	"github.com/larksuite/oapi-sdk-go/v3/event/dispatcher/callback"
+	larkim "github.com/larksuite/oapi-sdk-go/v3/service/im/v1"
+	larkws "github.com/larksuite/oapi-sdk-go/v3/ws"
+)
+
+// wsConn is the WebSocket long connection of one app. The SDK client can't be
+// closed (Start never returns and ignores its context), and Feishu spreads
+// events over all connections of an app, so a restarted platform must not
+// open a second one. Each app gets one connection per process; platforms
+// attach to it while running and detach when stopped.
+type wsConn struct {
+	client    *larkws.Client
+	appSecret string
+
+	mu      sync.Mutex
+	current *Platform // Receives the events; nil while stopped
+	running bool
+	err     error // Set when the client exits
+}
+
+var (
+	wsConnsMu sync.Mutex
+	wsConns   = make(map[string]*wsConn) // by domain and app ID
+)
+
+// sharedConn returns the process-wide connection of an app
+func sharedConn(domain, appID, appSecret string) *wsConn {
+	wsConnsMu.Lock()
+	defer wsConnsMu.Unlock()
+
+	key := domain + "|" + appID
+	if c, ok := wsConns[key]; ok {
+		if c.appSecret != appSecret {
+			log.Printf("[Feishu] App secret changed; the WebSocket connection keeps the old one until restart")
+		}
+		return c
+	}
+
+	c := &wsConn{appSecret: appSecret}
+	handler := dispatcher.NewEventDispatcher("", "")
+	handler.OnP2MessageReceiveV1(func(ctx context.Context, event *larkim.P2MessageReceiveV1) error {
+		if p := c.platform(); p != nil {
+			return p.handleMessageEvent(ctx, event)
+		}
+		return nil
+	})
+	handler.OnP2CardActionTrigger(func(ctx context.Context, event *callback.CardActionTriggerEvent) (*callback.CardActionTriggerResponse, error) {
+		if p := c.platform(); p != nil {
+			return p.handleCardAction(ctx, event)
+		}
+		return nil, nil
+	})
+	c.client = larkws.NewClient(appID, appSecret,
+		larkws.WithEventHandler(handler),
+		larkws.WithLogLevel(larkcore.LogLevelInfo),
+		larkws.WithDomain(domain),
+	)
+	wsConns[key] = c
+	return c
+}
+
+// attach routes the app's events to p, connecting on first use or after the
+// client exited
+func (c *wsConn) attach(p *Platform) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.current = p
+	if !c.running {
+		c.running, c.err = true, nil
+		go c.run()
+	}
+}
+
+// detach stops routing events to p; the connection stays open
+func (c *wsConn) detach(p *Platform) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == p {
+		c.current = nil
+	}
+}
+
+func (c *wsConn) run() {
+	err := c.client.Start(context.Background())
+	if err == nil {
+		err = fmt.Errorf("WebSocket client exited")
+	}
+	log.Printf("[Feishu] WebSocket error: %v", err)
+
+	c.mu.Lock()
+	c.running, c.err = false, err
+	c.mu.Unlock()
+}
+
+func (c *wsConn) platform() *Platform {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// healthy reports an error if the client has exited
+func (c *wsConn) healthy() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
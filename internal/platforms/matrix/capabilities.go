@@ -0,0 +1,127 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// Edit replaces the text of a message the bot sent
+func (p *Platform) Edit(ctx context.Context, channelID, messageID string, resp router.Response) error {
+	content := messageContent(" * "+resp.Text, "")
+	content["m.new_content"] = messageContent(resp.Text, "")
+	content["m.relates_to"] = map[string]string{"rel_type": "m.replace", "event_id": messageID}
+	_, err := p.client.sendEvent(ctx, channelID, "m.room.message", content)
+	return err
+}
+
+// Delete redacts a message
+func (p *Platform) Delete(ctx context.Context, channelID, messageID string) error {
+	return p.client.redact(ctx, channelID, messageID)
+}
+
+// React annotates a message with an emoji (the emoji itself, e.g. "👍")
+func (p *Platform) React(ctx context.Context, channelID, messageID, emoji string) error {
+	eventID, err := p.client.sendEvent(ctx, channelID, "m.reaction", map[string]any{
+		"m.relates_to": map[string]string{
+			"rel_type": "m.annotation",
+			"event_id": messageID,
+			"key":      emoji,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	p.reactionsMu.Lock()
+	p.reactions[reactionKey(channelID, messageID, emoji)] = eventID
+	p.reactionsMu.Unlock()
+	return nil
+}
+
+// Unreact redacts a reaction the bot added since it started
+func (p *Platform) Unreact(ctx context.Context, channelID, messageID, emoji string) error {
+	key := reactionKey(channelID, messageID, emoji)
+	p.reactionsMu.Lock()
+	eventID, ok := p.reactions[key]
+	delete(p.reactions, key)
+	p.reactionsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no %s reaction on %s", emoji, messageID)
+	}
+	return p.client.redact(ctx, channelID, eventID)
+}
+
+func reactionKey(channelID, messageID, emoji string) string {
+	return channelID + "|" + messageID + "|" + emoji
+}
+
+// SendFile uploads a file to the media repository and posts it, as an image
+// if it is one. resp.Text is sent as a separate message before the file.
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+	if resp.Text != "" {
+		if _, err := p.Send(ctx, channelID, resp); err != nil {
+			return "", err
+		}
+	}
+
+	mimeType := orDefault(file.MimeType, "application/octet-stream")
+	uri, err := p.client.upload(ctx, file.Name, mimeType, file.Data)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+
+	msgType := "m.file"
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		msgType = "m.image"
+	case strings.HasPrefix(mimeType, "audio/"):
+		msgType = "m.audio"
+	case strings.HasPrefix(mimeType, "video/"):
+		msgType = "m.video"
+	}
+	content := map[string]any{
+		"msgtype":  msgType,
+		"body":     file.Name,
+		"filename": file.Name,
+		"url":      uri,
+		"info":     map[string]any{"mimetype": mimeType, "size": len(file.Data)},
+	}
+	if resp.ThreadID != "" {
+		content["m.relates_to"] = threadRelation(resp.ThreadID)
+	}
+	return p.client.sendEvent(ctx, channelID, "m.room.message", content)
+}
+
+// FetchHistory returns recent text messages in a room, oldest first
+func (p *Platform) FetchHistory(ctx context.Context, channelID string, limit int) ([]router.Message, error) {
+	events, err := p.client.messages(ctx, channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]router.Message, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		var content messageEventContent
+		if err := json.Unmarshal(ev.Content, &content); err != nil || content.Body == "" {
+			continue
+		}
+		messages = append(messages, router.Message{
+			ID:        ev.EventID,
+			Platform:  "matrix",
+			ChannelID: channelID,
+			UserID:    ev.Sender,
+			Username:  ev.Sender,
+			Text:      stripReplyFallback(content.Body),
+		})
+	}
+	return messages, nil
+}
@@ -0,0 +1,289 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// client is a minimal Matrix client-server API client
+type client struct {
+	homeserver string // Base URL, e.g. https://matrix.example.org
+	token      string
+	http       *http.Client
+	txnPrefix  string
+	txnCounter atomic.Int64
+}
+
+// apiError is an error response from the homeserver
+type apiError struct {
+	Status       int    `json:"-"`
+	ErrCode      string `json:"errcode"`
+	Message      string `json:"error"`
+	RetryAfterMs int64  `json:"retry_after_ms"`
+}
+
+func (e *apiError) Error() string {
+	if e.ErrCode == "" {
+		return fmt.Sprintf("matrix: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("matrix: %s: %s", e.ErrCode, e.Message)
+}
+
+func newClient(homeserver, token string, httpClient *http.Client) *client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &client{
+		homeserver: strings.TrimRight(homeserver, "/"),
+		token:      token,
+		http:       httpClient,
+		txnPrefix:  strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// txnID returns a transaction ID that is unique for this process, so the
+// homeserver can drop retried sends
+func (c *client) txnID() string {
+	return c.txnPrefix + "." + strconv.FormatInt(c.txnCounter.Add(1), 10)
+}
+
+// do sends a JSON request and decodes the JSON response into out (if not nil).
+// A rate limited request is retried once after the time the server asks for.
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if payload != nil {
+			reader = bytes.NewReader(payload)
+		}
+		err := c.request(ctx, method, path, query, "application/json", reader, out)
+
+		apiErr, ok := err.(*apiError)
+		if !ok || apiErr.ErrCode != "M_LIMIT_EXCEEDED" || attempt > 0 {
+			return err
+		}
+		wait := time.Duration(apiErr.RetryAfterMs) * time.Millisecond
+		if wait <= 0 || wait > 30*time.Second {
+			wait = 2 * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// request performs a single API call with the given body
+func (c *client) request(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	u := c.homeserver + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &apiError{Status: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = json.Unmarshal(data, apiErr)
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// whoami returns the user ID the access token belongs to
+func (c *client) whoami(ctx context.Context) (string, error) {
+	var resp struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/_matrix/client/v3/account/whoami", nil, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.UserID, nil
+}
+
+// sync long-polls for new events since the given batch token
+func (c *client) sync(ctx context.Context, since, filter string, timeout time.Duration) (*syncResponse, error) {
+	query := url.Values{"timeout": {strconv.FormatInt(timeout.Milliseconds(), 10)}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	var resp syncResponse
+	if err := c.do(ctx, http.MethodGet, "/_matrix/client/v3/sync", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// sendEvent sends a room event and returns its event ID
+func (c *client) sendEvent(ctx context.Context, roomID, eventType string, content any) (string, error) {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/%s/%s",
+		url.PathEscape(roomID), url.PathEscape(eventType), url.PathEscape(c.txnID()))
+	var resp struct {
+		EventID string `json:"event_id"`
+	}
+	if err := c.do(ctx, http.MethodPut, path, nil, content, &resp); err != nil {
+		return "", err
+	}
+	return resp.EventID, nil
+}
+
+// redact removes an event
+func (c *client) redact(ctx context.Context, roomID, eventID string) error {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/redact/%s/%s",
+		url.PathEscape(roomID), url.PathEscape(eventID), url.PathEscape(c.txnID()))
+	return c.do(ctx, http.MethodPut, path, nil, map[string]any{}, nil)
+}
+
+// getEvent fetches a single room event
+func (c *client) getEvent(ctx context.Context, roomID, eventID string) (*event, error) {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/event/%s", url.PathEscape(roomID), url.PathEscape(eventID))
+	var ev event
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &ev); err != nil {
+		return nil, err
+	}
+	return &ev, nil
+}
+
+// joinedMembers returns the number of users in a room
+func (c *client) joinedMembers(ctx context.Context, roomID string) (int, error) {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/joined_members", url.PathEscape(roomID))
+	var resp struct {
+		Joined map[string]json.RawMessage `json:"joined"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &resp); err != nil {
+		return 0, err
+	}
+	return len(resp.Joined), nil
+}
+
+// displayName returns a user's display name, or "" if they have none
+func (c *client) displayName(ctx context.Context, userID string) (string, error) {
+	path := fmt.Sprintf("/_matrix/client/v3/profile/%s/displayname", url.PathEscape(userID))
+	var resp struct {
+		DisplayName string `json:"displayname"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.DisplayName, nil
+}
+
+// join accepts an invite
+func (c *client) join(ctx context.Context, roomID string) error {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/join", url.PathEscape(roomID))
+	return c.do(ctx, http.MethodPost, path, nil, map[string]any{}, nil)
+}
+
+// typing sets or clears the typing notification
+func (c *client) typing(ctx context.Context, roomID, userID string, typing bool, timeout time.Duration) error {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/typing/%s", url.PathEscape(roomID), url.PathEscape(userID))
+	body := map[string]any{"typing": typing}
+	if typing {
+		body["timeout"] = timeout.Milliseconds()
+	}
+	return c.do(ctx, http.MethodPut, path, nil, body, nil)
+}
+
+// messages returns up to limit of the latest room events, newest first
+func (c *client) messages(ctx context.Context, roomID string, limit int) ([]event, error) {
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/messages", url.PathEscape(roomID))
+	query := url.Values{
+		"dir":    {"b"},
+		"limit":  {strconv.Itoa(limit)},
+		"filter": {`{"types":["m.room.message"]}`},
+	}
+	var resp struct {
+		Chunk []event `json:"chunk"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Chunk, nil
+}
+
+// upload stores a file in the media repository and returns its mxc:// URI
+func (c *client) upload(ctx context.Context, name, contentType string, data []byte) (string, error) {
+	query := url.Values{"filename": {name}}
+	var resp struct {
+		ContentURI string `json:"content_uri"`
+	}
+	err := c.request(ctx, http.MethodPost, "/_matrix/media/v3/upload", query, contentType, bytes.NewReader(data), &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.ContentURI, nil
+}
+
+// download fetches an mxc:// file, trying the authenticated media API first
+func (c *client) download(ctx context.Context, mxc string, maxSize int64) ([]byte, error) {
+	serverAndID, ok := strings.CutPrefix(mxc, "mxc://")
+	server, mediaID, found := strings.Cut(serverAndID, "/")
+	if !ok || !found {
+		return nil, fmt.Errorf("invalid media URI %q", mxc)
+	}
+	suffix := url.PathEscape(server) + "/" + url.PathEscape(mediaID)
+
+	var lastErr error
+	for _, prefix := range []string{"/_matrix/client/v1/media/download/", "/_matrix/media/v3/download/"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.homeserver+prefix+suffix, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 300 {
+			resp.Body.Close()
+			lastErr = &apiError{Status: resp.StatusCode}
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > maxSize {
+			return nil, fmt.Errorf("file is larger than %d bytes", maxSize)
+		}
+		return data, nil
+	}
+	return nil, lastErr
+}
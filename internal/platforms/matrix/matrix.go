@@ -0,0 +1,501 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	// syncTimeout is how long the homeserver may hold a sync request open
+	syncTimeout = 30 * time.Second
+	// maxSyncFailures is how many sync errors in a row end the connection
+	maxSyncFailures = 5
+	// maxAttachmentSize caps files downloaded from incoming messages
+	maxAttachmentSize = 20 << 20
+)
+
+// initialFilter skips the history of every room on the first sync; only
+// messages sent after the bot started are answered
+const initialFilter = `{"room":{"timeline":{"limit":1}}}`
+
+// Platform implements router.Platform for Matrix
+type Platform struct {
+	client         *client
+	userID         string
+	displayName    string
+	autoJoin       bool
+	messageHandler func(msg router.Message)
+
+	rooms   map[string]*roomInfo
+	roomsMu sync.Mutex
+
+	reactions   map[string]string // channel|message|emoji → reaction event ID
+	reactionsMu sync.Mutex
+
+	runErr error // Set when the sync loop gives up
+	runMu  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config holds Matrix configuration
+type Config struct {
+	Homeserver  string       // e.g. https://matrix.example.org
+	AccessToken string       // Access token of the bot account
+	UserID      string       // Optional; looked up with the token if empty
+	AutoJoin    bool         // Accept room invites
+	HTTPClient  *http.Client // Optional, e.g. for tests
+}
+
+// roomInfo is what the bot remembers about a joined room
+type roomInfo struct {
+	members       int  // Joined members; 0 = unknown
+	warnedEncrypt bool // Users were told encrypted messages can't be read
+}
+
+// New creates a new Matrix platform
+func New(cfg Config) (*Platform, error) {
+	if cfg.Homeserver == "" || cfg.AccessToken == "" {
+		return nil, fmt.Errorf("homeserver and access token are required")
+	}
+	if !strings.HasPrefix(cfg.Homeserver, "http://") && !strings.HasPrefix(cfg.Homeserver, "https://") {
+		return nil, fmt.Errorf("homeserver must be an http(s) URL, got %q", cfg.Homeserver)
+	}
+
+	return &Platform{
+		client:    newClient(cfg.Homeserver, cfg.AccessToken, cfg.HTTPClient),
+		userID:    cfg.UserID,
+		autoJoin:  cfg.AutoJoin,
+		rooms:     make(map[string]*roomInfo),
+		reactions: make(map[string]string),
+	}, nil
+}
+
+// Name returns the platform name
+func (p *Platform) Name() string {
+	return "matrix"
+}
+
+// MessageLimits keeps a message, sent as both text and HTML, well inside
+// Matrix's 64 KiB event size limit
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 16000, Unit: router.UnitBytes}
+}
+
+// SetMessageHandler sets the callback for incoming messages
+func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
+	p.messageHandler = handler
+}
+
+// Start checks the access token, skips old messages and begins syncing
+func (p *Platform) Start(ctx context.Context) error {
+	userID, err := p.client.whoami(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to auth: %w", err)
+	}
+	if p.userID != "" && p.userID != userID {
+		return fmt.Errorf("access token belongs to %s, not %s", userID, p.userID)
+	}
+	p.userID = userID
+	if name, err := p.client.displayName(ctx, userID); err == nil {
+		p.displayName = name
+	}
+
+	initial, err := p.client.sync(ctx, "", initialFilter, 0)
+	if err != nil {
+		return fmt.Errorf("initial sync failed: %w", err)
+	}
+	p.applyState(initial)
+
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.runMu.Lock()
+	p.runErr = nil
+	p.runMu.Unlock()
+
+	go p.syncLoop(p.ctx, initial.NextBatch)
+
+	log.Printf("[Matrix] Connected as %s", p.userID)
+	return nil
+}
+
+// Stop ends the sync loop
+func (p *Platform) Stop() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	return nil
+}
+
+// Healthy reports an error if the sync loop has given up
+func (p *Platform) Healthy() error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	return p.runErr
+}
+
+// Send sends a message to a room. With a ThreadID it goes into that thread.
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
+	return p.client.sendEvent(ctx, channelID, "m.room.message", messageContent(resp.Text, resp.ThreadID))
+}
+
+// messageContent builds an m.text body with an HTML rendering of the Markdown
+func messageContent(text, threadID string) map[string]any {
+	content := map[string]any{
+		"msgtype":        "m.text",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": markdown.HTML(text),
+	}
+	if threadID != "" {
+		content["m.relates_to"] = threadRelation(threadID)
+	}
+	return content
+}
+
+// threadRelation puts an event in a thread, with a reply fallback for
+// clients that don't show threads
+func threadRelation(threadID string) map[string]any {
+	return map[string]any{
+		"rel_type":        "m.thread",
+		"event_id":        threadID,
+		"is_falling_back": true,
+		"m.in_reply_to":   map[string]string{"event_id": threadID},
+	}
+}
+
+// syncLoop long-polls the homeserver until ctx ends or syncing keeps failing
+func (p *Platform) syncLoop(ctx context.Context, since string) {
+	failures := 0
+	for {
+		resp, err := p.client.sync(ctx, since, "", syncTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			failures++
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.ErrCode == "M_UNKNOWN_TOKEN" || failures >= maxSyncFailures {
+				log.Printf("[Matrix] Sync error: %v", err)
+				p.runMu.Lock()
+				p.runErr = err
+				p.runMu.Unlock()
+				return
+			}
+			log.Printf("[Matrix] Sync error (retrying): %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(failures) * 2 * time.Second):
+			}
+			continue
+		}
+
+		failures = 0
+		since = resp.NextBatch
+		p.applyState(resp)
+		p.handleSync(ctx, resp)
+	}
+}
+
+// applyState updates room membership counts from a sync
+func (p *Platform) applyState(resp *syncResponse) {
+	p.roomsMu.Lock()
+	defer p.roomsMu.Unlock()
+
+	for roomID, room := range resp.Rooms.Join {
+		info := p.room(roomID)
+		if room.Summary.JoinedMemberCount != nil {
+			info.members = *room.Summary.JoinedMemberCount
+		}
+		for _, ev := range append(room.State.Events, room.Timeline.Events...) {
+			if ev.Type == "m.room.member" && room.Summary.JoinedMemberCount == nil {
+				info.members = 0 // Recount on demand
+			}
+		}
+	}
+	for roomID := range resp.Rooms.Leave {
+		delete(p.rooms, roomID)
+	}
+}
+
+// room returns the room's info, creating it; callers hold roomsMu
+func (p *Platform) room(roomID string) *roomInfo {
+	info, ok := p.rooms[roomID]
+	if !ok {
+		info = &roomInfo{}
+		p.rooms[roomID] = info
+	}
+	return info
+}
+
+// handleSync accepts invites and dispatches new room messages
+func (p *Platform) handleSync(ctx context.Context, resp *syncResponse) {
+	if p.autoJoin {
+		for roomID := range resp.Rooms.Invite {
+			if err := p.client.join(ctx, roomID); err != nil {
+				log.Printf("[Matrix] Failed to join %s: %v", roomID, err)
+			} else {
+				log.Printf("[Matrix] Joined %s", roomID)
+			}
+		}
+	}
+
+	for roomID, room := range resp.Rooms.Join {
+		for _, ev := range room.Timeline.Events {
+			if ev.Sender == p.userID {
+				continue
+			}
+			switch ev.Type {
+			case "m.room.message":
+				p.handleMessage(ctx, roomID, ev)
+			case "m.room.encrypted":
+				p.warnEncrypted(ctx, roomID)
+			}
+		}
+	}
+}
+
+// handleMessage turns a room message into a router message if the bot should answer it
+func (p *Platform) handleMessage(ctx context.Context, roomID string, ev event) {
+	var content messageEventContent
+	if err := json.Unmarshal(ev.Content, &content); err != nil {
+		return
+	}
+	// Edits arrive as new events; the original was already answered
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.replace" {
+		return
+	}
+	if content.MsgType == "m.notice" {
+		return // Other bots
+	}
+
+	direct := p.isDirect(ctx, roomID)
+	if !direct && !p.shouldRespond(ctx, roomID, &content) {
+		return
+	}
+
+	text := p.cleanMention(stripReplyFallback(content.Body))
+	var attachments []router.Attachment
+	switch content.MsgType {
+	case "m.image", "m.file", "m.audio", "m.video":
+		if content.URL == "" {
+			return // Encrypted file
+		}
+		data, err := p.client.download(ctx, content.URL, maxAttachmentSize)
+		if err != nil {
+			log.Printf("[Matrix] Failed to download %s: %v", content.URL, err)
+		} else {
+			attachments = append(attachments, router.Attachment{
+				Name:     orDefault(content.FileName, content.Body),
+				MimeType: content.Info.MimeType,
+				Data:     data,
+			})
+		}
+		text = ""
+		if content.FileName != "" && content.Body != content.FileName {
+			text = p.cleanMention(content.Body) // Caption
+		}
+	}
+	if text == "" && len(attachments) == 0 {
+		return
+	}
+
+	threadID := ""
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.thread" {
+		threadID = content.RelatesTo.EventID
+	}
+
+	chatType := "group"
+	if direct {
+		chatType = "dm"
+	}
+
+	if p.messageHandler != nil {
+		p.messageHandler(router.Message{
+			ID:          ev.EventID,
+			Platform:    "matrix",
+			ChannelID:   roomID,
+			UserID:      ev.Sender,
+			Username:    p.getUsername(ctx, ev.Sender),
+			Text:        text,
+			ThreadID:    threadID,
+			Attachments: attachments,
+			Metadata: map[string]string{
+				"chat_type": chatType,
+				"msgtype":   content.MsgType,
+			},
+		})
+	}
+}
+
+// isDirect reports whether only the bot and one other user are in the room
+func (p *Platform) isDirect(ctx context.Context, roomID string) bool {
+	p.roomsMu.Lock()
+	members := p.room(roomID).members
+	p.roomsMu.Unlock()
+
+	if members == 0 {
+		count, err := p.client.joinedMembers(ctx, roomID)
+		if err != nil {
+			log.Printf("[Matrix] Failed to get members of %s: %v", roomID, err)
+			return false
+		}
+		members = count
+		p.roomsMu.Lock()
+		p.room(roomID).members = count
+		p.roomsMu.Unlock()
+	}
+	return members == 2
+}
+
+// shouldRespond checks if a group room message mentions or replies to the bot
+func (p *Platform) shouldRespond(ctx context.Context, roomID string, content *messageEventContent) bool {
+	if content.Mentions != nil {
+		for _, userID := range content.Mentions.UserIDs {
+			if userID == p.userID {
+				return true
+			}
+		}
+	}
+	if strings.Contains(content.Body, p.userID) || strings.Contains(content.FormattedBody, "matrix.to/#/"+p.userID) {
+		return true
+	}
+	if p.displayName != "" && strings.HasPrefix(stripReplyFallback(content.Body), p.displayName+":") {
+		return true
+	}
+
+	// Replies to the bot's own messages
+	if content.RelatesTo != nil && content.RelatesTo.InReplyTo != nil && !content.RelatesTo.IsFallingBack {
+		replied, err := p.client.getEvent(ctx, roomID, content.RelatesTo.InReplyTo.EventID)
+		if err == nil && replied.Sender == p.userID {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanMention removes the bot's user ID or display name ("Bot: hi") from the message
+func (p *Platform) cleanMention(text string) string {
+	text = strings.ReplaceAll(text, p.userID, "")
+	if p.displayName != "" {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(text), p.displayName); ok {
+			text = strings.TrimPrefix(strings.TrimSpace(rest), ":")
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// stripReplyFallback removes the quoted "> <@user> text" lines clients put in
+// front of replies
+func stripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> <") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	return strings.TrimSpace(strings.Join(lines[i:], "\n"))
+}
+
+// warnEncrypted tells a room once that the bot can't read encrypted messages
+func (p *Platform) warnEncrypted(ctx context.Context, roomID string) {
+	p.roomsMu.Lock()
+	info := p.room(roomID)
+	warned := info.warnedEncrypt
+	info.warnedEncrypt = true
+	p.roomsMu.Unlock()
+	if warned {
+		return
+	}
+
+	log.Printf("[Matrix] Room %s is end-to-end encrypted; encrypted messages are not supported", roomID)
+	_, err := p.client.sendEvent(ctx, roomID, "m.room.message", map[string]any{
+		"msgtype": "m.notice",
+		"body":    "此房间启用了端到端加密，机器人无法读取加密消息。请在未加密的房间中与我对话。",
+	})
+	if err != nil {
+		log.Printf("[Matrix] Failed to send encryption notice: %v", err)
+	}
+}
+
+// getUsername returns a user's display name, falling back to the user ID
+func (p *Platform) getUsername(ctx context.Context, userID string) string {
+	name, err := p.client.displayName(ctx, userID)
+	if err != nil || name == "" {
+		return userID
+	}
+	return name
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// syncResponse is the part of a /sync response the bot uses
+type syncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join   map[string]joinedRoom      `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+		Leave  map[string]json.RawMessage `json:"leave"`
+	} `json:"rooms"`
+}
+
+type joinedRoom struct {
+	Summary struct {
+		JoinedMemberCount *int `json:"m.joined_member_count"`
+	} `json:"summary"`
+	State struct {
+		Events []event `json:"events"`
+	} `json:"state"`
+	Timeline struct {
+		Events []event `json:"events"`
+	} `json:"timeline"`
+}
+
+// event is a room event
+type event struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id"`
+	Sender  string          `json:"sender"`
+	Content json.RawMessage `json:"content"`
+	TS      int64           `json:"origin_server_ts"`
+}
+
+// messageEventContent is the content of an m.room.message event
+type messageEventContent struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	FormattedBody string `json:"formatted_body"`
+	FileName      string `json:"filename"`
+	URL           string `json:"url"` // mxc:// URI of a file
+	Info          struct {
+		MimeType string `json:"mimetype"`
+	} `json:"info"`
+	Mentions *struct {
+		UserIDs []string `json:"user_ids"`
+	} `json:"m.mentions"`
+	RelatesTo *struct {
+		RelType       string `json:"rel_type"`
+		EventID       string `json:"event_id"`
+		IsFallingBack bool   `json:"is_falling_back"`
+		InReplyTo     *struct {
+			EventID string `json:"event_id"`
+		} `json:"m.in_reply_to"`
+	} `json:"m.relates_to"`
+}
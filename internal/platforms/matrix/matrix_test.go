@@ -0,0 +1,287 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	botID   = "@bot:example.org"
+	aliceID = "@alice:example.org"
+)
+
+// sentEvent is an event the bot sent to the fake homeserver
+type sentEvent struct {
+	Room    string
+	Type    string
+	Content map[string]any
+}
+
+// homeserver stands in for the client-server API endpoints the bot uses
+type homeserver struct {
+	t       *testing.T
+	url     string
+	client  *http.Client
+	batches chan map[string]any // /sync responses after the initial one
+	sent    chan sentEvent
+	events  map[string]map[string]any // events served by /event, by ID
+
+	mu    sync.Mutex
+	batch int
+}
+
+func newHomeserver(t *testing.T, events map[string]map[string]any) *homeserver {
+	hs := &homeserver{
+		t:       t,
+		batches: make(chan map[string]any, 10),
+		sent:    make(chan sentEvent, 10),
+		events:  events,
+	}
+	server := httptest.NewServer(hs.handler())
+	t.Cleanup(server.Close)
+	hs.url, hs.client = server.URL, server.Client()
+	return hs
+}
+
+// login starts a platform with token, handing incoming messages to handler
+func (hs *homeserver) login(token string, handler func(router.Message)) (*Platform, error) {
+	p, err := New(Config{Homeserver: hs.url, AccessToken: token, HTTPClient: hs.client})
+	if err != nil {
+		return nil, err
+	}
+	p.SetMessageHandler(handler)
+	if err := p.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	hs.t.Cleanup(func() { p.Stop() })
+	return p, nil
+}
+
+func (hs *homeserver) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_matrix/client/v3/account/whoami", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"user_id": botID})
+	})
+	mux.HandleFunc("GET /_matrix/client/v3/profile/{user}/displayname", func(w http.ResponseWriter, r *http.Request) {
+		names := map[string]string{botID: "Bot", aliceID: "Alice"}
+		writeJSON(w, map[string]string{"displayname": names[r.PathValue("user")]})
+	})
+	mux.HandleFunc("GET /_matrix/client/v3/sync", hs.sync)
+	mux.HandleFunc("GET /_matrix/client/v3/rooms/{room}/event/{event}", func(w http.ResponseWriter, r *http.Request) {
+		ev, ok := hs.events[r.PathValue("event")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			writeJSON(w, map[string]string{"errcode": "M_NOT_FOUND"})
+			return
+		}
+		writeJSON(w, ev)
+	})
+	mux.HandleFunc("PUT /_matrix/client/v3/rooms/{room}/send/{type}/{txn}", func(w http.ResponseWriter, r *http.Request) {
+		var content map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&content); err != nil {
+			hs.t.Errorf("invalid event content: %v", err)
+		}
+		hs.sent <- sentEvent{Room: r.PathValue("room"), Type: r.PathValue("type"), Content: content}
+		writeJSON(w, map[string]string{"event_id": "$sent"})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{})
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]string{"errcode": "M_UNKNOWN_TOKEN"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// sync answers the first sync with a message sent before the bot started,
+// then long-polls for queued batches
+func (hs *homeserver) sync(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("since") == "" {
+		writeJSON(w, map[string]any{
+			"next_batch": "s0",
+			"rooms": map[string]any{"join": map[string]any{
+				"!dm:example.org": room(2, textEvent("$old", aliceID, "sent while the bot was offline")),
+			}},
+		})
+		return
+	}
+
+	select {
+	case batch := <-hs.batches:
+		hs.mu.Lock()
+		hs.batch++
+		batch["next_batch"] = fmt.Sprintf("s%d", hs.batch)
+		hs.mu.Unlock()
+		writeJSON(w, batch)
+	case <-time.After(50 * time.Millisecond):
+		writeJSON(w, map[string]any{"next_batch": r.URL.Query().Get("since")})
+	case <-r.Context().Done():
+	}
+}
+
+// push queues a sync batch with timeline events for one room
+func (hs *homeserver) push(roomID string, members int, events ...map[string]any) {
+	hs.batches <- map[string]any{
+		"rooms": map[string]any{"join": map[string]any{roomID: room(members, events...)}},
+	}
+}
+
+func room(members int, events ...map[string]any) map[string]any {
+	return map[string]any{
+		"summary":  map[string]any{"m.joined_member_count": members},
+		"timeline": map[string]any{"events": events},
+	}
+}
+
+func textEvent(id, sender, body string) map[string]any {
+	return map[string]any{
+		"type":     "m.room.message",
+		"event_id": id,
+		"sender":   sender,
+		"content":  map[string]any{"msgtype": "m.text", "body": body},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// received returns the messages handled until none arrive for a while
+func received(messages chan router.Message) []router.Message {
+	var got []router.Message
+	for {
+		select {
+		case msg := <-messages:
+			got = append(got, msg)
+		case <-time.After(200 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+func TestSyncTimeline(t *testing.T) {
+	hs := newHomeserver(t, map[string]map[string]any{
+		"$answer": textEvent("$answer", botID, "It's sunny"),
+	})
+	messages := make(chan router.Message, 10)
+	if _, err := hs.login("secret", func(msg router.Message) { messages <- msg }); err != nil {
+		t.Fatal(err)
+	}
+
+	withRelation := func(ev map[string]any, relation map[string]any) map[string]any {
+		ev["content"].(map[string]any)["m.relates_to"] = relation
+		return ev
+	}
+	message := func(id, room, text, thread, chatType string) router.Message {
+		return router.Message{
+			ID:        id,
+			Platform:  "matrix",
+			ChannelID: room,
+			UserID:    aliceID,
+			Username:  "Alice",
+			Text:      text,
+			ThreadID:  thread,
+			Metadata:  map[string]string{"chat_type": chatType, "msgtype": "m.text"},
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		room    string
+		members int
+		events  []map[string]any
+		want    []router.Message
+	}{
+		{
+			name: "direct message", room: "!dm:example.org", members: 2,
+			events: []map[string]any{textEvent("$d1", aliceID, "hello there")},
+			want:   []router.Message{message("$d1", "!dm:example.org", "hello there", "", "dm")},
+		},
+		{
+			name: "group chatter and own messages", room: "!chatter:example.org", members: 5,
+			events: []map[string]any{
+				textEvent("$c1", aliceID, "lunch anyone?"),
+				textEvent("$c2", botID, "Bot: talking to myself"),
+			},
+		},
+		{
+			name: "mention in a thread", room: "!group:example.org", members: 5,
+			events: []map[string]any{withRelation(textEvent("$g1", aliceID, "Bot: what's the weather?"),
+				map[string]any{"rel_type": "m.thread", "event_id": "$root"})},
+			want: []router.Message{message("$g1", "!group:example.org", "what's the weather?", "$root", "group")},
+		},
+		{
+			name: "reply to the bot", room: "!group:example.org", members: 5,
+			events: []map[string]any{withRelation(textEvent("$g2", aliceID, "> <@bot:example.org> It's sunny\n\nand tomorrow?"),
+				map[string]any{"m.in_reply_to": map[string]any{"event_id": "$answer"}})},
+			want: []router.Message{message("$g2", "!group:example.org", "and tomorrow?", "", "group")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hs.push(tc.room, tc.members, tc.events...)
+			if got := received(messages); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSendThreadedReply(t *testing.T) {
+	hs := newHomeserver(t, nil)
+	p, err := hs.login("secret", func(router.Message) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := p.Send(context.Background(), "!group:example.org", router.Response{Text: "**Done**", ThreadID: "$root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "$sent" {
+		t.Errorf("event ID = %q", id)
+	}
+
+	sent := <-hs.sent
+	want := sentEvent{
+		Room: "!group:example.org",
+		Type: "m.room.message",
+		Content: map[string]any{
+			"msgtype":        "m.text",
+			"body":           "**Done**",
+			"format":         "org.matrix.custom.html",
+			"formatted_body": "<p><strong>Done</strong></p>",
+			"m.relates_to": map[string]any{
+				"rel_type":        "m.thread",
+				"event_id":        "$root",
+				"is_falling_back": true,
+				"m.in_reply_to":   map[string]any{"event_id": "$root"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent\ngot  %+v\nwant %+v", sent, want)
+	}
+}
+
+func TestUnknownToken(t *testing.T) {
+	hs := newHomeserver(t, nil)
+	if _, err := hs.login("wrong", nil); err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Errorf("Start with a bad token: %v", err)
+	}
+}
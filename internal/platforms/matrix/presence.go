@@ -0,0 +1,91 @@
+package matrix
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// typingTimeout is how long one typing notification lasts; it is renewed
+// before it runs out
+const typingTimeout = 30 * time.Second
+
+// StartPresence shows "typing…" until the response is sent. Progress updates are
+// shown in a placeholder message that is redacted afterwards.
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	if err := p.client.typing(ctx, msg.ChannelID, p.userID, true, typingTimeout); err != nil {
+		return nil, err
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	ind := &indicator{platform: p, roomID: msg.ChannelID, threadID: msg.ThreadID, cancel: cancel}
+	go ind.typing(loopCtx)
+	return ind, nil
+}
+
+// indicator is a typing notification plus an optional progress placeholder
+type indicator struct {
+	platform    *Platform
+	roomID      string
+	threadID    string
+	placeholder string // Event ID of the progress placeholder
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+}
+
+func (i *indicator) typing(ctx context.Context) {
+	ticker := time.NewTicker(typingTimeout - 5*time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := i.platform.client.typing(ctx, i.roomID, i.platform.userID, true, typingTimeout); err != nil && ctx.Err() == nil {
+			log.Printf("[Matrix] Failed to send typing notification: %v", err)
+			return
+		}
+	}
+}
+
+// Update posts or edits the progress placeholder
+func (i *indicator) Update(ctx context.Context, status string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder == "" {
+		eventID, err := i.platform.Send(ctx, i.roomID, router.Response{Text: status, ThreadID: i.threadID})
+		if err != nil {
+			log.Printf("[Matrix] Failed to send placeholder: %v", err)
+			return
+		}
+		i.placeholder = eventID
+		return
+	}
+	if err := i.platform.Edit(ctx, i.roomID, i.placeholder, router.Response{Text: status}); err != nil {
+		log.Printf("[Matrix] Failed to update placeholder: %v", err)
+	}
+}
+
+// Stop clears the typing notification and removes the placeholder
+func (i *indicator) Stop(ctx context.Context) {
+	i.cancel()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.platform.client.typing(ctx, i.roomID, i.platform.userID, false, 0); err != nil {
+		log.Printf("[Matrix] Failed to clear typing notification: %v", err)
+	}
+	if i.placeholder != "" {
+		if err := i.platform.Delete(ctx, i.roomID, i.placeholder); err != nil {
+			log.Printf("[Matrix] Failed to delete placeholder: %v", err)
+		}
+		i.placeholder = ""
+	}
+}
@@ -0,0 +1,85 @@
+package mattermost
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// maxHistory is the upper bound for FetchHistory
+const maxHistory = 200
+
+// Edit replaces the text of a message the bot sent
+func (p *Platform) Edit(ctx context.Context, channelID, messageID string, resp router.Response) error {
+	return p.client.patchPost(ctx, messageID, resp.Text)
+}
+
+// Delete removes a message
+func (p *Platform) Delete(ctx context.Context, channelID, messageID string) error {
+	return p.client.deletePost(ctx, messageID)
+}
+
+// React adds a reaction; emoji is a Mattermost emoji name without colons
+func (p *Platform) React(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.client.addReaction(ctx, p.botUserID, messageID, strings.Trim(emoji, ":"))
+}
+
+// Unreact removes the bot's reaction
+func (p *Platform) Unreact(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.client.removeReaction(ctx, p.botUserID, messageID, strings.Trim(emoji, ":"))
+}
+
+// SendFile uploads a file and posts it with resp.Text as the message
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+
+	fileID, err := p.client.upload(ctx, channelID, file.Name, file.Data)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	created, err := p.client.createPost(ctx, post{
+		ChannelID: channelID,
+		Message:   resp.Text,
+		RootID:    resp.ThreadID,
+		FileIDs:   []string{fileID},
+	})
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// FetchHistory returns up to limit recent channel messages, oldest first
+func (p *Platform) FetchHistory(ctx context.Context, channelID string, limit int) ([]router.Message, error) {
+	if limit <= 0 || limit > maxHistory {
+		limit = maxHistory
+	}
+
+	posts, err := p.client.channelPosts(ctx, channelID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mattermost returns newest first
+	history := make([]router.Message, 0, len(posts))
+	for i := len(posts) - 1; i >= 0; i-- {
+		ps := posts[i]
+		if ps.Type != "" {
+			continue
+		}
+		history = append(history, router.Message{
+			ID:        ps.ID,
+			Platform:  "mattermost",
+			ChannelID: channelID,
+			UserID:    ps.UserID,
+			Username:  ps.UserID,
+			Text:      ps.Message,
+			ThreadID:  ps.RootID,
+		})
+	}
+	return history, nil
+}
@@ -0,0 +1,281 @@
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// client is a minimal Mattermost REST API (v4) client
+type client struct {
+	baseURL string // Server URL, e.g. https://mattermost.example.com
+	token   string
+	http    *http.Client
+}
+
+// apiError is an error response from the server
+type apiError struct {
+	Status  int    `json:"status_code"`
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	if e.ID == "" {
+		return fmt.Sprintf("mattermost: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("mattermost: %s: %s", e.ID, e.Message)
+}
+
+// post is a Mattermost message
+type post struct {
+	ID        string   `json:"id"`
+	ChannelID string   `json:"channel_id"`
+	UserID    string   `json:"user_id"`
+	RootID    string   `json:"root_id"`
+	Message   string   `json:"message"`
+	Type      string   `json:"type"` // Empty for user posts, "system_*" otherwise
+	FileIDs   []string `json:"file_ids"`
+	CreateAt  int64    `json:"create_at"`
+}
+
+// user is the part of a Mattermost user the adapter needs
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	IsBot    bool   `json:"is_bot"`
+}
+
+// fileInfo describes an uploaded file
+type fileInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+func newClient(baseURL, token string, httpClient *http.Client) *client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    httpClient,
+	}
+}
+
+// websocketURL returns the URL of the event WebSocket
+func (c *client) websocketURL() string {
+	u := c.baseURL + "/api/v4/websocket"
+	if rest, ok := strings.CutPrefix(u, "https://"); ok {
+		return "wss://" + rest
+	}
+	return "ws://" + strings.TrimPrefix(u, "http://")
+}
+
+// do sends a JSON request and decodes the JSON response into out (if not nil)
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	return c.request(ctx, method, path, query, "application/json", reader, out)
+}
+
+// request performs a single API call with the given body
+func (c *client) request(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &apiError{}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = json.Unmarshal(data, apiErr)
+		apiErr.Status = resp.StatusCode
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// me returns the user the token belongs to
+func (c *client) me(ctx context.Context) (*user, error) {
+	var u user
+	if err := c.do(ctx, http.MethodGet, "/api/v4/users/me", nil, nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// user looks up a user by ID
+func (c *client) user(ctx context.Context, userID string) (*user, error) {
+	var u user
+	if err := c.do(ctx, http.MethodGet, "/api/v4/users/"+url.PathEscape(userID), nil, nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// createPost sends a message and returns the created post
+func (c *client) createPost(ctx context.Context, p post) (*post, error) {
+	var created post
+	body := map[string]any{"channel_id": p.ChannelID, "message": p.Message}
+	if p.RootID != "" {
+		body["root_id"] = p.RootID
+	}
+	if len(p.FileIDs) > 0 {
+		body["file_ids"] = p.FileIDs
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v4/posts", nil, body, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// getPost fetches a post by ID
+func (c *client) getPost(ctx context.Context, postID string) (*post, error) {
+	var p post
+	if err := c.do(ctx, http.MethodGet, "/api/v4/posts/"+url.PathEscape(postID), nil, nil, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// patchPost replaces a post's message
+func (c *client) patchPost(ctx context.Context, postID, message string) error {
+	return c.do(ctx, http.MethodPut, "/api/v4/posts/"+url.PathEscape(postID)+"/patch", nil,
+		map[string]string{"message": message}, nil)
+}
+
+// deletePost removes a post
+func (c *client) deletePost(ctx context.Context, postID string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v4/posts/"+url.PathEscape(postID), nil, nil, nil)
+}
+
+// addReaction reacts to a post with an emoji name
+func (c *client) addReaction(ctx context.Context, userID, postID, emoji string) error {
+	return c.do(ctx, http.MethodPost, "/api/v4/reactions", nil,
+		map[string]string{"user_id": userID, "post_id": postID, "emoji_name": emoji}, nil)
+}
+
+// removeReaction removes a reaction the user added
+func (c *client) removeReaction(ctx context.Context, userID, postID, emoji string) error {
+	path := fmt.Sprintf("/api/v4/users/%s/posts/%s/reactions/%s",
+		url.PathEscape(userID), url.PathEscape(postID), url.PathEscape(emoji))
+	return c.do(ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// channelPosts returns recent posts in a channel, newest first
+func (c *client) channelPosts(ctx context.Context, channelID string, limit int) ([]post, error) {
+	var resp struct {
+		Order []string        `json:"order"`
+		Posts map[string]post `json:"posts"`
+	}
+	query := url.Values{"per_page": {strconv.Itoa(limit)}}
+	if err := c.do(ctx, http.MethodGet, "/api/v4/channels/"+url.PathEscape(channelID)+"/posts", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	posts := make([]post, 0, len(resp.Order))
+	for _, id := range resp.Order {
+		if p, ok := resp.Posts[id]; ok {
+			posts = append(posts, p)
+		}
+	}
+	return posts, nil
+}
+
+// fileInfo returns the metadata of an uploaded file
+func (c *client) fileInfo(ctx context.Context, fileID string) (*fileInfo, error) {
+	var info fileInfo
+	if err := c.do(ctx, http.MethodGet, "/api/v4/files/"+url.PathEscape(fileID)+"/info", nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// download fetches a file's content, refusing files larger than maxSize
+func (c *client) download(ctx context.Context, fileID string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/v4/files/"+url.PathEscape(fileID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, &apiError{Status: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// upload stores a file in a channel and returns its ID for a post's file_ids
+func (c *client) upload(ctx context.Context, channelID, name string, data []byte) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := w.WriteField("channel_id", channelID); err != nil {
+		return "", err
+	}
+	part, err := w.CreateFormFile("files", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		FileInfos []fileInfo `json:"file_infos"`
+	}
+	if err := c.request(ctx, http.MethodPost, "/api/v4/files", nil, w.FormDataContentType(), &body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.FileInfos) == 0 {
+		return "", fmt.Errorf("upload returned no file")
+	}
+	return resp.FileInfos[0].ID, nil
+}
@@ -0,0 +1,317 @@
+package mattermost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	// pingInterval is how often the WebSocket is pinged; a connection that
+	// stays silent for two intervals is considered dead
+	pingInterval = 30 * time.Second
+	// maxAttachmentSize caps files downloaded from incoming messages
+	maxAttachmentSize = 20 << 20
+)
+
+// Platform implements router.Platform for Mattermost
+type Platform struct {
+	client         *client
+	botUserID      string
+	botUsername    string
+	messageHandler func(msg router.Message)
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex // gorilla/websocket allows one concurrent writer
+	seq     int
+
+	runErr error // Set when the WebSocket connection ends unexpectedly
+	runMu  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config holds Mattermost configuration
+type Config struct {
+	URL        string       // Server URL, e.g. https://mattermost.example.com
+	Token      string       // Bot account or personal access token
+	HTTPClient *http.Client // Optional, e.g. for tests
+}
+
+// event is a message on the event WebSocket
+type event struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// postedData is the data of a "posted" event; post and mentions are JSON
+// encoded strings
+type postedData struct {
+	ChannelType string `json:"channel_type"` // D = direct, G = group, O = public, P = private
+	Post        string `json:"post"`
+	Mentions    string `json:"mentions"`
+	SenderName  string `json:"sender_name"`
+}
+
+// New creates a new Mattermost platform
+func New(cfg Config) (*Platform, error) {
+	if cfg.URL == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("URL and token are required")
+	}
+	if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+		return nil, fmt.Errorf("URL must be http(s), got %q", cfg.URL)
+	}
+
+	return &Platform{
+		client: newClient(cfg.URL, cfg.Token, cfg.HTTPClient),
+	}, nil
+}
+
+// Name returns the platform name
+func (p *Platform) Name() string {
+	return "mattermost"
+}
+
+// MessageLimits returns Mattermost's post length limit (16383 characters)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 16000, Unit: router.UnitRunes}
+}
+
+// SetMessageHandler sets the callback for incoming messages
+func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
+	p.messageHandler = handler
+}
+
+// Start checks the token and connects to the event WebSocket
+func (p *Platform) Start(ctx context.Context) error {
+	me, err := p.client.me(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to auth: %w", err)
+	}
+	p.botUserID, p.botUsername = me.ID, me.Username
+
+	header := http.Header{"Authorization": {"Bearer " + p.client.token}}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, p.client.websocketURL(), header)
+	if err != nil {
+		return fmt.Errorf("failed to connect to WebSocket: %w", err)
+	}
+	p.conn = conn
+
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.runMu.Lock()
+	p.runErr = nil
+	p.runMu.Unlock()
+
+	go p.readLoop(p.ctx, conn)
+	go p.pingLoop(p.ctx, conn)
+
+	log.Printf("[Mattermost] Connected as @%s", p.botUsername)
+	return nil
+}
+
+// Stop closes the WebSocket connection
+func (p *Platform) Stop() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.conn != nil {
+		p.writeMu.Lock()
+		_ = p.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		p.writeMu.Unlock()
+		return p.conn.Close()
+	}
+	return nil
+}
+
+// Healthy reports an error if the WebSocket connection has ended
+func (p *Platform) Healthy() error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	return p.runErr
+}
+
+// Send posts a message to a channel. With a ThreadID it goes into that thread.
+// Mattermost renders Markdown itself.
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
+	created, err := p.client.createPost(ctx, post{ChannelID: channelID, Message: resp.Text, RootID: resp.ThreadID})
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// readLoop dispatches events until the connection ends
+func (p *Platform) readLoop(ctx context.Context, conn *websocket.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	})
+
+	for {
+		var ev event
+		err := conn.ReadJSON(&ev)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[Mattermost] WebSocket error: %v", err)
+			p.runMu.Lock()
+			p.runErr = err
+			p.runMu.Unlock()
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+
+		if ev.Event == "posted" {
+			var data postedData
+			if err := json.Unmarshal(ev.Data, &data); err != nil {
+				continue
+			}
+			go p.handlePosted(ctx, data)
+		}
+	}
+}
+
+// pingLoop keeps the connection alive and detects dead connections
+func (p *Platform) pingLoop(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.writeMu.Lock()
+		err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+		p.writeMu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// sendAction sends a WebSocket action such as user_typing
+func (p *Platform) sendAction(action string, data map[string]string) error {
+	if p.conn == nil {
+		return errors.New("not connected")
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	p.seq++
+	_ = p.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return p.conn.WriteJSON(map[string]any{"action": action, "seq": p.seq, "data": data})
+}
+
+// handlePosted turns a new post into a router message if the bot should answer it
+func (p *Platform) handlePosted(ctx context.Context, data postedData) {
+	var ps post
+	var props struct {
+		Props map[string]any `json:"props"`
+	}
+	if err := json.Unmarshal([]byte(data.Post), &ps); err != nil {
+		return
+	}
+	_ = json.Unmarshal([]byte(data.Post), &props)
+
+	// Ignore the bot's own posts, system messages and other bots
+	if ps.UserID == p.botUserID || ps.Type != "" || props.Props["from_bot"] == "true" {
+		return
+	}
+
+	var mentions []string
+	if data.Mentions != "" {
+		_ = json.Unmarshal([]byte(data.Mentions), &mentions)
+	}
+	if !p.shouldRespond(data.ChannelType, ps.Message, mentions) {
+		return
+	}
+
+	msg := router.Message{
+		ID:        ps.ID,
+		Platform:  "mattermost",
+		ChannelID: ps.ChannelID,
+		UserID:    ps.UserID,
+		Username:  strings.TrimPrefix(data.SenderName, "@"),
+		Text:      p.cleanMention(ps.Message),
+		ThreadID:  ps.RootID,
+		Metadata: map[string]string{
+			"channel_type": data.ChannelType,
+		},
+	}
+	if msg.Username == "" {
+		msg.Username = p.getUsername(ctx, ps.UserID)
+	}
+	for _, fileID := range ps.FileIDs {
+		if att, err := p.attachment(ctx, fileID); err != nil {
+			log.Printf("[Mattermost] Failed to download file %s: %v", fileID, err)
+		} else {
+			msg.Attachments = append(msg.Attachments, att)
+		}
+	}
+
+	if p.messageHandler != nil {
+		p.messageHandler(msg)
+	}
+}
+
+// shouldRespond checks if the bot should respond to this message
+func (p *Platform) shouldRespond(channelType, text string, mentions []string) bool {
+	// Respond to DMs
+	if channelType == "D" {
+		return true
+	}
+
+	// Respond to mentions
+	if slices.Contains(mentions, p.botUserID) {
+		return true
+	}
+	return strings.Contains(strings.ToLower(text), "@"+strings.ToLower(p.botUsername))
+}
+
+// cleanMention removes the bot mention from the message
+func (p *Platform) cleanMention(text string) string {
+	mention := "@" + p.botUsername
+	for {
+		i := strings.Index(strings.ToLower(text), strings.ToLower(mention))
+		if i < 0 {
+			break
+		}
+		text = text[:i] + text[i+len(mention):]
+	}
+	return strings.TrimSpace(text)
+}
+
+// attachment downloads a file attached to a post
+func (p *Platform) attachment(ctx context.Context, fileID string) (router.Attachment, error) {
+	info, err := p.client.fileInfo(ctx, fileID)
+	if err != nil {
+		return router.Attachment{}, err
+	}
+	data, err := p.client.download(ctx, fileID, maxAttachmentSize)
+	if err != nil {
+		return router.Attachment{}, err
+	}
+	return router.Attachment{Name: info.Name, MimeType: info.MimeType, Data: data}, nil
+}
+
+// getUsername fetches the username for a user ID
+func (p *Platform) getUsername(ctx context.Context, userID string) string {
+	u, err := p.client.user(ctx, userID)
+	if err != nil {
+		return userID
+	}
+	return u.Username
+}
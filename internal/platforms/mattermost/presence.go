@@ -0,0 +1,89 @@
+package mattermost
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// typingInterval is how often "typing…" is renewed; clients hide it after
+// about five seconds
+const typingInterval = 4 * time.Second
+
+// StartPresence shows "typing…" until the response is sent. Progress updates are
+// shown in a placeholder message that is deleted afterwards.
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	data := map[string]string{"channel_id": msg.ChannelID, "parent_id": msg.ThreadID}
+	if err := p.sendAction("user_typing", data); err != nil {
+		return nil, err
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	ind := &indicator{platform: p, channelID: msg.ChannelID, threadID: msg.ThreadID, cancel: cancel}
+	go ind.typing(loopCtx, data)
+	return ind, nil
+}
+
+// indicator is a typing notification plus an optional progress placeholder
+type indicator struct {
+	platform    *Platform
+	channelID   string
+	threadID    string
+	placeholder string // Post ID of the progress placeholder
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+}
+
+func (i *indicator) typing(ctx context.Context, data map[string]string) {
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := i.platform.sendAction("user_typing", data); err != nil {
+			log.Printf("[Mattermost] Failed to send typing notification: %v", err)
+			return
+		}
+	}
+}
+
+// Update posts or edits the progress placeholder
+func (i *indicator) Update(ctx context.Context, status string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder == "" {
+		postID, err := i.platform.Send(ctx, i.channelID, router.Response{Text: status, ThreadID: i.threadID})
+		if err != nil {
+			log.Printf("[Mattermost] Failed to send placeholder: %v", err)
+			return
+		}
+		i.placeholder = postID
+		return
+	}
+	if err := i.platform.client.patchPost(ctx, i.placeholder, status); err != nil {
+		log.Printf("[Mattermost] Failed to update placeholder: %v", err)
+	}
+}
+
+// Stop ends the typing notification and removes the placeholder
+func (i *indicator) Stop(ctx context.Context) {
+	i.cancel()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder != "" {
+		if err := i.platform.client.deletePost(ctx, i.placeholder); err != nil {
+			log.Printf("[Mattermost] Failed to delete placeholder: %v", err)
+		}
+		i.placeholder = ""
+	}
+}
@@ -217,8 +217,8 @@ func (p *Platform) Stop() error {
 	return nil
 }
 
-// Send sends a response via webhook
-func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) error {
+// Send sends a response via webhook. The relay does not report message IDs.
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
 	outgoing := OutgoingResponse{
 		Type:      "response",
 		MessageID: resp.Metadata["message_id"],
@@ -229,12 +229,12 @@ func (p *Platform) Send(ctx context.Context, channelID string, resp router.Respo
 
 	body, err := json.Marshal(outgoing)
 	if err != nil {
-		return fmt.Errorf("failed to marshal response: %w", err)
+		return "", fmt.Errorf("failed to marshal response: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.WebhookURL, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -243,15 +243,15 @@ func (p *Platform) Send(ctx context.Context, channelID string, resp router.Respo
 
 	httpResp, err := p.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send webhook: %w", err)
+		return "", fmt.Errorf("failed to send webhook: %w", err)
 	}
 	defer httpResp.Body.Close()
 
 	if httpResp.StatusCode >= 400 {
-		return fmt.Errorf("webhook returned status %d", httpResp.StatusCode)
+		return "", fmt.Errorf("webhook returned status %d", httpResp.StatusCode)
 	}
 
-	return nil
+	return "", nil
 }
 
 // connect establishes WebSocket connection and authenticates
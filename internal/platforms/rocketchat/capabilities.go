@@ -0,0 +1,88 @@
+package rocketchat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// maxHistory is the upper bound for FetchHistory
+const maxHistory = 200
+
+// Edit replaces the text of a message the bot sent
+func (p *Platform) Edit(ctx context.Context, channelID, messageID string, resp router.Response) error {
+	return p.client.updateMessage(ctx, channelID, messageID, resp.Text)
+}
+
+// Delete removes a message
+func (p *Platform) Delete(ctx context.Context, channelID, messageID string) error {
+	return p.client.deleteMessage(ctx, channelID, messageID)
+}
+
+// React adds a reaction; emoji is an emoji name with or without colons
+func (p *Platform) React(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.client.react(ctx, messageID, emojiName(emoji), true)
+}
+
+// Unreact removes the bot's reaction
+func (p *Platform) Unreact(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.client.react(ctx, messageID, emojiName(emoji), false)
+}
+
+// emojiName returns the ":name:" form Rocket.Chat expects
+func emojiName(emoji string) string {
+	return ":" + strings.Trim(emoji, ":") + ":"
+}
+
+// SendFile uploads a file to a room with resp.Text as its description
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	id, err := p.client.upload(ctx, channelID, file.Name, mimeType, file.Data, resp.Text, resp.ThreadID)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	return id, nil
+}
+
+// FetchHistory returns up to limit recent room messages, oldest first
+func (p *Platform) FetchHistory(ctx context.Context, channelID string, limit int) ([]router.Message, error) {
+	if limit <= 0 || limit > maxHistory {
+		limit = maxHistory
+	}
+
+	info, err := p.room(ctx, channelID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := p.client.history(ctx, channelID, info.Type, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rocket.Chat returns newest first
+	history := make([]router.Message, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := messages[i]
+		if m.Type != "" {
+			continue
+		}
+		history = append(history, router.Message{
+			ID:        m.ID,
+			Platform:  "rocketchat",
+			ChannelID: channelID,
+			UserID:    m.User.ID,
+			Username:  m.User.Username,
+			Text:      m.Text,
+			ThreadID:  m.ThreadID,
+		})
+	}
+	return history, nil
+}
@@ -0,0 +1,332 @@
+package rocketchat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// client is a minimal Rocket.Chat REST API client
+type client struct {
+	baseURL string // Server URL, e.g. https://chat.example.com
+	userID  string
+	token   string // Personal access token
+	http    *http.Client
+}
+
+// apiError is an error response from the server
+type apiError struct {
+	Status    int    `json:"-"`
+	ErrorText string `json:"error"`
+	ErrorType string `json:"errorType"`
+}
+
+func (e *apiError) Error() string {
+	if e.ErrorText == "" {
+		return fmt.Sprintf("rocketchat: HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("rocketchat: %s", e.ErrorText)
+}
+
+// message is a Rocket.Chat message as sent by the REST and realtime APIs
+type message struct {
+	ID       string          `json:"_id"`
+	RoomID   string          `json:"rid"`
+	Text     string          `json:"msg"`
+	ThreadID string          `json:"tmid"`
+	Type     string          `json:"t"` // Empty for user messages, e.g. "uj" for joins
+	User     messageUser     `json:"u"`
+	Mentions []messageUser   `json:"mentions"`
+	Files    []messageFile   `json:"files"`
+	File     *messageFile    `json:"file"`
+	EditedAt json.RawMessage `json:"editedAt"`
+	Bot      json.RawMessage `json:"bot"`
+}
+
+type messageUser struct {
+	ID       string `json:"_id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type messageFile struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// room is the part of a room the adapter needs
+type room struct {
+	ID         string   `json:"_id"`
+	Type       string   `json:"t"` // d = direct, c = public, p = private
+	UsersCount int      `json:"usersCount"`
+	Usernames  []string `json:"usernames"`
+}
+
+func newClient(baseURL, userID, token string, httpClient *http.Client) *client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		userID:  userID,
+		token:   token,
+		http:    httpClient,
+	}
+}
+
+// websocketURL returns the URL of the realtime API
+func (c *client) websocketURL() string {
+	u := c.baseURL + "/websocket"
+	if rest, ok := strings.CutPrefix(u, "https://"); ok {
+		return "wss://" + rest
+	}
+	return "ws://" + strings.TrimPrefix(u, "http://")
+}
+
+// do sends a JSON request and decodes the JSON response into out (if not nil)
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	return c.request(ctx, method, path, query, "application/json", reader, out)
+}
+
+// request performs a single API call with the given body
+func (c *client) request(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	c.authorize(req)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &apiError{Status: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		_ = json.Unmarshal(data, apiErr)
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *client) authorize(req *http.Request) {
+	req.Header.Set("X-User-Id", c.userID)
+	req.Header.Set("X-Auth-Token", c.token)
+}
+
+// me returns the username of the token's user
+func (c *client) me(ctx context.Context) (string, error) {
+	var resp struct {
+		ID       string `json:"_id"`
+		Username string `json:"username"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/me", nil, nil, &resp); err != nil {
+		return "", err
+	}
+	if resp.ID != c.userID {
+		return "", fmt.Errorf("token belongs to %s, not %s", resp.ID, c.userID)
+	}
+	return resp.Username, nil
+}
+
+// roomInfo looks up a room by ID
+func (c *client) roomInfo(ctx context.Context, roomID string) (*room, error) {
+	var resp struct {
+		Room room `json:"room"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/rooms.info", url.Values{"roomId": {roomID}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Room, nil
+}
+
+// sendMessage posts a message, in a thread if threadID is set
+func (c *client) sendMessage(ctx context.Context, roomID, text, threadID string) (string, error) {
+	msg := map[string]string{"rid": roomID, "msg": text}
+	if threadID != "" {
+		msg["tmid"] = threadID
+	}
+	var resp struct {
+		Message message `json:"message"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/chat.sendMessage", nil, map[string]any{"message": msg}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Message.ID, nil
+}
+
+// updateMessage replaces a message's text
+func (c *client) updateMessage(ctx context.Context, roomID, messageID, text string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/chat.update", nil,
+		map[string]string{"roomId": roomID, "msgId": messageID, "text": text}, nil)
+}
+
+// deleteMessage removes a message
+func (c *client) deleteMessage(ctx context.Context, roomID, messageID string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/chat.delete", nil,
+		map[string]string{"roomId": roomID, "msgId": messageID}, nil)
+}
+
+// react adds (or with add false removes) the user's reaction
+func (c *client) react(ctx context.Context, messageID, emoji string, add bool) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/chat.react", nil,
+		map[string]any{"messageId": messageID, "emoji": emoji, "shouldReact": add}, nil)
+}
+
+// history returns recent messages in a room, newest first. The endpoint
+// depends on the room type.
+func (c *client) history(ctx context.Context, roomID, roomType string, count int) ([]message, error) {
+	endpoint := "/api/v1/channels.history"
+	switch roomType {
+	case "p":
+		endpoint = "/api/v1/groups.history"
+	case "d":
+		endpoint = "/api/v1/im.history"
+	}
+	var resp struct {
+		Messages []message `json:"messages"`
+	}
+	query := url.Values{"roomId": {roomID}, "count": {strconv.Itoa(count)}}
+	if err := c.do(ctx, http.MethodGet, endpoint, query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// download fetches an uploaded file, refusing files larger than maxSize
+func (c *client) download(ctx context.Context, file messageFile, maxSize int64) ([]byte, error) {
+	u := c.baseURL + "/file-upload/" + url.PathEscape(file.ID) + "/" + url.PathEscape(file.Name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authorize(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, &apiError{Status: resp.StatusCode}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("file is larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// upload posts a file with a message. Servers from 6.8 on take the file with
+// rooms.media and post it with rooms.mediaConfirm; older ones only have
+// rooms.upload.
+func (c *client) upload(ctx context.Context, roomID, name, mimeType string, data []byte, text, threadID string) (string, error) {
+	body, contentType, err := fileForm(name, mimeType, data, nil)
+	if err != nil {
+		return "", err
+	}
+	var media struct {
+		File struct {
+			ID string `json:"_id"`
+		} `json:"file"`
+	}
+	err = c.request(ctx, http.MethodPost, "/api/v1/rooms.media/"+url.PathEscape(roomID), nil, contentType, body, &media)
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return c.legacyUpload(ctx, roomID, name, mimeType, data, text, threadID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	confirm := map[string]string{"msg": text}
+	if threadID != "" {
+		confirm["tmid"] = threadID
+	}
+	var resp struct {
+		Message message `json:"message"`
+	}
+	path := "/api/v1/rooms.mediaConfirm/" + url.PathEscape(roomID) + "/" + url.PathEscape(media.File.ID)
+	if err := c.do(ctx, http.MethodPost, path, nil, confirm, &resp); err != nil {
+		return "", err
+	}
+	return resp.Message.ID, nil
+}
+
+func (c *client) legacyUpload(ctx context.Context, roomID, name, mimeType string, data []byte, text, threadID string) (string, error) {
+	fields := map[string]string{"msg": text}
+	if threadID != "" {
+		fields["tmid"] = threadID
+	}
+	body, contentType, err := fileForm(name, mimeType, data, fields)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Message message `json:"message"`
+	}
+	if err := c.request(ctx, http.MethodPost, "/api/v1/rooms.upload/"+url.PathEscape(roomID), nil, contentType, body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Message.ID, nil
+}
+
+// fileForm builds a multipart form with the file under "file"
+func fileForm(name, mimeType string, data []byte, fields map[string]string) (*bytes.Buffer, string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for key, value := range fields {
+		if err := w.WriteField(key, value); err != nil {
+			return nil, "", err
+		}
+	}
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {fmt.Sprintf(`form-data; name="file"; filename=%q`, name)},
+		"Content-Type":        {mimeType},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, w.FormDataContentType(), nil
+}
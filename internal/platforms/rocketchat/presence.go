@@ -0,0 +1,105 @@
+package rocketchat
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// typingInterval is how often "typing…" is renewed before clients hide it
+const typingInterval = 4 * time.Second
+
+// StartPresence shows "typing…" until the response is sent. Progress updates are
+// shown in a placeholder message that is deleted afterwards.
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	ind := &indicator{platform: p, roomID: msg.ChannelID, threadID: msg.ThreadID}
+	if err := ind.setTyping(true); err != nil {
+		return nil, err
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	ind.cancel = cancel
+	go ind.typing(loopCtx)
+	return ind, nil
+}
+
+// indicator is a typing notification plus an optional progress placeholder
+type indicator struct {
+	platform    *Platform
+	roomID      string
+	threadID    string
+	placeholder string // Message ID of the progress placeholder
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+}
+
+// setTyping reports the bot's activity in the room
+func (i *indicator) setTyping(typing bool) error {
+	activities := []string{}
+	if typing {
+		activities = append(activities, "user-typing")
+	}
+	extras := map[string]string{}
+	if i.threadID != "" {
+		extras["tmid"] = i.threadID
+	}
+	_, err := i.platform.call("stream-notify-room", i.roomID+"/user-activity", i.platform.username, activities, extras)
+	return err
+}
+
+func (i *indicator) typing(ctx context.Context) {
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := i.setTyping(true); err != nil {
+			log.Printf("[RocketChat] Failed to send typing notification: %v", err)
+			return
+		}
+	}
+}
+
+// Update posts or edits the progress placeholder
+func (i *indicator) Update(ctx context.Context, status string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder == "" {
+		id, err := i.platform.client.sendMessage(ctx, i.roomID, status, i.threadID)
+		if err != nil {
+			log.Printf("[RocketChat] Failed to send placeholder: %v", err)
+			return
+		}
+		i.placeholder = id
+		return
+	}
+	if err := i.platform.client.updateMessage(ctx, i.roomID, i.placeholder, status); err != nil {
+		log.Printf("[RocketChat] Failed to update placeholder: %v", err)
+	}
+}
+
+// Stop clears the typing notification and removes the placeholder
+func (i *indicator) Stop(ctx context.Context) {
+	i.cancel()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.setTyping(false); err != nil {
+		log.Printf("[RocketChat] Failed to clear typing notification: %v", err)
+	}
+	if i.placeholder != "" {
+		if err := i.platform.client.deleteMessage(ctx, i.roomID, i.placeholder); err != nil {
+			log.Printf("[RocketChat] Failed to delete placeholder: %v", err)
+		}
+		i.placeholder = ""
+	}
+}
@@ -0,0 +1,406 @@
+package rocketchat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	// pingInterval is how often the realtime API is pinged; a connection that
+	// stays silent for two intervals is considered dead
+	pingInterval = 30 * time.Second
+	// maxAttachmentSize caps files downloaded from incoming messages
+	maxAttachmentSize = 20 << 20
+	// messagesStream delivers new messages in every room the bot is in
+	messagesStream = "stream-room-messages"
+)
+
+// Platform implements router.Platform for Rocket.Chat, receiving messages
+// over the realtime (DDP) API and sending them over REST
+type Platform struct {
+	client         *client
+	username       string
+	messageHandler func(msg router.Message)
+
+	conn     *websocket.Conn
+	writeMu  sync.Mutex // gorilla/websocket allows one concurrent writer
+	methodID int
+
+	rooms   map[string]*room
+	roomsMu sync.Mutex
+
+	runErr error // Set when the realtime connection ends unexpectedly
+	runMu  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config holds Rocket.Chat configuration
+type Config struct {
+	URL        string       // Server URL, e.g. https://chat.example.com
+	UserID     string       // User ID of the bot account
+	Token      string       // Personal access token of the bot account
+	HTTPClient *http.Client // Optional, e.g. for tests
+}
+
+// ddpMessage is a message of the realtime API
+type ddpMessage struct {
+	Msg        string          `json:"msg"`
+	ID         string          `json:"id"`
+	Collection string          `json:"collection"`
+	Fields     json.RawMessage `json:"fields"`
+	Error      *ddpError       `json:"error"`
+	Subs       []string        `json:"subs"`
+}
+
+type ddpError struct {
+	Error   any    `json:"error"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *ddpError) String() string {
+	if e.Reason != "" {
+		return e.Reason
+	}
+	return e.Message
+}
+
+// New creates a new Rocket.Chat platform
+func New(cfg Config) (*Platform, error) {
+	if cfg.URL == "" || cfg.UserID == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("URL, user ID and token are required")
+	}
+	if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+		return nil, fmt.Errorf("URL must be http(s), got %q", cfg.URL)
+	}
+
+	return &Platform{
+		client: newClient(cfg.URL, cfg.UserID, cfg.Token, cfg.HTTPClient),
+		rooms:  make(map[string]*room),
+	}, nil
+}
+
+// Name returns the platform name
+func (p *Platform) Name() string {
+	return "rocketchat"
+}
+
+// MessageLimits returns Rocket.Chat's default message size limit (5000 characters)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 5000, Unit: router.UnitRunes}
+}
+
+// SetMessageHandler sets the callback for incoming messages
+func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
+	p.messageHandler = handler
+}
+
+// Start checks the token, logs in to the realtime API and subscribes to
+// the bot's messages
+func (p *Platform) Start(ctx context.Context) error {
+	username, err := p.client.me(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to auth: %w", err)
+	}
+	p.username = username
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, p.client.websocketURL(), nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to realtime API: %w", err)
+	}
+	p.conn = conn
+	if err := p.handshake(); err != nil {
+		conn.Close()
+		return err
+	}
+
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.runMu.Lock()
+	p.runErr = nil
+	p.runMu.Unlock()
+
+	go p.readLoop(p.ctx, conn)
+	go p.pingLoop(p.ctx)
+
+	log.Printf("[RocketChat] Connected as @%s", p.username)
+	return nil
+}
+
+// handshake connects, logs in with the access token and subscribes to messages
+func (p *Platform) handshake() error {
+	_ = p.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	defer p.conn.SetReadDeadline(time.Time{})
+
+	if err := p.write(map[string]any{"msg": "connect", "version": "1", "support": []string{"1"}}); err != nil {
+		return err
+	}
+	if _, err := p.await(func(m *ddpMessage) bool { return m.Msg == "connected" }); err != nil {
+		return fmt.Errorf("connect failed: %w", err)
+	}
+
+	loginID, err := p.call("login", map[string]string{"resume": p.client.token})
+	if err != nil {
+		return err
+	}
+	reply, err := p.await(func(m *ddpMessage) bool { return m.Msg == "result" && m.ID == loginID })
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	if reply.Error != nil {
+		return fmt.Errorf("login failed: %s", reply.Error)
+	}
+
+	if err := p.write(map[string]any{
+		"msg": "sub", "id": "messages", "name": messagesStream,
+		"params": []any{"__my_messages__", false},
+	}); err != nil {
+		return err
+	}
+	reply, err = p.await(func(m *ddpMessage) bool {
+		return m.Msg == "ready" || m.Msg == "nosub" && m.ID == "messages"
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe failed: %w", err)
+	}
+	if reply.Msg == "nosub" {
+		return fmt.Errorf("subscribe failed: %s", reply.Error)
+	}
+	return nil
+}
+
+// await reads messages, answering pings, until one matches
+func (p *Platform) await(match func(*ddpMessage) bool) (*ddpMessage, error) {
+	for {
+		var m ddpMessage
+		if err := p.conn.ReadJSON(&m); err != nil {
+			return nil, err
+		}
+		if m.Msg == "ping" {
+			if err := p.write(map[string]string{"msg": "pong"}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if m.Msg == "error" {
+			return nil, errors.New("server rejected the connection")
+		}
+		if match(&m) {
+			return &m, nil
+		}
+	}
+}
+
+// Stop closes the realtime connection
+func (p *Platform) Stop() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.conn != nil {
+		_ = p.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		return p.conn.Close()
+	}
+	return nil
+}
+
+// Healthy reports an error if the realtime connection has ended
+func (p *Platform) Healthy() error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	return p.runErr
+}
+
+// Send posts a message to a room. With a ThreadID it goes into that thread.
+// Rocket.Chat renders Markdown itself.
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
+	return p.client.sendMessage(ctx, channelID, resp.Text, resp.ThreadID)
+}
+
+// write sends a realtime API message
+func (p *Platform) write(v any) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_ = p.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return p.conn.WriteJSON(v)
+}
+
+// call invokes a server method and returns the call's ID; results are not waited for
+func (p *Platform) call(method string, params ...any) (string, error) {
+	if p.conn == nil {
+		return "", errors.New("not connected")
+	}
+	p.writeMu.Lock()
+	p.methodID++
+	id := strconv.Itoa(p.methodID)
+	p.writeMu.Unlock()
+	return id, p.write(map[string]any{"msg": "method", "method": method, "id": id, "params": params})
+}
+
+// readLoop dispatches messages until the connection ends
+func (p *Platform) readLoop(ctx context.Context, conn *websocket.Conn) {
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		var m ddpMessage
+		err := conn.ReadJSON(&m)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("[RocketChat] Realtime API error: %v", err)
+			p.runMu.Lock()
+			p.runErr = err
+			p.runMu.Unlock()
+			return
+		}
+
+		switch {
+		case m.Msg == "ping":
+			_ = p.write(map[string]string{"msg": "pong"})
+		case m.Msg == "changed" && m.Collection == messagesStream:
+			var fields struct {
+				Args []message `json:"args"`
+			}
+			if err := json.Unmarshal(m.Fields, &fields); err != nil {
+				continue
+			}
+			for _, msg := range fields.Args {
+				go p.handleMessage(ctx, msg)
+			}
+		}
+	}
+}
+
+// pingLoop keeps the connection alive; the server's pongs reset the read deadline
+func (p *Platform) pingLoop(ctx context.Context) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := p.write(map[string]string{"msg": "ping"}); err != nil {
+			return
+		}
+	}
+}
+
+// handleMessage turns a new message into a router message if the bot should answer it
+func (p *Platform) handleMessage(ctx context.Context, m message) {
+	// Ignore the bot's own messages, system messages, edits and other bots
+	if m.User.ID == p.client.userID || m.Type != "" || isSet(m.EditedAt) || isSet(m.Bot) {
+		return
+	}
+
+	info, err := p.room(ctx, m.RoomID)
+	if err != nil {
+		log.Printf("[RocketChat] Failed to look up room %s: %v", m.RoomID, err)
+		return
+	}
+	if !p.shouldRespond(info, m) {
+		return
+	}
+
+	msg := router.Message{
+		ID:        m.ID,
+		Platform:  "rocketchat",
+		ChannelID: m.RoomID,
+		UserID:    m.User.ID,
+		Username:  m.User.Username,
+		Text:      p.cleanMention(m.Text),
+		ThreadID:  m.ThreadID,
+		Metadata: map[string]string{
+			"channel_type": info.Type,
+		},
+	}
+
+	files := m.Files
+	if len(files) == 0 && m.File != nil {
+		files = []messageFile{*m.File}
+	}
+	for _, f := range files {
+		data, err := p.client.download(ctx, f, maxAttachmentSize)
+		if err != nil {
+			log.Printf("[RocketChat] Failed to download %s: %v", f.Name, err)
+			continue
+		}
+		msg.Attachments = append(msg.Attachments, router.Attachment{Name: f.Name, MimeType: f.Type, Data: data})
+	}
+
+	if p.messageHandler != nil {
+		p.messageHandler(msg)
+	}
+}
+
+// room returns a room's type and members, looking it up once
+func (p *Platform) room(ctx context.Context, roomID string) (*room, error) {
+	p.roomsMu.Lock()
+	info, ok := p.rooms[roomID]
+	p.roomsMu.Unlock()
+	if ok {
+		return info, nil
+	}
+
+	info, err := p.client.roomInfo(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	p.roomsMu.Lock()
+	p.rooms[roomID] = info
+	p.roomsMu.Unlock()
+	return info, nil
+}
+
+// shouldRespond checks if the bot should respond to this message
+func (p *Platform) shouldRespond(info *room, m message) bool {
+	// Respond to DMs (a direct room with more than two people is a group DM)
+	if info.Type == "d" {
+		members := info.UsersCount
+		if len(info.Usernames) > 0 {
+			members = len(info.Usernames)
+		}
+		if members <= 2 {
+			return true
+		}
+	}
+
+	// Respond to mentions
+	for _, mention := range m.Mentions {
+		if mention.ID == p.client.userID {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(m.Text), "@"+strings.ToLower(p.username))
+}
+
+// cleanMention removes the bot mention from the message
+func (p *Platform) cleanMention(text string) string {
+	mention := "@" + p.username
+	for {
+		i := strings.Index(strings.ToLower(text), strings.ToLower(mention))
+		if i < 0 {
+			break
+		}
+		text = text[:i] + text[i+len(mention):]
+	}
+	return strings.TrimSpace(text)
+}
+
+// isSet reports whether an optional JSON field is present and not null
+func isSet(raw json.RawMessage) bool {
+	return len(raw) > 0 && string(raw) != "null"
+}
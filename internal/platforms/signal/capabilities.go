@@ -0,0 +1,79 @@
+package signal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// Edit replaces the text of a message the bot sent. Signal accepts edits for
+// 24 hours and marks the message as edited.
+func (p *Platform) Edit(ctx context.Context, channelID, messageID string, resp router.Response) error {
+	timestamp, _, err := parseMessageID(messageID)
+	if err != nil {
+		return err
+	}
+	params := p.target(channelID)
+	params["editTimestamp"] = timestamp
+	_, err = p.send(ctx, params, resp.Text)
+	return err
+}
+
+// Delete removes a message the bot sent for everyone
+func (p *Platform) Delete(ctx context.Context, channelID, messageID string) error {
+	timestamp, _, err := parseMessageID(messageID)
+	if err != nil {
+		return err
+	}
+	params := p.target(channelID)
+	params["targetTimestamp"] = timestamp
+	return p.rpc.call(ctx, "remoteDelete", p.params(params), nil)
+}
+
+// React adds a reaction; emoji is the emoji itself, e.g. "👍". Signal allows
+// one reaction per person, so it replaces any earlier one.
+func (p *Platform) React(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.react(ctx, channelID, messageID, emoji, false)
+}
+
+// Unreact removes the bot's reaction
+func (p *Platform) Unreact(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.react(ctx, channelID, messageID, emoji, true)
+}
+
+func (p *Platform) react(ctx context.Context, channelID, messageID, emoji string, remove bool) error {
+	timestamp, author, err := parseMessageID(messageID)
+	if err != nil {
+		return err
+	}
+	params := p.target(channelID)
+	params["emoji"] = emoji
+	params["targetAuthor"] = author
+	params["targetTimestamp"] = timestamp
+	params["remove"] = remove
+	return p.rpc.call(ctx, "sendReaction", p.params(params), nil)
+}
+
+// SendFile sends a file with resp.Text as its caption
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	params := p.target(channelID)
+	params["attachments"] = []string{fmt.Sprintf("data:%s;filename=%s;base64,%s",
+		mimeType, strings.NewReplacer(";", "_", ",", "_").Replace(file.Name), base64.StdEncoding.EncodeToString(file.Data))}
+	quoteParams(params, resp.ThreadID)
+	id, err := p.send(ctx, params, resp.Text)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,68 @@
+package signal
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// typingInterval is how often "typing…" is renewed; clients hide it after
+// fifteen seconds
+const typingInterval = 10 * time.Second
+
+// StartPresence shows "typing…" until the response is sent. Progress is not
+// posted: deleted Signal messages leave a "This message was deleted" notice.
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	ind := &indicator{platform: p, channelID: msg.ChannelID}
+	if err := ind.setTyping(ctx, true); err != nil {
+		return nil, err
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	ind.cancel = cancel
+	go ind.typing(loopCtx)
+	return ind, nil
+}
+
+// indicator is a typing notification
+type indicator struct {
+	platform  *Platform
+	channelID string
+	cancel    context.CancelFunc
+}
+
+func (i *indicator) setTyping(ctx context.Context, typing bool) error {
+	params := i.platform.target(i.channelID)
+	params["stop"] = !typing
+	return i.platform.rpc.call(ctx, "sendTyping", i.platform.params(params), nil)
+}
+
+func (i *indicator) typing(ctx context.Context) {
+	ticker := time.NewTicker(typingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := i.setTyping(ctx, true); err != nil {
+			log.Printf("[Signal] Failed to send typing notification: %v", err)
+			return
+		}
+	}
+}
+
+// Update does nothing; the typing notification stays until Stop
+func (i *indicator) Update(ctx context.Context, status string) {}
+
+// Stop ends the typing notification
+func (i *indicator) Stop(ctx context.Context) {
+	i.cancel()
+	if err := i.setTyping(ctx, false); err != nil {
+		log.Printf("[Signal] Failed to stop typing notification: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package signal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// rpcClient speaks JSON-RPC 2.0 with a signal-cli daemon: one JSON object per
+// line in both directions, with incoming messages sent as "receive"
+// notifications between responses
+type rpcClient struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	nextID  int
+
+	pending map[string]chan rpcMessage
+	mu      sync.Mutex
+	done    chan struct{} // Closed when the connection ends
+	err     error         // Why the connection ended
+}
+
+type rpcMessage struct {
+	ID     string          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// rpcError is an error returned by signal-cli
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("signal-cli: %s (code %d)", e.Message, e.Code)
+}
+
+// dialRPC connects to the daemon's socket. An address containing a slash is a
+// Unix socket path, anything else a TCP host:port.
+func dialRPC(ctx context.Context, address string) (*rpcClient, error) {
+	network := "tcp"
+	if strings.Contains(address, "/") {
+		network = "unix"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcClient{
+		conn:    conn,
+		pending: make(map[string]chan rpcMessage),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// call invokes a method and decodes its result into out (if not nil)
+func (c *rpcClient) call(ctx context.Context, method string, params, out any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	reply := make(chan rpcMessage, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	request, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method, "params": params, "id": id})
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	_, err = c.conn.Write(append(request, '\n'))
+	c.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if out == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, out)
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// readLoop delivers responses to their callers and notifications to notify
+// until the connection ends
+func (c *rpcClient) readLoop(notify func(method string, params json.RawMessage)) {
+	// Lines can be large: getAttachment returns the file base64-encoded
+	reader := bufio.NewReader(c.conn)
+	var err error
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		var msg rpcMessage
+		if json.Unmarshal(line, &msg) != nil {
+			continue
+		}
+		if msg.Method != "" {
+			notify(msg.Method, msg.Params)
+			continue
+		}
+		c.mu.Lock()
+		reply, ok := c.pending[msg.ID]
+		c.mu.Unlock()
+		if ok {
+			reply <- msg
+		}
+	}
+
+	c.mu.Lock()
+	c.err = fmt.Errorf("connection to signal-cli lost: %w", err)
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// close ends the connection; readLoop returns afterwards
+func (c *rpcClient) close() error {
+	return c.conn.Close()
+}
+
+// isRPCError reports whether err was returned by signal-cli itself
+func isRPCError(err error) bool {
+	var rpcErr *rpcError
+	return errors.As(err, &rpcErr)
+}
@@ -0,0 +1,458 @@
+package signal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	// maxAttachmentSize caps files downloaded from incoming messages
+	maxAttachmentSize = 20 << 20
+	// groupPrefix marks channel IDs that are groups rather than phone numbers
+	groupPrefix = "group."
+	// mentionPlaceholder stands in the message text for each mention
+	mentionPlaceholder = '\uFFFC'
+)
+
+// Platform implements router.Platform for Signal through the JSON-RPC
+// interface of a signal-cli daemon (signal-cli -a ACCOUNT daemon --socket)
+type Platform struct {
+	account        string // The bot's phone number
+	uuid           string // The bot's ACI, for recognising mentions
+	address        string
+	messageHandler func(msg router.Message)
+
+	rpc    *rpcClient
+	runErr error // Set when the daemon connection ends unexpectedly
+	runMu  sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Config holds Signal configuration
+type Config struct {
+	Account string // Phone number registered with signal-cli, e.g. "+8613800000000"
+	Socket  string // Daemon socket path or TCP host:port (default: $XDG_RUNTIME_DIR/signal-cli/socket)
+}
+
+// envelope is the part of a received Signal envelope the adapter needs
+type envelope struct {
+	Source       string       `json:"source"`
+	SourceNumber string       `json:"sourceNumber"`
+	SourceUUID   string       `json:"sourceUuid"`
+	SourceName   string       `json:"sourceName"`
+	Timestamp    int64        `json:"timestamp"`
+	DataMessage  *dataMessage `json:"dataMessage"`
+}
+
+type dataMessage struct {
+	Timestamp    int64           `json:"timestamp"`
+	Message      string          `json:"message"`
+	GroupInfo    *groupInfo      `json:"groupInfo"`
+	Quote        *quote          `json:"quote"`
+	Attachments  []attachment    `json:"attachments"`
+	Mentions     []mention       `json:"mentions"`
+	Reaction     json.RawMessage `json:"reaction"`
+	RemoteDelete json.RawMessage `json:"remoteDelete"`
+}
+
+type groupInfo struct {
+	GroupID string `json:"groupId"`
+	Type    string `json:"type"`
+}
+
+type quote struct {
+	ID           int64  `json:"id"` // Timestamp of the quoted message
+	Author       string `json:"author"`
+	AuthorNumber string `json:"authorNumber"`
+	AuthorUUID   string `json:"authorUuid"`
+	Text         string `json:"text"`
+}
+
+type attachment struct {
+	ContentType string `json:"contentType"`
+	Filename    string `json:"filename"`
+	ID          string `json:"id"`
+	Size        int64  `json:"size"`
+}
+
+type mention struct {
+	Name   string `json:"name"`
+	Number string `json:"number"`
+	UUID   string `json:"uuid"`
+	Start  int    `json:"start"`  // In UTF-16 code units
+	Length int    `json:"length"` // Always 1, the placeholder
+}
+
+// New creates a new Signal platform
+func New(cfg Config) (*Platform, error) {
+	if !strings.HasPrefix(cfg.Account, "+") {
+		return nil, fmt.Errorf("account must be a phone number in international format, e.g. +8613800000000")
+	}
+	address := cfg.Socket
+	if address == "" {
+		address = DefaultSocket()
+	}
+	return &Platform{account: cfg.Account, address: address}, nil
+}
+
+// DefaultSocket returns the socket signal-cli's daemon listens on by default
+func DefaultSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "signal-cli", "socket")
+}
+
+// Name returns the platform name
+func (p *Platform) Name() string {
+	return "signal"
+}
+
+// MessageLimits returns Signal's message size limit; longer texts are only
+// delivered as attachments
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 2000, Unit: router.UnitRunes}
+}
+
+// SetMessageHandler sets the callback for incoming messages
+func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
+	p.messageHandler = handler
+}
+
+// Start connects to the signal-cli daemon. Messages arrive as notifications
+// as long as the daemon runs in its default receive mode.
+func (p *Platform) Start(ctx context.Context) error {
+	rpc, err := dialRPC(ctx, p.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to signal-cli at %s: %w", p.address, err)
+	}
+	p.rpc = rpc
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.runMu.Lock()
+	p.runErr = nil
+	p.runMu.Unlock()
+
+	go func() {
+		rpc.readLoop(p.notification)
+		if p.ctx.Err() != nil {
+			return
+		}
+		log.Printf("[Signal] %v", rpc.err)
+		p.runMu.Lock()
+		p.runErr = rpc.err
+		p.runMu.Unlock()
+	}()
+
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := rpc.call(ctx, "version", nil, &version); err != nil {
+		p.Stop()
+		return fmt.Errorf("signal-cli not responding: %w", err)
+	}
+
+	// The bot's UUID identifies mentions, which may not carry a phone number
+	var status []struct {
+		UUID string `json:"uuid"`
+	}
+	if err := rpc.call(ctx, "getUserStatus", p.params(map[string]any{"recipient": []string{p.account}}), &status); err != nil {
+		log.Printf("[Signal] Failed to look up own UUID, mentions by UUID won't be recognised: %v", err)
+	} else if len(status) > 0 {
+		p.uuid = status[0].UUID
+	}
+
+	log.Printf("[Signal] Connected to signal-cli %s as %s", version.Version, p.account)
+	return nil
+}
+
+// Stop closes the connection to the daemon
+func (p *Platform) Stop() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.rpc != nil {
+		return p.rpc.close()
+	}
+	return nil
+}
+
+// Healthy reports an error if the daemon connection has ended
+func (p *Platform) Healthy() error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	return p.runErr
+}
+
+// Send sends a message to a phone number or "group.<id>". With a ThreadID the
+// message quotes that message. Markdown is sent as Signal text styles.
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
+	params := p.target(channelID)
+	quoteParams(params, resp.ThreadID)
+	return p.send(ctx, params, resp.Text)
+}
+
+// quoteParams makes the message quote threadID, if it is a message ID
+func quoteParams(params map[string]any, threadID string) {
+	if timestamp, author, err := parseMessageID(threadID); err == nil {
+		params["quoteTimestamp"] = timestamp
+		params["quoteAuthor"] = author
+	}
+}
+
+// send renders text into params and sends the message, retrying without text
+// styles if signal-cli rejects them
+func (p *Platform) send(ctx context.Context, params map[string]any, text string) (string, error) {
+	plain, styles := markdown.Signal(text)
+	params["message"] = plain
+	if len(styles) > 0 {
+		params["textStyle"] = styles
+	}
+
+	var result struct {
+		Timestamp int64 `json:"timestamp"`
+	}
+	err := p.rpc.call(ctx, "send", p.params(params), &result)
+	if err != nil && len(styles) > 0 && isRPCError(err) {
+		log.Printf("[Signal] Send with text styles failed, retrying without: %v", err)
+		delete(params, "textStyle")
+		err = p.rpc.call(ctx, "send", p.params(params), &result)
+	}
+	if err != nil {
+		return "", err
+	}
+	return messageID(result.Timestamp, p.account), nil
+}
+
+// params adds the account, which a daemon serving several accounts needs
+func (p *Platform) params(params map[string]any) map[string]any {
+	params["account"] = p.account
+	return params
+}
+
+// target returns the recipient parameters for a channel ID
+func (p *Platform) target(channelID string) map[string]any {
+	if groupID, ok := strings.CutPrefix(channelID, groupPrefix); ok {
+		return map[string]any{"groupId": groupID}
+	}
+	return map[string]any{"recipient": []string{channelID}}
+}
+
+// notification handles a JSON-RPC notification from the daemon
+func (p *Platform) notification(method string, params json.RawMessage) {
+	if method != "receive" {
+		return
+	}
+	var receive struct {
+		Account  string   `json:"account"`
+		Envelope envelope `json:"envelope"`
+	}
+	if err := json.Unmarshal(params, &receive); err != nil {
+		log.Printf("[Signal] Failed to parse notification: %v", err)
+		return
+	}
+	if receive.Account != "" && receive.Account != p.account {
+		return
+	}
+	// Handle in the background: downloads need the read loop for their responses
+	go p.handleEnvelope(p.ctx, receive.Envelope)
+}
+
+// handleEnvelope turns a data message into a router message if the bot should answer it
+func (p *Platform) handleEnvelope(ctx context.Context, env envelope) {
+	// Receipts, typing notifications and messages synced from the bot's other
+	// devices have no data message
+	data := env.DataMessage
+	if data == nil || len(data.Reaction) > 0 || len(data.RemoteDelete) > 0 {
+		return
+	}
+	sender := firstOf(env.SourceNumber, env.SourceUUID, env.Source)
+	if sender == "" || p.isSelf(env.SourceNumber, env.SourceUUID) {
+		return
+	}
+	if data.Message == "" && len(data.Attachments) == 0 {
+		return
+	}
+
+	timestamp := firstNonZero(data.Timestamp, env.Timestamp)
+	msg := router.Message{
+		ID:        messageID(timestamp, sender),
+		Platform:  "signal",
+		ChannelID: sender,
+		UserID:    sender,
+		Username:  firstOf(env.SourceName, sender),
+		Metadata:  map[string]string{"chat_type": "direct"},
+	}
+
+	if data.GroupInfo != nil {
+		if !p.addressed(data) {
+			return
+		}
+		msg.ChannelID = groupPrefix + data.GroupInfo.GroupID
+		msg.Metadata["chat_type"] = "group"
+		// Quote the message being answered so the reply is clear in a busy group
+		msg.ThreadID = msg.ID
+	}
+
+	msg.Text = p.resolveMentions(data.Message, data.Mentions)
+	if q := data.Quote; q != nil && q.Text != "" && !p.isSelf(q.AuthorNumber, q.AuthorUUID) {
+		// The agent remembers its own messages, but not what others wrote
+		msg.Text = quoteLines(q.Text) + "\n\n" + msg.Text
+	}
+
+	for _, a := range data.Attachments {
+		file, err := p.download(ctx, msg.ChannelID, a)
+		if err != nil {
+			log.Printf("[Signal] Failed to download attachment %s: %v", a.ID, err)
+			continue
+		}
+		msg.Attachments = append(msg.Attachments, file)
+	}
+
+	p.markRead(ctx, sender, timestamp)
+
+	if p.messageHandler != nil {
+		p.messageHandler(msg)
+	}
+}
+
+// addressed reports whether a group message mentions the bot or quotes one of its messages
+func (p *Platform) addressed(data *dataMessage) bool {
+	for _, m := range data.Mentions {
+		if p.isSelf(m.Number, m.UUID) {
+			return true
+		}
+	}
+	return data.Quote != nil && p.isSelf(data.Quote.AuthorNumber, data.Quote.AuthorUUID)
+}
+
+// isSelf reports whether a number or UUID is the bot's
+func (p *Platform) isSelf(number, uuid string) bool {
+	return number != "" && number == p.account || uuid != "" && uuid == p.uuid
+}
+
+// resolveMentions replaces mention placeholders with "@name", dropping the bot's own
+func (p *Platform) resolveMentions(text string, mentions []mention) string {
+	if len(mentions) == 0 {
+		return strings.TrimSpace(text)
+	}
+	names := make(map[int]string, len(mentions))
+	for _, m := range mentions {
+		if p.isSelf(m.Number, m.UUID) {
+			names[m.Start] = ""
+		} else {
+			names[m.Start] = "@" + firstOf(m.Name, m.Number, m.UUID)
+		}
+	}
+
+	var b strings.Builder
+	offset := 0
+	for _, r := range []rune(text) {
+		if name, ok := names[offset]; ok && r == mentionPlaceholder {
+			b.WriteString(name)
+		} else {
+			b.WriteRune(r)
+		}
+		offset += utf16.RuneLen(r)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// download fetches an attachment's content from the daemon
+func (p *Platform) download(ctx context.Context, channelID string, a attachment) (router.Attachment, error) {
+	file := router.Attachment{Name: a.Filename, MimeType: a.ContentType}
+	if file.Name == "" {
+		file.Name = a.ID
+	}
+	if a.Size > maxAttachmentSize {
+		return file, fmt.Errorf("file is larger than %d bytes", maxAttachmentSize)
+	}
+
+	params := p.target(channelID)
+	if recipients, ok := params["recipient"].([]string); ok {
+		params["recipient"] = recipients[0]
+	}
+	params["id"] = a.ID
+	var result struct {
+		Data string `json:"data"`
+	}
+	if err := p.rpc.call(ctx, "getAttachment", p.params(params), &result); err != nil {
+		return file, err
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Data)
+	if err != nil {
+		return file, err
+	}
+	file.Data = data
+	return file, nil
+}
+
+// markRead sends a read receipt for a message
+func (p *Platform) markRead(ctx context.Context, sender string, timestamp int64) {
+	err := p.rpc.call(ctx, "sendReceipt", p.params(map[string]any{
+		"recipient":       sender,
+		"targetTimestamp": []int64{timestamp},
+		"type":            "read",
+	}), nil)
+	if err != nil {
+		log.Printf("[Signal] Failed to send read receipt: %v", err)
+	}
+}
+
+// messageID identifies a message by its timestamp and author, which is how
+// Signal refers to messages in quotes, reactions and deletions
+func messageID(timestamp int64, author string) string {
+	return strconv.FormatInt(timestamp, 10) + ":" + author
+}
+
+func parseMessageID(id string) (int64, string, error) {
+	ts, author, ok := strings.Cut(id, ":")
+	if !ok || author == "" {
+		return 0, "", fmt.Errorf("invalid message ID %q", id)
+	}
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid message ID %q", id)
+	}
+	return timestamp, author, nil
+}
+
+// quoteLines prefixes every line with "> "
+func quoteLines(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNonZero(values ...int64) int64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,285 @@
+package signal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	botNumber = "+15550000000"
+	botUUID   = "bot-uuid"
+)
+
+// request is a JSON-RPC request received by fakeDaemon
+type request struct {
+	Method string         `json:"method"`
+	Params map[string]any `json:"params"`
+	ID     string         `json:"id"`
+}
+
+// fakeDaemon stands in for signal-cli's daemon on a Unix socket. It serves a
+// single platform and records the messages that platform hands to the router.
+type fakeDaemon struct {
+	*Platform
+	t        *testing.T
+	conn     net.Conn
+	requests chan request
+	handled  chan router.Message
+}
+
+func newDaemon(t *testing.T) *fakeDaemon {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "socket")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	d := &fakeDaemon{t: t, requests: make(chan request, 100), handled: make(chan router.Message, 10)}
+	accepted := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		d.conn = conn
+		close(accepted)
+		d.serve()
+	}()
+
+	d.Platform, err = New(Config{Account: botNumber, Socket: socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetMessageHandler(func(msg router.Message) { d.handled <- msg })
+	if err := d.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Stop() })
+	<-accepted
+	return d
+}
+
+// serve answers requests until the connection closes
+func (d *fakeDaemon) serve() {
+	reader := bufio.NewReader(d.conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			d.t.Errorf("invalid request %q: %v", line, err)
+			return
+		}
+		d.requests <- req
+
+		var result any = map[string]any{}
+		switch req.Method {
+		case "version":
+			result = map[string]string{"version": "0.13.0"}
+		case "getUserStatus":
+			result = []map[string]string{{"uuid": botUUID}}
+		case "send":
+			result = map[string]int64{"timestamp": 1700000000999}
+		}
+		d.write(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}
+}
+
+func (d *fakeDaemon) write(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	if _, err := d.conn.Write(append(data, '\n')); err != nil {
+		d.t.Error(err)
+	}
+}
+
+// receive sends a "receive" notification with a data message
+func (d *fakeDaemon) receive(source, name string, data map[string]any) {
+	d.write(map[string]any{
+		"jsonrpc": "2.0",
+		"method":  "receive",
+		"params": map[string]any{
+			"account": botNumber,
+			"envelope": map[string]any{
+				"source":       source,
+				"sourceNumber": source,
+				"sourceName":   name,
+				"timestamp":    data["timestamp"],
+				"dataMessage":  data,
+			},
+		},
+	})
+}
+
+// nextRequest returns the next request for method, skipping others
+func (d *fakeDaemon) nextRequest(method string) request {
+	d.t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case req := <-d.requests:
+			if req.Method == method {
+				return req
+			}
+		case <-timeout:
+			d.t.Fatalf("no %s request", method)
+		}
+	}
+}
+
+// next returns the next message the platform handed to the router
+func (d *fakeDaemon) next() router.Message {
+	d.t.Helper()
+	select {
+	case msg := <-d.handled:
+		return msg
+	case <-time.After(2 * time.Second):
+		d.t.Fatal("no message handled")
+		return router.Message{}
+	}
+}
+
+func TestDirectMessageWithQuote(t *testing.T) {
+	d := newDaemon(t)
+
+	d.receive("+15551234567", "Alice", map[string]any{
+		"timestamp": 1700000000001,
+		"message":   "What does this mean?",
+		"quote": map[string]any{
+			"id":           1690000000000,
+			"authorNumber": "+15557654321",
+			"text":         "Ship it\non Friday",
+		},
+	})
+
+	msg := d.next()
+	want := router.Message{
+		ID:        "1700000000001:+15551234567",
+		Platform:  "signal",
+		ChannelID: "+15551234567",
+		UserID:    "+15551234567",
+		Username:  "Alice",
+		Text:      "> Ship it\n> on Friday\n\nWhat does this mean?",
+		Metadata:  map[string]string{"chat_type": "direct"},
+	}
+	if !reflect.DeepEqual(msg, want) {
+		t.Errorf("got  %+v\nwant %+v", msg, want)
+	}
+
+	receipt := d.nextRequest("sendReceipt")
+	if receipt.Params["recipient"] != "+15551234567" || receipt.Params["type"] != "read" {
+		t.Errorf("unexpected read receipt %v", receipt.Params)
+	}
+}
+
+func TestGroupMentions(t *testing.T) {
+	d := newDaemon(t)
+	group := map[string]any{"groupId": "Z3JvdXA=", "type": "DELIVER"}
+
+	// Not addressed to the bot: ignored
+	d.receive("+15551234567", "Alice", map[string]any{
+		"timestamp": 1700000000001,
+		"message":   "lunch anyone?",
+		"groupInfo": group,
+	})
+
+	// Mentions the bot and another member, each as a placeholder
+	d.receive("+15551234567", "Alice", map[string]any{
+		"timestamp": 1700000000002,
+		"message":   "\uFFFC ask \uFFFC about it",
+		"groupInfo": group,
+		"mentions": []map[string]any{
+			{"uuid": botUUID, "start": 0, "length": 1},
+			{"name": "Bob", "number": "+15550001111", "start": 6, "length": 1},
+		},
+	})
+
+	msg := d.next()
+	if msg.ID != "1700000000002:+15551234567" {
+		t.Fatalf("unaddressed group message was not ignored, got %q", msg.ID)
+	}
+	if msg.Text != "ask @Bob about it" {
+		t.Errorf("text = %q", msg.Text)
+	}
+	if msg.ChannelID != "group.Z3JvdXA=" || msg.Metadata["chat_type"] != "group" {
+		t.Errorf("channel = %q, chat_type = %q", msg.ChannelID, msg.Metadata["chat_type"])
+	}
+	if msg.ThreadID != msg.ID {
+		t.Errorf("thread = %q, want the message itself", msg.ThreadID)
+	}
+}
+
+func TestQuotingBotAddressesIt(t *testing.T) {
+	d := newDaemon(t)
+
+	// Quoting the bot addresses it in a group; its own text isn't repeated
+	d.receive("+15551234567", "Alice", map[string]any{
+		"timestamp": 1700000000003,
+		"message":   "and tomorrow?",
+		"groupInfo": map[string]any{"groupId": "Z3JvdXA="},
+		"quote": map[string]any{
+			"id":           1700000000000,
+			"authorNumber": botNumber,
+			"text":         "Sunny today",
+		},
+	})
+
+	msg := d.next()
+	if msg.Text != "and tomorrow?" {
+		t.Errorf("text = %q", msg.Text)
+	}
+}
+
+func TestSendStyledQuote(t *testing.T) {
+	d := newDaemon(t)
+
+	id, err := d.Send(context.Background(), "group.Z3JvdXA=", router.Response{
+		Text:     "**Done** in `2s`",
+		ThreadID: "1700000000002:+15551234567",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "1700000000999:"+botNumber {
+		t.Errorf("message ID = %q", id)
+	}
+
+	req := d.nextRequest("send")
+	want := map[string]any{
+		"account":        botNumber,
+		"groupId":        "Z3JvdXA=",
+		"message":        "Done in 2s",
+		"textStyle":      []any{"0:4:BOLD", "8:2:MONOSPACE"},
+		"quoteTimestamp": float64(1700000000002),
+		"quoteAuthor":    "+15551234567",
+	}
+	if !reflect.DeepEqual(req.Params, want) {
+		t.Errorf("send params\ngot  %v\nwant %v", req.Params, want)
+	}
+
+	if _, err := d.Send(context.Background(), "+15551234567", router.Response{Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	req = d.nextRequest("send")
+	if !reflect.DeepEqual(req.Params["recipient"], []any{"+15551234567"}) || req.Params["message"] != "hi" {
+		t.Errorf("direct send params %v", req.Params)
+	}
+	if _, ok := req.Params["textStyle"]; ok {
+		t.Errorf("plain text sent with text styles: %v", req.Params)
+	}
+}
@@ -0,0 +1,172 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+	"github.com/slack-go/slack"
+)
+
+const (
+	maxButtons      = 25   // Slack's limit of elements in an actions block
+	maxButtonValue  = 2000 // Slack's limit for a button's value
+	maxHistory      = 200  // Upper bound for FetchHistory
+	buttonActionIDs = "lingti_button_"
+)
+
+// Edit replaces the text of a message the bot sent
+func (p *Platform) Edit(ctx context.Context, channelID, messageID string, resp router.Response) error {
+	_, _, _, err := p.client.UpdateMessageContext(ctx, channelID, messageID,
+		slack.MsgOptionText(markdown.SlackMrkdwn(resp.Text), false),
+		slack.MsgOptionBlocks(sectionBlocks(resp.Text)...),
+	)
+	return err
+}
+
+// Delete removes a message
+func (p *Platform) Delete(ctx context.Context, channelID, messageID string) error {
+	_, _, err := p.client.DeleteMessageContext(ctx, channelID, messageID)
+	return err
+}
+
+// React adds a reaction; emoji is a Slack emoji name without colons
+func (p *Platform) React(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.client.AddReactionContext(ctx, strings.Trim(emoji, ":"), slack.NewRefToMessage(channelID, messageID))
+}
+
+// Unreact removes the bot's reaction
+func (p *Platform) Unreact(ctx context.Context, channelID, messageID, emoji string) error {
+	return p.client.RemoveReactionContext(ctx, strings.Trim(emoji, ":"), slack.NewRefToMessage(channelID, messageID))
+}
+
+// SendFile uploads a file to a channel. Slack's upload API doesn't return the
+// message timestamp, so the returned ID is the file ID.
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+
+	summary, err := p.client.UploadFileV2Context(ctx, slack.UploadFileV2Parameters{
+		Reader:          bytes.NewReader(file.Data),
+		FileSize:        len(file.Data),
+		Filename:        file.Name,
+		Title:           file.Name,
+		InitialComment:  markdown.SlackMrkdwn(resp.Text),
+		Channel:         channelID,
+		ThreadTimestamp: resp.ThreadID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return summary.ID, nil
+}
+
+// SendInteractive sends a message with a row of buttons
+func (p *Platform) SendInteractive(ctx context.Context, channelID string, resp router.Response, buttons []router.Button) (string, error) {
+	if len(buttons) > maxButtons {
+		return "", fmt.Errorf("too many buttons: %d (max %d)", len(buttons), maxButtons)
+	}
+
+	elements := make([]slack.BlockElement, 0, len(buttons))
+	for i, b := range buttons {
+		if len(b.Value) > maxButtonValue {
+			return "", fmt.Errorf("button value exceeds %d characters", maxButtonValue)
+		}
+		btn := slack.NewButtonBlockElement(fmt.Sprintf("%s%d", buttonActionIDs, i), b.Value,
+			slack.NewTextBlockObject(slack.PlainTextType, b.Label, false, false))
+		switch b.Style {
+		case "primary":
+			btn.WithStyle(slack.StylePrimary)
+		case "danger":
+			btn.WithStyle(slack.StyleDanger)
+		}
+		elements = append(elements, btn)
+	}
+
+	blocks := append(sectionBlocks(resp.Text), slack.NewActionBlock("", elements...))
+	options := []slack.MsgOption{
+		slack.MsgOptionText(markdown.SlackMrkdwn(resp.Text), false),
+		slack.MsgOptionBlocks(blocks...),
+	}
+	if resp.ThreadID != "" {
+		options = append(options, slack.MsgOptionTS(resp.ThreadID))
+	}
+
+	_, ts, err := p.client.PostMessageContext(ctx, channelID, options...)
+	return ts, err
+}
+
+// FetchHistory returns up to limit recent channel messages, oldest first
+func (p *Platform) FetchHistory(ctx context.Context, channelID string, limit int) ([]router.Message, error) {
+	if limit <= 0 || limit > maxHistory {
+		limit = maxHistory
+	}
+
+	result, err := p.client.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
+		ChannelID: channelID,
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Slack returns newest first
+	history := make([]router.Message, 0, len(result.Messages))
+	for i := len(result.Messages) - 1; i >= 0; i-- {
+		m := result.Messages[i]
+		msg := router.Message{
+			ID:        m.Timestamp,
+			Platform:  "slack",
+			ChannelID: channelID,
+			UserID:    m.User,
+			Username:  m.Username,
+			Text:      m.Text,
+			ThreadID:  m.ThreadTimestamp,
+		}
+		for _, f := range m.Files {
+			msg.Attachments = append(msg.Attachments, router.Attachment{
+				Name:     f.Name,
+				MimeType: f.Mimetype,
+				URL:      f.URLPrivate,
+			})
+		}
+		history = append(history, msg)
+	}
+	return history, nil
+}
+
+// handleInteraction turns a button click into a message for the router
+func (p *Platform) handleInteraction(callback slack.InteractionCallback) {
+	if callback.Type != slack.InteractionTypeBlockActions || p.messageHandler == nil {
+		return
+	}
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		if !strings.HasPrefix(action.ActionID, buttonActionIDs) {
+			continue
+		}
+
+		channelID := callback.Container.ChannelID
+		if channelID == "" {
+			channelID = callback.Channel.ID
+		}
+
+		p.messageHandler(router.Message{
+			ID:        "action:" + action.ActionTs,
+			Platform:  "slack",
+			ChannelID: channelID,
+			UserID:    callback.User.ID,
+			Username:  p.getUsername(callback.User.ID),
+			Text:      action.Value,
+			ThreadID:  callback.Container.ThreadTs,
+			Metadata: map[string]string{
+				"button":     "true",
+				"message_id": callback.Container.MessageTs,
+			},
+		})
+	}
+}
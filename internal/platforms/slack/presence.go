@@ -0,0 +1,82 @@
+package slack
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/pltanton/lingti-bot/internal/router"
+	"github.com/slack-go/slack"
+)
+
+// workingReaction is added to the user's message while the bot is working
+const workingReaction = "hourglass_flowing_sand"
+
+// StartPresence reacts to the user's message until the response is sent. Progress
+// updates are shown in a placeholder message that is deleted afterwards.
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	ind := &indicator{client: p.client, channelID: msg.ChannelID, messageTS: msg.ID, threadTS: msg.ThreadID}
+
+	if msg.ID != "" {
+		if err := p.client.AddReactionContext(ctx, workingReaction, slack.NewRefToMessage(msg.ChannelID, msg.ID)); err != nil {
+			// Missing reactions:write scope; placeholders still work
+			log.Printf("[Slack] Failed to add reaction: %v", err)
+		} else {
+			ind.reacted = true
+		}
+	}
+	return ind, nil
+}
+
+// indicator is a reaction plus an optional progress placeholder
+type indicator struct {
+	client      *slack.Client
+	channelID   string
+	messageTS   string // The user's message
+	threadTS    string
+	reacted     bool
+	placeholder string // Timestamp of the progress placeholder
+	mu          sync.Mutex
+}
+
+// Update posts or edits the progress placeholder
+func (i *indicator) Update(ctx context.Context, status string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder == "" {
+		options := []slack.MsgOption{slack.MsgOptionText(status, false)}
+		if i.threadTS != "" {
+			options = append(options, slack.MsgOptionTS(i.threadTS))
+		}
+		_, ts, err := i.client.PostMessageContext(ctx, i.channelID, options...)
+		if err != nil {
+			log.Printf("[Slack] Failed to send placeholder: %v", err)
+			return
+		}
+		i.placeholder = ts
+		return
+	}
+	if _, _, _, err := i.client.UpdateMessageContext(ctx, i.channelID, i.placeholder, slack.MsgOptionText(status, false)); err != nil {
+		log.Printf("[Slack] Failed to update placeholder: %v", err)
+	}
+}
+
+// Stop removes the reaction and the placeholder
+func (i *indicator) Stop(ctx context.Context) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.reacted {
+		if err := i.client.RemoveReactionContext(ctx, workingReaction, slack.NewRefToMessage(i.channelID, i.messageTS)); err != nil {
+			log.Printf("[Slack] Failed to remove reaction: %v", err)
+		}
+		i.reacted = false
+	}
+	if i.placeholder != "" {
+		if _, _, err := i.client.DeleteMessageContext(ctx, i.channelID, i.placeholder); err != nil {
+			log.Printf("[Slack] Failed to delete placeholder: %v", err)
+		}
+		i.placeholder = ""
+	}
+}
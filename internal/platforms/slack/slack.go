@@ -2,10 +2,13 @@ package slack
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
+	"github.com/pltanton/lingti-bot/internal/markdown"
 	"github.com/pltanton/lingti-bot/internal/router"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
@@ -18,6 +21,8 @@ type Platform struct {
 	socketClient   *socketmode.Client
 	botUserID      string
 	messageHandler func(msg router.Message)
+	runErr         error // Set when the socket mode connection ends unexpectedly
+	runMu          sync.Mutex
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -44,16 +49,9 @@ func New(cfg Config) (*Platform, error) {
 		socketmode.OptionDebug(false),
 	)
 
-	// Get bot user ID
-	authTest, err := client.AuthTest()
-	if err != nil {
-		return nil, fmt.Errorf("failed to auth: %w", err)
-	}
-
 	return &Platform{
 		client:       client,
 		socketClient: socketClient,
-		botUserID:    authTest.UserID,
 	}, nil
 }
 
@@ -62,6 +60,11 @@ func (p *Platform) Name() string {
 	return "slack"
 }
 
+// MessageLimits returns the recommended Slack message length (4000 characters)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 4000, Unit: router.UnitRunes}
+}
+
 // SetMessageHandler sets the callback for incoming messages
 func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 	p.messageHandler = handler
@@ -69,14 +72,32 @@ func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 
 // Start begins listening for Slack events
 func (p *Platform) Start(ctx context.Context) error {
-	p.ctx, p.cancel = context.WithCancel(ctx)
+	// Get bot user ID
+	authTest, err := p.client.AuthTestContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to auth: %w", err)
+	}
+	p.botUserID = authTest.UserID
 
-	go p.handleEvents()
-	go func() {
-		if err := p.socketClient.RunContext(p.ctx); err != nil {
-			log.Printf("[Slack] Socket mode error: %v", err)
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	p.runMu.Lock()
+	p.runErr = nil
+	p.runMu.Unlock()
+
+	go p.handleEvents(p.ctx)
+	go func(ctx context.Context) {
+		err := p.socketClient.RunContext(ctx)
+		if ctx.Err() != nil {
+			return
 		}
-	}()
+		if err == nil {
+			err = errors.New("socket mode connection closed")
+		}
+		log.Printf("[Slack] Socket mode error: %v", err)
+		p.runMu.Lock()
+		p.runErr = err
+		p.runMu.Unlock()
+	}(p.ctx)
 
 	log.Printf("[Slack] Connected as bot user: %s", p.botUserID)
 	return nil
@@ -90,25 +111,81 @@ func (p *Platform) Stop() error {
 	return nil
 }
 
+// Healthy reports an error if the socket mode connection has ended
+func (p *Platform) Healthy() error {
+	p.runMu.Lock()
+	defer p.runMu.Unlock()
+	return p.runErr
+}
+
 // Send sends a message to a Slack channel
-func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) error {
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
 	options := []slack.MsgOption{
-		slack.MsgOptionText(resp.Text, false),
+		slack.MsgOptionText(markdown.SlackMrkdwn(resp.Text), false),
+		slack.MsgOptionBlocks(sectionBlocks(resp.Text)...),
 	}
 
 	if resp.ThreadID != "" {
 		options = append(options, slack.MsgOptionTS(resp.ThreadID))
 	}
 
-	_, _, err := p.client.PostMessageContext(ctx, channelID, options...)
-	return err
+	_, ts, err := p.client.PostMessageContext(ctx, channelID, options...)
+	if err != nil && isFormattingError(err) {
+		// Slack rejected the blocks; fall back to plain text
+		log.Printf("[Slack] Formatting rejected, retrying as plain text: %v", err)
+		options = []slack.MsgOption{slack.MsgOptionText(markdown.PlainText(resp.Text), false)}
+		if resp.ThreadID != "" {
+			options = append(options, slack.MsgOptionTS(resp.ThreadID))
+		}
+		_, ts, err = p.client.PostMessageContext(ctx, channelID, options...)
+	}
+	return ts, err
+}
+
+// maxSectionText is Slack's limit for a section block's text
+const maxSectionText = 3000
+
+// sectionBlocks renders Markdown as Block Kit mrkdwn sections
+func sectionBlocks(text string) []slack.Block {
+	var blocks []slack.Block
+	current := ""
+
+	flush := func() {
+		if current != "" {
+			blocks = append(blocks, slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, current, false, false), nil, nil))
+			current = ""
+		}
+	}
+
+	for _, section := range markdown.SlackSections(text) {
+		if current != "" && len([]rune(current))+2+len([]rune(section)) > maxSectionText {
+			flush()
+		}
+		// A single oversized section is cut to fit; the fallback text keeps the full content
+		if runes := []rune(section); len(runes) > maxSectionText {
+			section = string(runes[:maxSectionText-1]) + "…"
+		}
+		if current == "" {
+			current = section
+		} else {
+			current += "\n\n" + section
+		}
+	}
+	flush()
+	return blocks
+}
+
+// isFormattingError reports whether Slack rejected a message's blocks or markup
+func isFormattingError(err error) bool {
+	return strings.Contains(err.Error(), "invalid_blocks") || strings.Contains(err.Error(), "invalid_attachments")
 }
 
 // handleEvents processes incoming Slack events
-func (p *Platform) handleEvents() {
+func (p *Platform) handleEvents(ctx context.Context) {
 	for {
 		select {
-		case <-p.ctx.Done():
+		case <-ctx.Done():
 			return
 		case evt := <-p.socketClient.Events:
 			switch evt.Type {
@@ -127,6 +204,19 @@ func (p *Platform) handleEvents() {
 				}
 				p.socketClient.Ack(*evt.Request)
 				p.handleSlashCommand(cmd)
+
+			case socketmode.EventTypeInteractive:
+				callback, ok := evt.Data.(slack.InteractionCallback)
+				if !ok {
+					continue
+				}
+				p.socketClient.Ack(*evt.Request)
+				p.handleInteraction(callback)
+
+			case socketmode.EventTypeInvalidAuth:
+				p.runMu.Lock()
+				p.runErr = errors.New("invalid app token")
+				p.runMu.Unlock()
 			}
 		}
 	}
@@ -0,0 +1,166 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// maxCallbackData is Telegram's limit for inline button callback data
+const maxCallbackData = 64
+
+// Edit replaces the text of a message the bot sent
+func (p *Platform) Edit(ctx context.Context, channelID, messageID string, resp router.Response) error {
+	bot, chatID, msgID, err := p.messageRef(channelID, messageID)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, msgID, "")
+	edit.Text, edit.ParseMode = p.render(resp.Text)
+
+	_, err = bot.Send(edit)
+	if err != nil && edit.ParseMode != "" && isFormattingError(err) {
+		// Telegram rejected the markup; fall back to plain text
+		edit.Text, edit.ParseMode = markdown.PlainText(resp.Text), ""
+		_, err = bot.Send(edit)
+	}
+	return err
+}
+
+// Delete removes a message
+func (p *Platform) Delete(ctx context.Context, channelID, messageID string) error {
+	bot, chatID, msgID, err := p.messageRef(channelID, messageID)
+	if err != nil {
+		return err
+	}
+	_, err = bot.Request(tgbotapi.NewDeleteMessage(chatID, msgID))
+	return err
+}
+
+// SendFile uploads a file, sending images as photos and everything else as documents
+func (p *Platform) SendFile(ctx context.Context, channelID string, file router.Attachment, resp router.Response) (string, error) {
+	bot, err := p.api()
+	if err != nil {
+		return "", err
+	}
+	chatID, err := parseChatID(channelID)
+	if err != nil {
+		return "", err
+	}
+
+	var data tgbotapi.RequestFileData
+	if len(file.Data) > 0 {
+		data = tgbotapi.FileBytes{Name: file.Name, Bytes: file.Data}
+	} else if file.URL != "" {
+		data = tgbotapi.FileURL(file.URL)
+	} else {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+
+	caption, parseMode := "", ""
+	if resp.Text != "" {
+		caption, parseMode = p.render(resp.Text)
+	}
+	replyTo := 0
+	if resp.ThreadID != "" {
+		replyTo, _ = parseMessageID(resp.ThreadID)
+	}
+
+	var config tgbotapi.Chattable
+	if strings.HasPrefix(file.MimeType, "image/") {
+		photo := tgbotapi.NewPhoto(chatID, data)
+		photo.Caption, photo.ParseMode, photo.ReplyToMessageID = caption, parseMode, replyTo
+		config = photo
+	} else {
+		doc := tgbotapi.NewDocument(chatID, data)
+		doc.Caption, doc.ParseMode, doc.ReplyToMessageID = caption, parseMode, replyTo
+		config = doc
+	}
+
+	sent, err := bot.Send(config)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(sent.MessageID), nil
+}
+
+// SendInteractive sends a message with an inline keyboard, one button per row
+func (p *Platform) SendInteractive(ctx context.Context, channelID string, resp router.Response, buttons []router.Button) (string, error) {
+	bot, err := p.api()
+	if err != nil {
+		return "", err
+	}
+	chatID, err := parseChatID(channelID)
+	if err != nil {
+		return "", err
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, b := range buttons {
+		if len(b.Value) > maxCallbackData {
+			return "", fmt.Errorf("button value %q exceeds %d bytes", b.Value, maxCallbackData)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(b.Label, b.Value)))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "")
+	msg.Text, msg.ParseMode = p.render(resp.Text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if resp.ThreadID != "" {
+		if msgID, err := parseMessageID(resp.ThreadID); err == nil {
+			msg.ReplyToMessageID = msgID
+		}
+	}
+
+	return p.sendMessage(bot, msg, resp.Text)
+}
+
+// handleCallback turns an inline button click into a message for the router
+func (p *Platform) handleCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	// Stop the button's loading spinner
+	if _, err := bot.Request(tgbotapi.NewCallback(query.ID, "")); err != nil {
+		log.Printf("[Telegram] Failed to answer callback: %v", err)
+	}
+
+	if p.messageHandler == nil || query.Message == nil || query.From == nil || query.From.IsBot {
+		return
+	}
+
+	p.messageHandler(router.Message{
+		ID:        "callback:" + query.ID,
+		Platform:  "telegram",
+		ChannelID: fmt.Sprintf("%d", query.Message.Chat.ID),
+		UserID:    fmt.Sprintf("%d", query.From.ID),
+		Username:  getUsername(query.From),
+		Text:      query.Data,
+		Metadata: map[string]string{
+			"chat_type":  query.Message.Chat.Type,
+			"button":     "true",
+			"message_id": strconv.Itoa(query.Message.MessageID),
+		},
+	})
+}
+
+// messageRef returns the bot and parses a chat and message ID pair
+func (p *Platform) messageRef(channelID, messageID string) (*tgbotapi.BotAPI, int64, int, error) {
+	bot, err := p.api()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	chatID, err := parseChatID(channelID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	msgID, err := parseMessageID(messageID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return bot, chatID, msgID, nil
+}
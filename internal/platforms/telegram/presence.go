@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// typingRefresh re-sends the typing action before Telegram's 5 second expiry
+const typingRefresh = 4 * time.Second
+
+// StartPresence shows "typing…" until the response is sent. Progress updates are
+// shown in a placeholder message that is deleted afterwards.
+func (p *Platform) StartPresence(ctx context.Context, msg router.Message) (router.Indicator, error) {
+	bot, err := p.api()
+	if err != nil {
+		return nil, err
+	}
+	chatID, err := parseChatID(msg.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	ind := &indicator{bot: bot, chatID: chatID, cancel: cancel}
+	go ind.typing(loopCtx)
+	return ind, nil
+}
+
+// indicator is a typing action plus an optional progress placeholder
+type indicator struct {
+	bot         *tgbotapi.BotAPI
+	chatID      int64
+	placeholder int // Message ID of the progress placeholder (0 = none)
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+}
+
+func (i *indicator) typing(ctx context.Context) {
+	ticker := time.NewTicker(typingRefresh)
+	defer ticker.Stop()
+
+	for {
+		// Request, not Send: sendChatAction returns true rather than a Message
+		if _, err := i.bot.Request(tgbotapi.NewChatAction(i.chatID, tgbotapi.ChatTyping)); err != nil {
+			log.Printf("[Telegram] Failed to send typing action: %v", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update posts or edits the progress placeholder
+func (i *indicator) Update(ctx context.Context, status string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder == 0 {
+		sent, err := i.bot.Send(tgbotapi.NewMessage(i.chatID, status))
+		if err != nil {
+			log.Printf("[Telegram] Failed to send placeholder: %v", err)
+			return
+		}
+		i.placeholder = sent.MessageID
+		return
+	}
+	if _, err := i.bot.Send(tgbotapi.NewEditMessageText(i.chatID, i.placeholder, status)); err != nil {
+		log.Printf("[Telegram] Failed to update placeholder: %v", err)
+	}
+}
+
+// Stop ends the typing action and removes the placeholder
+func (i *indicator) Stop(ctx context.Context) {
+	i.cancel()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.placeholder != 0 {
+		if _, err := i.bot.Request(tgbotapi.NewDeleteMessage(i.chatID, i.placeholder)); err != nil {
+			log.Printf("[Telegram] Failed to delete placeholder: %v", err)
+		}
+		i.placeholder = 0
+	}
+}
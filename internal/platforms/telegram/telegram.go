@@ -6,9 +6,12 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pltanton/lingti-bot/internal/markdown"
 	"github.com/pltanton/lingti-bot/internal/router"
 )
 
@@ -19,9 +22,13 @@ type VoiceTranscriber interface {
 
 // Platform implements router.Platform for Telegram
 type Platform struct {
-	bot            *tgbotapi.BotAPI
+	token          string
+	debug          bool
+	bot            *tgbotapi.BotAPI // Created by Start
+	botMu          sync.RWMutex
 	messageHandler func(msg router.Message)
 	transcriber    VoiceTranscriber
+	parseMode      string
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -31,6 +38,7 @@ type Config struct {
 	Token       string           // Bot token from @BotFather
 	Debug       bool             // Enable debug logging
 	Transcriber VoiceTranscriber // Optional voice transcriber for voice messages
+	ParseMode   string           // "HTML" (default), "MarkdownV2" or "plain"
 }
 
 // New creates a new Telegram platform
@@ -39,16 +47,20 @@ func New(cfg Config) (*Platform, error) {
 		return nil, fmt.Errorf("Telegram bot token is required")
 	}
 
-	bot, err := tgbotapi.NewBotAPI(cfg.Token)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Telegram bot: %w", err)
+	// Parse modes are matched case-insensitively, like the config accepts them
+	var parseMode string
+	switch strings.ToLower(cfg.ParseMode) {
+	case "", "html":
+		parseMode = tgbotapi.ModeHTML
+	case "markdownv2":
+		parseMode = tgbotapi.ModeMarkdownV2
 	}
 
-	bot.Debug = cfg.Debug
-
 	return &Platform{
-		bot:         bot,
+		token:       cfg.Token,
+		debug:       cfg.Debug,
 		transcriber: cfg.Transcriber,
+		parseMode:   parseMode,
 	}, nil
 }
 
@@ -57,6 +69,11 @@ func (p *Platform) Name() string {
 	return "telegram"
 }
 
+// MessageLimits returns Telegram's message length limit (4096 UTF-16 code units)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: 4096, Unit: router.UnitUTF16}
+}
+
 // SetMessageHandler sets the callback for incoming messages
 func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 	p.messageHandler = handler
@@ -64,16 +81,26 @@ func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 
 // Start begins listening for Telegram updates
 func (p *Platform) Start(ctx context.Context) error {
+	// A stopped bot can't poll again, so each start creates a fresh one
+	bot, err := tgbotapi.NewBotAPI(p.token)
+	if err != nil {
+		return fmt.Errorf("failed to create Telegram bot: %w", err)
+	}
+	bot.Debug = p.debug
+	p.botMu.Lock()
+	p.bot = bot
+	p.botMu.Unlock()
+
 	p.ctx, p.cancel = context.WithCancel(ctx)
 
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
-	updates := p.bot.GetUpdatesChan(u)
+	updates := bot.GetUpdatesChan(u)
 
-	go p.handleUpdates(updates)
+	go p.handleUpdates(p.ctx, bot, updates)
 
-	log.Printf("[Telegram] Connected as bot: @%s", p.bot.Self.UserName)
+	log.Printf("[Telegram] Connected as bot: @%s", bot.Self.UserName)
 	return nil
 }
 
@@ -82,40 +109,156 @@ func (p *Platform) Stop() error {
 	if p.cancel != nil {
 		p.cancel()
 	}
-	p.bot.StopReceivingUpdates()
+	if bot, err := p.api(); err == nil {
+		bot.StopReceivingUpdates()
+	}
 	return nil
 }
 
-// Send sends a message to a Telegram chat
-func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) error {
-	chatID, err := parseChatID(channelID)
+// Healthy reports an error if the Bot API can't be reached with the token
+func (p *Platform) Healthy() error {
+	bot, err := p.api()
 	if err != nil {
 		return err
 	}
+	if _, err := bot.GetMe(); err != nil {
+		return fmt.Errorf("Bot API unreachable: %w", err)
+	}
+	return nil
+}
+
+// api returns the bot created by the last Start
+func (p *Platform) api() (*tgbotapi.BotAPI, error) {
+	p.botMu.RLock()
+	defer p.botMu.RUnlock()
+	if p.bot == nil {
+		return nil, fmt.Errorf("Telegram bot is not connected")
+	}
+	return p.bot, nil
+}
 
-	msg := tgbotapi.NewMessage(chatID, resp.Text)
+// Send sends a message to a Telegram chat
+func (p *Platform) Send(ctx context.Context, channelID string, resp router.Response) (string, error) {
+	bot, err := p.api()
+	if err != nil {
+		return "", err
+	}
 
-	// Enable Markdown formatting
-	msg.ParseMode = "Markdown"
+	chatID, err := parseChatID(channelID)
+	if err != nil {
+		return "", err
+	}
 
 	// Reply to specific message if ThreadID is set
+	replyTo := 0
 	if resp.ThreadID != "" {
-		if msgID, err := parseMessageID(resp.ThreadID); err == nil {
-			msg.ReplyToMessageID = msgID
+		replyTo, _ = parseMessageID(resp.ThreadID)
+	}
+
+	var messageID string
+	for _, part := range p.renderParts(resp.Text) {
+		msg := tgbotapi.NewMessage(chatID, part.text)
+		msg.ParseMode = part.mode
+		msg.ReplyToMessageID = replyTo
+
+		id, err := p.sendMessage(bot, msg, part.source)
+		if err != nil {
+			return messageID, err
 		}
+		messageID = id
+	}
+	return messageID, nil
+}
+
+// renderedPart is a piece of a response converted for the parse mode
+type renderedPart struct {
+	source string // The part's Markdown, for the plain-text fallback
+	text   string
+	mode   string
+}
+
+// renderParts converts text for the parse mode, splitting it so that every
+// part fits Telegram's limit once rendered. The router sizes parts by their
+// Markdown, but escapes and tags can make the rendered text longer.
+func (p *Platform) renderParts(text string) []renderedPart {
+	limits := p.MessageLimits()
+	var parts []renderedPart
+	for _, source := range router.SplitMessage(text, limits) {
+		parts = append(parts, p.renderFitting(source, limits)...)
+	}
+	return parts
+}
+
+// renderFitting renders source, splitting it further in proportion to how
+// much rendering grew it until each part fits
+func (p *Platform) renderFitting(source string, limits router.MessageLimits) []renderedPart {
+	rendered, mode := p.render(source)
+	length := limits.Measure(rendered)
+	if length <= limits.MaxLength {
+		return []renderedPart{{source: source, text: rendered, mode: mode}}
+	}
+
+	smaller := limits
+	smaller.MaxLength = limits.MaxLength * limits.Measure(source) / length
+	pieces := router.SplitMessage(source, smaller)
+	if len(pieces) < 2 {
+		// Can't be split any further; plain text is never longer than the Markdown
+		return []renderedPart{{source: source, text: markdown.PlainText(source)}}
+	}
+
+	var parts []renderedPart
+	for _, piece := range pieces {
+		parts = append(parts, p.renderFitting(piece, limits)...)
+	}
+	return parts
+}
+
+// render converts the agent's Markdown for the configured parse mode
+func (p *Platform) render(text string) (string, string) {
+	switch p.parseMode {
+	case tgbotapi.ModeHTML:
+		return markdown.TelegramHTML(text), tgbotapi.ModeHTML
+	case tgbotapi.ModeMarkdownV2:
+		return markdown.TelegramMarkdownV2(text), tgbotapi.ModeMarkdownV2
+	default:
+		return markdown.PlainText(text), ""
+	}
+}
+
+// sendMessage sends msg, retrying as plain text if Telegram rejects the markup.
+// source is the Markdown msg was rendered from, not the whole response.
+func (p *Platform) sendMessage(bot *tgbotapi.BotAPI, msg tgbotapi.MessageConfig, source string) (string, error) {
+	sent, err := bot.Send(msg)
+	if err != nil && msg.ParseMode != "" && isFormattingError(err) {
+		// Telegram rejected the markup; fall back to plain text
+		log.Printf("[Telegram] Formatting rejected, retrying as plain text: %v", err)
+		msg.Text = markdown.PlainText(source)
+		msg.ParseMode = ""
+		sent, err = bot.Send(msg)
+	}
+	if err != nil {
+		return "", err
 	}
+	return strconv.Itoa(sent.MessageID), nil
+}
 
-	_, err = p.bot.Send(msg)
-	return err
+// isFormattingError reports whether Telegram rejected a message's entities
+func isFormattingError(err error) bool {
+	return strings.Contains(err.Error(), "can't parse entities") ||
+		strings.Contains(err.Error(), "can't find end of")
 }
 
 // handleUpdates processes incoming Telegram updates
-func (p *Platform) handleUpdates(updates tgbotapi.UpdatesChannel) {
+func (p *Platform) handleUpdates(ctx context.Context, bot *tgbotapi.BotAPI, updates tgbotapi.UpdatesChannel) {
 	for {
 		select {
-		case <-p.ctx.Done():
+		case <-ctx.Done():
 			return
 		case update := <-updates:
+			if update.CallbackQuery != nil {
+				p.handleCallback(bot, update.CallbackQuery)
+				continue
+			}
 			if update.Message == nil {
 				continue
 			}
@@ -126,7 +269,7 @@ func (p *Platform) handleUpdates(updates tgbotapi.UpdatesChannel) {
 			}
 
 			// Check if we should respond
-			if !p.shouldRespond(update.Message) {
+			if !p.shouldRespond(bot, update.Message) {
 				continue
 			}
 
@@ -141,7 +284,7 @@ func (p *Platform) handleUpdates(updates tgbotapi.UpdatesChannel) {
 				}
 
 				// Transcribe voice message
-				transcribed, err := p.transcribeVoice(update.Message.Voice.FileID)
+				transcribed, err := p.transcribeVoice(bot, update.Message.Voice.FileID)
 				if err != nil {
 					log.Printf("[Telegram] Failed to transcribe voice: %v", err)
 					continue
@@ -156,7 +299,7 @@ func (p *Platform) handleUpdates(updates tgbotapi.UpdatesChannel) {
 					continue
 				}
 
-				transcribed, err := p.transcribeVoice(update.Message.Audio.FileID)
+				transcribed, err := p.transcribeVoice(bot, update.Message.Audio.FileID)
 				if err != nil {
 					log.Printf("[Telegram] Failed to transcribe audio: %v", err)
 					continue
@@ -165,7 +308,7 @@ func (p *Platform) handleUpdates(updates tgbotapi.UpdatesChannel) {
 				isVoice = true
 				log.Printf("[Telegram] Transcribed audio: %s", text)
 			} else {
-				text = p.cleanMention(update.Message.Text)
+				text = p.cleanMention(bot, update.Message.Text)
 			}
 
 			if text == "" {
@@ -201,15 +344,15 @@ func (p *Platform) handleUpdates(updates tgbotapi.UpdatesChannel) {
 }
 
 // transcribeVoice downloads and transcribes a voice message
-func (p *Platform) transcribeVoice(fileID string) (string, error) {
+func (p *Platform) transcribeVoice(bot *tgbotapi.BotAPI, fileID string) (string, error) {
 	// Get file info from Telegram
-	file, err := p.bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	file, err := bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
 	if err != nil {
 		return "", fmt.Errorf("failed to get file info: %w", err)
 	}
 
 	// Download the file
-	fileURL := file.Link(p.bot.Token)
+	fileURL := file.Link(bot.Token)
 	resp, err := http.Get(fileURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to download file: %w", err)
@@ -226,7 +369,7 @@ func (p *Platform) transcribeVoice(fileID string) (string, error) {
 }
 
 // shouldRespond checks if the bot should respond to this message
-func (p *Platform) shouldRespond(msg *tgbotapi.Message) bool {
+func (p *Platform) shouldRespond(bot *tgbotapi.BotAPI, msg *tgbotapi.Message) bool {
 	// Always respond in private chats
 	if msg.Chat.IsPrivate() {
 		return true
@@ -235,12 +378,12 @@ func (p *Platform) shouldRespond(msg *tgbotapi.Message) bool {
 	// In groups, only respond to mentions or replies to bot
 	if msg.Chat.IsGroup() || msg.Chat.IsSuperGroup() {
 		// Check for @mention
-		if strings.Contains(msg.Text, "@"+p.bot.Self.UserName) {
+		if strings.Contains(msg.Text, "@"+bot.Self.UserName) {
 			return true
 		}
 
 		// Check if replying to bot's message
-		if msg.ReplyToMessage != nil && msg.ReplyToMessage.From.ID == p.bot.Self.ID {
+		if msg.ReplyToMessage != nil && msg.ReplyToMessage.From.ID == bot.Self.ID {
 			return true
 		}
 
@@ -256,8 +399,8 @@ func (p *Platform) shouldRespond(msg *tgbotapi.Message) bool {
 }
 
 // cleanMention removes the bot mention from the message
-func (p *Platform) cleanMention(text string) string {
-	mention := "@" + p.bot.Self.UserName
+func (p *Platform) cleanMention(bot *tgbotapi.BotAPI, text string) string {
+	mention := "@" + bot.Self.UserName
 	text = strings.ReplaceAll(text, mention, "")
 	return strings.TrimSpace(text)
 }
@@ -0,0 +1,142 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// botAPI is a fake Bot API that records sendMessage calls and enforces
+// Telegram's message length limit
+type botAPI struct {
+	mu     sync.Mutex
+	sent   []sentMessage
+	reject func(msg sentMessage) string // Returns an error description to fail a call
+}
+
+type sentMessage struct {
+	Text      string
+	ParseMode string
+}
+
+func (api *botAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getMe"):
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": map[string]any{"id": 1, "is_bot": true, "username": "test_bot"}})
+	case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+		msg := sentMessage{Text: r.FormValue("text"), ParseMode: r.FormValue("parse_mode")}
+		description := ""
+		if (router.MessageLimits{Unit: router.UnitUTF16}).Measure(msg.Text) > 4096 {
+			description = "Bad Request: message is too long"
+		} else if api.reject != nil {
+			description = api.reject(msg)
+		}
+		if description != "" {
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": 400, "description": description})
+			return
+		}
+
+		api.mu.Lock()
+		api.sent = append(api.sent, msg)
+		id := len(api.sent)
+		api.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": map[string]any{"message_id": id, "chat": map[string]any{"id": 42}}})
+	default:
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": true})
+	}
+}
+
+// newPlatform returns a platform connected to api with the given parse mode
+func newPlatform(t *testing.T, api *botAPI, parseMode string) *Platform {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	p, err := New(Config{Token: "test", ParseMode: parseMode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.bot, err = tgbotapi.NewBotAPIWithClient("test", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSendSplitsRenderedText(t *testing.T) {
+	// Fits as Markdown, but escaping makes it several times longer
+	text := strings.Repeat("a < b && c_d_e ", 270)
+	if n := len(text); n > 4096 {
+		t.Fatalf("test text is %d characters, want it to fit before rendering", n)
+	}
+
+	for _, tc := range []struct {
+		mode, escaped string
+		count         int
+	}{
+		{"HTML", "&lt;", 270},
+		{"MarkdownV2", `\_`, 540},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			api := &botAPI{}
+			p := newPlatform(t, api, tc.mode)
+
+			id, err := p.Send(context.Background(), "42", router.Response{Text: text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(api.sent) < 2 {
+				t.Fatalf("sent %d messages, want the rendered text split", len(api.sent))
+			}
+			if want := strconv.Itoa(len(api.sent)); id != want {
+				t.Errorf("message ID = %q, want the last part's %q", id, want)
+			}
+
+			escaped := 0
+			for _, msg := range api.sent {
+				if msg.ParseMode != tc.mode {
+					t.Errorf("part sent with parse mode %q", msg.ParseMode)
+				}
+				escaped += strings.Count(msg.Text, tc.escaped)
+			}
+			if escaped != tc.count {
+				t.Errorf("found %q %d times across parts, want %d", tc.escaped, escaped, tc.count)
+			}
+		})
+	}
+}
+
+func TestSendFallsBackPerPart(t *testing.T) {
+	api := &botAPI{reject: func(msg sentMessage) string {
+		if msg.ParseMode != "" && strings.Contains(msg.Text, "<b>beta</b>") {
+			return "Bad Request: can't parse entities: unsupported start tag"
+		}
+		return ""
+	}}
+	p := newPlatform(t, api, "HTML")
+
+	text := strings.Repeat("**alpha** ", 300) + "\n\n" + strings.Repeat("**beta** ", 300)
+	if _, err := p.Send(context.Background(), "42", router.Response{Text: text}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(api.sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(api.sent))
+	}
+	if first := api.sent[0]; first.ParseMode != "HTML" || !strings.Contains(first.Text, "<b>alpha</b>") {
+		t.Errorf("first part = %+v", first)
+	}
+	second := api.sent[1]
+	if second.ParseMode != "" || !strings.HasPrefix(second.Text, "beta beta") || strings.Contains(second.Text, "alpha") {
+		t.Errorf("the rejected part should be resent alone as plain text, got %q (%s)", second.Text[:min(len(second.Text), 40)], second.ParseMode)
+	}
+}
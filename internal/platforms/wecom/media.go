@@ -0,0 +1,128 @@
+package wecom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+// maxMediaSize caps media downloaded from incoming messages
+const maxMediaSize = 20 << 20
+
+// readMessage extracts the text and attachments of a received message.
+// Images and videos are downloaded; voice is transcribed when a transcriber
+// is set, otherwise attached.
+func (p *Platform) readMessage(ctx context.Context, msg ReceivedMsg) (string, []router.Attachment, map[string]string, error) {
+	metadata := map[string]string{}
+
+	switch msg.MsgType {
+	case "text":
+		return msg.Content, nil, metadata, nil
+
+	case "image", "video", "file":
+		file, err := p.download(ctx, msg.MediaId)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to download %s: %w", msg.MsgType, err)
+		}
+		return "", []router.Attachment{file}, metadata, nil
+
+	case "voice":
+		file, err := p.download(ctx, msg.MediaId)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to download voice: %w", err)
+		}
+		file.MimeType = "audio/" + strings.ToLower(msg.Format)
+		if p.transcriber == nil {
+			logger.Info("[WeCom] Voice message received but no transcriber configured")
+			return "", []router.Attachment{file}, metadata, nil
+		}
+		text, err := p.transcriber.Transcribe(ctx, toWAV(ctx, file.Data))
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to transcribe voice: %w", err)
+		}
+		metadata["message_type"] = "voice"
+		return text, nil, metadata, nil
+
+	case "location":
+		return fmt.Sprintf("[location: %s (%g, %g)]", msg.Label, msg.LocationX, msg.LocationY), nil, metadata, nil
+
+	case "link":
+		return strings.TrimSpace(strings.Join([]string{msg.Title, msg.Description, msg.Url}, "\n")), nil, metadata, nil
+
+	default:
+		return "", nil, nil, fmt.Errorf("unsupported message type %q", msg.MsgType)
+	}
+}
+
+// download fetches temporary media by its media ID
+func (p *Platform) download(ctx context.Context, mediaID string) (router.Attachment, error) {
+	file := router.Attachment{Name: mediaID}
+	if mediaID == "" {
+		return file, fmt.Errorf("no media ID")
+	}
+	token, err := p.getToken()
+	if err != nil {
+		return file, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/media/get?access_token=%s&media_id=%s", p.baseURL, token, mediaID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return file, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return file, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+	if err != nil {
+		return file, err
+	}
+	if len(data) > maxMediaSize {
+		return file, fmt.Errorf("media is larger than %d bytes", maxMediaSize)
+	}
+
+	// Errors come back as JSON instead of the media
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var result apiResult
+		if err := json.Unmarshal(data, &result); err == nil && result.err() != nil {
+			return file, result.err()
+		}
+	}
+
+	file.Data = data
+	file.MimeType = http.DetectContentType(data)
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		file.Name = params["filename"]
+	}
+	return file, nil
+}
+
+// toWAV converts AMR voice, which speech-to-text services don't accept, to
+// WAV with ffmpeg. Without ffmpeg the audio is returned unchanged.
+func toWAV(ctx context.Context, audio []byte) []byte {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		logger.Debug("[WeCom] ffmpeg not found, transcribing AMR as is")
+		return audio
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", "-loglevel", "error", "-i", "pipe:0", "-ar", "16000", "-f", "wav", "pipe:1")
+	cmd.Stdin = bytes.NewReader(audio)
+	wav, err := cmd.Output()
+	if err != nil {
+		logger.Error("[WeCom] Failed to convert voice to WAV: %v", err)
+		return audio
+	}
+	return wav
+}
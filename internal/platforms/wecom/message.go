@@ -0,0 +1,366 @@
+package wecom
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/markdown"
+	"github.com/pltanton/lingti-bot/internal/router"
+)
+
+const (
+	// maxMessageBytes is WeCom's limit for text and markdown content
+	maxMessageBytes = 2048
+
+	// Limits for text card and news article fields
+	maxTitleBytes       = 128
+	maxDescriptionBytes = 512
+
+	// webhookChannel addresses the group robot when app credentials are
+	// configured as well
+	webhookChannel = "webhook"
+)
+
+// message is the msgtype-specific part of an outgoing message
+type message struct {
+	Type    string
+	Content any
+}
+
+func textMessage(text string) message {
+	return message{Type: "text", Content: map[string]string{"content": text}}
+}
+
+func markdownMessage(text string) message {
+	return message{Type: "markdown", Content: map[string]string{"content": text}}
+}
+
+// cardMessage renders a response with a "url" as a text card, or as a news
+// article when an "image_url" is set or the text card isn't available
+func cardMessage(resp router.Response, textcard bool) message {
+	text := strings.TrimSpace(markdown.PlainText(resp.Text))
+	title := resp.Metadata["title"]
+	if title == "" {
+		title, text, _ = strings.Cut(text, "\n")
+		text = strings.TrimSpace(text)
+	}
+	if text == "" {
+		text = title
+	}
+	title, text = truncate(title, maxTitleBytes), truncate(text, maxDescriptionBytes)
+
+	if textcard && resp.Metadata["image_url"] == "" {
+		card := map[string]string{
+			"title":       title,
+			"description": text,
+			"url":         resp.Metadata["url"],
+		}
+		if label := resp.Metadata["button_text"]; label != "" {
+			card["btntxt"] = label
+		}
+		return message{Type: "textcard", Content: card}
+	}
+
+	return message{Type: "news", Content: map[string]any{
+		"articles": []map[string]string{{
+			"title":       title,
+			"description": text,
+			"url":         resp.Metadata["url"],
+			"picurl":      resp.Metadata["image_url"],
+		}},
+	}}
+}
+
+// Send sends a message to a WeChat Work user ("a|b" for several, "@all" for
+// everyone), or to the group robot. A response with a "url" in its metadata
+// becomes a text card, or a news article if an "image_url" is also set. Other
+// responses are sent as markdown or text, split at WeCom's 2048-byte limit.
+func (p *Platform) Send(ctx context.Context, userID string, resp router.Response) (string, error) {
+	send := func(msg message) (string, error) {
+		if p.useWebhook(userID) {
+			return "", p.webhook.send(ctx, msg)
+		}
+		return p.sendMessage(ctx, userID, msg)
+	}
+
+	if resp.Metadata["url"] != "" {
+		return send(cardMessage(resp, !p.useWebhook(userID)))
+	}
+
+	if p.msgType == "markdown" {
+		id, sent, err := sendParts(send, markdown.WeCom(resp.Text), markdownMessage)
+		if err == nil || sent > 0 {
+			return id, err
+		}
+		logger.Verbose("[WeCom] Markdown rejected, sending plain text: %v", err)
+	}
+	id, _, err := sendParts(send, markdown.PlainText(resp.Text), textMessage)
+	return id, err
+}
+
+// sendParts splits content at WeCom's limit and sends the parts in order. It
+// returns the ID of the last message and how many parts were sent.
+func sendParts(send func(message) (string, error), content string, build func(string) message) (string, int, error) {
+	parts := router.SplitMessage(content, router.MessageLimits{MaxLength: maxMessageBytes, Unit: router.UnitBytes})
+
+	var messageID string
+	for i, part := range parts {
+		id, err := send(build(part))
+		if err != nil {
+			return messageID, i, err
+		}
+		messageID = id
+	}
+	return messageID, len(parts), nil
+}
+
+// useWebhook reports whether messages to a channel go through the group robot
+func (p *Platform) useWebhook(channelID string) bool {
+	return p.webhook != nil && (p.webhookOnly() || channelID == webhookChannel)
+}
+
+// sendMessage sends an application message to users
+func (p *Platform) sendMessage(ctx context.Context, userID string, msg message) (string, error) {
+	body := map[string]any{
+		"touser":  userID,
+		"msgtype": msg.Type,
+		"agentid": p.agentID,
+		msg.Type:  msg.Content,
+	}
+
+	var result struct {
+		MsgID string `json:"msgid"`
+	}
+	if err := p.post(ctx, "/message/send", body, &result); err != nil {
+		return "", fmt.Errorf("failed to send message: %w", err)
+	}
+	return result.MsgID, nil
+}
+
+// SendFile uploads a file and sends it as an image, voice, video or file
+// message. The caption, if any, is sent as a separate message first.
+func (p *Platform) SendFile(ctx context.Context, userID string, file router.Attachment, resp router.Response) (string, error) {
+	if len(file.Data) == 0 {
+		return "", fmt.Errorf("attachment %q has no data", file.Name)
+	}
+	if strings.TrimSpace(resp.Text) != "" {
+		if _, err := p.Send(ctx, userID, router.Response{Text: resp.Text}); err != nil {
+			return "", err
+		}
+	}
+
+	if p.useWebhook(userID) {
+		return "", p.webhook.sendFile(ctx, file)
+	}
+
+	mediaType := uploadType(file)
+	mediaID, err := p.upload(ctx, mediaType, file)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	return p.sendMessage(ctx, userID, message{Type: mediaType, Content: map[string]string{"media_id": mediaID}})
+}
+
+// uploadType picks the media type for a file. WeCom plays voice messages only
+// in AMR and videos only in MP4; anything else is sent as a file.
+func uploadType(file router.Attachment) string {
+	switch mimeType(file) {
+	case "image/jpeg", "image/png":
+		return "image"
+	case "audio/amr":
+		return "voice"
+	case "video/mp4":
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+func mimeType(file router.Attachment) string {
+	if file.MimeType != "" {
+		return file.MimeType
+	}
+	return http.DetectContentType(file.Data)
+}
+
+// upload stores a file as temporary media (kept for three days) and returns its media ID
+func (p *Platform) upload(ctx context.Context, mediaType string, file router.Attachment) (string, error) {
+	token, err := p.getToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/media/upload?access_token=%s&type=%s", p.baseURL, token, mediaType)
+	return uploadMedia(ctx, p.client, endpoint, file)
+}
+
+// post calls an API method with the access token
+func (p *Platform) post(ctx context.Context, path string, body, out any) error {
+	token, err := p.getToken()
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	return postJSON(ctx, p.client, fmt.Sprintf("%s%s?access_token=%s", p.baseURL, path, token), body, out)
+}
+
+// apiResult is the status every API response carries
+type apiResult struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func (r apiResult) err() error {
+	if r.ErrCode != 0 {
+		return fmt.Errorf("API error: %d - %s", r.ErrCode, r.ErrMsg)
+	}
+	return nil
+}
+
+// postJSON posts a JSON body and decodes the response into out (if not nil)
+func postJSON(ctx context.Context, client *http.Client, endpoint string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(client, req, out)
+}
+
+// uploadMedia posts a file as multipart form data and returns its media ID
+func uploadMedia(ctx context.Context, client *http.Client, endpoint string, file router.Attachment) (string, error) {
+	name := file.Name
+	if name == "" {
+		name = "file"
+	}
+
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf("form-data; name=\"media\"; filename=%q; filelength=%d", name, len(file.Data)))
+	header.Set("Content-Type", mimeType(file))
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	part.Write(file.Data)
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	var result struct {
+		MediaID string `json:"media_id"`
+	}
+	if err := do(client, req, &result); err != nil {
+		return "", err
+	}
+	return result.MediaID, nil
+}
+
+// do sends a request and checks the API status of the JSON response
+func do(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	var result apiResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("failed to decode response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if err := result.err(); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// webhookClient posts to a group robot (群机器人) webhook. A group robot can
+// only send to its own group and receives no messages.
+type webhookClient struct {
+	url    *url.URL // .../webhook/send?key=...
+	client *http.Client
+}
+
+func newWebhookClient(rawURL string, client *http.Client) (*webhookClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Query().Get("key") == "" {
+		return nil, fmt.Errorf("webhook URL must be a group robot URL with a key, e.g. %s/webhook/send?key=...", apiBaseURL)
+	}
+	return &webhookClient{url: u, client: client}, nil
+}
+
+func (w *webhookClient) send(ctx context.Context, msg message) error {
+	body := map[string]any{
+		"msgtype": msg.Type,
+		msg.Type:  msg.Content,
+	}
+	if err := postJSON(ctx, w.client, w.url.String(), body, nil); err != nil {
+		return fmt.Errorf("failed to send webhook message: %w", err)
+	}
+	return nil
+}
+
+// sendFile posts a JPG or PNG of up to 2 MB as an image and anything else as
+// a file
+func (w *webhookClient) sendFile(ctx context.Context, file router.Attachment) error {
+	if t := mimeType(file); (t == "image/jpeg" || t == "image/png") && len(file.Data) <= 2<<20 {
+		sum := md5.Sum(file.Data)
+		return w.send(ctx, message{Type: "image", Content: map[string]string{
+			"base64": base64.StdEncoding.EncodeToString(file.Data),
+			"md5":    hex.EncodeToString(sum[:]),
+		}})
+	}
+
+	upload := *w.url
+	upload.Path = strings.TrimSuffix(upload.Path, "/send") + "/upload_media"
+	query := upload.Query()
+	query.Set("type", "file")
+	upload.RawQuery = query.Encode()
+
+	mediaID, err := uploadMedia(ctx, w.client, upload.String(), file)
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
+	}
+	return w.send(ctx, message{Type: "file", Content: map[string]string{"media_id": mediaID}})
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n-len("…")]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "…"
+}
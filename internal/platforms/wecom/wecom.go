@@ -1,12 +1,12 @@
 package wecom
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -15,10 +15,12 @@ import (
 	"github.com/pltanton/lingti-bot/internal/router"
 )
 
-const (
-	tokenURL   = "https://qyapi.weixin.qq.com/cgi-bin/gettoken"
-	sendMsgURL = "https://qyapi.weixin.qq.com/cgi-bin/message/send"
-)
+const apiBaseURL = "https://qyapi.weixin.qq.com/cgi-bin"
+
+// VoiceTranscriber transcribes voice messages
+type VoiceTranscriber interface {
+	Transcribe(ctx context.Context, audio []byte) (string, error)
+}
 
 // Platform implements router.Platform for WeChat Work (企业微信)
 type Platform struct {
@@ -28,12 +30,22 @@ type Platform struct {
 	token          string
 	encodingAESKey string
 
+	msgType     string // markdown or text
+	transcriber VoiceTranscriber
+	webhook     *webhookClient // Group robot, if configured
+
+	baseURL        string
+	client         *http.Client
 	msgCrypt       *MsgCrypt
 	accessToken    string
 	tokenExpiry    time.Time
 	tokenMu        sync.RWMutex
 	messageHandler func(msg router.Message)
+	addr           string
+	handler        http.Handler
 	server         *http.Server
+	serveErr       error // Set if the callback server stopped unexpectedly
+	serverMu       sync.Mutex
 	ctx            context.Context
 	cancel         context.CancelFunc
 }
@@ -46,10 +58,43 @@ type Config struct {
 	Token          string // 回调Token
 	EncodingAESKey string // 回调EncodingAESKey
 	CallbackPort   int    // 回调服务端口 (default: 8080)
+
+	MsgType     string           // Reply format: markdown (default) or text
+	WebhookURL  string           // 群机器人 webhook; without app credentials the platform only sends
+	Transcriber VoiceTranscriber // Optional, transcribes voice messages
 }
 
-// New creates a new WeChat Work platform
+// New creates a new WeChat Work platform. With only a WebhookURL it sends
+// through the group robot and receives nothing.
 func New(cfg Config) (*Platform, error) {
+	msgType := cfg.MsgType
+	switch msgType {
+	case "":
+		msgType = "markdown"
+	case "markdown", "text":
+	default:
+		return nil, fmt.Errorf("unknown message type %q (supported: markdown, text)", msgType)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	p := &Platform{
+		msgType:     msgType,
+		transcriber: cfg.Transcriber,
+		baseURL:     apiBaseURL,
+		client:      client,
+	}
+
+	if cfg.WebhookURL != "" {
+		webhook, err := newWebhookClient(cfg.WebhookURL, client)
+		if err != nil {
+			return nil, err
+		}
+		p.webhook = webhook
+		if cfg.CorpID == "" && cfg.AgentID == "" && cfg.Secret == "" {
+			return p, nil
+		}
+	}
+
 	if cfg.CorpID == "" || cfg.AgentID == "" || cfg.Secret == "" {
 		return nil, fmt.Errorf("CorpID, AgentID, and Secret are required")
 	}
@@ -67,23 +112,18 @@ func New(cfg Config) (*Platform, error) {
 		port = 8080
 	}
 
-	p := &Platform{
-		corpID:         cfg.CorpID,
-		agentID:        cfg.AgentID,
-		secret:         cfg.Secret,
-		token:          cfg.Token,
-		encodingAESKey: cfg.EncodingAESKey,
-		msgCrypt:       msgCrypt,
-	}
+	p.corpID = cfg.CorpID
+	p.agentID = cfg.AgentID
+	p.secret = cfg.Secret
+	p.token = cfg.Token
+	p.encodingAESKey = cfg.EncodingAESKey
+	p.msgCrypt = msgCrypt
+	p.addr = fmt.Sprintf(":%d", port)
 
-	// Set up HTTP server for callbacks
+	// Set up HTTP handler for callbacks
 	mux := http.NewServeMux()
 	mux.HandleFunc("/wecom/callback", p.handleCallback)
-
-	p.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
-	}
+	p.handler = mux
 
 	return p, nil
 }
@@ -93,6 +133,16 @@ func (p *Platform) Name() string {
 	return "wecom"
 }
 
+// MessageLimits returns WeCom's text and markdown message limit (2048 bytes)
+func (p *Platform) MessageLimits() router.MessageLimits {
+	return router.MessageLimits{MaxLength: maxMessageBytes, Unit: router.UnitBytes}
+}
+
+// webhookOnly reports whether the platform only sends through a group robot
+func (p *Platform) webhookOnly() bool {
+	return p.corpID == ""
+}
+
 // SetMessageHandler sets the callback for incoming messages
 func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 	p.messageHandler = handler
@@ -102,19 +152,39 @@ func (p *Platform) SetMessageHandler(handler func(msg router.Message)) {
 func (p *Platform) Start(ctx context.Context) error {
 	p.ctx, p.cancel = context.WithCancel(ctx)
 
+	if p.webhookOnly() {
+		logger.Info("[WeCom] Sending through the group robot webhook; incoming messages are not received")
+		return nil
+	}
+
 	// Get initial access token
 	if err := p.refreshToken(); err != nil {
 		return fmt.Errorf("failed to get access token: %w", err)
 	}
 
 	// Start token refresh goroutine
-	go p.tokenRefreshLoop()
+	go p.tokenRefreshLoop(p.ctx)
+
+	// Bind the callback port up front so a port conflict fails Start
+	ln, err := net.Listen("tcp", p.addr)
+	if err != nil {
+		p.cancel()
+		return fmt.Errorf("failed to listen on %s: %w", p.addr, err)
+	}
+
+	server := &http.Server{Handler: p.handler}
+	p.serverMu.Lock()
+	p.server = server
+	p.serveErr = nil
+	p.serverMu.Unlock()
 
-	// Start HTTP server
 	go func() {
-		logger.Info("[WeCom] Starting callback server on %s", p.server.Addr)
-		if err := p.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("[WeCom] Starting callback server on %s", p.addr)
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
 			logger.Error("[WeCom] Server error: %v", err)
+			p.serverMu.Lock()
+			p.serveErr = err
+			p.serverMu.Unlock()
 		}
 	}()
 
@@ -127,55 +197,23 @@ func (p *Platform) Stop() error {
 	if p.cancel != nil {
 		p.cancel()
 	}
-	if p.server != nil {
+	p.serverMu.Lock()
+	server := p.server
+	p.serverMu.Unlock()
+
+	if server != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		return p.server.Shutdown(ctx)
+		return server.Shutdown(ctx)
 	}
 	return nil
 }
 
-// Send sends a message to a WeChat Work user
-func (p *Platform) Send(ctx context.Context, userID string, resp router.Response) error {
-	token, err := p.getToken()
-	if err != nil {
-		return fmt.Errorf("failed to get access token: %w", err)
-	}
-
-	msg := map[string]any{
-		"touser":  userID,
-		"msgtype": "text",
-		"agentid": p.agentID,
-		"text": map[string]string{
-			"content": resp.Text,
-		},
-	}
-
-	body, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
-	}
-
-	url := fmt.Sprintf("%s?access_token=%s", sendMsgURL, token)
-	httpResp, err := http.Post(url, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
-	}
-	defer httpResp.Body.Close()
-
-	var result struct {
-		ErrCode int    `json:"errcode"`
-		ErrMsg  string `json:"errmsg"`
-	}
-	if err := json.NewDecoder(httpResp.Body).Decode(&result); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if result.ErrCode != 0 {
-		return fmt.Errorf("API error: %d - %s", result.ErrCode, result.ErrMsg)
-	}
-
-	return nil
+// Healthy reports an error if the callback server has stopped
+func (p *Platform) Healthy() error {
+	p.serverMu.Lock()
+	defer p.serverMu.Unlock()
+	return p.serveErr
 }
 
 // handleCallback handles incoming callback requests from WeChat Work
@@ -220,11 +258,10 @@ func (p *Platform) handleCallback(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// Parse the decrypted message
-		p.processMessage(plaintext)
-
-		// Return success (empty response)
+		// Ack immediately; WeCom retries callbacks not answered within 5 seconds
 		w.WriteHeader(http.StatusOK)
+
+		go p.processMessage(plaintext)
 	}
 }
 
@@ -238,6 +275,18 @@ type ReceivedMsg struct {
 	Content      string   `xml:"Content"`
 	MsgId        string   `xml:"MsgId"`
 	AgentID      string   `xml:"AgentID"`
+	// Media fields
+	PicUrl       string `xml:"PicUrl"`
+	MediaId      string `xml:"MediaId"`
+	Format       string `xml:"Format"` // Voice codec, e.g. amr
+	ThumbMediaId string `xml:"ThumbMediaId"`
+	// Location and link fields
+	LocationX   float64 `xml:"Location_X"`
+	LocationY   float64 `xml:"Location_Y"`
+	Label       string  `xml:"Label"`
+	Title       string  `xml:"Title"`
+	Description string  `xml:"Description"`
+	Url         string  `xml:"Url"`
 	// Event fields
 	Event    string `xml:"Event"`
 	EventKey string `xml:"EventKey"`
@@ -251,26 +300,35 @@ func (p *Platform) processMessage(plaintext []byte) {
 		return
 	}
 
-	// Only handle text messages for now
-	if msg.MsgType != "text" {
-		logger.Debug("[WeCom] Ignoring message type: %s", msg.MsgType)
+	if msg.MsgType == "event" {
+		logger.Debug("[WeCom] Ignoring event: %s", msg.Event)
+		return
+	}
+	if p.messageHandler == nil {
 		return
 	}
 
-	if p.messageHandler != nil {
-		p.messageHandler(router.Message{
-			ID:        msg.MsgId,
-			Platform:  "wecom",
-			ChannelID: msg.FromUserName, // Use UserID as channel for DM
-			UserID:    msg.FromUserName,
-			Username:  msg.FromUserName, // WeChat Work doesn't provide username in callback
-			Text:      msg.Content,
-			Metadata: map[string]string{
-				"agent_id": msg.AgentID,
-				"msg_type": msg.MsgType,
-			},
-		})
+	text, attachments, metadata, err := p.readMessage(p.ctx, msg)
+	if err != nil {
+		logger.Error("[WeCom] Failed to read %s message: %v", msg.MsgType, err)
+		return
+	}
+	if text == "" && len(attachments) == 0 {
+		return
 	}
+	metadata["agent_id"] = msg.AgentID
+	metadata["msg_type"] = msg.MsgType
+
+	p.messageHandler(router.Message{
+		ID:          msg.MsgId,
+		Platform:    "wecom",
+		ChannelID:   msg.FromUserName, // Use UserID as channel for DM
+		UserID:      msg.FromUserName,
+		Username:    msg.FromUserName, // WeChat Work doesn't provide username in callback
+		Text:        text,
+		Metadata:    metadata,
+		Attachments: attachments,
+	})
 }
 
 // Token management
@@ -283,8 +341,8 @@ type tokenResponse struct {
 }
 
 func (p *Platform) refreshToken() error {
-	url := fmt.Sprintf("%s?corpid=%s&corpsecret=%s", tokenURL, p.corpID, p.secret)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("%s/gettoken?corpid=%s&corpsecret=%s", p.baseURL, p.corpID, p.secret)
+	resp, err := p.client.Get(url)
 	if err != nil {
 		return fmt.Errorf("failed to request token: %w", err)
 	}
@@ -327,13 +385,13 @@ func (p *Platform) getToken() (string, error) {
 	return token, nil
 }
 
-func (p *Platform) tokenRefreshLoop() {
+func (p *Platform) tokenRefreshLoop(ctx context.Context) {
 	ticker := time.NewTicker(30 * time.Minute)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-p.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			if err := p.refreshToken(); err != nil {
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"context"
+	"strings"
+)
+
+// Attachment is a file sent to or received from a platform
+type Attachment struct {
+	Name     string // File name, e.g. "report.pdf"
+	MimeType string // Content type if known
+	Data     []byte // File content; may be empty if URL is set
+	URL      string // Download link when the platform provides one instead of data
+}
+
+// Button is an interactive choice shown with a message. When clicked, the
+// platform delivers a Message whose Text is Value and whose Metadata has
+// "button" set to "true".
+type Button struct {
+	Label string
+	Value string
+	Style string // "primary", "danger" or "" for the platform default
+}
+
+// MessageEditor is implemented by platforms that can change or remove sent messages
+type MessageEditor interface {
+	Edit(ctx context.Context, channelID, messageID string, resp Response) error
+	Delete(ctx context.Context, channelID, messageID string) error
+}
+
+// Reactor is implemented by platforms that support emoji reactions.
+// Emoji names use the platform's own vocabulary.
+type Reactor interface {
+	React(ctx context.Context, channelID, messageID, emoji string) error
+	Unreact(ctx context.Context, channelID, messageID, emoji string) error
+}
+
+// FileSender is implemented by platforms that can upload files.
+// resp.Text, if set, is sent as the file's caption or comment.
+type FileSender interface {
+	SendFile(ctx context.Context, channelID string, file Attachment, resp Response) (string, error)
+}
+
+// InteractiveSender is implemented by platforms that can show buttons
+type InteractiveSender interface {
+	SendInteractive(ctx context.Context, channelID string, resp Response, buttons []Button) (string, error)
+}
+
+// HistoryFetcher is implemented by platforms that can read recent channel messages.
+// Messages are returned oldest first.
+type HistoryFetcher interface {
+	FetchHistory(ctx context.Context, channelID string, limit int) ([]Message, error)
+}
+
+// Capabilities lists the optional features a platform supports
+type Capabilities struct {
+	Edit     bool // MessageEditor
+	React    bool // Reactor
+	Files    bool // FileSender
+	Buttons  bool // InteractiveSender
+	History  bool // HistoryFetcher
+	Presence bool // Presence
+	Limits   MessageLimits
+}
+
+// CapabilitiesOf reports which optional interfaces a platform implements
+func CapabilitiesOf(platform Platform) Capabilities {
+	var caps Capabilities
+	_, caps.Edit = platform.(MessageEditor)
+	_, caps.React = platform.(Reactor)
+	_, caps.Files = platform.(FileSender)
+	_, caps.Buttons = platform.(InteractiveSender)
+	_, caps.History = platform.(HistoryFetcher)
+	_, caps.Presence = platform.(Presence)
+	if limited, ok := platform.(LimitedPlatform); ok {
+		caps.Limits = limited.MessageLimits()
+	}
+	return caps
+}
+
+// Names returns the supported capabilities as short names, e.g. "edit", "files"
+func (c Capabilities) Names() []string {
+	var names []string
+	for _, feature := range []struct {
+		ok   bool
+		name string
+	}{
+		{c.Edit, "edit"},
+		{c.React, "react"},
+		{c.Files, "files"},
+		{c.Buttons, "buttons"},
+		{c.History, "history"},
+		{c.Presence, "presence"},
+	} {
+		if feature.ok {
+			names = append(names, feature.name)
+		}
+	}
+	return names
+}
+
+// String returns the capability names joined with commas
+func (c Capabilities) String() string {
+	return strings.Join(c.Names(), ",")
+}
@@ -0,0 +1,296 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// LengthUnit defines how a platform counts message length
+type LengthUnit int
+
+const (
+	// UnitRunes counts Unicode code points
+	UnitRunes LengthUnit = iota
+	// UnitBytes counts UTF-8 bytes
+	UnitBytes
+	// UnitUTF16 counts UTF-16 code units (Telegram)
+	UnitUTF16
+)
+
+// MessageLimits describes the maximum size of a single outgoing message
+type MessageLimits struct {
+	MaxLength int        // Maximum message length (0 = unlimited)
+	Unit      LengthUnit // How MaxLength is measured
+}
+
+// LimitedPlatform is implemented by platforms that cap message length.
+// The router splits longer responses and sends the parts in order.
+type LimitedPlatform interface {
+	MessageLimits() MessageLimits
+}
+
+// Measure returns the length of s in the limit's unit
+func (l MessageLimits) Measure(s string) int {
+	switch l.Unit {
+	case UnitBytes:
+		return len(s)
+	case UnitUTF16:
+		n := 0
+		for _, r := range s {
+			if r >= 0x10000 {
+				n += 2
+			} else {
+				n++
+			}
+		}
+		return n
+	default:
+		return utf8.RuneCountInString(s)
+	}
+}
+
+// chunkMarkerReserve is the space kept free for a " (12/34)" style marker
+const chunkMarkerReserve = 12
+
+// SplitMessage splits text into parts that fit within limits.
+// It prefers paragraph boundaries, never splits inside a multibyte character,
+// and closes/reopens fenced code blocks that have to be split.
+func SplitMessage(text string, limits MessageLimits) []string {
+	if limits.MaxLength <= 0 || limits.Measure(text) <= limits.MaxLength {
+		return []string{text}
+	}
+
+	var chunks []string
+	current := ""
+	for _, blk := range splitBlocks(text) {
+		for _, piece := range fitBlock(blk, limits) {
+			if current == "" {
+				current = piece
+				continue
+			}
+			if candidate := current + "\n\n" + piece; limits.Measure(candidate) <= limits.MaxLength {
+				current = candidate
+				continue
+			}
+			chunks = append(chunks, current)
+			current = piece
+		}
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// SplitMessageWithMarkers splits text like SplitMessage and appends "(1/3)" markers
+// when more than one part is produced.
+func SplitMessageWithMarkers(text string, limits MessageLimits) []string {
+	if limits.MaxLength <= 0 || limits.Measure(text) <= limits.MaxLength {
+		return []string{text}
+	}
+
+	reserved := limits
+	if reserved.MaxLength > chunkMarkerReserve*2 {
+		reserved.MaxLength -= chunkMarkerReserve
+	}
+
+	chunks := SplitMessage(text, reserved)
+	if len(chunks) > 1 {
+		for i := range chunks {
+			chunks[i] = fmt.Sprintf("%s\n(%d/%d)", chunks[i], i+1, len(chunks))
+		}
+	}
+	return chunks
+}
+
+// block is a paragraph or a fenced code block
+type block struct {
+	text  string
+	fence string // Opening fence line for code blocks, e.g. "```go"
+}
+
+// splitBlocks splits text into paragraphs and fenced code blocks
+func splitBlocks(text string) []block {
+	var blocks []block
+	var lines []string
+	fence := ""
+
+	flush := func() {
+		if len(lines) > 0 {
+			blocks = append(blocks, block{text: strings.Join(lines, "\n")})
+			lines = nil
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			lines = append(lines, line)
+			if strings.HasPrefix(trimmed, fenceMarker(fence)) && strings.Trim(trimmed, fenceMarker(fence)[:1]) == "" {
+				blocks = append(blocks, block{text: strings.Join(lines, "\n"), fence: fence})
+				lines = nil
+				fence = ""
+			}
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			flush()
+			fence = trimmed
+			lines = append(lines, line)
+			continue
+		}
+
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	// An unterminated code block is kept as a code block
+	if fence != "" && len(lines) > 0 {
+		blocks = append(blocks, block{text: strings.Join(lines, "\n"), fence: fence})
+		return blocks
+	}
+	flush()
+	return blocks
+}
+
+// fenceMarker returns the ``` or ~~~ run that opens a fence line
+func fenceMarker(fence string) string {
+	ch := fence[0]
+	n := 0
+	for n < len(fence) && fence[n] == ch {
+		n++
+	}
+	return fence[:n]
+}
+
+// fitBlock splits a single block into pieces that each fit within limits
+func fitBlock(blk block, limits MessageLimits) []string {
+	if limits.Measure(blk.text) <= limits.MaxLength {
+		return []string{blk.text}
+	}
+
+	if blk.fence == "" {
+		return packLines(strings.Split(blk.text, "\n"), limits.MaxLength, limits)
+	}
+
+	// Split code blocks by line, re-wrapping every piece in fences
+	open := blk.fence
+	close := fenceMarker(blk.fence)
+	lines := strings.Split(blk.text, "\n")[1:]
+	if n := len(lines); n > 0 && strings.TrimSpace(lines[n-1]) == close {
+		lines = lines[:n-1]
+	}
+
+	budget := limits.MaxLength - limits.Measure(open) - limits.Measure(close) - 2
+	if budget < 1 {
+		// Fences alone don't fit; fall back to splitting the raw text
+		return hardSplit(blk.text, limits.MaxLength, limits)
+	}
+
+	var pieces []string
+	for _, body := range packLines(lines, budget, limits) {
+		pieces = append(pieces, open+"\n"+body+"\n"+close)
+	}
+	return pieces
+}
+
+// packLines greedily joins lines with "\n" into pieces within budget,
+// splitting lines that are too long on their own
+func packLines(lines []string, budget int, limits MessageLimits) []string {
+	var pieces []string
+	current := ""
+	started := false
+
+	add := func(s string) {
+		if !started {
+			current, started = s, true
+			return
+		}
+		if limits.Measure(current)+1+limits.Measure(s) <= budget {
+			current += "\n" + s
+			return
+		}
+		pieces = append(pieces, current)
+		current = s
+	}
+
+	for _, line := range lines {
+		if limits.Measure(line) <= budget {
+			add(line)
+			continue
+		}
+		for _, part := range splitLine(line, budget, limits) {
+			add(part)
+		}
+	}
+	if started {
+		pieces = append(pieces, current)
+	}
+	return pieces
+}
+
+// splitLine splits a long line at spaces or CJK punctuation, falling back to hard splits
+func splitLine(line string, budget int, limits MessageLimits) []string {
+	var parts []string
+	current := ""
+
+	for _, word := range splitWords(line) {
+		if limits.Measure(current)+limits.Measure(word) <= budget {
+			current += word
+			continue
+		}
+		if current != "" {
+			parts = append(parts, strings.TrimRight(current, " "))
+			current = ""
+		}
+		if limits.Measure(word) <= budget {
+			current = word
+			continue
+		}
+		hard := hardSplit(word, budget, limits)
+		parts = append(parts, hard[:len(hard)-1]...)
+		current = hard[len(hard)-1]
+	}
+	if strings.TrimSpace(current) != "" {
+		parts = append(parts, strings.TrimRight(current, " "))
+	}
+	return parts
+}
+
+// splitWords cuts s after spaces and CJK sentence punctuation, keeping separators
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	for i, r := range s {
+		if r == ' ' || strings.ContainsRune("。！？；，、", r) {
+			end := i + utf8.RuneLen(r)
+			words = append(words, s[start:end])
+			start = end
+		}
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// hardSplit splits s into pieces within budget on rune boundaries
+func hardSplit(s string, budget int, limits MessageLimits) []string {
+	var parts []string
+	start, size := 0, 0
+	for i, r := range s {
+		n := limits.Measure(string(r))
+		if size+n > budget && i > start {
+			parts = append(parts, s[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,162 @@
+package router
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+)
+
+// Deduplicator suppresses redelivered events by remembering message IDs for a TTL.
+// Feishu, DingTalk and WeCom retry delivery when the bot acks slowly, so the same
+// message can arrive several times.
+type Deduplicator struct {
+	ttl   time.Duration
+	path  string               // Optional file to persist seen IDs across restarts
+	seen  map[string]time.Time // key -> expiry
+	dirty bool
+	mu    sync.Mutex
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewDeduplicator creates a deduplicator; if path is set, seen IDs are loaded from
+// and periodically saved to that file
+func NewDeduplicator(ttl time.Duration, path string) *Deduplicator {
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+
+	d := &Deduplicator{
+		ttl:  ttl,
+		path: path,
+		seen: make(map[string]time.Time),
+		done: make(chan struct{}),
+	}
+
+	if path != "" {
+		if err := d.load(); err != nil {
+			logger.Error("[Router] Failed to load dedup cache: %v", err)
+		}
+	}
+
+	go d.cleanup()
+
+	return d
+}
+
+// Seen records the message and reports whether it was already processed.
+// Messages without an ID are never treated as duplicates.
+func (d *Deduplicator) Seen(platform, messageID string) bool {
+	if messageID == "" {
+		return false
+	}
+
+	key := platform + ":" + messageID
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if expiry, ok := d.seen[key]; ok && now.Before(expiry) {
+		return true
+	}
+	d.seen[key] = now.Add(d.ttl)
+	d.dirty = true
+	return false
+}
+
+// Close stops the cleanup goroutine and saves the cache
+func (d *Deduplicator) Close() error {
+	d.once.Do(func() { close(d.done) })
+	return d.Save()
+}
+
+// Save writes seen IDs to the persistence file, if configured
+func (d *Deduplicator) Save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	d.mu.Lock()
+	if !d.dirty {
+		d.mu.Unlock()
+		return nil
+	}
+	entries := make(map[string]int64, len(d.seen))
+	for key, expiry := range d.seen {
+		entries[key] = expiry.Unix()
+	}
+	d.dirty = false
+	d.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(d.path), 0700); err != nil {
+		return err
+	}
+
+	// Write atomically so a crash never leaves a truncated file
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
+
+// load reads unexpired IDs from the persistence file
+func (d *Deduplicator) load() error {
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var entries map[string]int64
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for key, unix := range entries {
+		if expiry := time.Unix(unix, 0); now.Before(expiry) {
+			d.seen[key] = expiry
+		}
+	}
+	return nil
+}
+
+// cleanup periodically removes expired IDs and persists the cache
+func (d *Deduplicator) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		d.mu.Lock()
+		for key, expiry := range d.seen {
+			if now.After(expiry) {
+				delete(d.seen, key)
+				d.dirty = true
+			}
+		}
+		d.mu.Unlock()
+
+		if err := d.Save(); err != nil {
+			logger.Error("[Router] Failed to save dedup cache: %v", err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package router
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+)
+
+// Presence is implemented by platforms that can show the bot is working on a
+// message: a typing indicator, a reaction on the user's message or a placeholder.
+type Presence interface {
+	StartPresence(ctx context.Context, msg Message) (Indicator, error)
+}
+
+// Indicator is a running presence signal for one incoming message
+type Indicator interface {
+	// Update shows what the bot is currently doing, e.g. the tool being run.
+	// Platforms without a placeholder may ignore it.
+	Update(ctx context.Context, status string)
+	// Stop clears the indicator; the router calls it before sending the response
+	Stop(ctx context.Context)
+}
+
+// presenceDelay keeps quick replies (built-in commands, cached answers) from
+// flashing an indicator
+const presenceDelay = time.Second
+
+type progressKey struct{}
+
+// WithProgress returns a context whose ReportProgress calls go to fn
+func WithProgress(ctx context.Context, fn func(status string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress tells the platform what the handler is doing. It is a no-op if
+// the message's platform has no presence support.
+func ReportProgress(ctx context.Context, status string) {
+	if fn, ok := ctx.Value(progressKey{}).(func(string)); ok && fn != nil {
+		fn(status)
+	}
+}
+
+// presenceRun starts an indicator after presenceDelay and forwards progress to it
+type presenceRun struct {
+	ctx      context.Context
+	platform Presence
+	msg      Message
+	timer    *time.Timer
+	ind      Indicator
+	status   string // Latest progress, applied when the indicator starts
+	done     bool
+	mu       sync.Mutex
+}
+
+// startPresence schedules an indicator for msg if the platform supports one
+func startPresence(ctx context.Context, platform Platform, msg Message) *presenceRun {
+	presence, ok := platform.(Presence)
+	if !ok {
+		return nil
+	}
+
+	run := &presenceRun{ctx: ctx, platform: presence, msg: msg}
+	run.timer = time.AfterFunc(presenceDelay, run.start)
+	return run
+}
+
+func (p *presenceRun) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done {
+		return
+	}
+	ind, err := p.platform.StartPresence(p.ctx, p.msg)
+	if err != nil {
+		logger.Debug("[Router] Presence failed for %s/%s: %v", p.msg.Platform, p.msg.ChannelID, err)
+		return
+	}
+	p.ind = ind
+	if p.status != "" {
+		ind.Update(p.ctx, p.status)
+	}
+}
+
+// update records progress and shows it if the indicator is running
+func (p *presenceRun) update(status string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done {
+		return
+	}
+	p.status = status
+	if p.ind != nil {
+		p.ind.Update(p.ctx, status)
+	}
+}
+
+// stop cancels a pending indicator or clears a running one
+func (p *presenceRun) stop() {
+	if p == nil {
+		return
+	}
+
+	p.timer.Stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done {
+		return
+	}
+	p.done = true
+	if p.ind != nil {
+		// Clear even if the handler context was cancelled
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		p.ind.Stop(ctx)
+	}
+}
@@ -0,0 +1,289 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit defines a token bucket: Rate tokens per minute, up to Burst tokens
+type RateLimit struct {
+	Rate  float64 // Tokens refilled per minute (0 disables the limit)
+	Burst int     // Maximum tokens that can accumulate
+}
+
+// RateLimitConfig holds rate limiting configuration
+type RateLimitConfig struct {
+	User          RateLimit     // Per platform+user limit
+	Channel       RateLimit     // Per platform+channel limit
+	Platform      RateLimit     // Per platform limit
+	Window        time.Duration // Window for "slow down" notices and violation counting (default: 1m)
+	BlockAfter    int           // Violations within Window before a temporary block (0 disables blocking)
+	BlockDuration time.Duration // How long a temporary block lasts (default: 10m)
+	Admins        []string      // Users who may lift blocks ("platform:user_id" or "user_id")
+}
+
+// Decision is the outcome of a rate limit check
+type Decision int
+
+const (
+	// Allowed means the message may be processed
+	Allowed Decision = iota
+	// Limited means the message exceeded a rate limit
+	Limited
+	// Blocked means the sender is temporarily blocked
+	Blocked
+)
+
+// bucket is a single token bucket
+type bucket struct {
+	tokens   float64
+	lastFill time.Time
+}
+
+// offender tracks rate limit violations for a user
+type offender struct {
+	violations   []time.Time
+	blockedUntil time.Time
+	lastNotice   time.Time
+}
+
+// RateLimiter enforces per-user, per-channel and per-platform token buckets
+type RateLimiter struct {
+	cfg       RateLimitConfig
+	buckets   map[string]*bucket
+	offenders map[string]*offender
+	lastPrune time.Time
+	mu        sync.Mutex
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a new RateLimiter
+func NewRateLimiter(cfg RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		cfg:       cfg.withDefaults(),
+		buckets:   make(map[string]*bucket),
+		offenders: make(map[string]*offender),
+		now:       time.Now,
+	}
+}
+
+func (c RateLimitConfig) withDefaults() RateLimitConfig {
+	if c.Window <= 0 {
+		c.Window = time.Minute
+	}
+	if c.BlockDuration <= 0 {
+		c.BlockDuration = 10 * time.Minute
+	}
+	return c
+}
+
+// SetConfig changes the limits and admins. Bucket levels and current blocks
+// are kept; buckets above a lowered burst are capped on their next refill.
+func (l *RateLimiter) SetConfig(cfg RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg.withDefaults()
+}
+
+// Check consumes a token for the message and reports whether it may be processed.
+// notify is true when the sender should be told about the limit; it is set at
+// most once per window so that flooding users don't get a reply per message.
+func (l *RateLimiter) Check(msg Message) (decision Decision, notify bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	userKey := userRateKey(msg.Platform, msg.UserID)
+	off := l.offenders[userKey]
+
+	// Blocked users are rejected without touching the buckets
+	if off != nil && now.Before(off.blockedUntil) {
+		return Blocked, l.shouldNotify(off, now)
+	}
+
+	keys := []struct {
+		key   string
+		limit RateLimit
+	}{
+		{userKey, l.cfg.User},
+		{"channel:" + msg.Platform + ":" + msg.ChannelID, l.cfg.Channel},
+		{"platform:" + msg.Platform, l.cfg.Platform},
+	}
+
+	// Only consume tokens when every bucket has one available
+	allowed, userEmpty := true, false
+	for _, k := range keys {
+		if k.limit.Rate <= 0 {
+			continue
+		}
+		if l.fill(k.key, k.limit, now).tokens < 1 {
+			allowed = false
+			userEmpty = userEmpty || k.key == userKey
+		}
+	}
+	if allowed {
+		for _, k := range keys {
+			if k.limit.Rate > 0 {
+				l.buckets[k.key].tokens--
+			}
+		}
+		return Allowed, false
+	}
+
+	// A busy channel or platform isn't the sender's fault: only an empty
+	// bucket of their own counts towards a block
+	if !userEmpty {
+		return Limited, false
+	}
+
+	// Record the violation against the sender
+	if off == nil {
+		off = &offender{}
+		l.offenders[userKey] = off
+	}
+	recent := off.violations[:0]
+	for _, t := range off.violations {
+		if now.Sub(t) < l.cfg.Window {
+			recent = append(recent, t)
+		}
+	}
+	off.violations = append(recent, now)
+
+	if l.cfg.BlockAfter > 0 && len(off.violations) >= l.cfg.BlockAfter {
+		off.blockedUntil = now.Add(l.cfg.BlockDuration)
+		off.violations = nil
+		off.lastNotice = time.Time{} // Always tell the user they were blocked
+		return Blocked, l.shouldNotify(off, now)
+	}
+
+	return Limited, l.shouldNotify(off, now)
+}
+
+// fill refills a bucket based on elapsed time and returns it
+func (l *RateLimiter) fill(key string, limit RateLimit, now time.Time) *bucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, lastFill: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	elapsed := now.Sub(b.lastFill).Minutes()
+	b.tokens += elapsed * limit.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.lastFill = now
+	return b
+}
+
+// shouldNotify reports whether a notice is due and records it
+func (l *RateLimiter) shouldNotify(off *offender, now time.Time) bool {
+	if now.Sub(off.lastNotice) < l.cfg.Window {
+		return false
+	}
+	off.lastNotice = now
+	return true
+}
+
+// prune drops idle buckets and expired offenders
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < 5*time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastFill) > 30*time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+	for key, off := range l.offenders {
+		if now.After(off.blockedUntil) && now.Sub(off.lastNotice) > l.cfg.Window && len(off.violations) == 0 {
+			delete(l.offenders, key)
+		}
+	}
+}
+
+// BlockedUntil returns when the user's block expires, or zero if not blocked
+func (l *RateLimiter) BlockedUntil(platform, userID string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	off, ok := l.offenders[userRateKey(platform, userID)]
+	if !ok || l.now().After(off.blockedUntil) {
+		return time.Time{}
+	}
+	return off.blockedUntil
+}
+
+// Unblock lifts a temporary block; returns false if the user wasn't blocked
+func (l *RateLimiter) Unblock(platform, userID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := userRateKey(platform, userID)
+	off, ok := l.offenders[key]
+	if !ok {
+		return false
+	}
+	wasBlocked := l.now().Before(off.blockedUntil)
+	delete(l.offenders, key)
+	return wasBlocked
+}
+
+// Blocked lists currently blocked users as "platform:user_id"
+func (l *RateLimiter) Blocked() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var users []string
+	for key, off := range l.offenders {
+		if now.Before(off.blockedUntil) {
+			users = append(users, strings.TrimPrefix(key, "user:"))
+		}
+	}
+	return users
+}
+
+// IsAdmin checks whether the message sender may manage rate limits
+func (l *RateLimiter) IsAdmin(msg Message) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, admin := range l.cfg.Admins {
+		if admin == msg.UserID || admin == msg.Platform+":"+msg.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockDuration returns the configured block duration
+func (l *RateLimiter) BlockDuration() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg.BlockDuration
+}
+
+func userRateKey(platform, userID string) string {
+	return "user:" + platform + ":" + userID
+}
+
+// rateLimitNotice returns the reply sent to a rate limited user
+func rateLimitNotice(decision Decision, until time.Time) string {
+	if decision == Blocked {
+		return fmt.Sprintf("消息过于频繁，已被临时限制至 %s。如有需要请联系管理员解除。", until.Format("15:04"))
+	}
+	return "消息太频繁了，请稍后再试。"
+}
@@ -2,6 +2,9 @@ package router
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/pltanton/lingti-bot/internal/logger"
@@ -9,14 +12,15 @@ import (
 
 // Message represents an incoming message from any platform
 type Message struct {
-	ID        string
-	Platform  string            // "slack", "telegram", "discord", etc.
-	ChannelID string            // Channel/Chat ID
-	UserID    string            // User who sent the message
-	Username  string            // Human-readable username
-	Text      string            // Message content
-	ThreadID  string            // For threaded replies
-	Metadata  map[string]string // Platform-specific metadata
+	ID          string
+	Platform    string            // "slack", "telegram", "discord", etc.
+	ChannelID   string            // Channel/Chat ID
+	UserID      string            // User who sent the message
+	Username    string            // Human-readable username
+	Text        string            // Message content
+	ThreadID    string            // For threaded replies
+	Metadata    map[string]string // Platform-specific metadata
+	Attachments []Attachment      // Files, images or audio sent with the message
 }
 
 // Response represents a response to send back
@@ -31,7 +35,7 @@ type Platform interface {
 	Name() string
 	Start(ctx context.Context) error
 	Stop() error
-	Send(ctx context.Context, channelID string, resp Response) error
+	Send(ctx context.Context, channelID string, resp Response) (string, error) // Returns the sent message ID
 	SetMessageHandler(handler func(msg Message))
 }
 
@@ -40,80 +44,383 @@ type MessageHandler func(ctx context.Context, msg Message) (Response, error)
 
 // Router manages multiple messaging platforms
 type Router struct {
-	platforms map[string]Platform
-	handler   MessageHandler
-	mu        sync.RWMutex
-	ctx       context.Context
-	cancel    context.CancelFunc
+	platforms   map[string]Platform
+	handler     MessageHandler
+	limiter     *RateLimiter
+	dedup       *Deduplicator
+	markers     bool // Append "(1/3)" markers to split messages
+	supervisor  SupervisorConfig
+	supervised  map[string]*supervised // Platforms whose supervisor is running
+	supervisors sync.WaitGroup
+	status      map[string]*PlatformStatus
+	statusMu    sync.Mutex
+	mu          sync.RWMutex
+	ctx         context.Context
+	cancel      context.CancelFunc
+
+	// Graceful shutdown: handlers run under handlerCtx, which Shutdown cancels
+	// once its deadline passes
+	handlerCtx    context.Context
+	handlerCancel context.CancelFunc
+	inflight      map[*inflight]struct{}
+	inflightWG    sync.WaitGroup
+	draining      bool
+	drainMu       sync.Mutex
 }
 
 // New creates a new Router
 func New(handler MessageHandler) *Router {
+	handlerCtx, handlerCancel := context.WithCancel(context.Background())
 	return &Router{
-		platforms: make(map[string]Platform),
-		handler:   handler,
+		platforms:     make(map[string]Platform),
+		supervised:    make(map[string]*supervised),
+		handler:       handler,
+		status:        make(map[string]*PlatformStatus),
+		handlerCtx:    handlerCtx,
+		handlerCancel: handlerCancel,
+		inflight:      make(map[*inflight]struct{}),
 	}
 }
 
-// Register adds a platform to the router
+// Register adds a platform to the router. If the router is already running,
+// the platform is started right away, replacing one registered under the same name.
 func (r *Router) Register(platform Platform) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
 	name := platform.Name()
+
+	r.mu.RLock()
+	_, exists := r.platforms[name]
+	r.mu.RUnlock()
+	if exists {
+		if err := r.Unregister(name); err != nil {
+			logger.Error("[Router] Error stopping replaced platform %s: %v", name, err)
+		}
+	}
+
+	r.mu.Lock()
 	r.platforms[name] = platform
+	running := r.ctx != nil && r.ctx.Err() == nil
 
-	// Set up message handling for this platform
+	// Set up message handling for this platform. Duplicates are dropped
+	// synchronously; processing happens in the background so adapters can ack at once.
 	platform.SetMessageHandler(func(msg Message) {
-		go r.handleMessage(msg)
+		r.touch(name)
+		if r.isDuplicate(msg) {
+			logger.Info("[Router] Dropping redelivered message %s/%s", msg.Platform, msg.ID)
+			return
+		}
+		entry, ok := r.begin(msg)
+		if !ok {
+			// Shutting down; ask the user to retry once we're back
+			go r.reply(context.Background(), msg, Response{Text: restartNotice})
+			return
+		}
+		go r.handleMessage(entry)
 	})
 
+	r.mu.Unlock()
+
+	r.setState(name, StateStopped, nil)
+
 	logger.Info("[Router] Registered platform: %s", name)
+
+	if running {
+		r.startSupervisor(name, platform)
+	}
+}
+
+// Unregister stops a platform and removes it from the router. Replies to
+// messages it delivered go through a platform registered under the same name
+// later, if any.
+func (r *Router) Unregister(name string) error {
+	r.mu.Lock()
+	platform, ok := r.platforms[name]
+	sup := r.supervised[name]
+	delete(r.platforms, name)
+	delete(r.supervised, name)
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownPlatform, name)
+	}
+
+	if sup != nil {
+		sup.cancel()
+		<-sup.done
+	}
+	err := platform.Stop()
+
+	r.statusMu.Lock()
+	delete(r.status, name)
+	r.statusMu.Unlock()
+
+	logger.Info("[Router] Unregistered platform: %s", name)
+	return err
+}
+
+// SetSupervisor configures how failed platforms are restarted
+func (r *Router) SetSupervisor(cfg SupervisorConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.supervisor = cfg
+}
+
+// SetRateLimiter enables rate limiting of incoming messages
+func (r *Router) SetRateLimiter(limiter *RateLimiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limiter = limiter
+}
+
+// SetDeduplicator enables suppression of redelivered messages
+func (r *Router) SetDeduplicator(dedup *Deduplicator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dedup = dedup
+}
+
+// isDuplicate reports whether the message was already received
+func (r *Router) isDuplicate(msg Message) bool {
+	r.mu.RLock()
+	dedup := r.dedup
+	r.mu.RUnlock()
+
+	return dedup != nil && dedup.Seen(msg.Platform, msg.ID)
+}
+
+// SetChunkMarkers enables "(1/3)" markers on responses split to fit platform limits
+func (r *Router) SetChunkMarkers(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.markers = enabled
 }
 
 // handleMessage processes an incoming message
-func (r *Router) handleMessage(msg Message) {
-	ctx := context.Background()
+func (r *Router) handleMessage(entry *inflight) {
+	defer r.end(entry)
+
+	ctx := r.handlerCtx
+	msg := entry.msg
 
 	logger.Info("[Router] Message from %s/%s: %s", msg.Platform, msg.Username, msg.Text)
 
+	if r.checkRateLimit(ctx, msg) {
+		return
+	}
+
+	// Show a typing indicator or placeholder while the handler runs
+	r.mu.RLock()
+	platform := r.platforms[msg.Platform]
+	r.mu.RUnlock()
+
+	var presence *presenceRun
+	if platform != nil {
+		msg = withCapabilities(msg, CapabilitiesOf(platform))
+		presence = startPresence(ctx, platform, msg)
+		ctx = WithProgress(ctx, presence.update)
+	}
+	defer presence.stop()
+
 	// Call the message handler
 	resp, err := r.handler(ctx, msg)
+	presence.stop()
+	if r.wasCancelled(entry) {
+		// Shutdown already sent a restart notice for this message
+		return
+	}
 	if err != nil {
 		logger.Error("[Router] Error handling message: %v", err)
 		resp = Response{Text: "Sorry, I encountered an error processing your request."}
 	}
 
 	// Send response back to the platform
+	if resp.Text != "" {
+		r.reply(ctx, msg, resp)
+	}
+}
+
+// checkRateLimit applies rate limits and admin commands; returns true if the message was consumed
+func (r *Router) checkRateLimit(ctx context.Context, msg Message) bool {
 	r.mu.RLock()
-	platform, ok := r.platforms[msg.Platform]
+	limiter := r.limiter
 	r.mu.RUnlock()
 
-	if ok && resp.Text != "" {
-		if msg.ThreadID != "" {
-			resp.ThreadID = msg.ThreadID
+	if limiter == nil {
+		return false
+	}
+
+	// Admins are never limited and can manage blocks
+	if limiter.IsAdmin(msg) {
+		if text, ok := r.handleRateLimitCommand(limiter, msg); ok {
+			r.reply(ctx, msg, Response{Text: text})
+			return true
 		}
-		if err := platform.Send(ctx, msg.ChannelID, resp); err != nil {
-			logger.Error("[Router] Error sending response: %v", err)
+		return false
+	}
+
+	decision, notify := limiter.Check(msg)
+	if decision == Allowed {
+		return false
+	}
+
+	logger.Info("[Router] Rate limited %s/%s (decision: %d)", msg.Platform, msg.UserID, decision)
+	if notify {
+		until := limiter.BlockedUntil(msg.Platform, msg.UserID)
+		r.reply(ctx, msg, Response{Text: rateLimitNotice(decision, until)})
+	}
+	return true
+}
+
+// handleRateLimitCommand handles /blocked and /unblock for admins
+func (r *Router) handleRateLimitCommand(limiter *RateLimiter, msg Message) (string, bool) {
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	switch fields[0] {
+	case "/blocked":
+		users := limiter.Blocked()
+		if len(users) == 0 {
+			return "当前没有被限制的用户。", true
+		}
+		return "被临时限制的用户:\n- " + strings.Join(users, "\n- "), true
+
+	case "/unblock":
+		if len(fields) < 2 {
+			return "用法: /unblock <platform:user_id> 或 /unblock <user_id>", true
+		}
+		platform, userID := msg.Platform, fields[1]
+		if p, u, ok := strings.Cut(fields[1], ":"); ok {
+			platform, userID = p, u
 		}
+		if !limiter.Unblock(platform, userID) {
+			return fmt.Sprintf("用户 %s:%s 未被限制。", platform, userID), true
+		}
+		logger.Info("[Router] %s/%s lifted block on %s:%s", msg.Platform, msg.UserID, platform, userID)
+		return fmt.Sprintf("已解除 %s:%s 的限制。", platform, userID), true
 	}
+
+	return "", false
 }
 
-// Start begins listening on all registered platforms
-func (r *Router) Start(ctx context.Context) error {
-	r.ctx, r.cancel = context.WithCancel(ctx)
+// reply sends a response to the channel a message came from
+func (r *Router) reply(ctx context.Context, msg Message, resp Response) {
+	r.mu.RLock()
+	platform, ok := r.platforms[msg.Platform]
+	r.mu.RUnlock()
+
+	if !ok {
+		return
+	}
+	if msg.ThreadID != "" {
+		resp.ThreadID = msg.ThreadID
+	}
+	if _, err := r.send(ctx, platform, msg.ChannelID, resp); err != nil {
+		logger.Error("[Router] Error sending response: %v", err)
+	}
+}
 
+// ErrUnknownPlatform is returned by SendTo for a platform that isn't registered
+var ErrUnknownPlatform = errors.New("unknown platform")
+
+// SendTo sends a message to a channel on the bot's own initiative, without an
+// inbound message to reply to. Long responses are split like replies. It returns
+// the ID of the last message sent, if the platform reports one.
+func (r *Router) SendTo(ctx context.Context, platformName, channelID string, resp Response) (string, error) {
 	r.mu.RLock()
-	defer r.mu.RUnlock()
+	platform, ok := r.platforms[platformName]
+	r.mu.RUnlock()
 
-	for name, platform := range r.platforms {
-		logger.Info("[Router] Starting platform: %s", name)
-		if err := platform.Start(r.ctx); err != nil {
-			return err
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPlatform, platformName)
+	}
+	if channelID == "" {
+		return "", fmt.Errorf("channel is required")
+	}
+
+	logger.Info("[Router] Outbound message to %s/%s", platformName, channelID)
+	return r.send(ctx, platform, channelID, resp)
+}
+
+// withCapabilities returns msg with the platform's capabilities in
+// Metadata["capabilities"], so handlers know what the platform supports
+func withCapabilities(msg Message, caps Capabilities) Message {
+	metadata := make(map[string]string, len(msg.Metadata)+1)
+	for k, v := range msg.Metadata {
+		metadata[k] = v
+	}
+	metadata["capabilities"] = caps.String()
+	msg.Metadata = metadata
+	return msg
+}
+
+// send delivers a response, splitting it into parts if the platform limits message
+// length. It returns the ID of the last message sent.
+func (r *Router) send(ctx context.Context, platform Platform, channelID string, resp Response) (string, error) {
+	limited, ok := platform.(LimitedPlatform)
+	if !ok {
+		return platform.Send(ctx, channelID, resp)
+	}
+
+	r.mu.RLock()
+	markers := r.markers
+	r.mu.RUnlock()
+
+	var parts []string
+	if markers {
+		parts = SplitMessageWithMarkers(resp.Text, limited.MessageLimits())
+	} else {
+		parts = SplitMessage(resp.Text, limited.MessageLimits())
+	}
+
+	if len(parts) > 1 {
+		logger.Debug("[Router] Splitting response for %s into %d parts", platform.Name(), len(parts))
+	}
+	var messageID string
+	for i, part := range parts {
+		chunk := resp
+		chunk.Text = part
+		id, err := platform.Send(ctx, channelID, chunk)
+		if err != nil {
+			return messageID, fmt.Errorf("part %d/%d: %w", i+1, len(parts), err)
 		}
+		messageID = id
+	}
+	return messageID, nil
+}
+
+// Start begins listening on all registered platforms. Each platform is supervised
+// independently: one that fails to start is retried in the background with backoff
+// while the others keep running. Start returns once every platform has made its
+// first start attempt.
+func (r *Router) Start(ctx context.Context) error {
+	r.mu.Lock()
+	r.ctx, r.cancel = context.WithCancel(ctx)
+	platforms := make(map[string]Platform, len(r.platforms))
+	for name, platform := range r.platforms {
+		platforms[name] = platform
+	}
+	r.mu.Unlock()
+
+	if len(platforms) == 0 {
+		return fmt.Errorf("no platforms registered")
 	}
 
-	logger.Info("[Router] All platforms started")
+	var pending []<-chan struct{}
+	for name, platform := range platforms {
+		pending = append(pending, r.startSupervisor(name, platform))
+	}
+	for _, started := range pending {
+		<-started
+	}
+
+	running := 0
+	for _, s := range r.Status() {
+		if s.State == StateRunning {
+			running++
+		}
+	}
+	logger.Info("[Router] %d/%d platforms started", running, len(platforms))
 	return nil
 }
 
@@ -122,6 +429,8 @@ func (r *Router) Stop() error {
 	if r.cancel != nil {
 		r.cancel()
 	}
+	// A supervisor may be in the middle of starting or restarting a platform
+	r.supervisors.Wait()
 
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -131,6 +440,13 @@ func (r *Router) Stop() error {
 		if err := platform.Stop(); err != nil {
 			logger.Error("[Router] Error stopping %s: %v", name, err)
 		}
+		r.setState(name, StateStopped, nil)
+	}
+
+	if r.dedup != nil {
+		if err := r.dedup.Close(); err != nil {
+			logger.Error("[Router] Error saving dedup cache: %v", err)
+		}
 	}
 
 	return nil
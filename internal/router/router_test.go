@@ -0,0 +1,74 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakePlatform records sent responses and lets tests inject messages
+type fakePlatform struct {
+	name    string
+	handler func(msg Message)
+	sent    chan Response
+}
+
+func newFakePlatform(name string) *fakePlatform {
+	return &fakePlatform{name: name, sent: make(chan Response, 10)}
+}
+
+func (p *fakePlatform) Name() string                      { return p.name }
+func (p *fakePlatform) Start(ctx context.Context) error   { return nil }
+func (p *fakePlatform) Stop() error                       { return nil }
+func (p *fakePlatform) SetMessageHandler(h func(Message)) { p.handler = h }
+
+func (p *fakePlatform) Send(ctx context.Context, channelID string, resp Response) (string, error) {
+	p.sent <- resp
+	return "sent", nil
+}
+
+// editingPlatform adds MessageEditor to fakePlatform
+type editingPlatform struct {
+	*fakePlatform
+}
+
+func (p editingPlatform) Edit(ctx context.Context, channelID, messageID string, resp Response) error {
+	return nil
+}
+
+func (p editingPlatform) Delete(ctx context.Context, channelID, messageID string) error {
+	return nil
+}
+
+func TestHandlerReceivesCapabilities(t *testing.T) {
+	received := make(chan Message, 1)
+	r := New(func(ctx context.Context, msg Message) (Response, error) {
+		received <- msg
+		return Response{}, nil
+	})
+
+	p := editingPlatform{newFakePlatform("fake")}
+	r.Register(p)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	metadata := map[string]string{"chat_type": "dm"}
+	p.handler(Message{ID: "1", Platform: "fake", ChannelID: "c", Text: "hi", Metadata: metadata})
+
+	select {
+	case msg := <-received:
+		if got := msg.Metadata["capabilities"]; got != "edit" {
+			t.Errorf("capabilities = %q, want %q", got, "edit")
+		}
+		if msg.Metadata["chat_type"] != "dm" {
+			t.Errorf("platform metadata lost: %v", msg.Metadata)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not called")
+	}
+	if _, ok := metadata["capabilities"]; ok {
+		t.Error("the platform's metadata map was modified")
+	}
+}
@@ -0,0 +1,103 @@
+package router
+
+import (
+	"context"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+)
+
+// restartNotice is sent to conversations whose request was cut off by a shutdown
+const restartNotice = "⚠️ 服务正在重启，本次请求未能完成，请稍后重试。"
+
+// noticeTimeout bounds each restart notice sent during shutdown
+const noticeTimeout = 5 * time.Second
+
+// inflight is a message whose handler is still running
+type inflight struct {
+	msg       Message
+	cancelled bool // Set when shutdown gave up waiting and notified the user
+}
+
+// begin registers a message as in flight; it returns false if the router is draining
+func (r *Router) begin(msg Message) (*inflight, bool) {
+	r.drainMu.Lock()
+	defer r.drainMu.Unlock()
+
+	if r.draining {
+		return nil, false
+	}
+	r.inflightWG.Add(1)
+	entry := &inflight{msg: msg}
+	r.inflight[entry] = struct{}{}
+	return entry, true
+}
+
+// end marks an in-flight message as finished
+func (r *Router) end(entry *inflight) {
+	r.drainMu.Lock()
+	delete(r.inflight, entry)
+	r.drainMu.Unlock()
+	r.inflightWG.Done()
+}
+
+// wasCancelled reports whether shutdown already told the user to retry
+func (r *Router) wasCancelled(entry *inflight) bool {
+	r.drainMu.Lock()
+	defer r.drainMu.Unlock()
+	return entry.cancelled
+}
+
+// Shutdown stops accepting new messages, waits for in-flight handlers until ctx
+// expires, tells the users of any handlers still running to retry, and then stops
+// all platforms and flushes persistent state.
+func (r *Router) Shutdown(ctx context.Context) error {
+	r.drainMu.Lock()
+	r.draining = true
+	pending := len(r.inflight)
+	r.drainMu.Unlock()
+
+	if pending > 0 {
+		logger.Info("[Router] Waiting for %d in-flight message(s)", pending)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.inflightWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		r.cutOff()
+
+		// Give cancelled handlers a moment to unwind before platforms go away
+		select {
+		case <-done:
+		case <-time.After(noticeTimeout):
+		}
+	}
+
+	return r.Stop()
+}
+
+// cutOff cancels the remaining handlers and sends each conversation a restart notice
+func (r *Router) cutOff() {
+	r.drainMu.Lock()
+	var cut []Message
+	for entry := range r.inflight {
+		entry.cancelled = true
+		cut = append(cut, entry.msg)
+	}
+	r.drainMu.Unlock()
+
+	r.handlerCancel()
+	logger.Info("[Router] Shutdown deadline reached, cutting off %d message(s)", len(cut))
+
+	for _, msg := range cut {
+		ctx, cancel := context.WithTimeout(context.Background(), noticeTimeout)
+		r.reply(ctx, msg, Response{Text: restartNotice})
+		cancel()
+	}
+}
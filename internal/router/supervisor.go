@@ -0,0 +1,286 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/logger"
+)
+
+// PlatformState describes where a platform is in its lifecycle
+type PlatformState string
+
+const (
+	StateStarting PlatformState = "starting" // Start in progress
+	StateRunning  PlatformState = "running"  // Connected and receiving messages
+	StateRetrying PlatformState = "retrying" // Failed; waiting to be restarted
+	StateFailed   PlatformState = "failed"   // Gave up after too many failures
+	StateStopped  PlatformState = "stopped"  // Shut down by the router
+)
+
+// HealthChecker is implemented by platforms that can detect a lost connection.
+// The router restarts a platform whose Healthy check returns an error.
+type HealthChecker interface {
+	Healthy() error
+}
+
+// SupervisorConfig controls how failed platforms are restarted
+type SupervisorConfig struct {
+	RetryMin       time.Duration // First restart delay after a failure
+	RetryMax       time.Duration // Cap for the exponential restart delay
+	MaxAttempts    int           // Consecutive failures before giving up (0 = never)
+	HealthInterval time.Duration // How often HealthChecker platforms are checked
+}
+
+// PlatformStatus is a snapshot of a platform's lifecycle state
+type PlatformStatus struct {
+	Name        string
+	State       PlatformState
+	Since       time.Time // When the platform entered State
+	LastError   string
+	LastErrorAt time.Time
+	LastMessage time.Time // When the last incoming message arrived
+	Restarts    int       // Restarts after the first successful start
+	NextRetry   time.Time // Set while retrying
+}
+
+func (c SupervisorConfig) withDefaults() SupervisorConfig {
+	if c.RetryMin <= 0 {
+		c.RetryMin = 5 * time.Second
+	}
+	if c.RetryMax < c.RetryMin {
+		c.RetryMax = 5 * time.Minute
+	}
+	if c.HealthInterval <= 0 {
+		c.HealthInterval = 30 * time.Second
+	}
+	return c
+}
+
+// supervised tracks a running supervisor so it can be stopped on its own
+type supervised struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startSupervisor supervises a platform until it is unregistered or the
+// router stops. The returned channel is closed after the first start attempt.
+func (r *Router) startSupervisor(name string, platform Platform) <-chan struct{} {
+	r.mu.Lock()
+	ctx, cancel := context.WithCancel(r.ctx)
+	sup := &supervised{cancel: cancel, done: make(chan struct{})}
+	r.supervised[name] = sup
+	r.mu.Unlock()
+
+	started := make(chan struct{})
+	r.supervisors.Add(1)
+	go func() {
+		defer r.supervisors.Done()
+		defer close(sup.done)
+		r.supervise(ctx, name, platform, started)
+	}()
+	return started
+}
+
+// supervise starts a platform and keeps it running until ctx is cancelled.
+// started is closed after the first start attempt, whether it succeeded or not.
+func (r *Router) supervise(ctx context.Context, name string, platform Platform, started chan<- struct{}) {
+	cfg := r.supervisorConfig()
+	delay := cfg.RetryMin
+	failures := 0
+	first := true
+	ran := false // Whether the platform has been running at least once
+
+	for {
+		r.setState(name, StateStarting, nil)
+		logger.Info("[Router] Starting platform: %s", name)
+
+		err := platform.Start(ctx)
+		if err == nil {
+			r.setState(name, StateRunning, nil)
+			if ran {
+				r.updateStatus(name, func(s *PlatformStatus) { s.Restarts++ })
+			}
+			if !first {
+				logger.Info("[Router] Platform %s recovered", name)
+			}
+			ran = true
+			failures, delay = 0, cfg.RetryMin
+		}
+		if first {
+			close(started)
+			first = false
+		}
+
+		if err == nil {
+			// Block until shutdown or until the connection is found to be dead
+			err = r.watch(ctx, platform, cfg.HealthInterval)
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Error("[Router] Platform %s is unhealthy: %v", name, err)
+			if stopErr := platform.Stop(); stopErr != nil {
+				logger.Error("[Router] Error stopping %s: %v", name, stopErr)
+			}
+		} else {
+			if ctx.Err() == nil {
+				logger.Error("[Router] Failed to start %s: %v", name, err)
+			}
+			// Release whatever a partial start left behind before trying again
+			if stopErr := platform.Stop(); stopErr != nil {
+				logger.Error("[Router] Error stopping %s: %v", name, stopErr)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		failures++
+		if cfg.MaxAttempts > 0 && failures >= cfg.MaxAttempts {
+			r.setState(name, StateFailed, err)
+			logger.Error("[Router] Giving up on %s after %d attempts", name, failures)
+			return
+		}
+
+		r.setState(name, StateRetrying, err)
+		r.updateStatus(name, func(s *PlatformStatus) { s.NextRetry = time.Now().Add(delay) })
+		logger.Info("[Router] Restarting %s in %s", name, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > cfg.RetryMax {
+			delay = cfg.RetryMax
+		}
+	}
+}
+
+// watch blocks until ctx is cancelled or the platform reports it is unhealthy
+func (r *Router) watch(ctx context.Context, platform Platform, interval time.Duration) error {
+	checker, ok := platform.(HealthChecker)
+	if !ok {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := checker.Healthy(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *Router) supervisorConfig() SupervisorConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.supervisor.withDefaults()
+}
+
+// setState moves a platform to a new state, recording err if set
+func (r *Router) setState(name string, state PlatformState, err error) {
+	r.updateStatus(name, func(s *PlatformStatus) {
+		if s.State != state {
+			s.State = state
+			s.Since = time.Now()
+		}
+		if state != StateRetrying {
+			s.NextRetry = time.Time{}
+		}
+		if err != nil {
+			s.LastError = err.Error()
+			s.LastErrorAt = time.Now()
+		}
+	})
+}
+
+// touch records that a message arrived on a platform
+func (r *Router) touch(name string) {
+	r.updateStatus(name, func(s *PlatformStatus) { s.LastMessage = time.Now() })
+}
+
+func (r *Router) updateStatus(name string, update func(s *PlatformStatus)) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	s, ok := r.status[name]
+	if !ok {
+		s = &PlatformStatus{Name: name}
+		r.status[name] = s
+	}
+	update(s)
+}
+
+// Status returns the state of every registered platform, sorted by name
+func (r *Router) Status() []PlatformStatus {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+
+	statuses := make([]PlatformStatus, 0, len(r.status))
+	for _, s := range r.status {
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// FormatStatus renders platform statuses as a short human-readable list.
+// Error messages may reveal hosts or credentials and are only included with
+// showErrors.
+func FormatStatus(statuses []PlatformStatus, showErrors bool) string {
+	if len(statuses) == 0 {
+		return "未注册任何平台。"
+	}
+
+	now := time.Now()
+	var lines []string
+	for _, s := range statuses {
+		line := fmt.Sprintf("- %s: %s", s.Name, stateLabel(s.State))
+		if s.State == StateRetrying && !s.NextRetry.IsZero() {
+			line += fmt.Sprintf("，%s 后重试", s.NextRetry.Sub(now).Round(time.Second))
+		}
+		if s.Restarts > 0 {
+			line += fmt.Sprintf("，已重启 %d 次", s.Restarts)
+		}
+		if !s.LastMessage.IsZero() {
+			line += fmt.Sprintf("，最近消息 %s 前", now.Sub(s.LastMessage).Round(time.Second))
+		}
+		if showErrors && s.LastError != "" && s.State != StateRunning {
+			line += fmt.Sprintf("\n  错误: %s", s.LastError)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func stateLabel(state PlatformState) string {
+	switch state {
+	case StateStarting:
+		return "启动中"
+	case StateRunning:
+		return "运行中"
+	case StateRetrying:
+		return "重试中"
+	case StateFailed:
+		return "已失败"
+	case StateStopped:
+		return "已停止"
+	default:
+		return string(state)
+	}
+}
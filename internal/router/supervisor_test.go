@@ -0,0 +1,67 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingPlatform fails to start a given number of times, counting calls
+type failingPlatform struct {
+	*fakePlatform
+	failures int32
+	starts   atomic.Int32
+	stops    atomic.Int32
+}
+
+func (p *failingPlatform) Start(ctx context.Context) error {
+	if p.starts.Add(1) <= p.failures {
+		return errors.New("dial tcp 10.0.0.5:443: connection refused")
+	}
+	return nil
+}
+
+func (p *failingPlatform) Stop() error {
+	p.stops.Add(1)
+	return nil
+}
+
+func TestSupervisorStopsAfterFailedStart(t *testing.T) {
+	r := New(func(ctx context.Context, msg Message) (Response, error) { return Response{}, nil })
+	r.SetSupervisor(SupervisorConfig{RetryMin: time.Millisecond, RetryMax: time.Millisecond})
+	p := &failingPlatform{fakePlatform: newFakePlatform("fake"), failures: 2}
+	r.Register(p)
+	if err := r.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Status()[0].State != StateRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("platform never recovered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := p.stops.Load(); got != 2 {
+		t.Errorf("Stop called %d times after 2 failed starts, want 2", got)
+	}
+
+	r.Stop()
+	if got := r.Status()[0].State; got != StateStopped {
+		t.Errorf("state after Stop = %s", got)
+	}
+}
+
+func TestFormatStatusErrors(t *testing.T) {
+	statuses := []PlatformStatus{{Name: "slack", State: StateFailed, LastError: "invalid_auth: xoxb-123"}}
+
+	if text := FormatStatus(statuses, false); strings.Contains(text, "xoxb") {
+		t.Errorf("error shown without showErrors:\n%s", text)
+	}
+	if text := FormatStatus(statuses, true); !strings.Contains(text, "错误: invalid_auth: xoxb-123") {
+		t.Errorf("error missing with showErrors:\n%s", text)
+	}
+}
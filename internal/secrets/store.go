@@ -0,0 +1,253 @@
+// Package secrets keeps API keys and platform credentials in an encrypted
+// file so they don't have to live in bot.yaml, shell profiles or flags.
+//
+// The file is encrypted with AES-256-GCM. The key is derived with scrypt from
+// a passphrase (LINGTI_SECRETS_PASSPHRASE) or, without one, from this
+// machine's ID and the current user, which keeps the file useless when copied
+// elsewhere but does not protect it from other programs run by the same user.
+// The mode is fixed when the file is created and only changes through Rekey.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/host"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv is the environment variable holding the store passphrase
+const PassphraseEnv = "LINGTI_SECRETS_PASSPHRASE"
+
+// RefPrefix marks a config value that refers to a stored secret
+const RefPrefix = "secret://"
+
+const (
+	modePassphrase = "passphrase"
+	modeMachine    = "machine"
+)
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ErrNotFound is returned for names that are not in the store
+var ErrNotFound = errors.New("secret not found")
+
+// Store is an encrypted name → value map backed by a file
+type Store struct {
+	path       string
+	mode       string // passphrase or machine, as stored in the file
+	passphrase string
+	entries    map[string]string
+}
+
+// file is the on-disk format; Data is the encrypted JSON of the entries
+type file struct {
+	Version int    `json:"version"`
+	Mode    string `json:"mode"` // passphrase or machine
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Open decrypts the store at path. A missing file is an empty store that
+// will be created with the passphrase, or with the machine-derived key when
+// the passphrase is empty. An existing file keeps the mode it was saved with.
+func Open(path, passphrase string) (*Store, error) {
+	s := &Store{path: path, entries: make(map[string]string)}
+	s.setKey(passphrase)
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("%s: corrupt secrets file: %w", path, err)
+	}
+	if f.Mode == modePassphrase && passphrase == "" {
+		return nil, fmt.Errorf("%s is protected by a passphrase; set %s", path, PassphraseEnv)
+	}
+
+	key, err := deriveKey(f.Mode, passphrase, f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		if f.Mode == modePassphrase {
+			return nil, fmt.Errorf("%s: wrong passphrase", path)
+		}
+		return nil, fmt.Errorf("%s: cannot decrypt (was it created on another machine or by another user?)", path)
+	}
+	if err := json.Unmarshal(plain, &s.entries); err != nil {
+		return nil, fmt.Errorf("%s: corrupt secrets file: %w", path, err)
+	}
+	if f.Mode == modeMachine {
+		s.setKey("")
+	}
+	return s, nil
+}
+
+// OpenDefault opens the store at path with the passphrase from the environment
+func OpenDefault(path string) (*Store, error) {
+	return Open(path, os.Getenv(PassphraseEnv))
+}
+
+// Get returns a secret's value
+func (s *Store) Get(name string) (string, error) {
+	value, ok := s.entries[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return value, nil
+}
+
+// Set adds or replaces a secret; call Save to persist it
+func (s *Store) Set(name, value string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	s.entries[name] = value
+	return nil
+}
+
+// Delete removes a secret; call Save to persist it
+func (s *Store) Delete(name string) error {
+	if _, ok := s.entries[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	delete(s.entries, name)
+	return nil
+}
+
+// Names returns the stored secret names, sorted
+func (s *Store) Names() []string {
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rekey switches the store to a new passphrase, or to the machine-derived key
+// when passphrase is empty; call Save to re-encrypt the file
+func (s *Store) Rekey(passphrase string) {
+	s.setKey(passphrase)
+}
+
+// UsesPassphrase reports whether the store is encrypted with a passphrase
+func (s *Store) UsesPassphrase() bool {
+	return s.mode == modePassphrase
+}
+
+func (s *Store) setKey(passphrase string) {
+	s.mode, s.passphrase = modeMachine, ""
+	if passphrase != "" {
+		s.mode, s.passphrase = modePassphrase, passphrase
+	}
+}
+
+// Save encrypts the store with a fresh salt and nonce and replaces the file
+// atomically, keeping its mode. The file is only readable by the current user.
+func (s *Store) Save() error {
+	f := file{Version: 1, Mode: s.mode, Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	key, err := deriveKey(s.mode, s.passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(s.entries)
+	if err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Ref returns the secret name a config value refers to ("secret://name")
+func Ref(value string) (string, bool) {
+	name, ok := strings.CutPrefix(value, RefPrefix)
+	return name, ok && name != ""
+}
+
+// deriveKey stretches the passphrase or machine identity into an AES-256 key
+func deriveKey(mode, passphrase string, salt []byte) ([]byte, error) {
+	var material string
+	switch mode {
+	case modePassphrase:
+		material = passphrase
+	case modeMachine:
+		id, err := machineIdentity()
+		if err != nil {
+			return nil, err
+		}
+		material = id
+	default:
+		return nil, fmt.Errorf("unsupported secrets file mode %q", mode)
+	}
+	return scrypt.Key([]byte(material), salt, 1<<15, 8, 1, 32)
+}
+
+// machineIdentity ties the machine-derived key to this host and user
+func machineIdentity() (string, error) {
+	hostID, err := host.HostID()
+	if err != nil || hostID == "" {
+		return "", fmt.Errorf("cannot determine machine ID, set %s instead: %v", PassphraseEnv, err)
+	}
+	username := ""
+	if u, err := user.Current(); err == nil {
+		username = u.Uid
+	}
+	return "lingti-bot:" + hostID + ":" + username, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
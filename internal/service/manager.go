@@ -198,6 +198,8 @@ const launchdPlistTemplate = `<?xml version="1.0" encoding="UTF-8"?>
     <true/>
     <key>KeepAlive</key>
     <true/>
+    <key>ExitTimeOut</key>
+    <integer>45</integer>
     <key>StandardOutPath</key>
     <string>/tmp/lingti-bot.log</string>
     <key>StandardErrorPath</key>
@@ -233,6 +235,8 @@ Type=simple
 ExecStart={{.BinaryPath}} serve
 Restart=always
 RestartSec=5
+KillSignal=SIGTERM
+TimeoutStopSec=45
 StandardOutput=append:/tmp/lingti-bot.log
 StandardError=append:/tmp/lingti-bot.log
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"strings"
@@ -38,8 +39,8 @@ func (e *ShellExecutor) Execute(ctx ExecutionContext, action Action) ExecutionRe
 		}
 	}
 
-	// Template substitution
-	command = substituteVariables(command, ctx)
+	// Template substitution; values are passed in the environment
+	command, env := shellVariables(command, ctx)
 
 	// Safety check
 	if containsDangerousCommand(command) {
@@ -58,6 +59,7 @@ func (e *ShellExecutor) Execute(ctx ExecutionContext, action Action) ExecutionRe
 	defer cancel()
 
 	cmd := exec.CommandContext(execCtx, e.Shell, "-c", command)
+	cmd.Env = append(os.Environ(), env...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -106,16 +108,19 @@ func NewHTTPExecutor() *HTTPExecutor {
 
 // Execute makes an HTTP request
 func (e *HTTPExecutor) Execute(ctx ExecutionContext, action Action) ExecutionResult {
-	url, ok := action.Config["url"].(string)
-	if !ok || url == "" {
+	target, ok := action.Config["url"].(string)
+	if !ok || target == "" {
 		return ExecutionResult{
 			Success: false,
 			Error:   fmt.Errorf("http action requires 'url' config"),
 		}
 	}
 
-	// Template substitution
-	url = substituteVariables(url, ctx)
+	// Template substitution; values are escaped for where they end up, so
+	// message text can't change the request's target or structure
+	target = substitute(target, ctx, func(_, value string) string {
+		return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+	})
 
 	method := "GET"
 	if m, ok := action.Config["method"].(string); ok {
@@ -130,13 +135,15 @@ func (e *HTTPExecutor) Execute(ctx ExecutionContext, action Action) ExecutionRes
 	execCtx, cancel := context.WithTimeout(ctx.Context, timeout)
 	defer cancel()
 
+	headers, _ := action.Config["headers"].(map[string]any)
+
 	var body io.Reader
 	if b, ok := action.Config["body"].(string); ok {
-		b = substituteVariables(b, ctx)
+		b = substitute(b, ctx, bodyEscaper(headers))
 		body = strings.NewReader(b)
 	}
 
-	req, err := http.NewRequestWithContext(execCtx, method, url, body)
+	req, err := http.NewRequestWithContext(execCtx, method, target, body)
 	if err != nil {
 		return ExecutionResult{
 			Success: false,
@@ -145,11 +152,11 @@ func (e *HTTPExecutor) Execute(ctx ExecutionContext, action Action) ExecutionRes
 	}
 
 	// Set headers
-	if headers, ok := action.Config["headers"].(map[string]any); ok {
-		for key, val := range headers {
-			if v, ok := val.(string); ok {
-				req.Header.Set(key, substituteVariables(v, ctx))
-			}
+	for key, val := range headers {
+		if v, ok := val.(string); ok {
+			req.Header.Set(key, substitute(v, ctx, func(_, value string) string {
+				return strings.Map(dropControl, value)
+			}))
 		}
 	}
 
@@ -304,44 +311,134 @@ func (e *WorkflowExecutor) Execute(ctx ExecutionContext, action Action) Executio
 
 // Helper functions
 
+// substituteVariables fills the context values into a template as they are
 func substituteVariables(text string, ctx ExecutionContext) string {
-	// Replace context variables
-	text = strings.ReplaceAll(text, "{{.Message}}", ctx.Message)
-	text = strings.ReplaceAll(text, "{{.SessionID}}", ctx.SessionID)
-	text = strings.ReplaceAll(text, "{{.UserID}}", ctx.UserID)
-	text = strings.ReplaceAll(text, "{{.Platform}}", ctx.Platform)
+	return substitute(text, ctx, func(_, value string) string { return value })
+}
+
+// shellVariables fills the context values into a shell command as references
+// to environment variables and returns those variables for the command's
+// environment. Message text never becomes part of the script, so it can't
+// inject commands; quote the references ("{{.Message}}") to avoid word
+// splitting.
+func shellVariables(command string, ctx ExecutionContext) (string, []string) {
+	values := make(map[string]string)
+	var env []string
+
+	command = substitute(command, ctx, func(name, value string) string {
+		key := "LINGTI_" + envName(name)
+		for i := 2; ; i++ {
+			existing, ok := values[key]
+			if !ok {
+				break
+			}
+			if existing == value {
+				return "${" + key + "}"
+			}
+			key = fmt.Sprintf("LINGTI_%s_%d", envName(name), i)
+		}
+		values[key] = value
+		env = append(env, key+"="+value)
+		return "${" + key + "}"
+	})
+	return command, env
+}
+
+// bodyEscaper returns how values are escaped in a request body of the
+// configured content type (JSON unless set): as JSON string contents, form
+// values, or as they are for other types
+func bodyEscaper(headers map[string]any) func(name, value string) string {
+	contentType := "application/json"
+	for key, val := range headers {
+		if v, ok := val.(string); ok && strings.EqualFold(key, "Content-Type") {
+			contentType = strings.ToLower(v)
+		}
+	}
+	switch {
+	case strings.Contains(contentType, "json"):
+		return func(_, value string) string {
+			quoted, _ := json.Marshal(value)
+			return string(quoted[1 : len(quoted)-1])
+		}
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		return func(_, value string) string { return url.QueryEscape(value) }
+	default:
+		return func(_, value string) string { return value }
+	}
+}
+
+// dropControl removes control characters, which can't appear in a header
+func dropControl(r rune) rune {
+	if r < 0x20 || r == 0x7f {
+		return -1
+	}
+	return r
+}
+
+// envName turns a variable name into an environment variable name
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// substitute expands environment variables in the template, then fills in
+// the context values as rendered by render. Values are inserted after
+// expansion and parsing, so text in them is never interpreted.
+func substitute(text string, ctx ExecutionContext, render func(name, value string) string) string {
+	values := map[string]string{
+		"Message":   render("Message", ctx.Message),
+		"SessionID": render("SessionID", ctx.SessionID),
+		"UserID":    render("UserID", ctx.UserID),
+		"Platform":  render("Platform", ctx.Platform),
+	}
 
-	// Replace match groups
+	// Match groups
+	matches := make([]string, len(ctx.Matches))
 	for i, match := range ctx.Matches {
-		text = strings.ReplaceAll(text, fmt.Sprintf("{{.Match%d}}", i), match)
+		name := fmt.Sprintf("Match%d", i)
+		matches[i] = render(name, match)
+		values[name] = matches[i]
 	}
 
-	// Replace custom variables
+	// Custom variables; context values take precedence
+	variables := make(map[string]string, len(ctx.Variables))
 	for key, val := range ctx.Variables {
-		text = strings.ReplaceAll(text, fmt.Sprintf("{{.%s}}", key), val)
+		variables[key] = render(key, val)
+		if _, ok := values[key]; !ok {
+			values[key] = variables[key]
+		}
 	}
 
 	// Environment variables
 	text = os.ExpandEnv(text)
 
-	// Use text/template for more complex substitutions
 	tmpl, err := template.New("cmd").Parse(text)
 	if err == nil {
+		data := make(map[string]any, len(values)+2)
+		for name, value := range values {
+			data[name] = value
+		}
+		data["Matches"], data["Variables"] = matches, variables
 		var buf bytes.Buffer
-		tmpl.Execute(&buf, map[string]any{
-			"Message":   ctx.Message,
-			"SessionID": ctx.SessionID,
-			"UserID":    ctx.UserID,
-			"Platform":  ctx.Platform,
-			"Matches":   ctx.Matches,
-			"Variables": ctx.Variables,
-		})
-		if buf.Len() > 0 {
-			text = buf.String()
+		if err := tmpl.Execute(&buf, data); err == nil {
+			return buf.String()
 		}
 	}
 
-	return text
+	// Not a valid template; replace the simple references in one pass
+	pairs := make([]string, 0, 2*len(values))
+	for name, value := range values {
+		pairs = append(pairs, "{{."+name+"}}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
 }
 
 func containsDangerousCommand(cmd string) bool {
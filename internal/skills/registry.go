@@ -29,6 +29,7 @@ type Trigger struct {
 	Type    TriggerType `json:"type"`
 	Pattern string      `json:"pattern,omitempty"` // Regex pattern for pattern trigger
 	Command string      `json:"command,omitempty"` // Command name for command trigger
+	Event   string      `json:"event,omitempty"`   // Event name for event trigger, e.g. "github.issues"
 }
 
 // TriggerType defines the type of trigger
@@ -233,6 +234,10 @@ func (r *Registry) FindByTrigger(triggerType TriggerType, value string) []*Skill
 				if trigger.Pattern == value {
 					matches = append(matches, skill)
 				}
+			case TriggerEvent:
+				if trigger.Event == value {
+					matches = append(matches, skill)
+				}
 			}
 		}
 	}
@@ -244,6 +249,11 @@ func (r *Registry) FindByCommand(command string) []*Skill {
 	return r.FindByTrigger(TriggerCommand, command)
 }
 
+// FindByEvent finds skills triggered by a system event
+func (r *Registry) FindByEvent(event string) []*Skill {
+	return r.FindByTrigger(TriggerEvent, event)
+}
+
 // Execute runs a skill's actions
 func (r *Registry) Execute(ctx ExecutionContext, skill *Skill) []ExecutionResult {
 	r.mu.RLock()
@@ -312,6 +322,42 @@ func (r *Registry) LoadFromDirectory(dir string) error {
 	return nil
 }
 
+// Reload replaces all skills with the ones currently in the skill directory.
+// Files that fail to parse are skipped, as in LoadFromDirectory.
+func (r *Registry) Reload() error {
+	entries, err := os.ReadDir(r.skillDir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read skill directory: %w", err)
+	}
+
+	loaded := make(map[string]*Skill)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(r.skillDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[Skills] Failed to load skill from %s: %v", path, err)
+			continue
+		}
+		var skill Skill
+		if err := json.Unmarshal(data, &skill); err != nil || skill.ID == "" {
+			log.Printf("[Skills] Failed to load skill from %s: invalid skill file", path)
+			continue
+		}
+		loaded[skill.ID] = &skill
+	}
+
+	r.mu.Lock()
+	r.skills = loaded
+	r.mu.Unlock()
+
+	log.Printf("[Skills] Reloaded %d skills from %s", len(loaded), r.skillDir)
+	return nil
+}
+
 // LoadFromFile loads a skill from a JSON file
 func (r *Registry) LoadFromFile(path string) error {
 	data, err := os.ReadFile(path)
@@ -0,0 +1,465 @@
+// Package webhook receives HTTP webhooks from external services (GitHub,
+// GitLab, Gitee, CI systems, ...) and turns them into agent runs or skill
+// executions whose output is delivered to a chat channel.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pltanton/lingti-bot/internal/agent"
+	"github.com/pltanton/lingti-bot/internal/logger"
+	"github.com/pltanton/lingti-bot/internal/router"
+	"github.com/pltanton/lingti-bot/internal/skills"
+)
+
+const (
+	maxBody    = 5 << 20 // Largest accepted payload
+	runTimeout = 5 * time.Minute
+
+	// deliveryTTL is how long deliveries are remembered to reject replays
+	deliveryTTL = 24 * time.Hour
+)
+
+// Route maps a webhook path to an action
+type Route struct {
+	Name     string
+	Path     string   // URL path, e.g. "/hooks/github"
+	Verify   string   // github, gitlab, gitee, hmac, token or none
+	Secret   string   // Shared secret for Verify
+	Events   []string // Only handle these events, e.g. "issues" or "issues.opened" (empty = all)
+	Prompt   string   // Go template rendered with the payload and sent to the agent
+	Profile  string   // Agent profile for the prompt; empty answers without tools
+	Trigger  string   // Run skills with this event trigger instead of the agent
+	Platform string   // Where to deliver the output
+	Channel  string
+}
+
+// Agent answers prompts; implemented by *agent.Agent
+type Agent interface {
+	HandleTranscript(ctx context.Context, t agent.Transcript) (router.Response, error)
+}
+
+// Sender delivers outbound messages; implemented by *router.Router
+type Sender interface {
+	SendTo(ctx context.Context, platform, channelID string, resp router.Response) (string, error)
+}
+
+// Config configures the webhook server
+type Config struct {
+	Listen string // Address to listen on, e.g. "127.0.0.1:8688"
+	Routes []Route
+}
+
+// Server receives webhooks and dispatches them to the agent or skills
+type Server struct {
+	listen string
+	routes map[string]*route
+	agent  Agent
+	sender Sender
+	skills *skills.Registry
+	seen   *router.Deduplicator // Deliveries already handled
+	server *http.Server
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// route is a validated Route with its parsed prompt template
+type route struct {
+	Route
+	prompt *template.Template
+}
+
+// templateData is available to prompt templates
+type templateData struct {
+	Route   string
+	Event   string // e.g. "issues", from X-GitHub-Event and similar headers
+	Action  string // payload "action" field (GitLab: object_attributes.action), e.g. "opened"
+	Payload any    // Decoded JSON body, nil if the body isn't JSON
+	Body    string // Raw body
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	},
+	"truncate": func(n int, s string) string {
+		if runes := []rune(s); len(runes) > n {
+			return string(runes[:n]) + "…"
+		}
+		return s
+	},
+}
+
+// New validates the routes and creates a webhook server. The skill registry
+// is only needed for routes with a trigger and may be nil otherwise.
+func New(cfg Config, agent Agent, sender Sender, registry *skills.Registry) (*Server, error) {
+	if cfg.Listen == "" {
+		return nil, fmt.Errorf("listen address is required")
+	}
+
+	s := &Server{
+		listen: cfg.Listen,
+		routes: make(map[string]*route),
+		agent:  agent,
+		sender: sender,
+		skills: registry,
+	}
+
+	for i, r := range cfg.Routes {
+		if r.Name == "" {
+			r.Name = r.Path
+		}
+		if err := s.addRoute(r); err != nil {
+			return nil, fmt.Errorf("webhook route %d (%s): %w", i+1, r.Name, err)
+		}
+	}
+	if len(s.routes) == 0 {
+		return nil, fmt.Errorf("no webhook routes configured")
+	}
+	s.seen = router.NewDeduplicator(deliveryTTL, "")
+	return s, nil
+}
+
+func (s *Server) addRoute(r Route) error {
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if _, exists := s.routes[r.Path]; exists {
+		return fmt.Errorf("duplicate path %s", r.Path)
+	}
+
+	if !validScheme(r.Verify) {
+		return fmt.Errorf("verify must be github, gitlab, gitee, hmac, token or none")
+	}
+	if r.Verify != VerifyNone && r.Secret == "" {
+		return fmt.Errorf("secret is required for %s verification", r.Verify)
+	}
+	if r.Verify == VerifyNone && !loopback(s.listen) {
+		return fmt.Errorf("verify none is only allowed when listening on a loopback address")
+	}
+
+	if (r.Prompt == "") == (r.Trigger == "") {
+		return fmt.Errorf("exactly one of prompt or trigger is required")
+	}
+	if r.Trigger != "" && s.skills == nil {
+		return fmt.Errorf("trigger requires the skill registry")
+	}
+	if (r.Platform == "") != (r.Channel == "") {
+		return fmt.Errorf("platform and channel must be set together")
+	}
+	if r.Profile != "" && r.Prompt == "" {
+		return fmt.Errorf("profile only applies to prompt routes")
+	}
+	if r.Prompt != "" && r.Platform == "" {
+		return fmt.Errorf("prompt routes need a platform and channel to deliver to")
+	}
+
+	compiled := &route{Route: r}
+	if r.Prompt != "" {
+		tmpl, err := template.New(r.Name).Funcs(templateFuncs).Parse(r.Prompt)
+		if err != nil {
+			return fmt.Errorf("invalid prompt template: %w", err)
+		}
+		compiled.prompt = tmpl
+	}
+
+	s.routes[r.Path] = compiled
+	return nil
+}
+
+// Start begins listening for webhooks in the background
+func (s *Server) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.listen, err)
+	}
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.server = &http.Server{Handler: http.HandlerFunc(s.handle), ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("[Webhook] Server error: %v", err)
+		}
+	}()
+
+	logger.Info("[Webhook] Listening on %s (%d routes)", s.listen, len(s.routes))
+	return nil
+}
+
+// Stop stops accepting webhooks and waits for running jobs until ctx expires
+func (s *Server) Stop(ctx context.Context) error {
+	defer s.seen.Close()
+	if s.server == nil {
+		return nil
+	}
+	err := s.server.Shutdown(ctx)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Info("[Webhook] Cancelling unfinished webhook jobs")
+	}
+	s.cancel()
+	return err
+}
+
+// handle verifies a webhook and queues it; the sender gets 202 right away
+// because most services time out after a few seconds
+func (s *Server) handle(w http.ResponseWriter, req *http.Request) {
+	r, ok := s.routes[req.URL.Path]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		writeStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBody))
+	if err != nil {
+		writeStatus(w, http.StatusRequestEntityTooLarge, "payload too large")
+		return
+	}
+
+	if err := verify(r.Verify, r.Secret, req.Header, body); err != nil {
+		logger.Info("[Webhook] %s: rejected request from %s: %v", r.Name, req.RemoteAddr, err)
+		writeStatus(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	// Record every key so a replay is caught whichever one it reuses
+	duplicate := false
+	for _, key := range deliveryKeys(r.Verify, req.Header, body) {
+		if s.seen.Seen(r.Path, key) {
+			duplicate = true
+		}
+	}
+	if duplicate {
+		logger.Info("[Webhook] %s: ignoring repeated delivery from %s", r.Name, req.RemoteAddr)
+		writeStatus(w, http.StatusOK, "duplicate")
+		return
+	}
+
+	data := templateData{
+		Route: r.Name,
+		Event: eventName(req.Header),
+		Body:  string(body),
+	}
+	var payload any
+	if json.Unmarshal(body, &payload) == nil {
+		data.Payload = payload
+		if fields, ok := payload.(map[string]any); ok {
+			data.Action = payloadAction(r.Verify, fields)
+		}
+	}
+
+	if data.Event == "ping" || !r.matches(data.Event, data.Action) {
+		writeStatus(w, http.StatusOK, "ignored")
+		return
+	}
+
+	logger.Info("[Webhook] %s: received %s", r.Name, describe(data))
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(r, data)
+	}()
+	writeStatus(w, http.StatusAccepted, "accepted")
+}
+
+// run executes a route's prompt or skills and delivers the output
+func (s *Server) run(r *route, data templateData) {
+	ctx, cancel := context.WithTimeout(s.ctx, runTimeout)
+	defer cancel()
+
+	var output string
+	var err error
+	if r.prompt != nil {
+		output, err = s.runPrompt(ctx, r, data)
+	} else {
+		output, err = s.runSkills(ctx, r, data)
+	}
+	if err != nil {
+		logger.Error("[Webhook] %s: %v", r.Name, err)
+		return
+	}
+
+	if output == "" || r.Platform == "" {
+		return
+	}
+	if _, err := s.sender.SendTo(ctx, r.Platform, r.Channel, router.Response{Text: output}); err != nil {
+		logger.Error("[Webhook] %s: failed to deliver to %s/%s: %v", r.Name, r.Platform, r.Channel, err)
+	}
+}
+
+func (s *Server) runPrompt(ctx context.Context, r *route, data templateData) (string, error) {
+	var prompt bytes.Buffer
+	if err := r.prompt.Execute(&prompt, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+
+	// Payloads carry text written by strangers, so without a profile the
+	// agent answers without tools
+	resp, err := s.agent.HandleTranscript(ctx, agent.Transcript{
+		Profile: r.Profile,
+		NoTools: r.Profile == "",
+		Message: router.Message{
+			ID:        fmt.Sprintf("webhook-%d", time.Now().UnixNano()),
+			Platform:  r.Platform,
+			ChannelID: r.Channel,
+			UserID:    "webhook:" + r.Name,
+			Username:  "webhook",
+			Text:      prompt.String(),
+			Metadata: map[string]string{
+				"webhook": r.Name,
+				"event":   data.Event,
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("agent failed: %w", err)
+	}
+	return resp.Text, nil
+}
+
+func (s *Server) runSkills(ctx context.Context, r *route, data templateData) (string, error) {
+	matched := s.skills.FindByEvent(r.Trigger)
+	if len(matched) == 0 {
+		return "", fmt.Errorf("no enabled skills for event %q", r.Trigger)
+	}
+
+	var outputs []string
+	for _, skill := range matched {
+		results := s.skills.Execute(skills.ExecutionContext{
+			Context:   ctx,
+			SessionID: "webhook:" + r.Name,
+			UserID:    "webhook",
+			Platform:  r.Platform,
+			Message:   data.Body,
+			Variables: map[string]string{
+				"event":  data.Event,
+				"action": data.Action,
+			},
+		}, skill)
+
+		for _, result := range results {
+			if !result.Success {
+				logger.Error("[Webhook] %s: skill %s failed: %v", r.Name, skill.ID, result.Error)
+				continue
+			}
+			if result.Output != "" {
+				outputs = append(outputs, result.Output)
+			}
+		}
+	}
+	return strings.Join(outputs, "\n\n"), nil
+}
+
+// matches reports whether the route handles an event, optionally with its action
+func (r *route) matches(event, action string) bool {
+	if len(r.Events) == 0 {
+		return true
+	}
+	for _, e := range r.Events {
+		if e == event || (action != "" && e == event+"."+action) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventName reads the event type from the headers GitHub, GitLab and Gitee set
+func eventName(header http.Header) string {
+	for _, key := range []string{"X-GitHub-Event", "X-Gitlab-Event", "X-Gitee-Event", "X-Event-Type"} {
+		if v := header.Get(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// payloadAction reads the event's action; GitLab nests it in object_attributes
+func payloadAction(scheme string, fields map[string]any) string {
+	if scheme == VerifyGitLab {
+		attrs, _ := fields["object_attributes"].(map[string]any)
+		action, _ := attrs["action"].(string)
+		return action
+	}
+	action, _ := fields["action"].(string)
+	return action
+}
+
+// deliveryKeys identifies a delivery so a captured request can't be replayed.
+// Delivery ID headers aren't signed, so for schemes that sign the body its
+// digest counts as well, and for signed Gitee requests the signature, which
+// covers a timestamp.
+func deliveryKeys(scheme string, header http.Header, body []byte) []string {
+	var keys []string
+	for _, name := range []string{"X-GitHub-Delivery", "X-Gitlab-Event-UUID", "X-Webhook-Delivery"} {
+		if id := header.Get(name); id != "" {
+			keys = append(keys, "id:"+id)
+		}
+	}
+
+	switch scheme {
+	case VerifyGitHub, VerifyHMAC:
+		sum := sha256.Sum256(body)
+		keys = append(keys, "sha256:"+hex.EncodeToString(sum[:]))
+	case VerifyGitee:
+		if header.Get("X-Gitee-Timestamp") != "" {
+			keys = append(keys, "gitee:"+header.Get("X-Gitee-Token"))
+		}
+	}
+	return keys
+}
+
+// loopback reports whether a listen address only accepts local connections
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func describe(data templateData) string {
+	switch {
+	case data.Event == "":
+		return "payload"
+	case data.Action != "":
+		return data.Event + "." + data.Action
+	default:
+		return data.Event
+	}
+}
+
+func writeStatus(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"status": text})
+}